@@ -2,16 +2,13 @@ package api
 
 import (
 	"backend/internal/handlers"
-	"backend/internal/service"
 	"backend/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SetupRouter 设置路由，调用前需先通过 service.InitServices 初始化服务
 func SetupRouter() *gin.Engine {
-	// 初始化所有服务
-	service.InitServices()
-
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware())
 	// 创建处理器实例
@@ -19,6 +16,11 @@ func SetupRouter() *gin.Engine {
 	roomHandler := handlers.NewRoomHandler()
 	authHandler := handlers.NewAuthHandler()
 	reportHandler := handlers.NewReportHandler()
+	clockHandler := handlers.NewClockHandler()
+	zoneHandler := handlers.NewZoneHandler()
+	preconditionHandler := handlers.NewPreconditionHandler()
+	timerHandler := handlers.NewTimerHandler()
+	folioHandler := handlers.NewFolioHandler()
 
 	// 空调控制面板相关路由组
 	panel := router.Group("/panel")
@@ -30,6 +32,10 @@ func SetupRouter() *gin.Engine {
 		panel.POST("/changespeed", acHandler.PanelChangeSpeed)
 		panel.POST("/requeststate", acHandler.PanelRequestStatus)
 		panel.POST("/requestallstate", acHandler.PanelRequestAllState)
+		// 定时关机、定时开机和睡眠曲线
+		panel.POST("/settimer", timerHandler.PanelSetTimer)
+		panel.POST("/canceltimer", timerHandler.PanelCancelTimer)
+		panel.POST("/timers", timerHandler.PanelTimers)
 
 	}
 
@@ -41,8 +47,19 @@ func SetupRouter() *gin.Engine {
 		room.POST("/checkin", roomHandler.CheckIn)
 		room.POST("/checkout", roomHandler.CheckOut)
 		room.POST("/aircon/report", reportHandler.GetReport)
+		room.POST("/aircon/energy", reportHandler.GetEnergyReport)
 		room.POST("/print-detail", roomHandler.PrintDetail)
 		room.POST("/print-bill", roomHandler.PrintBill)
+		// 住客账单与发票
+		room.POST("/folio", folioHandler.GetFolio)
+		room.POST("/folio/post", folioHandler.PostFolioItem)
+		room.POST("/invoices", folioHandler.Invoices)
+		room.POST("/invoice", folioHandler.Invoice)
+		room.POST("/print-invoice", folioHandler.PrintInvoice)
+		// 入住前预调温
+		room.POST("/precondition", preconditionHandler.SchedulePrecondition)
+		room.POST("/cancelprecondition", preconditionHandler.CancelPrecondition)
+		room.POST("/preconditions", preconditionHandler.Preconditions)
 	}
 	admin := router.Group("/admin")
 	{
@@ -51,14 +68,46 @@ func SetupRouter() *gin.Engine {
 		admin.POST("/changemode", acHandler.AdminChangeMode)
 		admin.POST("/changetemprange", acHandler.AdminChangeTempRange)
 		admin.POST("/changerate", acHandler.AdminChangeRate)
+		admin.POST("/changeenergyrates", acHandler.AdminChangeEnergyRates)
+		admin.POST("/changemoderate", acHandler.AdminChangeModeRate)
+		admin.POST("/tariffs", acHandler.AdminTariffs)
+		admin.POST("/changetimeofuse", acHandler.AdminChangeTimeOfUse)
 		admin.POST("/requestallstate", acHandler.AdminRequestAllState)
 		admin.POST("/changedefaulttemp", acHandler.AdminChangeDefaultTemp)
+		admin.POST("/changepolicy", acHandler.AdminChangePolicy)
+		admin.POST("/changecapacity", acHandler.AdminChangeCapacity)
+		admin.POST("/changepowerbudget", acHandler.AdminChangePowerBudget)
+		admin.POST("/changetimeslice", acHandler.AdminChangeTimeSlice)
+		admin.POST("/changeaging", acHandler.AdminChangeAging)
+		admin.POST("/changehysteresis", acHandler.AdminChangeHysteresis)
+		admin.POST("/changethermal", acHandler.AdminChangeThermal)
+		admin.POST("/changeroomthermal", acHandler.AdminChangeRoomThermal)
+		admin.POST("/changepreconditioncharge", acHandler.AdminChangePreconditionCharge)
+		admin.POST("/changepriorityweights", acHandler.AdminChangePriorityWeights)
+		admin.POST("/changeroomclass", acHandler.AdminChangeRoomClass)
+		admin.POST("/loadshed", acHandler.AdminLoadShed)
+		admin.POST("/endloadshed", acHandler.AdminEndLoadShed)
+		// 房费计算规则
+		admin.POST("/roomcharge", folioHandler.AdminRoomCharge)
+		admin.POST("/changeroomcharge", folioHandler.AdminChangeRoomCharge)
+		// 空调机组
+		admin.POST("/zones", zoneHandler.AdminZones)
+		admin.POST("/createzone", zoneHandler.AdminCreateZone)
+		admin.POST("/deletezone", zoneHandler.AdminDeleteZone)
+		admin.POST("/assignrooms", zoneHandler.AdminAssignRooms)
+		// 模拟时钟
+		admin.POST("/clockstate", clockHandler.AdminClockState)
+		admin.POST("/clockpause", clockHandler.AdminClockPause)
+		admin.POST("/clockresume", clockHandler.AdminClockResume)
+		admin.POST("/clockstep", clockHandler.AdminClockStep)
+		admin.POST("/clockspeed", clockHandler.AdminClockSpeed)
 	}
 	monitor := router.Group("/monitor")
 	{
 		monitor.POST("/monitorpoweron", acHandler.MonitorPowerOn)
 		monitor.POST("/monitorpoweroff", acHandler.MonitorPowerOff)
 		monitor.POST("/monitorrequeststates", acHandler.MonitorRequestStates)
+		monitor.POST("/queues", acHandler.MonitorQueues)
 	}
 	return router
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"backend/internal/clock"
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/service"
+	"backend/internal/types"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// runBench 执行 bench 子命令：在内存数据库中创建大量房间，全部入住并开机，
+// 按调度周期推进模拟时钟，统计每个周期的真实耗时和批量写回的耗时
+// 用法: backend bench [-rooms 500] [-services 0] [-minutes 10] [-speed 6] [-flush 2s]
+// 服务队列默认容纳全部房间，所有房间每个周期都在送风或回温，每次写回都应包含几乎全部房间；
+// 周期预算为调度周期按模拟倍速折算的真实时间，超出预算的周期过多或写回的房间数明显偏少时以非0状态退出
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	roomCount := fs.Int("rooms", 500, "房间数")
+	services := fs.Int("services", 0, "服务队列容量，0为全部房间")
+	minutes := fs.Int("minutes", 10, "推进的系统时间(分钟)")
+	speed := fs.Float64("speed", clock.DefaultTimeScale, "模拟倍速，决定每个调度周期的真实时间预算")
+	flushInterval := fs.Duration("flush", db.DefaultFlushInterval, "房间状态批量写回的间隔(真实时间)")
+	verbose := fs.Bool("v", false, "输出服务日志到标准错误")
+	fs.Parse(args)
+
+	if *roomCount <= 0 || *services < 0 || *minutes <= 0 || *speed <= 0 || *flushInterval <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *verbose {
+		logger.SetOutput(os.Stderr)
+	} else {
+		logger.SetLevel(logger.OffLevel)
+		log.SetOutput(io.Discard)
+	}
+	defer logger.Close()
+
+	db.Init_DBWithName("file:bench?mode=memory&cache=shared")
+	defer db.SQLDB.Close()
+	db.SQLDB.SetMaxOpenConns(1)
+	if !*verbose {
+		db.DB.Config.Logger = gormlogger.Default.LogMode(gormlogger.Silent)
+	}
+
+	roomRepo := db.NewRoomRepository()
+	for roomID := 1; roomID <= *roomCount; roomID++ {
+		if _, err := roomRepo.GetRoomByID(roomID); err == nil {
+			continue
+		}
+		// 初始温度分布在28~37度之间，高于默认目标温度25度，送风中的房间降温，达到目标后待机的房间回温，
+		// 每个周期所有房间的温度都会变化
+		temp := float32(28 + roomID%10)
+		if err := roomRepo.CreateRoom(&db.RoomInfo{
+			RoomID:        roomID,
+			CurrentTemp:   temp,
+			InitialTemp:   temp,
+			DailyRate:     types.Fen(10000),
+			Volume:        45,
+			Insulation:    25,
+			OccupancyLoad: 100,
+			Zone:          db.DefaultZone,
+			Humidity:      65,
+			RunState:      string(types.RunOff),
+		}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	simClock := clock.NewSimClock(time.Now().Truncate(time.Minute), *speed)
+	service.InitServices(simClock)
+	defer service.StopServices()
+
+	acService := service.GetACService()
+	capacity := *services
+	if capacity == 0 {
+		capacity = *roomCount
+	}
+	if err := acService.UpdateConfig(db.DefaultZone, func(config *types.Config) error {
+		config.MaxServices = capacity
+		return nil
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "设置服务队列容量失败: %v\n", err)
+		return 1
+	}
+	if err := acService.StartCentralAC(db.DefaultZone, types.ModeCooling); err != nil {
+		fmt.Fprintf(os.Stderr, "启动中央空调失败: %v\n", err)
+		return 1
+	}
+	// 每分钟的房间状态日志逐个计算所有房间的费用，不属于调度周期，压测时关闭
+	service.StopMonitorService()
+	for roomID := 1; roomID <= *roomCount; roomID++ {
+		if err := acService.CheckIn(roomID, fmt.Sprintf("BENCH%03d", roomID), fmt.Sprintf("房间%d", roomID), types.Fen(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "房间 %d 入住失败: %v\n", roomID, err)
+			return 1
+		}
+		if err := acService.PowerOn(roomID); err != nil {
+			fmt.Fprintf(os.Stderr, "房间 %d 开机失败: %v\n", roomID, err)
+			return 1
+		}
+	}
+
+	store := db.GetRoomStore()
+	if _, err := store.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	tick := service.TickInterval()
+	budget := time.Duration(float64(tick) / *speed)
+	ticks := int(time.Duration(*minutes) * time.Minute / tick)
+	tickTimes := make([]time.Duration, 0, ticks)
+	var flushTimes []time.Duration
+	var flushedRooms int
+	lastFlush := time.Now()
+	for i := 0; i < ticks; i++ {
+		start := time.Now()
+		simClock.Advance(tick)
+		tickTimes = append(tickTimes, time.Since(start))
+
+		// 按真实时间的写回间隔写回。服务器在后台goroutine中写回，这里在周期之间同步写回以便单独计时，
+		// 写回耗时不计入周期耗时
+		if time.Since(lastFlush) >= *flushInterval {
+			start := time.Now()
+			count, err := store.Flush()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			flushTimes = append(flushTimes, time.Since(start))
+			flushedRooms += count
+			lastFlush = time.Now()
+		}
+	}
+	start := time.Now()
+	count, err := store.Flush()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	flushTimes = append(flushTimes, time.Since(start))
+	flushedRooms += count
+
+	over := 0
+	for _, d := range tickTimes {
+		if d > budget {
+			over++
+		}
+	}
+	sorted := append([]time.Duration(nil), tickTimes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	fmt.Printf("房间数: %d, 服务队列容量: %d, 调度周期: %v, 推进: %d 个周期(%d 分钟)\n", *roomCount, capacity, tick, ticks, *minutes)
+	fmt.Printf("周期预算: %v (倍速 %.1f)\n", budget, *speed)
+	fmt.Printf("周期耗时: 平均 %v, p50 %v, p99 %v, 最大 %v, 超出预算 %d 个\n",
+		average(tickTimes), percentile(sorted, 0.50), percentile(sorted, 0.99), sorted[len(sorted)-1], over)
+	fmt.Printf("批量写回: %d 次, 共 %d 个房间, 平均 %v, 最大 %v\n",
+		len(flushTimes), flushedRooms, average(flushTimes), maxDuration(flushTimes))
+
+	// 偶发的超时(如垃圾回收)允许存在，超过1%的周期超出预算视为失败
+	if over*100 > ticks {
+		fmt.Fprintf(os.Stderr, "FAIL: %d 个周期超出预算\n", over)
+		return 1
+	}
+	// 每两次写回之间至少有一个周期，所有房间都应被写回；低于九成说明大部分房间没有变化，结果不能反映批量写回的规模
+	if expected := len(flushTimes) * *roomCount; flushedRooms*10 < expected*9 {
+		fmt.Fprintf(os.Stderr, "FAIL: 写回 %d 个房间，期望约 %d 个\n", flushedRooms, expected)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "PASS: 调度周期在预算之内，每次写回包含全部房间")
+	return 0
+}
+
+// average 平均耗时
+func average(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// percentile 已排序耗时的分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// maxDuration 最大耗时
+func maxDuration(durations []time.Duration) time.Duration {
+	var max time.Duration
+	for _, d := range durations {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
@@ -1,4 +1,4 @@
 #!/bin/bash
 rm ./hotel.db
 
-go run main.go
\ No newline at end of file
+go run .
\ No newline at end of file
@@ -2,9 +2,12 @@ package main
 
 import (
 	"backend/api"
+	"backend/internal/clock"
 	"backend/internal/db"
 	"backend/internal/logger"
+	"backend/internal/service"
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,6 +17,20 @@ import (
 )
 
 func main() {
+	// 子命令: backend simulate scenario.yaml
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(runSimulate(os.Args[2:]))
+	}
+	// 子命令: backend bench
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+
+	clockMode := flag.String("clock", "real", "系统时钟: real(真实时间，默认) 或 sim(模拟时间，可暂停/步进/变速，用于演示)")
+	speed := flag.Float64("speed", clock.DefaultTimeScale, "模拟时钟的倍速，默认真实10秒对应系统1分钟")
+	flushInterval := flag.Duration("flush", db.DefaultFlushInterval, "房间状态批量写回数据库的间隔(真实时间)")
+	flag.Parse()
+
 	fmt.Println("Hello, World!")
 	// 初始化日志
 	logger.SetLevel(logger.InfoLevel)
@@ -22,6 +39,44 @@ func main() {
 	// 初始化数据库连接
 	db.Init_DB()
 	defer db.SQLDB.Close()
+	if *flushInterval <= 0 {
+		logger.Error("房间状态的写回间隔必须大于0")
+		os.Exit(1)
+	}
+	// 房间状态按间隔批量写回，退出时在关闭数据库连接前写回剩余的修改
+	roomStore := db.GetRoomStore()
+	roomStore.StartFlusher(*flushInterval)
+	defer func() {
+		if err := roomStore.Close(); err != nil {
+			logger.Error("写回房间状态失败: %v", err)
+		}
+	}()
+
+	// 初始化系统时钟和所有服务
+	var clk clock.Clock
+	switch *clockMode {
+	case "real":
+		clk = clock.NewRealClock()
+	case "sim":
+		if *speed <= 0 {
+			logger.Error("模拟时钟的倍速必须大于0")
+			os.Exit(1)
+		}
+		// 虚拟时间可能快于真实时间，重启后从上次运行的虚拟时间继续
+		start := time.Now()
+		if heartbeat, ok := service.LastHeartbeat(); ok && heartbeat.After(start) {
+			start = heartbeat
+		}
+		simClock := clock.NewSimClock(start, *speed)
+		simClock.Start(10 * time.Millisecond)
+		defer simClock.Stop()
+		clk = simClock
+	default:
+		logger.Error("无效的时钟类型: %s", *clockMode)
+		os.Exit(1)
+	}
+	service.InitServices(clk)
+	defer service.StopServices()
 
 	// 设置路由
 	r := api.SetupRouter()
@@ -0,0 +1,268 @@
+// internal/clock/clock.go
+// Package clock 提供系统时钟抽象
+// 调度、计费、统计等服务都通过 Clock 获取时间，以便在虚拟时间下运行演示和测试
+package clock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeScale 默认模拟倍速：真实10秒对应系统1分钟
+const DefaultTimeScale = 6.0
+
+// Clock 时钟接口
+type Clock interface {
+	// Now 当前时间
+	Now() time.Time
+	// Since 自t以来经过的时间
+	Since(t time.Time) time.Duration
+	// Every 每隔interval(按该时钟计)调用一次f，返回停止函数
+	// 同一个Every注册的回调不会并发执行
+	Every(interval time.Duration, f func()) (stop func())
+}
+
+// RealClock 真实时钟，直接使用系统时间
+type RealClock struct{}
+
+// NewRealClock 创建真实时钟
+func NewRealClock() *RealClock {
+	return &RealClock{}
+}
+
+func (c *RealClock) Now() time.Time { return time.Now() }
+
+func (c *RealClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (c *RealClock) Every(interval time.Duration, f func()) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// SimClock 模拟时钟
+// 虚拟时间可以按倍速随真实时间推进，也可以暂停后手动步进。
+// 到期的定时回调在推进时间的goroutine中按到期先后依次同步执行，
+// 因此手动步进时的执行顺序是确定的。
+type SimClock struct {
+	mu      sync.Mutex
+	now     time.Time   // 当前虚拟时间
+	speed   float64     // 倍速
+	paused  bool        // 是否暂停
+	timers  []*simTimer // 定时回调
+	nextID  int         // 定时回调编号，到期时间相同时按注册顺序执行
+	running bool        // 自动推进是否已启动
+
+	advanceMu sync.Mutex    // 串行化时间推进
+	stopChan  chan struct{} // 停止自动推进
+}
+
+type simTimer struct {
+	id       int
+	interval time.Duration
+	next     time.Time
+	f        func()
+	stopped  bool
+}
+
+// SimClockState 模拟时钟状态
+type SimClockState struct {
+	Now    time.Time `json:"now"`
+	Speed  float64   `json:"speed"`
+	Paused bool      `json:"paused"`
+}
+
+// NewSimClock 创建模拟时钟
+// start: 虚拟时间起点
+// speed: 倍速，自动推进时虚拟时间流逝速度为真实时间的speed倍
+func NewSimClock(start time.Time, speed float64) *SimClock {
+	return &SimClock{
+		now:   start,
+		speed: speed,
+	}
+}
+
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *SimClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *SimClock) Every(interval time.Duration, f func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	timer := &simTimer{
+		id:       c.nextID,
+		interval: interval,
+		next:     c.now.Add(interval),
+		f:        f,
+	}
+	c.timers = append(c.timers, timer)
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		timer.stopped = true
+	}
+}
+
+// Start 启动自动推进，resolution为真实时间的推进间隔
+func (c *SimClock) Start(resolution time.Duration) {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = true
+	c.stopChan = make(chan struct{})
+	stopChan := c.stopChan
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(resolution)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				elapsed := now.Sub(last)
+				last = now
+
+				c.mu.Lock()
+				paused, speed := c.paused, c.speed
+				c.mu.Unlock()
+				if paused {
+					continue
+				}
+				c.Advance(time.Duration(float64(elapsed) * speed))
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止自动推进
+func (c *SimClock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		close(c.stopChan)
+		c.running = false
+	}
+}
+
+// Pause 暂停自动推进，暂停期间仍可手动步进
+func (c *SimClock) Pause() {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+}
+
+// Resume 恢复自动推进
+func (c *SimClock) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+}
+
+// SetSpeed 设置倍速
+func (c *SimClock) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("倍速必须大于0")
+	}
+	c.mu.Lock()
+	c.speed = speed
+	c.mu.Unlock()
+	return nil
+}
+
+// State 获取模拟时钟状态
+func (c *SimClock) State() SimClockState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return SimClockState{
+		Now:    c.now,
+		Speed:  c.speed,
+		Paused: c.paused,
+	}
+}
+
+// Step 手动推进虚拟时间
+func (c *SimClock) Step(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("步进时长必须大于0")
+	}
+	c.Advance(d)
+	return nil
+}
+
+// Advance 推进虚拟时间d，并按到期先后依次执行到期的定时回调
+// 回调执行时虚拟时间恰好等于其到期时间
+func (c *SimClock) Advance(d time.Duration) {
+	c.advanceMu.Lock()
+	defer c.advanceMu.Unlock()
+
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		timer := c.nextDue(target)
+		if timer == nil {
+			break
+		}
+		c.now = timer.next
+		timer.next = timer.next.Add(timer.interval)
+
+		// 回调中可能再次访问时钟，执行期间释放锁
+		c.mu.Unlock()
+		timer.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// nextDue 找出不晚于target的最早到期回调，同时清理已停止的回调，调用方需持有锁
+func (c *SimClock) nextDue(target time.Time) *simTimer {
+	active := c.timers[:0]
+	for _, timer := range c.timers {
+		if !timer.stopped {
+			active = append(active, timer)
+		}
+	}
+	c.timers = active
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		if c.timers[i].next.Equal(c.timers[j].next) {
+			return c.timers[i].id < c.timers[j].id
+		}
+		return c.timers[i].next.Before(c.timers[j].next)
+	})
+	if len(c.timers) == 0 || c.timers[0].next.After(target) {
+		return nil
+	}
+	return c.timers[0]
+}
@@ -3,6 +3,7 @@ package db
 
 import (
 	"backend/internal/logger"
+	"backend/internal/types"
 	"errors"
 	"fmt"
 	"time"
@@ -25,7 +26,7 @@ func (r *DetailRepository) CreateDetail(detail *Detail) error {
 		logger.Error("创建详单记录失败 - 房间ID: %d, 错误: %v", detail.RoomID, err)
 		return fmt.Errorf("创建详单记录失败: %v", err)
 	}
-	logger.Info("成功创建详单记录 - 房间ID: %d, 开始时间: %v, 服务时长: %.1f分钟, 费用: %.2f元, 风速: %s",
+	logger.Info("成功创建详单记录 - 房间ID: %d, 开始时间: %v, 服务时长: %.1f分钟, 费用: %s, 风速: %s",
 		detail.RoomID, detail.StartTime.Format("15:04:05"), detail.ServeTime, detail.Cost, detail.Speed)
 	return nil
 }
@@ -76,18 +77,32 @@ func (r *DetailRepository) GetLatestDetail(roomID int) (*Detail, error) {
 }
 
 // GetTotalCost 获取指定房间在时间范围内的总费用（不包括当前开机的费用）
-func (r *DetailRepository) GetTotalCost(roomID int, startTime, endTime time.Time) (float32, error) {
-	var totalCost float32
+func (r *DetailRepository) GetTotalCost(roomID int, startTime, endTime time.Time) (types.Money, error) {
+	var totalFen int64
 	err := r.db.Model(&Detail{}).
 		Where("room_id = ? AND query_time BETWEEN ? AND ?", roomID, startTime, endTime).
-		Select("COALESCE(SUM(cost), 0) as total_cost").
-		Scan(&totalCost).Error
+		Select("COALESCE(SUM(cost_fen), 0) as total_fen").
+		Scan(&totalFen).Error
 	if err != nil {
 		logger.Error("计算总费用失败 - 房间ID: %d, 时间范围: %v 到 %v, 错误: %v",
 			roomID, startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"), err)
-		return 0, fmt.Errorf("计算总费用失败: %v", err)
+		return types.Money{}, fmt.Errorf("计算总费用失败: %v", err)
 	}
-	return totalCost, nil
+	return types.Fen(totalFen), nil
+}
+
+// GetPreconditionCost 获取预调温计划期间产生的费用
+func (r *DetailRepository) GetPreconditionCost(planID int) (types.Money, error) {
+	var totalFen int64
+	err := r.db.Model(&Detail{}).
+		Where("precondition_id = ?", planID).
+		Select("COALESCE(SUM(cost_fen), 0) as total_fen").
+		Scan(&totalFen).Error
+	if err != nil {
+		logger.Error("计算预调温费用失败 - 计划: %d, 错误: %v", planID, err)
+		return types.Money{}, fmt.Errorf("计算预调温费用失败: %v", err)
+	}
+	return types.Fen(totalFen), nil
 }
 
 // DeleteDetails 删除指定房间的所有详单
@@ -0,0 +1,59 @@
+// internal/db/energy_repository.go
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EnergyRepository struct {
+	db *gorm.DB
+}
+
+// NewEnergyRepository 创建累计耗电量仓库
+func NewEnergyRepository() *EnergyRepository {
+	return &EnergyRepository{db: DB}
+}
+
+// Add 将房间的耗电量同时累计到房间、所属机组和全酒店的计量中
+func (r *EnergyRepository) Add(roomID int, zone string, energy float64, at time.Time) error {
+	meters := []EnergyMeter{
+		{Scope: MeterRoom, Key: strconv.Itoa(roomID)},
+		{Scope: MeterZone, Key: zone},
+		{Scope: MeterHotel},
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, meter := range meters {
+			meter.Energy = energy
+			meter.UpdatedAt = at
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "scope"}, {Name: "key"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"energy":     gorm.Expr("energy + ?", energy),
+					"updated_at": at,
+				}),
+			}).Create(&meter).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("累计耗电量失败: %v", err)
+	}
+	return nil
+}
+
+// List 获取某个范围的全部累计耗电量，按键排序
+func (r *EnergyRepository) List(scope string) ([]EnergyMeter, error) {
+	var meters []EnergyMeter
+	if err := r.db.Where("scope = ?", scope).Order("key ASC").Find(&meters).Error; err != nil {
+		return nil, fmt.Errorf("获取累计耗电量失败: %v", err)
+	}
+	return meters, nil
+}
@@ -0,0 +1,184 @@
+// internal/db/folio_repository.go
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InvoiceNumberFormat 发票编号的格式，按序号生成
+const InvoiceNumberFormat = "INV-%08d"
+
+type FolioRepository struct {
+	db *gorm.DB
+}
+
+// NewFolioRepository 创建住客账单和发票仓库
+func NewFolioRepository() *FolioRepository {
+	return &FolioRepository{db: DB}
+}
+
+// Create 开立住客账单
+func (r *FolioRepository) Create(folio *Folio) error {
+	if err := r.db.Create(folio).Error; err != nil {
+		return fmt.Errorf("开立账单失败: %v", err)
+	}
+	return nil
+}
+
+// GetOpenByRoom 获取房间未结算的账单
+// 返回值: 账单，房间没有未结算的账单时为nil
+func (r *FolioRepository) GetOpenByRoom(roomID int) (*Folio, error) {
+	var folio Folio
+	err := r.db.Where("room_id = ? AND status = ?", roomID, FolioOpen).
+		Order("id DESC").
+		First(&folio).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取账单失败: %v", err)
+	}
+	return &folio, nil
+}
+
+// AddItem 向未结算的账单追加一条账目
+func (r *FolioRepository) AddItem(item *FolioItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var folio Folio
+		if err := tx.First(&folio, item.FolioID).Error; err != nil {
+			return fmt.Errorf("获取账单失败: %v", err)
+		}
+		if folio.Status != FolioOpen {
+			return fmt.Errorf("账单 %d 已结算，不能追加账目", folio.ID)
+		}
+		if err := tx.Create(item).Error; err != nil {
+			return fmt.Errorf("追加账目失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// ListItems 按记账顺序获取账单的账目
+func (r *FolioRepository) ListItems(folioID int) ([]FolioItem, error) {
+	var items []FolioItem
+	if err := r.db.Where("folio_id = ?", folioID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("获取账目失败: %v", err)
+	}
+	return items, nil
+}
+
+// Finalize 在一个事务中写入结算时的账目、以下一个序号开具发票并关闭账单
+// items: 结算时追加的账目(房费和空调费用)；invoice: 除编号和账目外已填好的发票
+// snapshot: 在结算的账目写入后生成发票的账目快照
+// 序号取已开具发票的最大序号加1，事务失败时整体回滚，发票编号不会出现空号
+func (r *FolioRepository) Finalize(folio *Folio, items []FolioItem, invoice *Invoice, snapshot func() (string, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current Folio
+		if err := tx.First(&current, folio.ID).Error; err != nil {
+			return fmt.Errorf("获取账单失败: %v", err)
+		}
+		if current.Status != FolioOpen {
+			return fmt.Errorf("账单 %d 已结算", folio.ID)
+		}
+		for i := range items {
+			items[i].FolioID = folio.ID
+			if err := tx.Create(&items[i]).Error; err != nil {
+				return fmt.Errorf("写入账目失败: %v", err)
+			}
+		}
+
+		snapshotItems, err := snapshot()
+		if err != nil {
+			return err
+		}
+		invoice.Items = snapshotItems
+
+		var last int64
+		if err := tx.Model(&Invoice{}).Select("COALESCE(MAX(seq), 0)").Scan(&last).Error; err != nil {
+			return fmt.Errorf("分配发票编号失败: %v", err)
+		}
+		invoice.Seq = last + 1
+		invoice.Number = fmt.Sprintf(InvoiceNumberFormat, invoice.Seq)
+		invoice.FolioID = folio.ID
+		if err := tx.Create(invoice).Error; err != nil {
+			return fmt.Errorf("开具发票失败: %v", err)
+		}
+
+		folio.Status = FolioClosed
+		folio.CheckoutTime = invoice.CheckoutTime
+		folio.InvoiceID = invoice.ID
+		err = tx.Model(&Folio{}).Where("id = ?", folio.ID).Updates(map[string]interface{}{
+			"status":        folio.Status,
+			"checkout_time": folio.CheckoutTime,
+			"invoice_id":    folio.InvoiceID,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("关闭账单失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// Reopen 撤销一次结算：删除结算时开具的发票和写入的账目，账单恢复为未结算
+// 用于结算后退房失败的情况。只能撤销最近开具的发票，撤销后该序号由下一次结算继续使用，发票编号不会出现空号；
+// 调用方需保证结算与撤销之间没有其他结算
+// items: 结算时写入的账目
+func (r *FolioRepository) Reopen(invoice *Invoice, items []FolioItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var last int64
+		if err := tx.Model(&Invoice{}).Select("COALESCE(MAX(seq), 0)").Scan(&last).Error; err != nil {
+			return fmt.Errorf("获取发票序号失败: %v", err)
+		}
+		if last != invoice.Seq {
+			return fmt.Errorf("发票 %s 不是最近开具的发票，不能撤销", invoice.Number)
+		}
+		if err := tx.Delete(&Invoice{}, invoice.ID).Error; err != nil {
+			return fmt.Errorf("删除发票失败: %v", err)
+		}
+		for _, item := range items {
+			if err := tx.Where("id = ? AND folio_id = ?", item.ID, invoice.FolioID).Delete(&FolioItem{}).Error; err != nil {
+				return fmt.Errorf("删除账目失败: %v", err)
+			}
+		}
+		err := tx.Model(&Folio{}).Where("id = ?", invoice.FolioID).Updates(map[string]interface{}{
+			"status":        FolioOpen,
+			"checkout_time": time.Time{},
+			"invoice_id":    0,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("恢复账单失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetInvoiceByNumber 按编号获取发票
+// 返回值: 发票，编号不存在时为nil
+func (r *FolioRepository) GetInvoiceByNumber(number string) (*Invoice, error) {
+	var invoice Invoice
+	err := r.db.Where("number = ?", number).First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取发票失败: %v", err)
+	}
+	return &invoice, nil
+}
+
+// ListInvoices 按编号顺序获取发票，roomID为0时获取全部房间的发票
+func (r *FolioRepository) ListInvoices(roomID int) ([]Invoice, error) {
+	var invoices []Invoice
+	query := r.db.Order("seq ASC")
+	if roomID != 0 {
+		query = query.Where("room_id = ?", roomID)
+	}
+	if err := query.Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("获取发票失败: %v", err)
+	}
+	return invoices, nil
+}
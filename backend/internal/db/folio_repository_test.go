@@ -0,0 +1,119 @@
+// internal/db/folio_repository_test.go
+package db
+
+import (
+	"backend/internal/types"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	Init_DBWithName("file:db_test?mode=memory&cache=shared")
+	DB.Config.Logger = gormlogger.Default.LogMode(gormlogger.Silent)
+
+	code := m.Run()
+	SQLDB.Close()
+	os.Exit(code)
+}
+
+// openFolio 为房间开立一个测试账单
+func openFolio(t *testing.T, repo *FolioRepository, roomID int, checkin time.Time) *Folio {
+	t.Helper()
+	folio := &Folio{
+		RoomID:      roomID,
+		ClientID:    fmt.Sprintf("T%03d", roomID),
+		ClientName:  "测试住客",
+		CheckinTime: checkin,
+		Status:      FolioOpen,
+	}
+	if err := repo.Create(folio); err != nil {
+		t.Fatal(err)
+	}
+	return folio
+}
+
+func TestFinalizeRollbackKeepsSeq(t *testing.T) {
+	repo := NewFolioRepository()
+	checkin := time.Date(2024, 6, 1, 14, 0, 0, 0, time.Local)
+	checkout := checkin.Add(22 * time.Hour)
+	roomNight := func() []FolioItem {
+		return []FolioItem{{
+			Kind:        FolioRoomNight,
+			Description: "房费 1晚",
+			Quantity:    1,
+			UnitPrice:   types.Fen(20000),
+			Amount:      types.Fen(20000),
+			CreatedTime: checkout,
+		}}
+	}
+	newInvoice := func(folio *Folio) *Invoice {
+		return &Invoice{
+			RoomID:       folio.RoomID,
+			CheckinTime:  folio.CheckinTime,
+			CheckoutTime: checkout,
+			Charges:      types.Fen(20000),
+			Balance:      types.Fen(20000),
+			IssuedAt:     checkout,
+		}
+	}
+
+	// 生成账目快照失败，整个结算回滚
+	failed := openFolio(t, repo, 1, checkin)
+	snapshotErr := errors.New("快照失败")
+	err := repo.Finalize(failed, roomNight(), newInvoice(failed), func() (string, error) { return "", snapshotErr })
+	if !errors.Is(err, snapshotErr) {
+		t.Fatalf("结算返回 %v，期望 %v", err, snapshotErr)
+	}
+	open, err := repo.GetOpenByRoom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open == nil || open.ID != failed.ID {
+		t.Fatalf("结算失败后账单 %d 不再是未结算状态", failed.ID)
+	}
+	items, err := repo.ListItems(failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("结算失败后账单仍有 %d 条账目", len(items))
+	}
+	invoices, err := repo.ListInvoices(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 0 {
+		t.Fatalf("结算失败后开具了 %d 张发票", len(invoices))
+	}
+
+	// 之后的结算仍从序号1开始
+	for i, folio := range []*Folio{openFolio(t, repo, 2, checkin), failed} {
+		invoice := newInvoice(folio)
+		if err := repo.Finalize(folio, roomNight(), invoice, func() (string, error) { return "[]", nil }); err != nil {
+			t.Fatal(err)
+		}
+		wantSeq := int64(i + 1)
+		if want := fmt.Sprintf(InvoiceNumberFormat, wantSeq); invoice.Seq != wantSeq || invoice.Number != want {
+			t.Errorf("第 %d 张发票序号为 %d、编号为 %s，期望 %d 和 %s", i+1, invoice.Seq, invoice.Number, wantSeq, want)
+		}
+		if folio.Status != FolioClosed || folio.InvoiceID != invoice.ID {
+			t.Errorf("账单 %d 状态为 %s、发票为 %d，期望 %s 和 %d", folio.ID, folio.Status, folio.InvoiceID, FolioClosed, invoice.ID)
+		}
+	}
+
+	// 已结算的账单不能再次结算
+	if err := repo.Finalize(failed, nil, newInvoice(failed), func() (string, error) { return "[]", nil }); err == nil {
+		t.Error("已结算的账单再次结算成功")
+	}
+	if invoice, err := repo.GetInvoiceByNumber(fmt.Sprintf(InvoiceNumberFormat, 3)); err != nil || invoice != nil {
+		t.Errorf("再次结算失败后开具了发票 %v (%v)", invoice, err)
+	}
+}
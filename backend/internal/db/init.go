@@ -1,6 +1,7 @@
 package db
 
 import (
+	"backend/internal/types"
 	"database/sql"
 	"fmt"
 	"log"
@@ -18,12 +19,18 @@ var SQLDB *sql.DB
 var DB *gorm.DB
 
 func Init_DB() {
-	if _, err := os.Stat(DB_NAME); os.IsNotExist(err) {
+	Init_DBWithName(DB_NAME)
+}
+
+// Init_DBWithName 使用指定的数据源初始化数据库
+// 数据源不存在时(包括内存数据库)会写入基础数据和房间
+func Init_DBWithName(name string) {
+	if _, err := os.Stat(name); os.IsNotExist(err) {
 		Init = true
 	} else {
 		fmt.Println("database already exists")
 	}
-	db, err := gorm.Open(sqlite.Open(DB_NAME), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database")
 	}
@@ -36,15 +43,62 @@ func Init_DB() {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 	DB = db
 	SQLDB = sqlDB
-	err = db.AutoMigrate(&RoomInfo{}, &Detail{}, &User{})
+	err = db.AutoMigrate(&RoomInfo{}, &Detail{}, &User{}, &SystemSetting{}, &Precondition{}, &RoomTimer{}, &Tariff{}, &TariffSchedule{}, &EnergyMeter{}, &Folio{}, &FolioItem{}, &Invoice{})
 	if err != nil {
 		panic("failed to migrate database")
 	}
+	if err := migrateMoneyColumns(db); err != nil {
+		panic(fmt.Sprintf("failed to migrate money columns: %v", err))
+	}
 	if Init {
 		InitBaseData()
 		InitRooms()
 	}
+	// 之后房间的读写都经过内存中的房间状态存储
+	roomStore, err = loadRoomStore(db)
+	if err != nil {
+		panic("failed to load rooms")
+	}
+}
+
+// legacyMoneyColumn 旧版本以浮点数(元)保存的金额列，迁移到以分为单位的整数列
+type legacyMoneyColumn struct {
+	model  interface{}
+	table  string
+	column string // 旧的浮点数列
+	prefix string // 新的整数列和货币列的前缀
+	fen    string // 由旧列计算分的表达式
+}
+
+// legacyMoneyColumns 需要迁移的金额列
+// 旧版本的详单只在结束服务段的详单上记录费用，调风、费率调整和时段切换详单的费用为0，迁移时按它们记录的
+// 服务时长和费率补上(旧版本的服务时长是按时间倍率缩放后的计费分钟数，与住客被收取的费用一致)，不按起止时间重新推算
+var legacyMoneyColumns = []legacyMoneyColumn{
+	{&Detail{}, "details", "cost", "cost_", `CASE WHEN detail_type IN ('speed_change', 'shed_speed', 'rate_change', 'band_change')
+		THEN ROUND(COALESCE(serve_time, 0) * COALESCE(rate, 0) * 100) ELSE ROUND(COALESCE(cost, 0) * 100) END`},
+	{&Precondition{}, "preconditions", "cost", "cost_", "ROUND(COALESCE(cost, 0) * 100)"},
+	{&RoomInfo{}, "room_infos", "deposit", "deposit_", "ROUND(COALESCE(deposit, 0) * 100)"},
+	{&RoomInfo{}, "room_infos", "daily_rate", "daily_rate_", "ROUND(COALESCE(daily_rate, 0) * 100)"},
+}
 
+// migrateMoneyColumns 将旧版本的浮点数金额列换算为分写入新的整数列后删除旧列
+// 只换算尚未写入货币的行，迁移中断后重新启动时不会覆盖已经迁移或新写入的金额
+func migrateMoneyColumns(db *gorm.DB) error {
+	for _, legacy := range legacyMoneyColumns {
+		if !db.Migrator().HasColumn(legacy.model, legacy.column) {
+			continue
+		}
+		sql := fmt.Sprintf("UPDATE %s SET %sfen = CAST(%s AS INTEGER), %scurrency = ? WHERE %scurrency IS NULL OR %scurrency = ''",
+			legacy.table, legacy.prefix, legacy.fen, legacy.prefix, legacy.prefix, legacy.prefix)
+		if err := db.Exec(sql, types.CNY).Error; err != nil {
+			return fmt.Errorf("换算 %s.%s 失败: %v", legacy.table, legacy.column, err)
+		}
+		if err := db.Migrator().DropColumn(legacy.model, legacy.column); err != nil {
+			return fmt.Errorf("删除 %s.%s 失败: %v", legacy.table, legacy.column, err)
+		}
+		log.Printf("已将 %s.%s 迁移为以分为单位的金额\n", legacy.table, legacy.column)
+	}
+	return nil
 }
 
 func InitBaseData() {
@@ -112,7 +166,7 @@ func InitRooms() {
 				CurrentTemp: 32.0,
 				ACState:     0, // 0: 关闭 1: 开启
 				InitialTemp: 32.0,
-				DailyRate:   100.0,
+				DailyRate:   types.Fen(10000),
 			},
 			{
 				RoomID:      2,
@@ -120,7 +174,7 @@ func InitRooms() {
 				CurrentTemp: 28.0,
 				ACState:     0,
 				InitialTemp: 28.0,
-				DailyRate:   125.0,
+				DailyRate:   types.Fen(12500),
 			},
 			{
 				RoomID:      3,
@@ -128,7 +182,7 @@ func InitRooms() {
 				CurrentTemp: 30.0,
 				ACState:     0,
 				InitialTemp: 30.0,
-				DailyRate:   150.0,
+				DailyRate:   types.Fen(15000),
 			},
 			{
 				RoomID:      4,
@@ -136,7 +190,7 @@ func InitRooms() {
 				CurrentTemp: 29.0,
 				ACState:     0,
 				InitialTemp: 29.0,
-				DailyRate:   200.0,
+				DailyRate:   types.Fen(20000),
 			},
 			{
 				RoomID:      5,
@@ -144,7 +198,7 @@ func InitRooms() {
 				CurrentTemp: 35.0,
 				ACState:     0,
 				InitialTemp: 35.0,
-				DailyRate:   100.0,
+				DailyRate:   types.Fen(10000),
 			},
 		}
 
@@ -1,6 +1,12 @@
 package db
 
-import "time"
+import (
+	"backend/internal/types"
+	"time"
+)
+
+// DefaultZone 默认空调机组，未分配机组的房间都属于该机组
+const DefaultZone = "main"
 
 // DetailType 详单类型
 type DetailType string
@@ -11,6 +17,15 @@ const (
 	DetailTypeServiceStart     DetailType = "service_start"
 	DetailTypeTemp             DetailType = "temp_change" // 调整目标温度
 	DetailTypeServiceInterrupt DetailType = "service_interrupt"
+	DetailTypeWaiting          DetailType = "waiting"   // 进入等待队列
+	DetailTypeStandby          DetailType = "standby"   // 等待中转为待机(队列被清空)
+	DetailTypePowerOff         DetailType = "power_off" // 未在送风时关机
+	// DetailTypePrecondition 入住前预调温的费用，预调温费用计入住客账单时在入住时记录
+	DetailTypePrecondition DetailType = "precondition"
+	DetailTypeShedSpeed    DetailType = "shed_speed"  // 限负荷模式降低风速，之后按实际风速计费
+	DetailTypeShedStop     DetailType = "shed_stop"   // 限负荷模式停止送风
+	DetailTypeRateChange   DetailType = "rate_change" // 服务中费率调整，之后按新费率计费
+	DetailTypeBandChange   DetailType = "band_change" // 服务段跨过分时电价的时段边界，之后按新时段计费
 )
 
 // 房间信息表
@@ -21,33 +36,113 @@ type RoomInfo struct {
 	CheckinTime     time.Time `gorm:"type:datetime"`
 	CheckoutTime    time.Time `gorm:"type:datetime"`
 	State           int
-	CurrentSpeed    string    `gorm:"type:varchar(255)"`
-	CurrentTemp     float32   `gorm:"type:float"`
-	ACState         int       // 0: 关闭 1: 开启
-	Mode            string    `gorm:"type:varchar(20)"` // cooling/heating
-	TargetTemp      float32   `gorm:"type:float(5, 2)"`
-	InitialTemp     float32   `gorm:"type:float(5,2)"`
-	LastPowerOnTime time.Time `gorm:"type:datetime"` // 记录最后一次开机时间
-	SwitchCount     int       `gorm:"type:int;default:0"`
-	DailyRate       float32   `gorm:"type:float(7,2)"`  // 每日房费
-	Deposit         float32   `gorm:"type:float(10,2)"` // 押金
+	CurrentSpeed    string      `gorm:"type:varchar(255)"`
+	CurrentTemp     float32     `gorm:"type:float"`
+	ACState         int         // 0: 关闭 1: 开启
+	Mode            string      `gorm:"type:varchar(20)"` // cooling/heating/auto/fan/dry
+	TargetTemp      float32     `gorm:"type:float(5, 2)"`
+	InitialTemp     float32     `gorm:"type:float(5,2)"`
+	LastPowerOnTime time.Time   `gorm:"type:datetime"` // 记录最后一次开机时间
+	SwitchCount     int         `gorm:"type:int;default:0"`
+	DailyRate       types.Money `gorm:"embedded;embeddedPrefix:daily_rate_"` // 每日房费
+	Deposit         types.Money `gorm:"embedded;embeddedPrefix:deposit_"`    // 押金
+	Volume          float32     `gorm:"type:float;default:45"`               // 房间容积(m³)
+	Insulation      float32     `gorm:"type:float;default:25"`               // 围护结构传热系数(W/K)
+	OccupancyLoad   float32     `gorm:"type:float;default:100"`              // 人员和设备散热(W)
+	Zone            string      `gorm:"type:varchar(32);default:main"`       // 所属空调机组
+	Humidity        float32     `gorm:"type:float;default:65"`               // 当前相对湿度(%)
+	RunState        string      `gorm:"type:varchar(16);default:off"`        // 空调运行状态 off/waiting/serving/standby
+	PreconditionID  int         `gorm:"default:0"`                           // 正在执行的预调温计划，0表示没有
+	Class           string      `gorm:"type:varchar(16);default:standard"`   // 房间等级 standard/premium/vip
+	GuestClass      string      `gorm:"type:varchar(16)"`                    // 本次入住的住客等级，退房时清除
 }
 
 // Detail 详单表
 type Detail struct {
-	ID          int        `gorm:"primary_key"`
-	RoomID      int        `gorm:"type:int"`
-	QueryTime   time.Time  `gorm:"type:datetime"`
-	StartTime   time.Time  `gorm:"type:datetime"`
-	EndTime     time.Time  `gorm:"type:datetime"`
-	ServeTime   float32    `gorm:"type:float(7,2)"` // 服务时长(分钟)
-	Speed       string     `gorm:"type:varchar(255)"`
-	Cost        float32    `gorm:"type:float(7,2)"`  // 费用(元)
-	Rate        float32    `gorm:"type:float(5,2)"`  // 每分钟费率(元/分钟)
-	TempChange  float32    `gorm:"type:float(5,2)"`  // 温度变化
-	CurrentTemp float32    `gorm:"type:float(5,2)"`  // 当前温度
-	TargetTemp  float32    `gorm:"type:float(5,2)"`  // 目标温度
-	DetailType  DetailType `gorm:"type:varchar(20)"` // 详单类型
+	ID          int         `gorm:"primary_key"`
+	RoomID      int         `gorm:"type:int"`
+	QueryTime   time.Time   `gorm:"type:datetime"`
+	StartTime   time.Time   `gorm:"type:datetime"`
+	EndTime     time.Time   `gorm:"type:datetime"`
+	ServeTime   float32     `gorm:"type:float(7,2)"` // 服务时长(分钟)
+	Speed       string      `gorm:"type:varchar(255)"`
+	Cost        types.Money `gorm:"embedded;embeddedPrefix:cost_"` // 计费时段的费用，其余详单为0
+	Rate        float32     `gorm:"type:float(5,2)"`               // 每分钟费率(元/分钟)
+	TempChange  float32     `gorm:"type:float(5,2)"`               // 温度变化
+	CurrentTemp float32     `gorm:"type:float(5,2)"`               // 当前温度
+	TargetTemp  float32     `gorm:"type:float(5,2)"`               // 目标温度
+	DetailType  DetailType  `gorm:"type:varchar(20)"`              // 详单类型
+	Mode        string      `gorm:"type:varchar(20)"`              // 服务段的工作模式
+	Band        string      `gorm:"type:varchar(32)"`              // 计费时段，未设置分时电价时为空
+	Energy      float32     `gorm:"type:float(10,4)"`              // 结束的计费时段的耗电量(度)，其余详单为0
+	FromState   string      `gorm:"type:varchar(16)"`              // 转换前的运行状态，调整风速的详单为空
+	ToState     string      `gorm:"type:varchar(16)"`              // 转换后的运行状态，调整风速的详单为空
+	// PreconditionID 入住前预调温期间产生的详单所属的计划，住客的详单为0
+	PreconditionID int `gorm:"default:0"`
+}
+
+// PreconditionStatus 预调温计划的状态
+type PreconditionStatus string
+
+const (
+	PreconditionPending   PreconditionStatus = "pending"   // 等待开机
+	PreconditionRunning   PreconditionStatus = "running"   // 调温中
+	PreconditionCompleted PreconditionStatus = "completed" // 住客已入住
+	PreconditionExpired   PreconditionStatus = "expired"   // 到达时间后一直未入住，已关机
+	PreconditionCancelled PreconditionStatus = "cancelled" // 已取消或空调被手动关闭
+	PreconditionFailed    PreconditionStatus = "failed"    // 开机失败
+)
+
+// Precondition 入住前预调温计划表
+type Precondition struct {
+	ID          int                `gorm:"primaryKey"`
+	RoomID      int                `gorm:"type:int"`
+	TargetTemp  float32            `gorm:"type:float(5,2)"`               // 目标温度
+	ArriveTime  time.Time          `gorm:"type:datetime"`                 // 住客预计到达时间
+	StartTime   time.Time          `gorm:"type:datetime"`                 // 按热模型估算的开机时间
+	StartedAt   time.Time          `gorm:"type:datetime"`                 // 实际开机时间
+	EndedAt     time.Time          `gorm:"type:datetime"`                 // 结束时间
+	Status      PreconditionStatus `gorm:"type:varchar(16)"`              // 计划状态
+	ChargeTo    string             `gorm:"type:varchar(16)"`              // 费用的计费对象 hotel/guest
+	Cost        types.Money        `gorm:"embedded;embeddedPrefix:cost_"` // 预调温的费用，结束时结算
+	Note        string             `gorm:"type:varchar(255)"`             // 失败或取消的原因
+	CreatedTime time.Time          `gorm:"type:datetime"`                 // 创建时间(系统时间)
+}
+
+// TimerKind 房间定时任务的类型
+type TimerKind string
+
+const (
+	TimerPowerOff TimerKind = "power_off" // 定时关机
+	TimerPowerOn  TimerKind = "power_on"  // 定时开机
+	TimerSleep    TimerKind = "sleep"     // 睡眠曲线，每小时调整一次目标温度
+)
+
+// TimerStatus 房间定时任务的状态
+type TimerStatus string
+
+const (
+	TimerPending   TimerStatus = "pending"   // 等待触发
+	TimerRunning   TimerStatus = "running"   // 睡眠曲线执行中
+	TimerDone      TimerStatus = "done"      // 已执行
+	TimerCancelled TimerStatus = "cancelled" // 已取消、住客已退房或空调已关闭
+	TimerFailed    TimerStatus = "failed"    // 执行失败
+)
+
+// RoomTimer 房间定时任务表
+type RoomTimer struct {
+	ID          int         `gorm:"primaryKey"`
+	RoomID      int         `gorm:"type:int"`
+	Kind        TimerKind   `gorm:"type:varchar(16)"`
+	FireTime    time.Time   `gorm:"type:datetime"`     // 触发时间，睡眠曲线为开始时间
+	TargetTemp  float32     `gorm:"type:float(5,2)"`   // 定时开机后的目标温度，0表示默认温度
+	Step        float32     `gorm:"type:float(5,2)"`   // 睡眠曲线每小时调整的温度(°C)，正数升温、负数降温
+	Hours       int         `gorm:"type:int"`          // 睡眠曲线持续的小时数
+	StepsDone   int         `gorm:"type:int"`          // 睡眠曲线已执行的调整次数
+	Status      TimerStatus `gorm:"type:varchar(16)"`  // 任务状态
+	Note        string      `gorm:"type:varchar(255)"` // 失败或取消的原因
+	CreatedTime time.Time   `gorm:"type:datetime"`     // 创建时间(系统时间)
+	EndedAt     time.Time   `gorm:"type:datetime"`     // 结束时间
 }
 
 // 用户表
@@ -57,3 +152,111 @@ type User struct {
 	Password string `gorm:"type:varchar(255);not null"`
 	Identity string `gorm:"type:varchar(255);not null"` // manager, customer, administrator, reception
 }
+
+// SystemSetting 系统设置表，按键保存JSON编码的设置值
+type SystemSetting struct {
+	Key     string    `gorm:"primaryKey;type:varchar(64)"`
+	Value   string    `gorm:"type:text"`
+	SavedAt time.Time `gorm:"type:datetime"` // 最后保存时间(系统时间)
+}
+
+// Tariff 费率表，记录各机组每个风速的费率及其生效时间
+// 服务段按开始时生效的费率计费，费率调整只追加新记录，不修改已有记录
+type Tariff struct {
+	ID            int       `gorm:"primaryKey"`
+	Zone          string    `gorm:"type:varchar(32);index"`
+	Speed         string    `gorm:"type:varchar(16)"`
+	Rate          float32   `gorm:"type:float(10,4)"` // 费率(元/分钟)
+	EffectiveFrom time.Time `gorm:"type:datetime"`    // 生效时间(系统时间)
+	CreatedTime   time.Time `gorm:"type:datetime"`    // 记录时间(系统时间)
+}
+
+// TariffSchedule 分时电价表的记录，Schedule 为JSON编码的分时电价表，为空表示全天按风速的费率计费
+// 与费率表一样只追加新记录，服务段按开始时生效的分时电价表计费
+type TariffSchedule struct {
+	ID            int       `gorm:"primaryKey"`
+	Zone          string    `gorm:"type:varchar(32);index"`
+	Schedule      string    `gorm:"type:text"`
+	EffectiveFrom time.Time `gorm:"type:datetime"` // 生效时间(系统时间)
+	CreatedTime   time.Time `gorm:"type:datetime"` // 记录时间(系统时间)
+}
+
+// 电量计量的范围
+const (
+	MeterRoom  = "room"  // 房间
+	MeterZone  = "zone"  // 机组
+	MeterHotel = "hotel" // 全酒店
+)
+
+// EnergyMeter 累计耗电量表，按房间、机组和全酒店分别累计，不随退房或价格调整清零
+type EnergyMeter struct {
+	Scope     string    `gorm:"primaryKey;type:varchar(8)"`
+	Key       string    `gorm:"primaryKey;type:varchar(32)"` // 房间号或机组编号，全酒店为空
+	Energy    float64   `gorm:"type:double"`                 // 累计耗电量(度)
+	UpdatedAt time.Time `gorm:"type:datetime"`               // 最后累计的时间(系统时间)
+}
+
+// FolioStatus 住客账单的状态
+type FolioStatus string
+
+const (
+	FolioOpen   FolioStatus = "open"   // 入住中，可以追加账目
+	FolioClosed FolioStatus = "closed" // 已在退房时结算为发票，不再修改
+)
+
+// FolioItemKind 账目类型
+type FolioItemKind string
+
+const (
+	FolioRoomNight  FolioItemKind = "room_night" // 房费，按入住的晚数计
+	FolioACSegment  FolioItemKind = "ac_segment" // 空调计费时段，对应一条有费用的详单
+	FolioExtra      FolioItemKind = "extra"      // 其他消费
+	FolioDeposit    FolioItemKind = "deposit"    // 押金，金额为负
+	FolioPayment    FolioItemKind = "payment"    // 付款，金额为负
+	FolioAdjustment FolioItemKind = "adjustment" // 调整，减免为负
+)
+
+// Folio 住客账单，入住时开立，住店期间追加押金、消费、付款和调整，退房时连同房费和空调费用结算为发票
+type Folio struct {
+	ID           int         `gorm:"primaryKey"`
+	RoomID       int         `gorm:"type:int;index"`
+	ClientID     string      `gorm:"type:varchar(255)"`
+	ClientName   string      `gorm:"type:varchar(255)"`
+	CheckinTime  time.Time   `gorm:"type:datetime"`
+	CheckoutTime time.Time   `gorm:"type:datetime"`    // 结算时间，未结算时为零值
+	Status       FolioStatus `gorm:"type:varchar(16)"` // 账单状态
+	InvoiceID    int         `gorm:"default:0"`        // 结算生成的发票，未结算时为0
+}
+
+// FolioItem 账单的账目，应收为正，押金和付款为负
+type FolioItem struct {
+	ID          int           `gorm:"primaryKey"`
+	FolioID     int           `gorm:"type:int;index"`
+	Kind        FolioItemKind `gorm:"type:varchar(16)"`
+	Description string        `gorm:"type:varchar(255)"`
+	Quantity    int           `gorm:"type:int"`                            // 数量，房费为晚数，其余为1
+	UnitPrice   types.Money   `gorm:"embedded;embeddedPrefix:unit_price_"` // 单价
+	Amount      types.Money   `gorm:"embedded;embeddedPrefix:amount_"`     // 金额，数量×单价
+	Mode        string        `gorm:"type:varchar(20)"`                    // 空调账目的工作模式
+	DetailID    int           `gorm:"default:0"`                           // 空调账目对应的详单
+	CreatedTime time.Time     `gorm:"type:datetime"`                       // 记账时间(系统时间)
+}
+
+// Invoice 发票，退房时由账单结算生成，生成后不再修改
+// Seq 从1开始连续编号，编号在生成发票的事务中分配，事务失败时不会占用编号
+type Invoice struct {
+	ID           int         `gorm:"primaryKey"`
+	Seq          int64       `gorm:"uniqueIndex"`
+	Number       string      `gorm:"type:varchar(32);uniqueIndex"` // 发票编号，如 INV-00000001
+	FolioID      int         `gorm:"uniqueIndex"`
+	RoomID       int         `gorm:"type:int;index"`
+	ClientID     string      `gorm:"type:varchar(255)"`
+	ClientName   string      `gorm:"type:varchar(255)"`
+	CheckinTime  time.Time   `gorm:"type:datetime"`
+	CheckoutTime time.Time   `gorm:"type:datetime"`
+	Items        string      `gorm:"type:text"`                        // 结算时全部账目的JSON快照
+	Charges      types.Money `gorm:"embedded;embeddedPrefix:charges_"` // 应收合计：房费、空调、消费和调整
+	Credits      types.Money `gorm:"embedded;embeddedPrefix:credits_"` // 押金和付款合计(正数)
+	Balance      types.Money `gorm:"embedded;embeddedPrefix:balance_"` // 应付余额，负数为应退还住客
+	IssuedAt     time.Time   `gorm:"type:datetime"`                    // 开具时间(系统时间)
+}
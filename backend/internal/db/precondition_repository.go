@@ -0,0 +1,67 @@
+// internal/db/precondition_repository.go
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type PreconditionRepository struct {
+	db *gorm.DB
+}
+
+// NewPreconditionRepository 创建预调温计划仓库
+func NewPreconditionRepository() *PreconditionRepository {
+	return &PreconditionRepository{db: DB}
+}
+
+// Create 新增预调温计划
+func (r *PreconditionRepository) Create(plan *Precondition) error {
+	if err := r.db.Create(plan).Error; err != nil {
+		return fmt.Errorf("创建预调温计划失败: %v", err)
+	}
+	return nil
+}
+
+// Save 保存预调温计划
+func (r *PreconditionRepository) Save(plan *Precondition) error {
+	if err := r.db.Save(plan).Error; err != nil {
+		return fmt.Errorf("保存预调温计划失败: %v", err)
+	}
+	return nil
+}
+
+// GetByID 获取预调温计划
+func (r *PreconditionRepository) GetByID(id int) (*Precondition, error) {
+	var plan Precondition
+	if err := r.db.First(&plan, id).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ListActive 按开机时间顺序获取等待开机和调温中的计划
+func (r *PreconditionRepository) ListActive() ([]Precondition, error) {
+	var plans []Precondition
+	err := r.db.Where("status IN ?", []PreconditionStatus{PreconditionPending, PreconditionRunning}).
+		Order("start_time ASC, id ASC").
+		Find(&plans).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取预调温计划失败: %v", err)
+	}
+	return plans, nil
+}
+
+// ListByRoom 获取房间的预调温计划，roomID为0时获取全部房间的计划，按到达时间倒序
+func (r *PreconditionRepository) ListByRoom(roomID int) ([]Precondition, error) {
+	var plans []Precondition
+	query := r.db.Order("arrive_time DESC, id DESC")
+	if roomID != 0 {
+		query = query.Where("room_id = ?", roomID)
+	}
+	if err := query.Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("获取预调温计划失败: %v", err)
+	}
+	return plans, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"backend/internal/types"
 	"errors"
 	"fmt"
 	"time"
@@ -8,218 +9,322 @@ import (
 	"gorm.io/gorm"
 )
 
+// RoomRepository 房间数据访问对象
+// 读写都经过内存中的房间状态存储，温度、湿度、风速等高频修改由存储批量写回数据库；
+// 入住、退房、开关机和划分机组等低频修改在返回前立即写回，避免重启后丢失
 type RoomRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	store *RoomStore
 }
 
 func NewRoomRepository() *RoomRepository {
-	return &RoomRepository{db: DB}
+	return &RoomRepository{db: DB, store: roomStore}
 }
 
+// errRoomNotFound 房间不存在
+var errRoomNotFound = errors.New("room not found")
+
 // GetRoomByID 通过房间号获取房间信息
 func (r *RoomRepository) GetRoomByID(roomID int) (*RoomInfo, error) {
-	var room RoomInfo
-	err := r.db.Where("room_id = ?", roomID).First(&room).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("room not found")
-		}
-		return nil, err
+	room, ok := r.store.Get(roomID)
+	if !ok {
+		return nil, errRoomNotFound
 	}
-	return &room, nil
+	return room, nil
+}
+
+// CreateRoom 新增房间
+func (r *RoomRepository) CreateRoom(room *RoomInfo) error {
+	return r.store.Insert(room)
 }
 
 // UpdateRoom 更新房间信息
 func (r *RoomRepository) UpdateRoom(room *RoomInfo) error {
 	// 只更新指定的字段,避免覆盖其他字段
-	updates := make(map[string]interface{})
-
-	if room.TargetTemp != 0 {
-		updates["target_temp"] = room.TargetTemp
-	}
-	if room.CurrentTemp != 0 {
-		updates["current_temp"] = room.CurrentTemp
-	}
-	if room.CurrentSpeed != "" {
-		updates["current_speed"] = room.CurrentSpeed
-	}
-	if room.ACState != 0 {
-		updates["ac_state"] = room.ACState
-	}
+	return r.update(room.RoomID, func(info *RoomInfo) {
+		if room.TargetTemp != 0 {
+			info.TargetTemp = room.TargetTemp
+		}
+		if room.CurrentTemp != 0 {
+			info.CurrentTemp = room.CurrentTemp
+		}
+		if room.CurrentSpeed != "" {
+			info.CurrentSpeed = room.CurrentSpeed
+		}
+		if room.ACState != 0 {
+			info.ACState = room.ACState
+		}
+	})
+}
 
-	return r.db.Model(&RoomInfo{}).
-		Where("room_id = ?", room.RoomID).
-		Updates(updates).Error
+// persist 立即写回修改，用于低频且不能丢失的修改
+func (r *RoomRepository) persist() error {
+	_, err := r.store.Flush()
+	return err
 }
 
 // CheckIn 入住
-func (r *RoomRepository) CheckIn(roomID int, clientID, clientName string, deposit float32) error {
-	now := time.Now()
-	return r.db.Model(&RoomInfo{}).Where("room_id = ? AND state = ?", roomID, 0).Updates(map[string]interface{}{
-		"client_id":     clientID,
-		"client_name":   clientName,
-		"checkin_time":  now,
-		"state":         1,
-		"ac_state":      0,           // 空调初始为关闭状态
-		"mode":          "cooling",   // 默认制冷模式
-		"current_speed": "",          // 清空风速
-		"target_temp":   float32(24), // 默认目标温度
-		"deposit":       deposit,     // 押金金额
-	}).Error
-}
-
-func (r *RoomRepository) CheckOut(roomID int) error {
-	now := time.Now()
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// 获取房间信息
-		var room RoomInfo
-		if err := tx.Where("room_id = ?", roomID).First(&room).Error; err != nil {
-			return err
+// now: 入住时间，由调用方从系统时钟获取
+func (r *RoomRepository) CheckIn(roomID int, clientID, clientName string, deposit types.Money, now time.Time) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		if room.State != 0 {
+			return
 		}
+		room.ClientID = clientID
+		room.ClientName = clientName
+		room.CheckinTime = now
+		room.State = 1
+		room.ACState = 0       // 空调初始为关闭状态
+		room.Mode = "cooling"  // 默认制冷模式
+		room.CurrentSpeed = "" // 清空风速
+		room.TargetTemp = 24   // 默认目标温度
+		room.Deposit = deposit // 押金金额
+	}); err != nil {
+		return err
+	}
+	return r.persist()
+}
 
-		// 更新房间状态
-		return tx.Model(&RoomInfo{}).Where("room_id = ? AND state = ?", roomID, 1).Updates(map[string]interface{}{
-			"client_id":     "",
-			"client_name":   "",
-			"checkout_time": now,
-			"state":         0,
-			"ac_state":      0,    // 确保空调关闭
-			"current_speed": "",   // 清空风速
-			"target_temp":   26.0, // 重置目标温度
-		}).Error
-	})
+// CheckOut 退房
+// now: 退房时间，由调用方从系统时钟获取
+// 退房立即写回，写回失败时恢复内存中的入住状态并返回错误，房间仍为入住
+func (r *RoomRepository) CheckOut(roomID int, now time.Time) error {
+	var before RoomInfo
+	if !r.store.Update(roomID, func(room *RoomInfo) {
+		if room.State != 1 {
+			return
+		}
+		before = *room
+		room.ClientID = ""
+		room.ClientName = ""
+		room.CheckoutTime = now
+		room.State = 0
+		room.ACState = 0       // 确保空调关闭
+		room.RunState = "off"  // 运行状态随之关机
+		room.CurrentSpeed = "" // 清空风速
+		room.TargetTemp = 26.0 // 重置目标温度
+		room.GuestClass = ""   // 住客等级只在本次入住期间有效
+	}) {
+		return gorm.ErrRecordNotFound
+	}
+	if err := r.persist(); err != nil {
+		if before.State == 1 {
+			r.store.Update(roomID, func(room *RoomInfo) {
+				room.ClientID = before.ClientID
+				room.ClientName = before.ClientName
+				room.CheckoutTime = before.CheckoutTime
+				room.State = before.State
+				room.ACState = before.ACState
+				room.RunState = before.RunState
+				room.CurrentSpeed = before.CurrentSpeed
+				room.TargetTemp = before.TargetTemp
+				room.GuestClass = before.GuestClass
+			})
+		}
+		return err
+	}
+	return nil
 }
 
 // UpdateRoomState 更新房间状态
 func (r *RoomRepository) UpdateRoomState(roomID, state int) error {
-	return r.db.Model(&RoomInfo{}).Where("room_id = ?", roomID).Update("state", state).Error
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.State = state
+	}); err != nil {
+		return err
+	}
+	return r.persist()
 }
 
 // UpdateRoomSpeed 更新房间环境
 func (r *RoomRepository) UpdateRoomEnvironment(roomID int, temp float32, speed string) error {
-	return r.db.Model(&RoomInfo{}).Where("room_id = ?", roomID).Updates(map[string]interface{}{
-		"current_speed": speed,
-		"current_temp":  temp,
-	}).Error
+	return r.update(roomID, func(room *RoomInfo) {
+		room.CurrentSpeed = speed
+		room.CurrentTemp = temp
+	})
 }
 
 // GetOccupiedRooms 获取所有已入住房间
 func (r *RoomRepository) GetOccupiedRooms() ([]RoomInfo, error) {
-	var rooms []RoomInfo
-	err := r.db.Where("state = ?", 1).Find(&rooms).Error
-	return rooms, err
+	return r.store.List(func(room *RoomInfo) bool { return room.State == 1 }), nil
 }
 
 // GetAvailableRooms 获取所有可入住房间
 func (r *RoomRepository) GetAvailableRooms() ([]RoomInfo, error) {
-	var rooms []RoomInfo
-	err := r.db.Where("state = ?", 0).Find(&rooms).Error
-	return rooms, err
+	return r.store.List(func(room *RoomInfo) bool { return room.State == 0 }), nil
 }
 
+// GetDB 获取数据库连接，房间表的内容可能落后于内存中的状态一个写回间隔
 func (r *RoomRepository) GetDB() *gorm.DB {
 	return r.db
 }
 
-// UpdateTemperature 更新房间温度
-func (r *RoomRepository) UpdateTemperature(roomID int, currTeemp float32) error {
-	result := r.db.Model(&RoomInfo{}).
-		Where("room_id = ?", roomID).
-		Update("current_temp", currTeemp)
-	if result.Error != nil {
-		return fmt.Errorf("更新房间温度失败: %v", result.Error)
-	}
-	if result.RowsAffected == 0 {
+// update 修改一个房间，房间不存在时返回错误
+func (r *RoomRepository) update(roomID int, update func(*RoomInfo)) error {
+	if !r.store.Update(roomID, update) {
 		return fmt.Errorf("房间不存在")
 	}
 	return nil
 }
 
-// UpdateTargetTemperature 更新房间目标温度
-func (r *RoomRepository) UpdateTargetTemperature(roomID int, targetTemp float32) error {
-	result := r.db.Model(&RoomInfo{}).
-		Where("room_id = ?", roomID).
-		Update("target_temp", targetTemp)
-	if result.Error != nil {
-		return fmt.Errorf("更新房间温度失败: %v", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("房间不存在")
+// UpdateTemperature 更新房间温度
+func (r *RoomRepository) UpdateTemperature(roomID int, currTeemp float32) error {
+	return r.update(roomID, func(room *RoomInfo) {
+		room.CurrentTemp = currTeemp
+	})
+}
+
+// UpdateHumidity 更新房间湿度
+func (r *RoomRepository) UpdateHumidity(roomID int, humidity float32) error {
+	return r.update(roomID, func(room *RoomInfo) {
+		room.Humidity = humidity
+	})
+}
+
+// UpdateRunState 更新房间空调的运行状态
+func (r *RoomRepository) UpdateRunState(roomID int, state string) error {
+	return r.update(roomID, func(room *RoomInfo) {
+		room.RunState = state
+	})
+}
+
+// SetPrecondition 记录房间正在执行的预调温计划，planID为0时清除
+func (r *RoomRepository) SetPrecondition(roomID, planID int) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.PreconditionID = planID
+	}); err != nil {
+		return err
 	}
-	return nil
+	return r.persist()
 }
 
-// UpdateSpeed 更新房间风速
-func (r *RoomRepository) UpdateSpeed(roomID int, speed string) error {
-	result := r.db.Model(&RoomInfo{}).
-		Where("room_id = ?", roomID).
-		Update("current_speed", speed)
-	if result.Error != nil {
-		return fmt.Errorf("更新房间风速失败: %v", result.Error)
+// UpdateClass 更新房间等级
+func (r *RoomRepository) UpdateClass(roomID int, class string) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.Class = class
+	}); err != nil {
+		return fmt.Errorf("更新房间等级失败: %v", err)
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("房间不存在")
+	return r.persist()
+}
+
+// UpdateGuestClass 更新本次入住的住客等级
+func (r *RoomRepository) UpdateGuestClass(roomID int, class string) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.GuestClass = class
+	}); err != nil {
+		return fmt.Errorf("更新住客等级失败: %v", err)
 	}
-	return nil
+	return r.persist()
 }
-func (r *RoomRepository) PowerOnAC(roomID int, mode string, defaultTemp float32) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// 更新房间空调状态
-		updates := map[string]interface{}{
-			"ac_state":           1,                             // 开机状态
-			"mode":               mode,                          // 工作模式
-			"target_temp":        defaultTemp,                   // 目标温度设为默认温度
-			"current_speed":      "中",                           // 初始中风速
-			"last_power_on_time": time.Now(),                    // 记录开机时间
-			"switch_count":       gorm.Expr("switch_count + 1"), // 增加开关次数
-		}
 
-		if err := tx.Model(&RoomInfo{}).Where("room_id = ?", roomID).Updates(updates).Error; err != nil {
-			return err
-		}
+// UpdateTargetTemperature 更新房间目标温度
+func (r *RoomRepository) UpdateTargetTemperature(roomID int, targetTemp float32) error {
+	return r.update(roomID, func(room *RoomInfo) {
+		room.TargetTemp = targetTemp
+	})
+}
 
-		return nil
+// UpdateSpeed 更新房间风速
+func (r *RoomRepository) UpdateSpeed(roomID int, speed string) error {
+	return r.update(roomID, func(room *RoomInfo) {
+		room.CurrentSpeed = speed
 	})
 }
 
-func (r *RoomRepository) PowerOffAC(roomID int) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// 更新房间空调状态
-		updates := map[string]interface{}{
-			"ac_state":      0,  // 关机状态
-			"current_speed": "", // 清除风速
-		}
+// PowerOnAC 开启房间空调
+// speed: 开机时的默认风速
+// now: 开机时间，由调用方从系统时钟获取
+func (r *RoomRepository) PowerOnAC(roomID int, mode string, defaultTemp float32, speed string, now time.Time) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.ACState = 1              // 开机状态
+		room.Mode = mode              // 工作模式
+		room.TargetTemp = defaultTemp // 目标温度设为默认温度
+		room.CurrentSpeed = speed     // 初始为默认风速
+		room.LastPowerOnTime = now    // 记录开机时间
+		room.SwitchCount++            // 增加开关次数
+	}); err != nil {
+		return err
+	}
+	return r.persist()
+}
 
-		if err := tx.Model(&RoomInfo{}).Where("room_id = ?", roomID).Updates(updates).Error; err != nil {
-			return err
-		}
+func (r *RoomRepository) PowerOffAC(roomID int) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.ACState = 0       // 关机状态
+		room.RunState = "off"  // 运行状态
+		room.CurrentSpeed = "" // 清除风速
+	}); err != nil {
+		return err
+	}
+	return r.persist()
+}
 
-		return nil
+// SetACMode 设置机组内所有房间的工作模式
+func (r *RoomRepository) SetACMode(zone, mode string) error {
+	r.store.UpdateWhere(func(room *RoomInfo) bool { return room.Zone == zone }, func(room *RoomInfo) {
+		room.Mode = mode
 	})
+	return r.persist()
 }
 
-func (r *RoomRepository) SetACMode(mode string) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// 更新所有房间的工作模式
-		if err := tx.Model(&RoomInfo{}).Where("1 = 1").Updates(map[string]interface{}{
-			"mode": mode,
-		}).Error; err != nil {
-			return err
-		}
+// ResetTemperature 重置房间的初始温度和当前温度
+func (r *RoomRepository) ResetTemperature(roomID int, temp float32) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.InitialTemp = temp
+		room.CurrentTemp = temp
+	}); err != nil {
+		return fmt.Errorf("重置房间温度失败: %v", err)
+	}
+	return r.persist()
+}
 
-		return nil
-	})
+// UpdateThermalParams 更新房间的热参数
+func (r *RoomRepository) UpdateThermalParams(roomID int, volume, insulation, occupancyLoad float32) error {
+	if err := r.update(roomID, func(room *RoomInfo) {
+		room.Volume = volume
+		room.Insulation = insulation
+		room.OccupancyLoad = occupancyLoad
+	}); err != nil {
+		return fmt.Errorf("更新房间热参数失败: %v", err)
+	}
+	return r.persist()
 }
 
 // GetAllRooms 获取所有房间信息
 func (r *RoomRepository) GetAllRooms() ([]RoomInfo, error) {
-	var rooms []RoomInfo
-	result := r.db.Find(&rooms)
-	if result.Error != nil {
-		return nil, fmt.Errorf("获取所有房间失败: %v", result.Error)
-	}
+	rooms := r.store.List(nil)
 	if len(rooms) == 0 {
 		return nil, fmt.Errorf("没有房间")
 	}
 	return rooms, nil
 }
+
+// GetRoomsByZone 获取机组内的所有房间，机组没有房间时返回空列表
+func (r *RoomRepository) GetRoomsByZone(zone string) ([]RoomInfo, error) {
+	return r.store.List(func(room *RoomInfo) bool { return room.Zone == zone }), nil
+}
+
+// UpdateZone 将房间划入指定机组
+func (r *RoomRepository) UpdateZone(roomIDs []int, zone string) error {
+	for _, roomID := range roomIDs {
+		r.store.Update(roomID, func(room *RoomInfo) {
+			room.Zone = zone
+		})
+	}
+	if err := r.persist(); err != nil {
+		return fmt.Errorf("更新房间所属机组失败: %v", err)
+	}
+	return nil
+}
+
+// ReassignZone 将一个机组的全部房间划入另一个机组
+func (r *RoomRepository) ReassignZone(from, to string) error {
+	r.store.UpdateWhere(func(room *RoomInfo) bool { return room.Zone == from }, func(room *RoomInfo) {
+		room.Zone = to
+	})
+	if err := r.persist(); err != nil {
+		return fmt.Errorf("迁移机组 %s 的房间失败: %v", from, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// internal/db/room_repository_test.go
+package db
+
+import (
+	"backend/internal/types"
+	"testing"
+	"time"
+)
+
+func TestUpdateMissingRoom(t *testing.T) {
+	repo := NewRoomRepository()
+	const missing = 9999
+	now := time.Date(2024, 6, 1, 14, 0, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		update func() error
+	}{
+		{"CheckIn", func() error { return repo.CheckIn(missing, "T999", "测试住客", types.Fen(0), now) }},
+		{"CheckOut", func() error { return repo.CheckOut(missing, now) }},
+		{"PowerOnAC", func() error { return repo.PowerOnAC(missing, "cooling", 25, "medium", now) }},
+		{"PowerOffAC", func() error { return repo.PowerOffAC(missing) }},
+		{"UpdateRoom", func() error { return repo.UpdateRoom(&RoomInfo{RoomID: missing, TargetTemp: 25}) }},
+		{"UpdateRoomState", func() error { return repo.UpdateRoomState(missing, 1) }},
+		{"UpdateRoomEnvironment", func() error { return repo.UpdateRoomEnvironment(missing, 25, "medium") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.update(); err == nil {
+				t.Errorf("修改不存在的房间 %d 没有返回错误", missing)
+			}
+		})
+	}
+	if _, err := repo.GetRoomByID(missing); err == nil {
+		t.Errorf("修改后出现了房间 %d", missing)
+	}
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultFlushInterval 房间状态写回数据库的默认间隔
+const DefaultFlushInterval = 2 * time.Second
+
+// RoomStore 房间状态的内存存储
+// 启动时从数据库加载全部房间，之后的读写都在内存中进行，内存中的状态是权威的。
+// 修改过的房间记为脏数据，由后台按固定间隔在一个事务中批量写回数据库，关闭时再写回一次。
+type RoomStore struct {
+	mu    sync.RWMutex
+	db    *gorm.DB
+	rooms map[int]*RoomInfo
+	dirty map[int]bool
+
+	flushMu sync.Mutex // 保证写回按顺序进行
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var roomStore *RoomStore
+
+// GetRoomStore 获取房间状态存储，需先初始化数据库
+func GetRoomStore() *RoomStore {
+	return roomStore
+}
+
+// loadRoomStore 从数据库加载全部房间，创建房间状态存储
+func loadRoomStore(db *gorm.DB) (*RoomStore, error) {
+	var rooms []RoomInfo
+	if err := db.Find(&rooms).Error; err != nil {
+		return nil, fmt.Errorf("加载房间失败: %v", err)
+	}
+	store := &RoomStore{
+		db:    db,
+		rooms: make(map[int]*RoomInfo, len(rooms)),
+		dirty: make(map[int]bool),
+	}
+	for i := range rooms {
+		store.rooms[rooms[i].RoomID] = &rooms[i]
+	}
+	return store, nil
+}
+
+// Get 获取房间的副本
+func (s *RoomStore) Get(roomID int) (*RoomInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return nil, false
+	}
+	clone := *room
+	return &clone, true
+}
+
+// List 按房间号顺序返回满足条件的房间副本，match为nil时返回全部房间
+func (s *RoomStore) List(match func(*RoomInfo) bool) []RoomInfo {
+	s.mu.RLock()
+	rooms := make([]RoomInfo, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		if match == nil || match(room) {
+			rooms = append(rooms, *room)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].RoomID < rooms[j].RoomID
+	})
+	return rooms
+}
+
+// Update 修改房间，房间有变化时记为脏数据
+// 返回值: 房间是否存在
+func (s *RoomStore) Update(roomID int, update func(*RoomInfo)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return false
+	}
+	s.apply(room, update)
+	return true
+}
+
+// UpdateWhere 修改所有满足条件的房间
+// 返回值: 满足条件的房间数
+func (s *RoomStore) UpdateWhere(match func(*RoomInfo) bool, update func(*RoomInfo)) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, room := range s.rooms {
+		if match(room) {
+			s.apply(room, update)
+			count++
+		}
+	}
+	return count
+}
+
+// apply 执行修改并在房间有变化时记为脏数据，调用方需持有锁
+func (s *RoomStore) apply(room *RoomInfo, update func(*RoomInfo)) {
+	before := *room
+	update(room)
+	room.RoomID = before.RoomID
+	if *room != before {
+		s.dirty[room.RoomID] = true
+	}
+}
+
+// Insert 新增房间并立即写入数据库
+func (s *RoomStore) Insert(room *RoomInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.rooms[room.RoomID]; exists {
+		return fmt.Errorf("房间 %d 已存在", room.RoomID)
+	}
+	if err := s.db.Create(room).Error; err != nil {
+		return fmt.Errorf("创建房间 %d 失败: %v", room.RoomID, err)
+	}
+	clone := *room
+	s.rooms[room.RoomID] = &clone
+	return nil
+}
+
+// Flush 在一个事务中将脏数据写回数据库
+// 写回失败的房间重新记为脏数据，等待下一次写回
+// 返回值: 写回的房间数和错误信息
+func (s *RoomStore) Flush() (int, error) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	if len(s.dirty) == 0 {
+		s.mu.Unlock()
+		return 0, nil
+	}
+	rooms := make([]RoomInfo, 0, len(s.dirty))
+	for roomID := range s.dirty {
+		rooms = append(rooms, *s.rooms[roomID])
+	}
+	s.dirty = make(map[int]bool)
+	s.mu.Unlock()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rooms {
+			if err := tx.Save(&rooms[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.mu.Lock()
+		for _, room := range rooms {
+			s.dirty[room.RoomID] = true
+		}
+		s.mu.Unlock()
+		return 0, fmt.Errorf("写回房间状态失败: %v", err)
+	}
+	return len(rooms), nil
+}
+
+// StartFlusher 启动后台写回，interval 为真实时间的写回间隔
+func (s *RoomStore) StartFlusher(interval time.Duration) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Flush(); err != nil {
+					log.Printf("%v\n", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(s.stop, s.done)
+}
+
+// Close 停止后台写回并写回剩余的脏数据，应在关闭数据库连接之前调用
+func (s *RoomStore) Close() error {
+	s.flushMu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.flushMu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	_, err := s.Flush()
+	return err
+}
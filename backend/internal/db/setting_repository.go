@@ -0,0 +1,72 @@
+// internal/db/setting_repository.go
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 系统设置的键
+const (
+	SettingACConfig       = "ac_config"       // 空调配置
+	SettingCentralAC      = "central_ac"      // 中央空调开关和模式
+	SettingSchedulerState = "scheduler_state" // 调度队列快照
+	SettingZones          = "zones"           // 空调机组列表
+	SettingRoomCharge     = "room_charge"     // 房费计算规则
+)
+
+// ZoneKey 机组设置的键
+// 默认机组沿用原来的键，兼容只有一台中央空调时保存的设置；其他机组在键后加上机组编号
+func ZoneKey(key, zone string) string {
+	if zone == DefaultZone {
+		return key
+	}
+	return key + "@" + zone
+}
+
+type SettingRepository struct {
+	db *gorm.DB
+}
+
+// NewSettingRepository 创建系统设置仓库
+func NewSettingRepository() *SettingRepository {
+	return &SettingRepository{db: DB}
+}
+
+// Load 读取指定键的设置并解码到value中
+// 返回值: 设置是否存在
+func (r *SettingRepository) Load(key string, value interface{}) (bool, error) {
+	var setting SystemSetting
+	err := r.db.Where(&SystemSetting{Key: key}).First(&setting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取设置 %s 失败: %v", key, err)
+	}
+	if err := json.Unmarshal([]byte(setting.Value), value); err != nil {
+		return false, fmt.Errorf("解析设置 %s 失败: %v", key, err)
+	}
+	return true, nil
+}
+
+// Save 将设置编码为JSON后保存，已存在时覆盖
+func (r *SettingRepository) Save(key string, value interface{}, now time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("编码设置 %s 失败: %v", key, err)
+	}
+	setting := SystemSetting{
+		Key:     key,
+		Value:   string(data),
+		SavedAt: now,
+	}
+	if err := r.db.Save(&setting).Error; err != nil {
+		return fmt.Errorf("保存设置 %s 失败: %v", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// internal/db/tariff_repository.go
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TariffRepository struct {
+	db *gorm.DB
+}
+
+// NewTariffRepository 创建费率表仓库
+func NewTariffRepository() *TariffRepository {
+	return &TariffRepository{db: DB}
+}
+
+// Create 追加一条费率记录
+func (r *TariffRepository) Create(tariff *Tariff) error {
+	if err := r.db.Create(tariff).Error; err != nil {
+		return fmt.Errorf("保存费率失败: %v", err)
+	}
+	return nil
+}
+
+// RateAt 获取机组某个风速在t时刻生效的费率记录
+// 同一时刻生效的多条记录以最后追加的为准
+// 返回值: 费率记录，t时刻之前没有生效的费率时为nil
+func (r *TariffRepository) RateAt(zone, speed string, t time.Time) (*Tariff, error) {
+	var tariff Tariff
+	err := r.db.Where("zone = ? AND speed = ? AND effective_from <= ?", zone, speed, t).
+		Order("effective_from DESC, id DESC").
+		First(&tariff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取费率失败: %v", err)
+	}
+	return &tariff, nil
+}
+
+// ListByZone 按生效时间顺序获取机组的费率记录，zone为空时获取所有机组的记录
+func (r *TariffRepository) ListByZone(zone string) ([]Tariff, error) {
+	var tariffs []Tariff
+	query := r.db.Order("effective_from ASC, id ASC")
+	if zone != "" {
+		query = query.Where("zone = ?", zone)
+	}
+	if err := query.Find(&tariffs).Error; err != nil {
+		return nil, fmt.Errorf("获取费率记录失败: %v", err)
+	}
+	return tariffs, nil
+}
+
+// CreateSchedule 追加一条分时电价表记录
+func (r *TariffRepository) CreateSchedule(schedule *TariffSchedule) error {
+	if err := r.db.Create(schedule).Error; err != nil {
+		return fmt.Errorf("保存分时电价表失败: %v", err)
+	}
+	return nil
+}
+
+// ScheduleAt 获取机组在t时刻生效的分时电价表记录
+// 返回值: 分时电价表记录，t时刻之前没有记录时为nil
+func (r *TariffRepository) ScheduleAt(zone string, t time.Time) (*TariffSchedule, error) {
+	var schedule TariffSchedule
+	err := r.db.Where("zone = ? AND effective_from <= ?", zone, t).
+		Order("effective_from DESC, id DESC").
+		First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取分时电价表失败: %v", err)
+	}
+	return &schedule, nil
+}
@@ -0,0 +1,86 @@
+// internal/db/timer_repository.go
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activeTimerStatus 未结束的定时任务状态
+var activeTimerStatus = []TimerStatus{TimerPending, TimerRunning}
+
+type TimerRepository struct {
+	db *gorm.DB
+}
+
+// NewTimerRepository 创建房间定时任务仓库
+func NewTimerRepository() *TimerRepository {
+	return &TimerRepository{db: DB}
+}
+
+// Create 新增定时任务
+func (r *TimerRepository) Create(timer *RoomTimer) error {
+	if err := r.db.Create(timer).Error; err != nil {
+		return fmt.Errorf("创建定时任务失败: %v", err)
+	}
+	return nil
+}
+
+// Update 保存未结束的定时任务
+// 任务在保存前已被取消或结束时不覆盖，返回值表示是否保存成功
+func (r *TimerRepository) Update(timer *RoomTimer) (bool, error) {
+	result := r.db.Model(timer).
+		Where("status IN ?", activeTimerStatus).
+		Select("*").
+		Updates(timer)
+	if result.Error != nil {
+		return false, fmt.Errorf("保存定时任务失败: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetByID 获取定时任务
+func (r *TimerRepository) GetByID(id int) (*RoomTimer, error) {
+	var timer RoomTimer
+	if err := r.db.First(&timer, id).Error; err != nil {
+		return nil, err
+	}
+	return &timer, nil
+}
+
+// ListActive 按触发时间顺序获取未结束的定时任务
+func (r *TimerRepository) ListActive() ([]RoomTimer, error) {
+	var timers []RoomTimer
+	err := r.db.Where("status IN ?", activeTimerStatus).
+		Order("fire_time ASC, id ASC").
+		Find(&timers).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取定时任务失败: %v", err)
+	}
+	return timers, nil
+}
+
+// ListByRoom 获取房间的定时任务，按创建时间倒序
+func (r *TimerRepository) ListByRoom(roomID int) ([]RoomTimer, error) {
+	var timers []RoomTimer
+	err := r.db.Where("room_id = ?", roomID).
+		Order("created_time DESC, id DESC").
+		Find(&timers).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取定时任务失败: %v", err)
+	}
+	return timers, nil
+}
+
+// CancelByRoom 取消房间所有未结束的定时任务
+func (r *TimerRepository) CancelByRoom(roomID int, note string, now time.Time) error {
+	err := r.db.Model(&RoomTimer{}).
+		Where("room_id = ? AND status IN ?", roomID, activeTimerStatus).
+		Updates(map[string]interface{}{"status": TimerCancelled, "note": note, "ended_at": now}).Error
+	if err != nil {
+		return fmt.Errorf("取消定时任务失败: %v", err)
+	}
+	return nil
+}
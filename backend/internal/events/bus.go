@@ -0,0 +1,284 @@
+// internal/events/bus.go
+// Package events 提供进程内的领域事件总线
+// 调度器和空调服务发布房间生命周期事件，计费、监控等模块订阅后各自处理，
+// 发布方不再直接调用这些模块。
+package events
+
+import (
+	"backend/internal/logger"
+	"backend/internal/types"
+	"sync"
+	"time"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	ServiceStarted   Type = "service_started"    // 房间进入服务队列
+	Preempted        Type = "preempted"          // 被更高优先级请求抢占或因容量减小被降级
+	TimeSliceExpired Type = "time_slice_expired" // 时间片到期被轮换出服务队列
+	TargetReached    Type = "target_reached"     // 达到目标温度，结束服务
+	ServiceStopped   Type = "service_stopped"    // 关机、退房或清空队列导致服务结束
+	SpeedChanged     Type = "speed_changed"      // 服务中或等待中调整风速
+	Waiting          Type = "waiting"            // 房间进入等待队列
+	PoweredOn        Type = "powered_on"         // 房间空调开机
+	PoweredOff       Type = "powered_off"        // 房间空调关机
+	CheckedIn        Type = "checked_in"         // 入住
+	CheckedOut       Type = "checked_out"        // 退房
+	ConfigChanged    Type = "config_changed"     // 空调配置变更
+	StateChanged     Type = "state_changed"      // 房间空调的运行状态变化
+	PreconditionOn   Type = "precondition_on"    // 入住前预调温开机
+	PreconditionOff  Type = "precondition_off"   // 入住前预调温结束
+	LoadShed         Type = "load_shed"          // 限负荷模式停止送风，也作为限负荷降低风速的原因
+	RateChanged      Type = "rate_changed"       // 费率调整，作为服务段按新费率重新开始的原因
+)
+
+// Event 领域事件
+// 服务类事件中的风速、温度和开始时间描述的是事件发生前的服务段
+type Event struct {
+	Type        Type
+	RoomID      int         // 房间号，配置类事件为0
+	Speed       types.Speed // 风速
+	Mode        types.Mode  // 服务段的工作模式
+	ModeRate    float32     // 服务段工作模式的费率系数
+	TargetTemp  float32     // 目标温度
+	CurrentTemp float32     // 当前温度
+	StartTime   time.Time   // 服务段的开始时间
+	Time        time.Time   // 事件发生时间(系统时间)
+	Fee         types.Money // 费用，退房和预调温结束事件使用
+	// PreconditionID 房间正在执行的预调温计划，0表示房间不在预调温
+	PreconditionID int
+
+	// 运行状态变化事件使用
+	From   types.RunState // 变化前的运行状态
+	To     types.RunState // 变化后的运行状态
+	Reason Type           // 引起状态变化的调度事件，限负荷降低风速的风速调整事件为 LoadShed，费率调整切分服务段时为 RateChanged
+}
+
+const (
+	// MailboxCapacity 尽力而为的订阅者邮箱最多积压的事件数，邮箱满时丢弃新事件并记录错误
+	MailboxCapacity = 10000
+	// BacklogWarnThreshold 邮箱积压达到该数量时记录警告，积压回落到一半以下后重新报警
+	BacklogWarnThreshold = 1000
+)
+
+// Handler 事件处理函数
+type Handler func(Event)
+
+// Bus 事件总线
+// 每个订阅者拥有独立的有界邮箱和处理goroutine，发布永远不会阻塞，
+// 因此可以在持有调度器锁时发布事件；同一订阅者按发布顺序依次处理事件。
+// 订阅者处理过慢时积压达到 BacklogWarnThreshold 记录警告。Subscribe 创建的订阅者是尽力而为的，
+// 积压达到 MailboxCapacity 后丢弃新事件并计数；SubscribeLossless 创建的订阅者邮箱不设上限，永不丢弃事件。
+// 积压情况通过 Stats 提供给监控。
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []*Subscription
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 订阅事件，eventTypes为空时订阅全部事件
+// 邮箱容量为 MailboxCapacity，积压过多时丢弃新事件，用于监控等允许丢失事件的订阅者
+func (b *Bus) Subscribe(name string, handler Handler, eventTypes ...Type) *Subscription {
+	return b.subscribe(name, handler, MailboxCapacity, eventTypes)
+}
+
+// SubscribeLossless 订阅事件，邮箱不设上限，积压时只记录警告而不丢弃事件
+// 用于计费等丢失事件就会少记费用的订阅者；发布仍然不会阻塞
+func (b *Bus) SubscribeLossless(name string, handler Handler, eventTypes ...Type) *Subscription {
+	return b.subscribe(name, handler, 0, eventTypes)
+}
+
+// subscribe 创建订阅者，capacity为邮箱容量，0表示不设上限
+func (b *Bus) subscribe(name string, handler Handler, capacity int, eventTypes []Type) *Subscription {
+	sub := &Subscription{
+		name:     name,
+		handler:  handler,
+		capacity: capacity,
+		done:     make(chan struct{}),
+	}
+	if len(eventTypes) > 0 {
+		sub.filter = make(map[Type]bool, len(eventTypes))
+		for _, t := range eventTypes {
+			sub.filter[t] = true
+		}
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+	go sub.run()
+
+	b.mu.Lock()
+	b.subscriptions = append(b.subscriptions, sub)
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish 发布事件，只把事件放入各订阅者的邮箱，不等待处理
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subscriptions {
+		sub.deliver(event)
+	}
+}
+
+// SubscriptionStats 订阅者邮箱的积压情况
+type SubscriptionStats struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"` // 邮箱容量，0表示不设上限
+	Backlog  int    `json:"backlog"`  // 邮箱中尚未处理的事件数
+	Handled  uint64 `json:"handled"`  // 已处理的事件数
+	Dropped  uint64 `json:"dropped"`  // 邮箱已满而丢弃的事件数
+}
+
+// Stats 各订阅者邮箱的积压情况，按订阅顺序排列
+func (b *Bus) Stats() []SubscriptionStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := make([]SubscriptionStats, 0, len(b.subscriptions))
+	for _, sub := range b.subscriptions {
+		stats = append(stats, sub.Stats())
+	}
+	return stats
+}
+
+// Flush 等待所有订阅者处理完此前发布的事件
+// 不能在订阅者的处理函数中调用
+func (b *Bus) Flush() {
+	b.mu.RLock()
+	subscriptions := append([]*Subscription(nil), b.subscriptions...)
+	b.mu.RUnlock()
+	for _, sub := range subscriptions {
+		sub.Flush()
+	}
+}
+
+// Close 处理完邮箱中剩余的事件后停止所有订阅者
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subscriptions := b.subscriptions
+	b.subscriptions = nil
+	b.mu.Unlock()
+	for _, sub := range subscriptions {
+		sub.Close()
+	}
+}
+
+// Subscription 一个订阅者
+type Subscription struct {
+	name     string
+	handler  Handler
+	filter   map[Type]bool
+	capacity int // 邮箱容量，0表示不设上限
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []Event // 邮箱
+	received uint64  // 已投递的事件数
+	handled  uint64  // 已处理的事件数
+	dropped  uint64  // 邮箱已满而丢弃的事件数
+	warned   bool    // 积压已超过警告阈值且尚未回落
+	closed   bool
+	done     chan struct{}
+}
+
+func (s *Subscription) deliver(event Event) {
+	if s.filter != nil && !s.filter[event.Type] {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.capacity > 0 && len(s.queue) >= s.capacity {
+		s.dropped++
+		if s.dropped == 1 || s.dropped%BacklogWarnThreshold == 0 {
+			logger.Error("事件订阅者 %s 的邮箱已满(%d)，丢弃 %s 事件，累计丢弃 %d 个", s.name, s.capacity, event.Type, s.dropped)
+		}
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.received++
+	if len(s.queue) >= BacklogWarnThreshold && !s.warned {
+		s.warned = true
+		logger.Warn("事件订阅者 %s 处理过慢，邮箱积压 %d 个事件", s.name, len(s.queue))
+	}
+	s.cond.Broadcast()
+}
+
+// Stats 该订阅者邮箱的积压情况
+func (s *Subscription) Stats() SubscriptionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SubscriptionStats{
+		Name:     s.name,
+		Capacity: s.capacity,
+		Backlog:  len(s.queue),
+		Handled:  s.handled,
+		Dropped:  s.dropped,
+	}
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue[0] = Event{}
+		s.queue = s.queue[1:]
+		if s.warned && len(s.queue) < BacklogWarnThreshold/2 {
+			s.warned = false
+			logger.Info("事件订阅者 %s 的积压已回落到 %d 个事件", s.name, len(s.queue))
+		}
+		s.mu.Unlock()
+
+		s.handle(event)
+
+		s.mu.Lock()
+		s.handled++
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// handle 执行处理函数，处理函数panic不会影响后续事件
+func (s *Subscription) handle(event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("事件订阅者 %s 处理 %s 事件失败: %v", s.name, event.Type, r)
+		}
+	}()
+	s.handler(event)
+}
+
+// Flush 等待该订阅者处理完此前投递的事件
+// 不能在该订阅者自身的处理函数中调用
+func (s *Subscription) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	target := s.received
+	for s.handled < target {
+		s.cond.Wait()
+	}
+}
+
+// Close 处理完邮箱中剩余的事件后停止该订阅者
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	<-s.done
+}
@@ -4,12 +4,15 @@ package handlers
 
 import (
 	"backend/internal/db"
+	"backend/internal/events"
 	"backend/internal/logger"
 	"backend/internal/service"
+	"backend/internal/thermal"
 	"backend/internal/types"
 	"fmt"
 	"math"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,6 +38,7 @@ type RoomStatusResponse struct {
 	CurrentCost        float32 `json:"currentCost"`
 	TotalCost          float32 `json:"totalCost"`
 	CurrentTemperature float32 `json:"currentTemperature"`
+	RunState           string  `json:"runState"` // 运行状态 off/waiting/serving/standby
 }
 
 // PowerOnResponse 响应PowerOn请求结构
@@ -55,7 +59,28 @@ type PowerOffResponse struct {
 
 // 设置空调模式
 type SetModeRequest struct {
-	Mode string `json:"mode" binding:"required"` // cooling/heating
+	Mode string `json:"mode" binding:"required"` // cooling/heating/auto/fan/dry
+}
+
+// modeNames 工作模式的中文名称
+var modeNames = map[string]types.Mode{
+	"制冷": types.ModeCooling,
+	"制热": types.ModeHeating,
+	"自动": types.ModeAuto,
+	"送风": types.ModeFan,
+	"除湿": types.ModeDry,
+}
+
+// invalidModeMsg 工作模式无效时的提示
+const invalidModeMsg = "无效的运行模式，只能是 cooling/heating/auto/fan/dry(制冷/制热/自动/送风/除湿)"
+
+// parseMode 解析工作模式，支持中文名称和英文取值
+func parseMode(name string) (types.Mode, bool) {
+	if mode, ok := modeNames[name]; ok {
+		return mode, true
+	}
+	mode := types.Mode(name)
+	return mode, mode.Valid()
 }
 
 // 温度调节请求
@@ -95,6 +120,7 @@ type AdminPowerOnRequest struct {
 	MediumSpeedRate          float32 `json:"mediumSpeedRate" binding:"required"`
 	HighSpeedRate            float32 `json:"highSpeedRate" binding:"required"`
 	DefaultTargetTemperature float32 `json:"defaultTargetTemperature" binding:"required"`
+	Zone                     string  `json:"zone"` // 机组编号，不传时为默认机组
 }
 
 // AdminPowerOn 处理管理员开启中央空调的请求
@@ -109,15 +135,10 @@ func (h *ACHandler) AdminPowerOn(c *gin.Context) {
 	}
 
 	// 验证模式
-	var mode types.Mode
-	switch req.OperationMode {
-	case "制冷":
-		mode = types.ModeCooling
-	case "制热":
-		mode = types.ModeHeating
-	default:
+	mode, ok := parseMode(req.OperationMode)
+	if !ok {
 		c.JSON(http.StatusBadRequest, Response{
-			Msg: "无效的运行模式，只能是 'cooling' 或 'heating'",
+			Msg: invalidModeMsg,
 		})
 		return
 	}
@@ -154,25 +175,23 @@ func (h *ACHandler) AdminPowerOn(c *gin.Context) {
 		return
 	}
 
-	// 准备新的配置
-	config := types.Config{
-		DefaultTemp:  req.DefaultTargetTemperature,
-		DefaultSpeed: types.SpeedMedium,
-		TempRanges: map[types.Mode]types.TempRange{
-			mode: {
-				Min: req.MinTemperature,
-				Max: req.MaxTemperature,
-			},
-		},
-		Rates: map[types.Speed]float32{
+	// 设置配置，调度参数和热模型沿用当前配置
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.DefaultTemp = req.DefaultTargetTemperature
+		config.DefaultSpeed = types.SpeedMedium
+		// 只修改所选模式的温度范围，其余模式沿用当前配置，便于之后切换模式
+		config.TempRanges[mode] = types.TempRange{
+			Min: req.MinTemperature,
+			Max: req.MaxTemperature,
+		}
+		config.Rates = map[types.Speed]float32{
 			types.SpeedLow:    req.LowSpeedRate,
 			types.SpeedMedium: req.MediumSpeedRate,
 			types.SpeedHigh:   req.HighSpeedRate,
-		},
-	}
-
-	// 设置配置
-	if err := h.acService.SetConfig(config); err != nil {
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "设置空调配置失败",
 			Err: err.Error(),
@@ -181,7 +200,7 @@ func (h *ACHandler) AdminPowerOn(c *gin.Context) {
 	}
 
 	// 启动中央空调
-	if err := h.acService.StartCentralAC(mode); err != nil {
+	if err := h.acService.StartCentralAC(req.Zone, mode); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "启动中央空调失败",
 			Err: err.Error(),
@@ -197,8 +216,17 @@ func (h *ACHandler) AdminPowerOn(c *gin.Context) {
 
 // AdminPowerOff 管理员关闭中央空调
 func (h *ACHandler) AdminPowerOff(c *gin.Context) {
+	var req ZoneRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
 	// 关闭中央空调
-	if err := h.acService.StopCentralAC(); err != nil {
+	if err := h.acService.StopCentralAC(req.Zone); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "关闭中央空调失败",
 			Err: err.Error(),
@@ -220,6 +248,7 @@ type PanelPowerOnResponse struct {
 	OperationMode      string  `json:"operationMode"`
 	TargetTemperature  int64   `json:"targetTemperature"`
 	TotalCost          float64 `json:"totalCost"`
+	RunState           string  `json:"runState"` // 运行状态 off/waiting/serving/standby
 }
 
 type PanelPowerOffResponse struct {
@@ -268,7 +297,7 @@ func (h *ACHandler) PanelPowerOn(c *gin.Context) {
 	}
 
 	billingService := service.GetBillingService()
-	var currentFee, totalFee float32 = 0, 0
+	currentFee, totalFee := types.Fen(0), types.Fen(0)
 	if billingService != nil {
 		// 使用新的独立方法获取费用
 		currentFee, err = billingService.CalculateCurrentSessionFee(room.RoomID)
@@ -284,12 +313,13 @@ func (h *ACHandler) PanelPowerOn(c *gin.Context) {
 
 	// 构建响应
 	response := PanelPowerOnResponse{
-		CurrentCost:        float64(currentFee),
+		CurrentCost:        currentFee.Yuan(),
 		CurrentFanSpeed:    string(status.CurrentSpeed),
 		CurrentTemperature: float64(status.CurrentTemp),
 		OperationMode:      string(status.Mode),
 		TargetTemperature:  int64(status.TargetTemp),
-		TotalCost:          float64(totalFee),
+		TotalCost:          totalFee.Yuan(),
+		RunState:           string(status.RunState),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -317,7 +347,7 @@ func (h *ACHandler) PanelPowerOff(c *gin.Context) {
 	}
 
 	billingService := service.GetBillingService()
-	var currentFee, totalFee float32 = 0, 0
+	currentFee, totalFee := types.Fen(0), types.Fen(0)
 	if billingService != nil {
 		// 在关机前获取最终费用
 		currentFee, err = billingService.CalculateCurrentSessionFee(room.RoomID)
@@ -342,8 +372,8 @@ func (h *ACHandler) PanelPowerOff(c *gin.Context) {
 
 	// 构建响应
 	response := PanelPowerOffResponse{
-		CurrentCost: float64(currentFee),
-		TotalCost:   float64(totalFee),
+		CurrentCost: currentFee.Yuan(),
+		TotalCost:   totalFee.Yuan(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -494,7 +524,7 @@ func (h *ACHandler) PanelRequestStatus(c *gin.Context) {
 
 	// 获取账单服务
 	billingService := service.GetBillingService()
-	var currentFee, totalFee float32 = 0, 0
+	currentFee, totalFee := types.Fen(0), types.Fen(0)
 	if billingService != nil && room.ACState == 1 {
 		// 获取当前费用
 		currentFee, err = billingService.CalculateCurrentSessionFee(room.RoomID)
@@ -510,9 +540,10 @@ func (h *ACHandler) PanelRequestStatus(c *gin.Context) {
 	}
 
 	response := RoomStatusResponse{
-		CurrentCost:        float32(currentFee),
+		CurrentCost:        float32(currentFee.Yuan()),
 		CurrentTemperature: float32(math.Round(float64(room.CurrentTemp)*100) / 100),
-		TotalCost:          float32(totalFee),
+		TotalCost:          float32(totalFee.Yuan()),
+		RunState:           string(service.RoomRunState(room)),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -534,6 +565,8 @@ type AllStateResponse struct {
 	OperationMode      string  `json:"operationMode"`
 	TargetTemperature  float64 `json:"targetTemperature"`
 	TotalCost          float64 `json:"totalCost"`
+	Humidity           float64 `json:"humidity"` // 当前相对湿度(%)
+	RunState           string  `json:"runState"` // 运行状态 off/waiting/serving/standby
 }
 
 // PanelRequestAllState 处理查询所有状态的请求
@@ -559,7 +592,7 @@ func (h *ACHandler) PanelRequestAllState(c *gin.Context) {
 
 	// 获取账单服务
 	billingService := service.GetBillingService()
-	var currentFee, totalFee float32 = 0, 0
+	currentFee, totalFee := types.Fen(0), types.Fen(0)
 	if billingService != nil && room.ACState == 1 {
 		// 获取当前费用
 		currentFee, err = billingService.CalculateCurrentSessionFee(room.RoomID)
@@ -588,12 +621,14 @@ func (h *ACHandler) PanelRequestAllState(c *gin.Context) {
 	}
 	response := AllStateResponse{
 		ACState:            room.ACState == 1,
-		CurrentCost:        float64(currentFee),
+		CurrentCost:        currentFee.Yuan(),
 		CurrentFanSpeed:    fanSpeed,
 		CurrentTemperature: math.Round(float64(room.CurrentTemp)*100) / 100,
 		OperationMode:      room.Mode,
 		TargetTemperature:  math.Round(float64(room.TargetTemp)*100) / 100,
-		TotalCost:          float64(totalFee),
+		TotalCost:          totalFee.Yuan(),
+		Humidity:           math.Round(float64(room.Humidity)*10) / 10,
+		RunState:           string(service.RoomRunState(room)),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -602,6 +637,7 @@ func (h *ACHandler) PanelRequestAllState(c *gin.Context) {
 // AdminChangeModeRequest 修改中央空调模式的请求结构
 type AdminChangeModeRequest struct {
 	OperationMode string `json:"operationMode" binding:"required"`
+	Zone          string `json:"zone"` // 机组编号，不传时为默认机组
 }
 
 // AdminChangeMode 处理管理员更改中央空调模式的请求
@@ -616,21 +652,16 @@ func (h *ACHandler) AdminChangeMode(c *gin.Context) {
 	}
 
 	// 验证模式是否合法
-	var mode types.Mode
-	switch req.OperationMode {
-	case "cooling":
-		mode = types.ModeCooling
-	case "heating":
-		mode = types.ModeHeating
-	default:
+	mode, ok := parseMode(req.OperationMode)
+	if !ok {
 		c.JSON(http.StatusBadRequest, Response{
-			Msg: "无效的运行模式，只能是 'cooling' 或 'heating'",
+			Msg: invalidModeMsg,
 		})
 		return
 	}
 
 	// 设置中央空调模式
-	if err := h.acService.SetCentralACMode(mode); err != nil {
+	if err := h.acService.SetCentralACMode(req.Zone, mode); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "更改中央空调模式失败",
 			Err: err.Error(),
@@ -648,6 +679,7 @@ func (h *ACHandler) AdminChangeMode(c *gin.Context) {
 type AdminChangeTempRangeRequest struct {
 	MinTemperature float32 `json:"minTemperature" binding:"required"`
 	MaxTemperature float32 `json:"maxTemperature" binding:"required"`
+	Zone           string  `json:"zone"` // 机组编号，不传时为默认机组
 }
 
 // AdminChangeTempRange 处理管理员更改温度范围的请求
@@ -669,11 +701,8 @@ func (h *ACHandler) AdminChangeTempRange(c *gin.Context) {
 		return
 	}
 
-	// 获取当前的空调配置
-	config := h.acService.GetConfig()
-
 	// 获取当前空调状态
-	isOn, mode := h.acService.GetCentralACState()
+	isOn, mode, _ := h.acService.GetCentralACState(req.Zone)
 	if !isOn {
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "中央空调未开启",
@@ -682,13 +711,14 @@ func (h *ACHandler) AdminChangeTempRange(c *gin.Context) {
 	}
 
 	// 更新当前模式的温度范围
-	config.TempRanges[mode] = types.TempRange{
-		Min: req.MinTemperature,
-		Max: req.MaxTemperature,
-	}
-
-	// 设置新的配置
-	if err := h.acService.SetConfig(config); err != nil {
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.TempRanges[mode] = types.TempRange{
+			Min: req.MinTemperature,
+			Max: req.MaxTemperature,
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "设置温度范围失败",
 			Err: err.Error(),
@@ -707,9 +737,11 @@ type AdminChangeRateRequest struct {
 	LowSpeedRate    float32 `json:"lowSpeedRate" binding:"required"`
 	MediumSpeedRate float32 `json:"mediumSpeedRate" binding:"required"`
 	HighSpeedRate   float32 `json:"highSpeedRate" binding:"required"`
+	Zone            string  `json:"zone"` // 机组编号，不传时为默认机组
 }
 
 // AdminChangeRate 处理管理员更改费率的请求
+// 新的费率追加到费率表并立即生效，服务中的房间在调整时刻结束当前服务段，之后按新费率计费
 func (h *ACHandler) AdminChangeRate(c *gin.Context) {
 	var req AdminChangeRateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -736,18 +768,16 @@ func (h *ACHandler) AdminChangeRate(c *gin.Context) {
 		return
 	}
 
-	// 获取当前配置
-	config := h.acService.GetConfig()
-
-	// 更新费率
-	config.Rates = map[types.Speed]float32{
-		types.SpeedLow:    req.LowSpeedRate,
-		types.SpeedMedium: req.MediumSpeedRate,
-		types.SpeedHigh:   req.HighSpeedRate,
-	}
-
-	// 设置新的配置
-	if err := h.acService.SetConfig(config); err != nil {
+	// 更新费率，其余配置沿用当前配置
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.Rates = map[types.Speed]float32{
+			types.SpeedLow:    req.LowSpeedRate,
+			types.SpeedMedium: req.MediumSpeedRate,
+			types.SpeedHigh:   req.HighSpeedRate,
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "设置费率失败",
 			Err: err.Error(),
@@ -756,59 +786,72 @@ func (h *ACHandler) AdminChangeRate(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, Response{
-		Msg: fmt.Sprintf("费率已更新 - 低速: %.2f 元/度, 中速: %.2f 元/度, 高速: %.2f 元/度",
+		Msg: fmt.Sprintf("费率已更新 - 低速: %.2f 元/分钟, 中速: %.2f 元/分钟, 高速: %.2f 元/分钟",
 			req.LowSpeedRate, req.MediumSpeedRate, req.HighSpeedRate),
 	})
 }
 
-// AdminAllStateResponse 管理员获取所有状态的响应结构
-type AdminAllStateResponse struct {
-	ACState                  bool    `json:"acState"`
-	DefaultTargetTemperature float64 `json:"defaultTargetTemperature"`
-	HighSpeedRate            float64 `json:"highSpeedRate"`
-	LowSpeedRate             float64 `json:"lowSpeedRate"`
-	MaxTemperature           int64   `json:"maxTemperature"`
-	MediumSpeedRate          float64 `json:"mediumSpeedRate"`
-	MinTemperature           int64   `json:"minTemperature"`
-	OperationMode            string  `json:"operationMode"`
+type AdminChangeEnergyRatesRequest struct {
+	LowSpeedEnergy    float32 `json:"lowSpeedEnergy" binding:"required"`
+	MediumSpeedEnergy float32 `json:"mediumSpeedEnergy" binding:"required"`
+	HighSpeedEnergy   float32 `json:"highSpeedEnergy" binding:"required"`
+	Zone              string  `json:"zone"` // 机组编号，不传时为默认机组
 }
 
-// AdminRequestAllState 处理管理员获取所有状态的请求
-func (h *ACHandler) AdminRequestAllState(c *gin.Context) {
-
-	// 获取中央空调状态和模式
-	isOn, mode := h.acService.GetCentralACState()
-
-	// 获取当前配置
-	config := h.acService.GetConfig()
+// AdminChangeEnergyRates 处理管理员更改各风速耗电量的请求
+// 耗电量只用于电量计量，不影响费率；之后结束的计费时段按新的耗电量计量
+func (h *ACHandler) AdminChangeEnergyRates(c *gin.Context) {
+	var req AdminChangeEnergyRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
 
-	// 获取当前模式的温度范围
-	tempRange := config.TempRanges[mode]
+	if req.LowSpeedEnergy <= 0 || req.MediumSpeedEnergy <= 0 || req.HighSpeedEnergy <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "耗电量必须大于0",
+		})
+		return
+	}
 
-	// 构建响应
-	response := AdminAllStateResponse{
-		ACState:                  isOn,
-		DefaultTargetTemperature: float64(config.DefaultTemp),
-		HighSpeedRate:            float64(config.Rates[types.SpeedHigh]),
-		LowSpeedRate:             float64(config.Rates[types.SpeedLow]),
-		MaxTemperature:           int64(tempRange.Max),
-		MediumSpeedRate:          float64(config.Rates[types.SpeedMedium]),
-		MinTemperature:           int64(tempRange.Min),
-		OperationMode:            string(mode),
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.EnergyRates = map[types.Speed]float32{
+			types.SpeedLow:    req.LowSpeedEnergy,
+			types.SpeedMedium: req.MediumSpeedEnergy,
+			types.SpeedHigh:   req.HighSpeedEnergy,
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "设置耗电量失败",
+			Err: err.Error(),
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("耗电量已更新 - 低速: %.4f 度/分钟, 中速: %.4f 度/分钟, 高速: %.4f 度/分钟",
+			req.LowSpeedEnergy, req.MediumSpeedEnergy, req.HighSpeedEnergy),
+	})
 }
 
-// AdminChangeDefaultTempRequest 修改默认温度的请求结构
-type AdminChangeDefaultTempRequest struct {
-	DefaultTargetTemperature int64 `json:"defaultTargetTemperature" binding:"required"`
+// TariffResponse 费率记录
+type TariffResponse struct {
+	Zone          string  `json:"zone"`
+	Speed         string  `json:"speed"`
+	Rate          float64 `json:"rate"`          // 费率(元/分钟)
+	EffectiveFrom string  `json:"effectiveFrom"` // 生效时间，机组的初始费率为空
+	CreatedTime   string  `json:"createdTime"`
 }
 
-// AdminChangeDefaultTemp 处理管理员更改默认温度的请求
-func (h *ACHandler) AdminChangeDefaultTemp(c *gin.Context) {
-	var req AdminChangeDefaultTempRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// AdminTariffs 查询机组的费率记录，按生效时间排序；不传机组时返回所有机组的记录
+func (h *ACHandler) AdminTariffs(c *gin.Context) {
+	var req ZoneRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "无效的请求格式",
 			Err: err.Error(),
@@ -816,50 +859,167 @@ func (h *ACHandler) AdminChangeDefaultTemp(c *gin.Context) {
 		return
 	}
 
-	// 获取当前空调状态和配置
-	isOn, mode := h.acService.GetCentralACState()
-	if !isOn {
-		c.JSON(http.StatusBadRequest, Response{
-			Msg: "中央空调未开启",
+	tariffs, err := h.acService.GetTariffs(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取费率记录失败",
+			Err: err.Error(),
 		})
 		return
 	}
 
-	config := h.acService.GetConfig()
-	tempRange := config.TempRanges[mode]
+	response := make([]TariffResponse, 0, len(tariffs))
+	for _, tariff := range tariffs {
+		response = append(response, TariffResponse{
+			Zone:          tariff.Zone,
+			Speed:         tariff.Speed,
+			Rate:          float64(tariff.Rate),
+			EffectiveFrom: formatTime(tariff.EffectiveFrom),
+			CreatedTime:   formatTime(tariff.CreatedTime),
+		})
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取费率记录成功",
+		Data: response,
+	})
+}
+
+// TimeBandSetting 分时电价的一个时段
+type TimeBandSetting struct {
+	Name       string             `json:"name" binding:"required"`  // 时段名称，如峰、平、谷
+	Start      string             `json:"start" binding:"required"` // 开始时刻 HH:MM
+	End        string             `json:"end" binding:"required"`   // 结束时刻 HH:MM(不含)，可以为 24:00
+	Multiplier float32            `json:"multiplier"`               // 风速费率的系数，不传时为1
+	Rates      map[string]float32 `json:"rates"`                    // 各风速(low/medium/high)的费率(元/分钟)，优先于系数
+}
+
+// TimeOfUseSetting 分时电价表
+type TimeOfUseSetting struct {
+	Days     map[string][]TimeBandSetting `json:"days"`     // 键为 mon~sun，default 用于未单独设置的日子
+	Holidays map[string][]TimeBandSetting `json:"holidays"` // 键为节假日日期 2006-01-02，覆盖当天的时段
+}
+
+// AdminChangeTimeOfUseRequest 设置分时电价表的请求结构，days 和 holidays 都为空时取消分时电价
+type AdminChangeTimeOfUseRequest struct {
+	TimeOfUseSetting
+	Zone string `json:"zone"` // 机组编号，不传时为默认机组
+}
+
+// toTimeBands 转换一天的时段设置
+func toTimeBands(settings []TimeBandSetting) []types.TimeBand {
+	bands := make([]types.TimeBand, 0, len(settings))
+	for _, setting := range settings {
+		band := types.TimeBand{
+			Name:       setting.Name,
+			Start:      setting.Start,
+			End:        setting.End,
+			Multiplier: setting.Multiplier,
+			Rates:      make(map[types.Speed]float32, len(setting.Rates)),
+		}
+		for speed, rate := range setting.Rates {
+			band.Rates[types.Speed(speed)] = rate
+		}
+		bands = append(bands, band)
+	}
+	return bands
+}
 
-	// 检查温度是否在当前模式的范围内
-	if float32(req.DefaultTargetTemperature) < tempRange.Min ||
-		float32(req.DefaultTargetTemperature) > tempRange.Max {
+// newTimeBandSettings 将一天的时段转换为响应中的设置
+func newTimeBandSettings(bands []types.TimeBand) []TimeBandSetting {
+	settings := make([]TimeBandSetting, 0, len(bands))
+	for _, band := range bands {
+		setting := TimeBandSetting{
+			Name:       band.Name,
+			Start:      band.Start,
+			End:        band.End,
+			Multiplier: band.Multiplier,
+			Rates:      make(map[string]float32, len(band.Rates)),
+		}
+		for speed, rate := range band.Rates {
+			setting.Rates[string(speed)] = rate
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// newTimeOfUseSetting 将分时电价表转换为响应中的设置，未设置时为nil
+func newTimeOfUseSetting(tou *types.TimeOfUse) *TimeOfUseSetting {
+	if tou == nil {
+		return nil
+	}
+	setting := &TimeOfUseSetting{
+		Days:     make(map[string][]TimeBandSetting, len(tou.Days)),
+		Holidays: make(map[string][]TimeBandSetting, len(tou.Holidays)),
+	}
+	for key, bands := range tou.Days {
+		setting.Days[key] = newTimeBandSettings(bands)
+	}
+	for date, bands := range tou.Holidays {
+		setting.Holidays[date] = newTimeBandSettings(bands)
+	}
+	return setting
+}
+
+// AdminChangeTimeOfUse 处理管理员设置分时电价表的请求
+// 新的分时电价表立即生效，服务中的房间在设置时刻结束当前服务段，之后按新的时段计费
+func (h *ACHandler) AdminChangeTimeOfUse(c *gin.Context) {
+	var req AdminChangeTimeOfUseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
-			Msg: fmt.Sprintf("默认温度必须在 %.1f°C - %.1f°C 范围内", tempRange.Min, tempRange.Max),
+			Msg: "无效的请求格式",
+			Err: err.Error(),
 		})
 		return
 	}
 
-	// 更新配置中的默认温度
-	config.DefaultTemp = float32(req.DefaultTargetTemperature)
-	if err := h.acService.SetConfig(config); err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "设置默认温度失败",
+	var tou *types.TimeOfUse
+	if len(req.Days) > 0 || len(req.Holidays) > 0 {
+		tou = &types.TimeOfUse{
+			Days:     make(map[string][]types.TimeBand, len(req.Days)),
+			Holidays: make(map[string][]types.TimeBand, len(req.Holidays)),
+		}
+		for key, bands := range req.Days {
+			tou.Days[key] = toTimeBands(bands)
+		}
+		for date, bands := range req.Holidays {
+			tou.Holidays[date] = toTimeBands(bands)
+		}
+	}
+
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.TimeOfUse = tou
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置分时电价失败",
 			Err: err.Error(),
 		})
 		return
 	}
 
+	msg := "分时电价表已更新"
+	if tou == nil {
+		msg = "已取消分时电价，全天按风速的费率计费"
+	}
 	c.JSON(http.StatusOK, Response{
-		Msg: fmt.Sprintf("默认温度已设置为 %d°C", req.DefaultTargetTemperature),
+		Msg:  msg,
+		Data: newTimeOfUseSetting(tou),
 	})
 }
 
-// MonitorPowerRequest 监控面板开机请求结构
-type MonitorPowerRequest struct {
-	RoomNumber int `json:"roomNumber" binding:"required"`
+// AdminChangeModeRateRequest 修改工作模式费率系数的请求结构
+type AdminChangeModeRateRequest struct {
+	OperationMode string   `json:"operationMode" binding:"required"`
+	Rate          *float32 `json:"rate" binding:"required"` // 费率系数，按风速计算的费用乘以该系数
+	Zone          string   `json:"zone"`                    // 机组编号，不传时为默认机组
 }
 
-// MonitorPowerOn 处理监控面板开机请求
-func (h *ACHandler) MonitorPowerOn(c *gin.Context) {
-	var req MonitorPowerRequest
+// AdminChangeModeRate 处理管理员修改工作模式费率系数的请求
+// 新的系数对之后开始的服务段生效
+func (h *ACHandler) AdminChangeModeRate(c *gin.Context) {
+	var req AdminChangeModeRateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "无效的请求格式",
@@ -868,34 +1028,73 @@ func (h *ACHandler) MonitorPowerOn(c *gin.Context) {
 		return
 	}
 
-	// 获取房间信息
-	_, err := h.roomRepo.GetRoomByID(req.RoomNumber)
-	if err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Msg: fmt.Sprintf("房间 %d 不存在", req.RoomNumber),
-			Err: err.Error(),
+	mode, ok := parseMode(req.OperationMode)
+	if !ok {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: invalidModeMsg,
+		})
+		return
+	}
+	if *req.Rate < 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "费率系数不能为负数",
 		})
 		return
 	}
 
-	// 开启空调
-	if err := h.acService.PowerOn(req.RoomNumber); err != nil {
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		config.ModeRates[mode] = *req.Rate
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "开启空调失败",
+			Msg: "设置费率系数失败",
 			Err: err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, Response{
-		Msg: "空调开启成功",
+		Msg: fmt.Sprintf("模式 %s 的费率系数已设置为 %.2f", mode, *req.Rate),
 	})
 }
 
-// MonitorPowerOff 处理监控面板关机请求
-func (h *ACHandler) MonitorPowerOff(c *gin.Context) {
-	var req MonitorPowerRequest // 可以复用开机的请求结构
-	if err := c.ShouldBindJSON(&req); err != nil {
+// AdminAllStateResponse 管理员获取所有状态的响应结构
+type AdminAllStateResponse struct {
+	Zone                     string             `json:"zone"` // 机组编号
+	ACState                  bool               `json:"acState"`
+	DefaultTargetTemperature float64            `json:"defaultTargetTemperature"`
+	HighSpeedRate            float64            `json:"highSpeedRate"`
+	LowSpeedRate             float64            `json:"lowSpeedRate"`
+	MaxTemperature           int64              `json:"maxTemperature"`
+	MediumSpeedRate          float64            `json:"mediumSpeedRate"`
+	MinTemperature           int64              `json:"minTemperature"`
+	OperationMode            string             `json:"operationMode"`
+	SchedulingPolicy         string             `json:"schedulingPolicy"`
+	CapacityMode             string             `json:"capacityMode"`       // 容量模型 count/power
+	MaxServices              int                `json:"maxServices"`        // 服务队列容量
+	PowerBudget              float64            `json:"powerBudget"`        // 功率预算(kW)
+	TimeSlice                float64            `json:"timeSlice"`          // 时间片(秒)
+	WaitGrowthFactor         float64            `json:"waitGrowthFactor"`   // 等待时长增长系数
+	AgingRate                float64            `json:"agingRate"`          // 优先级老化速率(每分钟)
+	MaxWait                  float64            `json:"maxWait"`            // 最长等待时间(秒)
+	Hysteresis               float64            `json:"hysteresis"`         // 回差(°C)
+	ThermalModel             string             `json:"thermalModel"`       // 房间热模型
+	OutdoorTemp              *float32           `json:"outdoorTemp"`        // 固定室外温度，未设置时为null
+	WeatherFile              string             `json:"weatherFile"`        // 天气曲线文件
+	ModeRate                 float64            `json:"modeRate"`           // 当前模式的费率系数
+	TargetHumidity           float64            `json:"targetHumidity"`     // 除湿模式的目标湿度(%)
+	PreconditionCharge       string             `json:"preconditionCharge"` // 预调温费用的计费对象 hotel/guest
+	SpeedWeight              float64            `json:"speedWeight"`        // 风速优先级的权重
+	ClassPriority            map[string]float64 `json:"classPriority"`      // 各房间等级的优先级加成
+	TimeOfUse                *TimeOfUseSetting  `json:"timeOfUse"`          // 分时电价表，未设置时为null
+	EnergyRates              map[string]float64 `json:"energyRates"`        // 各风速每分钟的耗电量(度)
+}
+
+// AdminRequestAllState 处理管理员获取所有状态的请求
+func (h *ACHandler) AdminRequestAllState(c *gin.Context) {
+	var req ZoneRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "无效的请求格式",
 			Err: err.Error(),
@@ -903,52 +1102,81 @@ func (h *ACHandler) MonitorPowerOff(c *gin.Context) {
 		return
 	}
 
-	// 获取房间信息
-	_, err := h.roomRepo.GetRoomByID(req.RoomNumber)
+	// 获取当前配置
+	config, err := h.acService.GetConfig(req.Zone)
 	if err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Msg: fmt.Sprintf("房间 %d 不存在", req.RoomNumber),
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "获取空调配置失败",
 			Err: err.Error(),
 		})
 		return
 	}
 
-	// 关闭空调
-	if err := h.acService.PowerOff(req.RoomNumber); err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "关闭空调失败",
-			Err: err.Error(),
-		})
-		return
+	// 获取中央空调状态、模式和调度策略
+	isOn, mode, _ := h.acService.GetCentralACState(req.Zone)
+	policy, _ := h.acService.GetSchedulingPolicy(req.Zone)
+
+	// 获取当前模式的温度范围和费率系数
+	tempRange := config.TempRanges[mode]
+	modeRate, ok := config.ModeRates[mode]
+	if !ok {
+		modeRate = 1
 	}
 
-	c.JSON(http.StatusOK, Response{
-		Msg: "空调关闭成功",
-	})
-}
+	// 构建响应
+	zoneID := req.Zone
+	if zoneID == "" {
+		zoneID = db.DefaultZone
+	}
+	response := AdminAllStateResponse{
+		Zone:                     zoneID,
+		ACState:                  isOn,
+		DefaultTargetTemperature: float64(config.DefaultTemp),
+		HighSpeedRate:            float64(config.Rates[types.SpeedHigh]),
+		LowSpeedRate:             float64(config.Rates[types.SpeedLow]),
+		MaxTemperature:           int64(tempRange.Max),
+		MediumSpeedRate:          float64(config.Rates[types.SpeedMedium]),
+		MinTemperature:           int64(tempRange.Min),
+		OperationMode:            string(mode),
+		SchedulingPolicy:         policy,
+		CapacityMode:             string(config.CapacityMode),
+		MaxServices:              config.MaxServices,
+		PowerBudget:              math.Round(float64(config.PowerBudget)*100) / 100,
+		TimeSlice:                config.TimeSlice.Seconds(),
+		WaitGrowthFactor:         float64(config.WaitGrowthFactor),
+		AgingRate:                float64(config.AgingRate),
+		MaxWait:                  config.MaxWait.Seconds(),
+		Hysteresis:               float64(config.Hysteresis),
+		ThermalModel:             config.Thermal.Model,
+		OutdoorTemp:              config.Thermal.OutdoorTemp,
+		WeatherFile:              config.Thermal.WeatherFile,
+		ModeRate:                 float64(modeRate),
+		SpeedWeight:              float64(config.SpeedWeight),
+		ClassPriority:            make(map[string]float64, len(config.ClassPriority)),
+		TimeOfUse:                newTimeOfUseSetting(config.TimeOfUse),
+		EnergyRates:              make(map[string]float64, len(config.EnergyRates)),
+		TargetHumidity:           float64(config.Thermal.TargetHumidity),
+		PreconditionCharge:       string(config.PreconditionCharge),
+	}
+	for class, weight := range config.ClassPriority {
+		response.ClassPriority[string(class)] = float64(weight)
+	}
+	for speed, energy := range config.EnergyRates {
+		response.EnergyRates[string(speed)] = float64(energy)
+	}
 
-// MonitorRequestStatesRequest 监控面板状态查询请求
-type MonitorRequestStatesRequest struct {
-	Index int `json:"index" binding:"required"`
+	c.JSON(http.StatusOK, response)
 }
 
-// MonitorStateResponse 监控面板状态响应
-type MonitorStateResponse struct {
-	ACState            bool    `json:"acState"`
-	CurrentFanSpeed    string  `json:"currentFanSpeed"`
-	CurrentTemperature float64 `json:"currentTemperature"`
-	OperationMode      string  `json:"operationMode"`
-	RoomNumber         int64   `json:"roomNumber"`
-	ScheduleStatus     bool    `json:"scheduleStatus"`
-	TargetTemperature  float64 `json:"targetTemperature"`
-	CurrentCost        float64 `json:"currentCost"`
-	TotalCost          float64 `json:"totalCost"`
-	Valid              bool    `json:"valid"`
+// AdminChangePolicyRequest 切换调度策略的请求结构
+type AdminChangePolicyRequest struct {
+	Policy string `json:"policy" binding:"required"` // priority/fcfs/roundrobin
+	Zone   string `json:"zone"`                      // 机组编号，不传时为默认机组
 }
 
-// MonitorRequestStates 处理监控面板状态查询请求
-func (h *ACHandler) MonitorRequestStates(c *gin.Context) {
-	var req MonitorRequestStatesRequest
+// AdminChangePolicy 处理管理员切换调度策略的请求
+func (h *ACHandler) AdminChangePolicy(c *gin.Context) {
+	var req AdminChangePolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "无效的请求格式",
@@ -957,52 +1185,917 @@ func (h *ACHandler) MonitorRequestStates(c *gin.Context) {
 		return
 	}
 
-	// 获取所有已入住房间
-	rooms, err := h.roomRepo.GetOccupiedRooms()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "获取房间信息失败",
-			Err: err.Error(),
+	if err := h.acService.SetSchedulingPolicy(req.Zone, req.Policy); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg:  "切换调度策略失败",
+			Data: service.SchedulingPolicyNames(),
+			Err:  err.Error(),
 		})
 		return
 	}
 
-	// 如果请求的索引超出有效房间数量，返回无效面板
-	if req.Index-1 >= len(rooms) {
-		c.JSON(http.StatusOK, MonitorStateResponse{
-			Valid: false,
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("调度策略已切换为 %s", req.Policy),
+	})
+}
+
+// AdminChangeCapacityRequest 修改服务队列容量的请求结构
+type AdminChangeCapacityRequest struct {
+	MaxServices int    `json:"maxServices" binding:"required"`
+	Zone        string `json:"zone"` // 机组编号，不传时为默认机组
+}
+
+// AdminChangeCapacity 处理管理员修改服务队列容量的请求
+func (h *ACHandler) AdminChangeCapacity(c *gin.Context) {
+	var req AdminChangeCapacityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
 		})
 		return
 	}
 
-	// 获取当前索引对应的房间
-	room := rooms[req.Index-1]
+	if req.MaxServices <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "服务队列容量必须大于0",
+		})
+		return
+	}
 
-	// 获取空调状态
-	acStatus, err := h.acService.GetACStatus(room.RoomID)
-	if err != nil {
+	if err := h.acService.SetCapacity(req.Zone, req.MaxServices); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "获取空调状态失败",
+			Msg: "设置服务队列容量失败",
 			Err: err.Error(),
 		})
 		return
 	}
 
-	// 获取调度状态
-	serviceQueue := h.acService.GetScheduler().GetServiceQueue()
-	_, isInService := serviceQueue[room.RoomID]
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("服务队列容量已设置为 %d", req.MaxServices),
+	})
+}
 
-	response := MonitorStateResponse{
+// AdminChangePowerBudgetRequest 修改容量模型和功率预算的请求结构
+// 未传的字段保持不变
+type AdminChangePowerBudgetRequest struct {
+	CapacityMode string   `json:"capacityMode"` // count/power
+	PowerBudget  *float32 `json:"powerBudget"`  // 功率预算(kW)
+	LowLoad      float32  `json:"lowLoad"`      // 低风速负载(kW)
+	MediumLoad   float32  `json:"mediumLoad"`   // 中风速负载(kW)
+	HighLoad     float32  `json:"highLoad"`     // 高风速负载(kW)
+	Zone         string   `json:"zone"`         // 机组编号，不传时为默认机组
+}
+
+// AdminChangePowerBudget 处理管理员修改功率预算的请求
+// 用于需求响应：预算减小时超出预算的服务对象立即降级到等待队列
+func (h *ACHandler) AdminChangePowerBudget(c *gin.Context) {
+	var req AdminChangePowerBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	loads := make(map[types.Speed]float32)
+	for speed, load := range map[types.Speed]float32{
+		types.SpeedLow:    req.LowLoad,
+		types.SpeedMedium: req.MediumLoad,
+		types.SpeedHigh:   req.HighLoad,
+	} {
+		if load < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Msg: "风速负载不能为负数",
+			})
+			return
+		}
+		if load > 0 {
+			loads[speed] = load
+		}
+	}
+
+	err := h.acService.SetPowerBudget(req.Zone, types.CapacityMode(req.CapacityMode), req.PowerBudget, loads)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置功率预算失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 未传的字段沿用修改时的配置，按修改后的配置返回
+	config, err := h.acService.GetConfig(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取空调配置失败",
+			Err: err.Error(),
+		})
+		return
+	}
+	msg := fmt.Sprintf("容量模型已设置为 %s", config.CapacityMode)
+	if config.CapacityMode == types.CapacityPower {
+		msg = fmt.Sprintf("%s，功率预算 %.2fkW", msg, config.PowerBudget)
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg: msg,
+	})
+}
+
+// AdminChangeTimeSliceRequest 修改时间片的请求结构
+type AdminChangeTimeSliceRequest struct {
+	TimeSlice        float64  `json:"timeSlice" binding:"required"` // 时间片(秒)
+	WaitGrowthFactor *float32 `json:"waitGrowthFactor"`             // 等待时长增长系数，不传时保持不变
+	Zone             string   `json:"zone"`                         // 机组编号，不传时为默认机组
+}
+
+// AdminChangeTimeSlice 处理管理员修改时间片的请求
+func (h *ACHandler) AdminChangeTimeSlice(c *gin.Context) {
+	var req AdminChangeTimeSliceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if req.TimeSlice < 1 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "时间片不能小于1秒",
+		})
+		return
+	}
+
+	if req.WaitGrowthFactor != nil && *req.WaitGrowthFactor < 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "等待时长增长系数不能为负数",
+		})
+		return
+	}
+
+	timeSlice := time.Duration(req.TimeSlice * float64(time.Second))
+	if err := h.acService.SetTimeSlice(req.Zone, timeSlice, req.WaitGrowthFactor); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "设置时间片失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	config, err := h.acService.GetConfig(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取空调配置失败",
+			Err: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("时间片已设置为 %.0f 秒, 等待时长增长系数: %.2f", req.TimeSlice, config.WaitGrowthFactor),
+	})
+}
+
+// AdminChangeAgingRequest 修改优先级老化参数的请求结构
+type AdminChangeAgingRequest struct {
+	AgingRate *float32 `json:"agingRate"` // 优先级老化速率(每分钟)，不传时保持不变
+	MaxWait   float64  `json:"maxWait"`   // 最长等待时间(秒)，不传时保持不变
+	Zone      string   `json:"zone"`      // 机组编号，不传时为默认机组
+}
+
+// AdminChangeAging 处理管理员修改优先级老化参数的请求
+func (h *ACHandler) AdminChangeAging(c *gin.Context) {
+	var req AdminChangeAgingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if req.AgingRate != nil && *req.AgingRate < 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "优先级老化速率不能为负数",
+		})
+		return
+	}
+
+	maxWait := time.Duration(req.MaxWait * float64(time.Second))
+	if err := h.acService.SetAging(req.Zone, req.AgingRate, maxWait); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置优先级老化参数失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	config, err := h.acService.GetConfig(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取空调配置失败",
+			Err: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("优先级老化速率已设置为 %.2f/分钟, 最长等待时间: %.0f 秒", config.AgingRate, config.MaxWait.Seconds()),
+	})
+}
+
+// AdminChangeHysteresisRequest 修改回差的请求结构
+type AdminChangeHysteresisRequest struct {
+	Hysteresis float32 `json:"hysteresis" binding:"required"` // 回差(°C)
+	Zone       string  `json:"zone"`                          // 机组编号，不传时为默认机组
+}
+
+// AdminChangeHysteresis 处理管理员修改回差的请求
+// 待机的房间温度向需要送风的方向偏离目标超过回差时重新请求服务
+func (h *ACHandler) AdminChangeHysteresis(c *gin.Context) {
+	var req AdminChangeHysteresisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if req.Hysteresis <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "回差必须大于0",
+		})
+		return
+	}
+
+	if err := h.acService.SetHysteresis(req.Zone, req.Hysteresis); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "设置回差失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("回差已设置为 %.2f°C", req.Hysteresis),
+	})
+}
+
+// AdminChangePreconditionChargeRequest 修改预调温费用计费对象的请求结构
+type AdminChangePreconditionChargeRequest struct {
+	ChargeTo string `json:"chargeTo" binding:"required"` // hotel: 计入酒店运营成本 guest: 计入住客账单
+	Zone     string `json:"zone"`                        // 机组编号，不传时为默认机组
+}
+
+// AdminChangePreconditionCharge 处理管理员修改入住前预调温费用计费对象的请求
+// 对之后安排的预调温计划生效
+func (h *ACHandler) AdminChangePreconditionCharge(c *gin.Context) {
+	var req AdminChangePreconditionChargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	charge := types.PreconditionCharge(req.ChargeTo)
+	if !charge.Valid() {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "计费对象必须为 hotel 或 guest",
+		})
+		return
+	}
+
+	if err := h.acService.SetPreconditionCharge(req.Zone, charge); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "设置预调温计费对象失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("预调温费用的计费对象已设置为 %s", charge),
+	})
+}
+
+// AdminChangePriorityWeightsRequest 修改调度优先级权重的请求结构
+type AdminChangePriorityWeightsRequest struct {
+	SpeedWeight   float32            `json:"speedWeight" binding:"required"` // 风速优先级的权重
+	ClassPriority map[string]float32 `json:"classPriority"`                  // 各房间等级的优先级加成，未传的等级保持不变
+	Zone          string             `json:"zone"`                           // 机组编号，不传时为默认机组
+}
+
+// AdminChangePriorityWeights 处理管理员修改调度优先级权重的请求
+// 调度优先级 = 风速优先级 × 风速权重 + 房间等级的优先级加成，队列中的房间立即按新的权重调度
+func (h *ACHandler) AdminChangePriorityWeights(c *gin.Context) {
+	var req AdminChangePriorityWeightsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	classPriority := make(map[types.RoomClass]float32, len(req.ClassPriority))
+	for name, weight := range req.ClassPriority {
+		class := types.RoomClass(name)
+		if !class.Valid() {
+			c.JSON(http.StatusBadRequest, Response{
+				Msg: fmt.Sprintf("无效的房间等级 %s，只能是 standard/premium/vip", name),
+			})
+			return
+		}
+		classPriority[class] = weight
+	}
+
+	if err := h.acService.SetPriorityWeights(req.Zone, req.SpeedWeight, classPriority); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置调度优先级权重失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("调度优先级权重已设置，风速权重 %.2f", req.SpeedWeight),
+	})
+}
+
+// AdminChangeRoomClassRequest 修改房间等级的请求结构
+type AdminChangeRoomClassRequest struct {
+	RoomNumber int    `json:"roomNumber" binding:"required"`
+	Class      string `json:"class" binding:"required"` // standard/premium/vip
+}
+
+// AdminChangeRoomClass 处理管理员修改房间等级的请求
+func (h *ACHandler) AdminChangeRoomClass(c *gin.Context) {
+	var req AdminChangeRoomClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if err := h.acService.SetRoomClass(req.RoomNumber, types.RoomClass(req.Class)); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置房间等级失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("房间 %d 的等级已设置为 %s", req.RoomNumber, req.Class),
+	})
+}
+
+// AdminLoadShedRequest 进入限负荷模式的请求结构
+type AdminLoadShedRequest struct {
+	MaxSpeed string `json:"maxSpeed"` // 风速上限 low/medium/high，不传时不限制风速
+	Suspend  bool   `json:"suspend"`  // 是否暂停准入
+	Shed     int    `json:"shed"`     // 按优先级从低到高停止送风的房间数，每个机组分别计算
+	Zone     string `json:"zone"`     // 机组编号，不传时全酒店所有机组同时进入
+}
+
+// LoadShedResponse 限负荷模式的状态
+type LoadShedResponse struct {
+	MaxSpeed  string         `json:"maxSpeed"`
+	Suspend   bool           `json:"suspend"`   // 是否暂停准入，停止送风时总是暂停
+	Shed      int            `json:"shed"`      // 进入时停止送风的房间数
+	Requested map[int]string `json:"requested"` // 风速被降低的房间及住客请求的风速
+	Held      []int          `json:"held"`      // 被停止送风、退出时优先恢复的房间
+}
+
+func newLoadShedResponse(state *service.LoadShedState) *LoadShedResponse {
+	if state == nil {
+		return nil
+	}
+	response := &LoadShedResponse{
+		MaxSpeed:  string(state.MaxSpeed),
+		Suspend:   state.Suspend || state.Shed > 0,
+		Shed:      state.Shed,
+		Requested: make(map[int]string, len(state.Requested)),
+		Held:      state.Held,
+	}
+	for roomID, speed := range state.Requested {
+		response.Requested[roomID] = string(speed)
+	}
+	return response
+}
+
+// AdminLoadShed 处理管理员进入限负荷模式的请求
+// 用于紧急情况下降低全酒店或单个机组的负载：限制风速上限、暂停准入或按优先级停止部分房间送风
+func (h *ACHandler) AdminLoadShed(c *gin.Context) {
+	var req AdminLoadShedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	zones, err := h.acService.EnterLoadShed(req.Zone, service.LoadShed{
+		MaxSpeed: types.Speed(req.MaxSpeed),
+		Suspend:  req.Suspend,
+		Shed:     req.Shed,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg:  "进入限负荷模式失败",
+			Data: zones,
+			Err:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("%d 个机组已进入限负荷模式", len(zones)),
+		Data: zones,
+	})
+}
+
+// AdminEndLoadShed 处理管理员退出限负荷模式的请求，恢复住客原来的风速和被停止送风的房间
+func (h *ACHandler) AdminEndLoadShed(c *gin.Context) {
+	var req ZoneRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	zones, err := h.acService.ExitLoadShed(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg:  "退出限负荷模式失败",
+			Data: zones,
+			Err:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("%d 个机组已退出限负荷模式", len(zones)),
+		Data: zones,
+	})
+}
+
+// AdminChangeThermalRequest 修改房间热模型的请求结构
+// 未传的字段保持不变
+type AdminChangeThermalRequest struct {
+	Model          string   `json:"model"`          // linear/physical
+	OutdoorTemp    *float32 `json:"outdoorTemp"`    // 固定室外温度
+	WeatherFile    *string  `json:"weatherFile"`    // 天气曲线文件，传空字符串取消
+	LowCapacity    float32  `json:"lowCapacity"`    // 低风速制冷/制热功率(W)
+	MediumCapacity float32  `json:"mediumCapacity"` // 中风速制冷/制热功率(W)
+	HighCapacity   float32  `json:"highCapacity"`   // 高风速制冷/制热功率(W)
+	TargetHumidity float32  `json:"targetHumidity"` // 除湿模式的目标湿度(%)
+	Zone           string   `json:"zone"`           // 机组编号，不传时为默认机组
+}
+
+// AdminChangeThermal 处理管理员修改房间热模型的请求
+func (h *ACHandler) AdminChangeThermal(c *gin.Context) {
+	var req AdminChangeThermalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	capacities := map[types.Speed]float32{
+		types.SpeedLow:    req.LowCapacity,
+		types.SpeedMedium: req.MediumCapacity,
+		types.SpeedHigh:   req.HighCapacity,
+	}
+	for _, power := range capacities {
+		if power < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Msg: "制冷/制热功率必须大于0",
+			})
+			return
+		}
+	}
+
+	var model string
+	err := h.acService.SetThermal(req.Zone, func(thermalConfig *types.ThermalConfig) {
+		if req.Model != "" {
+			thermalConfig.Model = req.Model
+		}
+		if req.OutdoorTemp != nil {
+			thermalConfig.OutdoorTemp = req.OutdoorTemp
+		}
+		if req.WeatherFile != nil {
+			thermalConfig.WeatherFile = *req.WeatherFile
+		}
+		if req.TargetHumidity != 0 {
+			thermalConfig.TargetHumidity = req.TargetHumidity
+		}
+		for speed, power := range capacities {
+			if power > 0 {
+				thermalConfig.Capacity[speed] = power
+			}
+		}
+		model = thermalConfig.Model
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg:  "设置房间热模型失败",
+			Data: thermal.ModelNames(),
+			Err:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("房间热模型已设置为 %s", model),
+	})
+}
+
+// AdminChangeRoomThermalRequest 修改房间热参数的请求结构
+type AdminChangeRoomThermalRequest struct {
+	RoomNumber    int     `json:"roomNumber" binding:"required"`
+	Volume        float32 `json:"volume" binding:"required"` // 容积(m³)
+	Insulation    float32 `json:"insulation"`                // 围护结构传热系数(W/K)
+	OccupancyLoad float32 `json:"occupancyLoad"`             // 人员和设备散热(W)
+}
+
+// AdminChangeRoomThermal 处理管理员修改房间热参数的请求
+func (h *ACHandler) AdminChangeRoomThermal(c *gin.Context) {
+	var req AdminChangeRoomThermalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	params := thermal.Params{
+		Volume:        req.Volume,
+		Insulation:    req.Insulation,
+		OccupancyLoad: req.OccupancyLoad,
+	}
+	if err := h.acService.SetRoomThermalParams(req.RoomNumber, params); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置房间热参数失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("房间 %d 热参数已更新", req.RoomNumber),
+	})
+}
+
+// AdminChangeDefaultTempRequest 修改默认温度的请求结构
+type AdminChangeDefaultTempRequest struct {
+	DefaultTargetTemperature int64  `json:"defaultTargetTemperature" binding:"required"`
+	Zone                     string `json:"zone"` // 机组编号，不传时为默认机组
+}
+
+// AdminChangeDefaultTemp 处理管理员更改默认温度的请求
+func (h *ACHandler) AdminChangeDefaultTemp(c *gin.Context) {
+	var req AdminChangeDefaultTempRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 获取当前空调状态
+	isOn, mode, _ := h.acService.GetCentralACState(req.Zone)
+	if !isOn {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "中央空调未开启",
+		})
+		return
+	}
+
+	// 更新配置中的默认温度，温度需在当前模式的范围内
+	err := h.acService.UpdateConfig(req.Zone, func(config *types.Config) error {
+		tempRange := config.TempRanges[mode]
+		if float32(req.DefaultTargetTemperature) < tempRange.Min ||
+			float32(req.DefaultTargetTemperature) > tempRange.Max {
+			return fmt.Errorf("默认温度必须在 %.1f°C - %.1f°C 范围内", tempRange.Min, tempRange.Max)
+		}
+		config.DefaultTemp = float32(req.DefaultTargetTemperature)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置默认温度失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("默认温度已设置为 %d°C", req.DefaultTargetTemperature),
+	})
+}
+
+// MonitorPowerRequest 监控面板开机请求结构
+type MonitorPowerRequest struct {
+	RoomNumber int `json:"roomNumber" binding:"required"`
+}
+
+// MonitorPowerOn 处理监控面板开机请求
+func (h *ACHandler) MonitorPowerOn(c *gin.Context) {
+	var req MonitorPowerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 获取房间信息
+	_, err := h.roomRepo.GetRoomByID(req.RoomNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Msg: fmt.Sprintf("房间 %d 不存在", req.RoomNumber),
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 开启空调
+	if err := h.acService.PowerOn(req.RoomNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "开启空调失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: "空调开启成功",
+	})
+}
+
+// MonitorPowerOff 处理监控面板关机请求
+func (h *ACHandler) MonitorPowerOff(c *gin.Context) {
+	var req MonitorPowerRequest // 可以复用开机的请求结构
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 获取房间信息
+	_, err := h.roomRepo.GetRoomByID(req.RoomNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Msg: fmt.Sprintf("房间 %d 不存在", req.RoomNumber),
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 关闭空调
+	if err := h.acService.PowerOff(req.RoomNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "关闭空调失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: "空调关闭成功",
+	})
+}
+
+// MonitorRequestStatesRequest 监控面板状态查询请求
+type MonitorRequestStatesRequest struct {
+	Index int `json:"index" binding:"required"`
+}
+
+// MonitorStateResponse 监控面板状态响应
+type MonitorStateResponse struct {
+	ACState            bool    `json:"acState"`
+	CurrentFanSpeed    string  `json:"currentFanSpeed"`
+	CurrentTemperature float64 `json:"currentTemperature"`
+	OperationMode      string  `json:"operationMode"`
+	RoomNumber         int64   `json:"roomNumber"`
+	Zone               string  `json:"zone"` // 房间所属机组
+	ScheduleStatus     bool    `json:"scheduleStatus"`
+	RunState           string  `json:"runState"` // 运行状态 off/waiting/serving/standby
+	TargetTemperature  float64 `json:"targetTemperature"`
+	CurrentCost        float64 `json:"currentCost"`
+	TotalCost          float64 `json:"totalCost"`
+	Valid              bool    `json:"valid"`
+	Waiting            bool    `json:"waiting"`           // 是否在等待队列中
+	Class              string  `json:"class"`             // 参与调度的等级，取房间和住客等级中较高的
+	EffectivePriority  float64 `json:"effectivePriority"` // 有效优先级：服务中为调度优先级，等待中还包含老化的增量
+	WaitedTime         float64 `json:"waitedTime"`        // 本次累计等待时间(秒)
+}
+
+// MonitorRequestStates 处理监控面板状态查询请求
+func (h *ACHandler) MonitorRequestStates(c *gin.Context) {
+	var req MonitorRequestStatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 获取所有已入住房间
+	rooms, err := h.roomRepo.GetOccupiedRooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取房间信息失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 如果请求的索引超出有效房间数量，返回无效面板
+	if req.Index-1 >= len(rooms) {
+		c.JSON(http.StatusOK, MonitorStateResponse{
+			Valid: false,
+		})
+		return
+	}
+
+	// 获取当前索引对应的房间
+	room := rooms[req.Index-1]
+
+	// 获取空调状态
+	acStatus, err := h.acService.GetACStatus(room.RoomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取空调状态失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	// 获取房间所属机组的调度状态
+	snapshot, err := h.acService.GetQueueSnapshot(room.Zone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取调度状态失败",
+			Err: err.Error(),
+		})
+		return
+	}
+	serving, isInService := snapshot.ServingRoom(room.RoomID)
+	wait, isWaiting := snapshot.WaitingRoom(room.RoomID)
+
+	response := MonitorStateResponse{
 		ACState:            acStatus.PowerState,
 		CurrentFanSpeed:    string(acStatus.CurrentSpeed),
 		CurrentTemperature: math.Round(float64(acStatus.CurrentTemp)*100) / 100,
 		OperationMode:      string(acStatus.Mode),
 		RoomNumber:         int64(room.RoomID),
+		Zone:               room.Zone,
 		ScheduleStatus:     isInService,
+		RunState:           string(acStatus.RunState),
 		TargetTemperature:  math.Round(float64(acStatus.TargetTemp)*100) / 100, // 保留2位小数
-		TotalCost:          float64(acStatus.TotalFee),
-		CurrentCost:        float64(acStatus.CurrentFee),
+		TotalCost:          acStatus.TotalFee.Yuan(),
+		CurrentCost:        acStatus.CurrentFee.Yuan(),
 		Valid:              true,
+		Waiting:            isWaiting,
+		Class:              string(service.RoomClass(&room)),
+	}
+	if isInService {
+		response.EffectivePriority = math.Round(serving.Priority*100) / 100
+	}
+	if isWaiting {
+		response.EffectivePriority = math.Round(wait.Priority*100) / 100
+		response.WaitedTime = float64(wait.Waited)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// QueueServiceEntry 服务队列中的一项
+type QueueServiceEntry struct {
+	RoomNumber         int     `json:"roomNumber"`
+	FanSpeed           string  `json:"fanSpeed"`
+	StartTime          string  `json:"startTime"`   // 本次服务开始时间
+	ServiceTime        float64 `json:"serviceTime"` // 本次已服务时间(秒)
+	TargetTemperature  float64 `json:"targetTemperature"`
+	CurrentTemperature float64 `json:"currentTemperature"`
+	Guaranteed         bool    `json:"guaranteed"`        // 是否处于保证时间片内
+	Class              string  `json:"class"`             // 房间等级
+	EffectivePriority  float64 `json:"effectivePriority"` // 调度优先级
+	Precondition       bool    `json:"precondition"`      // 是否为空房入住前的预调温，固定为最低优先级
+}
+
+// QueueWaitEntry 等待队列中的一项
+type QueueWaitEntry struct {
+	RoomNumber         int     `json:"roomNumber"`
+	FanSpeed           string  `json:"fanSpeed"`
+	RequestTime        string  `json:"requestTime"`   // 进入等待队列的时间
+	RemainingWait      float64 `json:"remainingWait"` // 剩余等待时间(秒)
+	WaitedTime         float64 `json:"waitedTime"`    // 累计等待时间(秒)
+	Class              string  `json:"class"`         // 房间等级
+	BasePriority       float64 `json:"basePriority"`  // 调度优先级，不含老化的增量
+	EffectivePriority  float64 `json:"effectivePriority"`
+	TargetTemperature  float64 `json:"targetTemperature"`
+	CurrentTemperature float64 `json:"currentTemperature"`
+	Precondition       bool    `json:"precondition"` // 是否为空房入住前的预调温，固定为最低优先级
+}
+
+// MonitorQueuesResponse 调度队列快照的响应结构
+type MonitorQueuesResponse struct {
+	Zone         string              `json:"zone"`    // 机组编号
+	Version      uint64              `json:"version"` // 快照版本号，单调递增
+	TakenAt      string              `json:"takenAt"` // 快照时间(系统时间)
+	Policy       string              `json:"policy"`
+	CapacityMode string              `json:"capacityMode"` // count/power
+	Capacity     int                 `json:"capacity"`     // 同时送风的房间数，count 模式使用
+	PowerBudget  float64             `json:"powerBudget"`  // 功率预算(kW)，power 模式使用
+	Used         float64             `json:"used"`         // 已占用的容量：房间数或负载之和(kW)
+	Serving      []QueueServiceEntry `json:"serving"`      // 按房间号排序
+	Waiting      []QueueWaitEntry    `json:"waiting"`      // 按有效优先级从高到低排序
+	LoadShed     *LoadShedResponse   `json:"loadShed"`     // 限负荷模式的状态，未处于限负荷模式时为null
+
+	EventBacklog []events.SubscriptionStats `json:"eventBacklog"` // 计费、监控等事件订阅者的邮箱积压
+}
+
+// MonitorQueues 一次返回机组服务队列和等待队列的一致快照
+func (h *ACHandler) MonitorQueues(c *gin.Context) {
+	var req ZoneRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	snapshot, err := h.acService.GetQueueSnapshot(req.Zone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "获取调度队列失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	response := MonitorQueuesResponse{
+		Zone:         snapshot.Zone,
+		Version:      snapshot.Version,
+		TakenAt:      snapshot.TakenAt.Format("2006-01-02 15:04:05"),
+		Policy:       snapshot.Policy,
+		CapacityMode: string(snapshot.Capacity.Mode),
+		Capacity:     snapshot.Capacity.Slots,
+		PowerBudget:  math.Round(float64(snapshot.Capacity.Budget)*100) / 100,
+		Used:         math.Round(float64(snapshot.Used)*100) / 100,
+		Serving:      make([]QueueServiceEntry, 0, len(snapshot.Serving)),
+		Waiting:      make([]QueueWaitEntry, 0, len(snapshot.Waiting)),
+		LoadShed:     newLoadShedResponse(snapshot.LoadShed),
+		EventBacklog: service.GetEventBus().Stats(),
+	}
+	for _, service := range snapshot.Serving {
+		response.Serving = append(response.Serving, QueueServiceEntry{
+			RoomNumber:         service.RoomID,
+			FanSpeed:           string(service.Speed),
+			StartTime:          service.StartTime.Format("2006-01-02 15:04:05"),
+			ServiceTime:        math.Round(float64(service.Duration)*100) / 100,
+			TargetTemperature:  math.Round(float64(service.TargetTemp)*100) / 100,
+			CurrentTemperature: math.Round(float64(service.CurrentTemp)*100) / 100,
+			Guaranteed:         service.Guaranteed,
+			Class:              string(service.Class),
+			EffectivePriority:  math.Round(service.Priority*100) / 100,
+			Precondition:       service.Precondition,
+		})
+	}
+	for _, wait := range snapshot.Waiting {
+		response.Waiting = append(response.Waiting, QueueWaitEntry{
+			RoomNumber:         wait.RoomID,
+			FanSpeed:           string(wait.Speed),
+			RequestTime:        wait.RequestTime.Format("2006-01-02 15:04:05"),
+			RemainingWait:      math.Round(float64(wait.WaitDuration)*100) / 100,
+			WaitedTime:         math.Round(float64(wait.Waited)*100) / 100,
+			Class:              string(wait.Class),
+			BasePriority:       math.Round(wait.BasePriority*100) / 100,
+			EffectivePriority:  math.Round(wait.Priority*100) / 100,
+			Precondition:       wait.Precondition,
+			TargetTemperature:  math.Round(float64(wait.TargetTemp)*100) / 100,
+			CurrentTemperature: math.Round(float64(wait.CurrentTemp)*100) / 100,
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
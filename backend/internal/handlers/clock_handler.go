@@ -0,0 +1,150 @@
+// internal/handlers/clock_handler.go
+package handlers
+
+import (
+	"backend/internal/clock"
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClockHandler struct {
+	simClock *clock.SimClock
+}
+
+func NewClockHandler() *ClockHandler {
+	return &ClockHandler{
+		simClock: service.GetSimClock(),
+	}
+}
+
+// ClockStepRequest 手动步进请求
+type ClockStepRequest struct {
+	Seconds float64 `json:"seconds" binding:"required"` // 步进的虚拟时长(秒)
+}
+
+// ClockSpeedRequest 修改倍速请求
+type ClockSpeedRequest struct {
+	Speed float64 `json:"speed" binding:"required"` // 虚拟时间相对真实时间的倍速
+}
+
+// ClockStateResponse 模拟时钟状态响应
+type ClockStateResponse struct {
+	Now    string  `json:"now"`
+	Speed  float64 `json:"speed"`
+	Paused bool    `json:"paused"`
+}
+
+// requireSimClock 检查系统是否运行在模拟时钟下
+func (h *ClockHandler) requireSimClock(c *gin.Context) bool {
+	if h.simClock == nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "系统当前使用真实时钟，不支持该操作",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *ClockHandler) stateResponse() ClockStateResponse {
+	state := h.simClock.State()
+	return ClockStateResponse{
+		Now:    state.Now.Format("2006-01-02 15:04:05"),
+		Speed:  state.Speed,
+		Paused: state.Paused,
+	}
+}
+
+// AdminClockState 获取模拟时钟状态
+func (h *ClockHandler) AdminClockState(c *gin.Context) {
+	if !h.requireSimClock(c) {
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取时钟状态成功",
+		Data: h.stateResponse(),
+	})
+}
+
+// AdminClockPause 暂停虚拟时间
+func (h *ClockHandler) AdminClockPause(c *gin.Context) {
+	if !h.requireSimClock(c) {
+		return
+	}
+	h.simClock.Pause()
+	c.JSON(http.StatusOK, Response{
+		Msg:  "模拟时钟已暂停",
+		Data: h.stateResponse(),
+	})
+}
+
+// AdminClockResume 恢复虚拟时间
+func (h *ClockHandler) AdminClockResume(c *gin.Context) {
+	if !h.requireSimClock(c) {
+		return
+	}
+	h.simClock.Resume()
+	c.JSON(http.StatusOK, Response{
+		Msg:  "模拟时钟已恢复",
+		Data: h.stateResponse(),
+	})
+}
+
+// AdminClockStep 手动推进虚拟时间
+func (h *ClockHandler) AdminClockStep(c *gin.Context) {
+	if !h.requireSimClock(c) {
+		return
+	}
+	var req ClockStepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if err := h.simClock.Step(time.Duration(req.Seconds * float64(time.Second))); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "推进时钟失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("模拟时钟已推进 %.1f 秒", req.Seconds),
+		Data: h.stateResponse(),
+	})
+}
+
+// AdminClockSpeed 修改模拟倍速
+func (h *ClockHandler) AdminClockSpeed(c *gin.Context) {
+	if !h.requireSimClock(c) {
+		return
+	}
+	var req ClockSpeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if err := h.simClock.SetSpeed(req.Speed); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置倍速失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("模拟倍速已设置为 %.1f", req.Speed),
+		Data: h.stateResponse(),
+	})
+}
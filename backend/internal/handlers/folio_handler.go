@@ -0,0 +1,351 @@
+// internal/handlers/folio_handler.go
+package handlers
+
+import (
+	"backend/internal/db"
+	"backend/internal/service"
+	"backend/internal/types"
+	"backend/internal/utils"
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FolioHandler struct {
+	acService *service.ACService
+}
+
+func NewFolioHandler() *FolioHandler {
+	return &FolioHandler{
+		acService: service.GetACService(),
+	}
+}
+
+// FolioRequest 查询住客账单的请求结构
+type FolioRequest struct {
+	RoomID int `json:"room_id" binding:"required"`
+}
+
+// PostFolioItemRequest 追加账目的请求结构
+type PostFolioItemRequest struct {
+	RoomID      int     `json:"room_id" binding:"required"`
+	Kind        string  `json:"kind" binding:"required"` // extra/payment/adjustment
+	Description string  `json:"description" binding:"required"`
+	Amount      float64 `json:"amount"` // 金额(元)，消费和付款为正数，调整为正数加收、负数减免
+}
+
+// InvoicesRequest 查询发票的请求结构
+type InvoicesRequest struct {
+	RoomID int `json:"room_id"` // 只查询该房间的发票，不传时查询全部发票
+}
+
+// InvoiceRequest 按编号获取发票的请求结构
+type InvoiceRequest struct {
+	Number string `json:"number" binding:"required"`
+}
+
+// FolioItemResponse 账目的响应结构，金额单位为元，押金和付款为负数
+type FolioItemResponse struct {
+	ID          int     `json:"id,omitempty"` // 尚未记入账单的房费和空调费用为0
+	Kind        string  `json:"kind"`
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unitPrice"`
+	Amount      float64 `json:"amount"`
+	Mode        string  `json:"mode,omitempty"`
+	Time        string  `json:"time"`
+}
+
+// FolioResponse 住客账单或发票的响应结构
+type FolioResponse struct {
+	Number       string              `json:"number,omitempty"` // 发票编号，未结算的账单为空
+	IssuedAt     string              `json:"issuedAt,omitempty"`
+	RoomID       int                 `json:"room_id"`
+	ClientID     string              `json:"client_ID"`
+	ClientName   string              `json:"client_name"`
+	CheckinTime  string              `json:"CheckinTime"`
+	CheckoutTime string              `json:"CheckoutTime,omitempty"`
+	Status       string              `json:"status"` // open/closed
+	Items        []FolioItemResponse `json:"items,omitempty"`
+	Charges      float64             `json:"charges"` // 应收合计
+	Credits      float64             `json:"credits"` // 押金和付款合计
+	Balance      float64             `json:"balance"` // 应付余额，负数为应退还住客
+}
+
+func newFolioItemResponse(item *db.FolioItem) FolioItemResponse {
+	return FolioItemResponse{
+		ID:          item.ID,
+		Kind:        string(item.Kind),
+		Description: item.Description,
+		Quantity:    item.Quantity,
+		UnitPrice:   item.UnitPrice.Yuan(),
+		Amount:      item.Amount.Yuan(),
+		Mode:        item.Mode,
+		Time:        formatTime(item.CreatedTime),
+	}
+}
+
+func newFolioItemResponses(items []db.FolioItem) []FolioItemResponse {
+	response := make([]FolioItemResponse, 0, len(items))
+	for i := range items {
+		response = append(response, newFolioItemResponse(&items[i]))
+	}
+	return response
+}
+
+func newInvoiceResponse(invoice *db.Invoice, statement *service.FolioStatement) FolioResponse {
+	response := FolioResponse{
+		Number:       invoice.Number,
+		IssuedAt:     formatTime(invoice.IssuedAt),
+		RoomID:       invoice.RoomID,
+		ClientID:     invoice.ClientID,
+		ClientName:   invoice.ClientName,
+		CheckinTime:  formatTime(invoice.CheckinTime),
+		CheckoutTime: formatTime(invoice.CheckoutTime),
+		Status:       string(db.FolioClosed),
+		Charges:      invoice.Charges.Yuan(),
+		Credits:      invoice.Credits.Yuan(),
+		Balance:      invoice.Balance.Yuan(),
+	}
+	if statement != nil {
+		response.Items = newFolioItemResponses(statement.Items)
+	}
+	return response
+}
+
+// GetFolio 查询入住中房间的账单，房费和空调费用计算到当前时刻
+func (h *FolioHandler) GetFolio(c *gin.Context) {
+	var req FolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	folio, statement, err := h.acService.GetFolio(req.RoomID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "获取账单失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: "获取账单成功",
+		Data: FolioResponse{
+			RoomID:      folio.RoomID,
+			ClientID:    folio.ClientID,
+			ClientName:  folio.ClientName,
+			CheckinTime: formatTime(folio.CheckinTime),
+			Status:      string(folio.Status),
+			Items:       newFolioItemResponses(statement.Items),
+			Charges:     statement.Charges.Yuan(),
+			Credits:     statement.Credits.Yuan(),
+			Balance:     statement.Balance.Yuan(),
+		},
+	})
+}
+
+// PostFolioItem 向入住中房间的账单追加消费、付款或调整
+func (h *FolioHandler) PostFolioItem(c *gin.Context) {
+	var req PostFolioItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	item, err := h.acService.PostFolioItem(req.RoomID, db.FolioItemKind(req.Kind), req.Description, types.Yuan(req.Amount))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "追加账目失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("房间 %d 的账单已追加账目", req.RoomID),
+		Data: newFolioItemResponse(item),
+	})
+}
+
+// Invoices 按编号顺序查询已开具的发票，不含账目
+func (h *FolioHandler) Invoices(c *gin.Context) {
+	var req InvoicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	invoices, err := h.acService.GetInvoices(req.RoomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取发票失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	response := make([]FolioResponse, 0, len(invoices))
+	for i := range invoices {
+		response = append(response, newInvoiceResponse(&invoices[i], nil))
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取发票成功",
+		Data: response,
+	})
+}
+
+// Invoice 按编号获取发票及开具时的账目
+func (h *FolioHandler) Invoice(c *gin.Context) {
+	var req InvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	invoice, statement, err := h.acService.GetInvoice(req.Number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Msg: "获取发票失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取发票成功",
+		Data: newInvoiceResponse(invoice, statement),
+	})
+}
+
+// PrintInvoice 按编号打印发票PDF
+func (h *FolioHandler) PrintInvoice(c *gin.Context) {
+	var req InvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	invoice, statement, err := h.acService.GetInvoice(req.Number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Msg: "获取发票失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	pdf, err := utils.GenerateInvoicePDF(utils.Invoice{
+		Number:       invoice.Number,
+		IssuedAt:     invoice.IssuedAt,
+		RoomID:       invoice.RoomID,
+		ClientName:   invoice.ClientName,
+		ClientID:     invoice.ClientID,
+		CheckInTime:  invoice.CheckinTime,
+		CheckOutTime: invoice.CheckoutTime,
+		Items:        statement.Items,
+		Charges:      statement.Charges,
+		Credits:      statement.Credits,
+		Balance:      statement.Balance,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "生成发票PDF失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "生成PDF文件失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	fileName := fmt.Sprintf("发票_%s.pdf", invoice.Number)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Length", fmt.Sprintf("%d", len(buf.Bytes())))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// AdminChangeRoomChargeRequest 修改房费计算规则的请求结构，未传的字段保持不变
+type AdminChangeRoomChargeRequest struct {
+	CheckoutCutoff     *string  `json:"checkoutCutoff"`     // 退房截止时刻 HH:MM，超过后加收半天
+	FullDayAfter       *string  `json:"fullDayAfter"`       // 超过该时刻退房加收一天 HH:MM
+	HalfDayRatio       *float64 `json:"halfDayRatio"`       // 半天房费占日房费的比例
+	CancelGraceMinutes *int     `json:"cancelGraceMinutes"` // 入住后多少分钟内当天退房视为当日取消，0为不受理取消
+	CancelRatio        *float64 `json:"cancelRatio"`        // 当日取消收取日房费的比例，0为免收
+}
+
+// AdminRoomCharge 查询房费计算规则
+func (h *FolioHandler) AdminRoomCharge(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取房费计算规则成功",
+		Data: h.acService.GetRoomChargePolicy(),
+	})
+}
+
+// AdminChangeRoomCharge 处理管理员修改房费计算规则的请求
+// 对之后的账单预览和退房生效，已开具的发票不变
+func (h *FolioHandler) AdminChangeRoomCharge(c *gin.Context) {
+	var req AdminChangeRoomChargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	policy := h.acService.GetRoomChargePolicy()
+	if req.CheckoutCutoff != nil {
+		policy.CheckoutCutoff = *req.CheckoutCutoff
+	}
+	if req.FullDayAfter != nil {
+		policy.FullDayAfter = *req.FullDayAfter
+	}
+	if req.HalfDayRatio != nil {
+		policy.HalfDayRatio = *req.HalfDayRatio
+	}
+	if req.CancelGraceMinutes != nil {
+		policy.CancelGraceMinutes = *req.CancelGraceMinutes
+	}
+	if req.CancelRatio != nil {
+		policy.CancelRatio = *req.CancelRatio
+	}
+
+	if err := h.acService.SetRoomChargePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置房费计算规则失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  "房费计算规则已修改",
+		Data: policy,
+	})
+}
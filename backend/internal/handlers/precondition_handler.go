@@ -0,0 +1,192 @@
+// internal/handlers/precondition_handler.go
+package handlers
+
+import (
+	"backend/internal/db"
+	"backend/internal/service"
+	"backend/internal/types"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeLayout 请求和响应中的时间格式，按系统时钟的本地时间解析
+const timeLayout = "2006-01-02 15:04:05"
+
+type PreconditionHandler struct {
+	acService *service.ACService
+}
+
+func NewPreconditionHandler() *PreconditionHandler {
+	return &PreconditionHandler{
+		acService: service.GetACService(),
+	}
+}
+
+// PreconditionRequest 安排入住前预调温的请求结构
+type PreconditionRequest struct {
+	RoomID     int     `json:"room_id" binding:"required"`
+	TargetTemp float32 `json:"target_temp" binding:"required"` // 目标温度
+	ArriveTime string  `json:"arrive_time" binding:"required"` // 住客预计到达时间，格式 2006-01-02 15:04:05
+}
+
+// CancelPreconditionRequest 取消预调温计划的请求结构
+type CancelPreconditionRequest struct {
+	ID int `json:"id" binding:"required"` // 计划编号
+}
+
+// PreconditionsRequest 查询预调温计划的请求结构，请求体可以为空
+type PreconditionsRequest struct {
+	RoomID int `json:"room_id"` // 房间号，不传时查询全部房间
+}
+
+// PreconditionResponse 预调温计划的响应结构
+type PreconditionResponse struct {
+	ID         int     `json:"id"`
+	RoomID     int     `json:"room_id"`
+	TargetTemp float64 `json:"target_temp"`
+	ArriveTime string  `json:"arrive_time"`
+	StartTime  string  `json:"start_time"` // 估算的开机时间
+	StartedAt  string  `json:"started_at"` // 实际开机时间，未开机时为空
+	EndedAt    string  `json:"ended_at"`   // 结束时间，未结束时为空
+	Status     string  `json:"status"`     // pending/running/completed/expired/cancelled/failed
+	ChargeTo   string  `json:"charge_to"`  // hotel/guest
+	Cost       float64 `json:"cost"`       // 费用(元)，结束时结算
+	Note       string  `json:"note,omitempty"`
+}
+
+// PreconditionsResponse 预调温计划列表的响应结构
+type PreconditionsResponse struct {
+	Plans        []PreconditionResponse `json:"plans"`
+	HotelExpense float64                `json:"hotel_expense"` // 已结束的计划中计入酒店运营成本的费用(元)
+	GuestCharged float64                `json:"guest_charged"` // 已计入住客账单的费用(元)
+}
+
+// formatTime 格式化时间，零值为空字符串
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+func newPreconditionResponse(plan *db.Precondition) PreconditionResponse {
+	return PreconditionResponse{
+		ID:         plan.ID,
+		RoomID:     plan.RoomID,
+		TargetTemp: math.Round(float64(plan.TargetTemp)*100) / 100,
+		ArriveTime: formatTime(plan.ArriveTime),
+		StartTime:  formatTime(plan.StartTime),
+		StartedAt:  formatTime(plan.StartedAt),
+		EndedAt:    formatTime(plan.EndedAt),
+		Status:     string(plan.Status),
+		ChargeTo:   plan.ChargeTo,
+		Cost:       plan.Cost.Yuan(),
+		Note:       plan.Note,
+	}
+}
+
+// SchedulePrecondition 前台安排空房在住客到达前预调温
+// 系统按热模型估算开机时间，到时以低风速请求服务，住客入住时结束
+func (h *PreconditionHandler) SchedulePrecondition(c *gin.Context) {
+	var req PreconditionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	arriveTime, err := time.ParseInLocation(timeLayout, req.ArriveTime, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的到达时间，格式应为 2006-01-02 15:04:05",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.acService.SchedulePrecondition(req.RoomID, req.TargetTemp, arriveTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "安排预调温失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("房间 %d 将于 %s 开始预调温", plan.RoomID, formatTime(plan.StartTime)),
+		Data: newPreconditionResponse(plan),
+	})
+}
+
+// CancelPrecondition 取消预调温计划
+func (h *PreconditionHandler) CancelPrecondition(c *gin.Context) {
+	var req CancelPreconditionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.acService.CancelPrecondition(req.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "取消预调温失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("预调温计划 %d 已取消", plan.ID),
+		Data: newPreconditionResponse(plan),
+	})
+}
+
+// Preconditions 查询预调温计划及其费用
+func (h *PreconditionHandler) Preconditions(c *gin.Context) {
+	var req PreconditionsRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	plans, err := h.acService.GetPreconditions(req.RoomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取预调温计划失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	response := PreconditionsResponse{Plans: make([]PreconditionResponse, 0, len(plans))}
+	hotelExpense, guestCharged := types.Fen(0), types.Fen(0)
+	for i := range plans {
+		plan := &plans[i]
+		response.Plans = append(response.Plans, newPreconditionResponse(plan))
+		if plan.ChargeTo == string(types.ChargeGuest) && plan.Status == db.PreconditionCompleted {
+			guestCharged = guestCharged.Add(plan.Cost)
+		} else {
+			hotelExpense = hotelExpense.Add(plan.Cost)
+		}
+	}
+	response.HotelExpense = hotelExpense.Yuan()
+	response.GuestCharged = guestCharged.Yuan()
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取预调温计划成功",
+		Data: response,
+	})
+}
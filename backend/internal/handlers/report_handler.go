@@ -6,24 +6,29 @@ import (
 	"backend/internal/service"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ReportRequest struct {
-	Period string `json:"period" binding:"required"`
+	Period  string `json:"period" binding:"required"`
+	Zone    string `json:"zone"`    // 只统计该机组的房间，不传时统计全部房间
+	GroupBy string `json:"groupBy"` // room(默认) 按房间统计，zone 按机组汇总
 }
 
 type ReportResponse struct {
-	DetailCount            string   `json:"detailCount"`            // 详单条数
-	DispatchCount          string   `json:"dispatchCount"`          // 调度次数
-	Duration               string   `json:"duration"`               // 请求时长
-	FanSpeedChangeCount    string   `json:"fanSpeedChangeCount"`    // 调风次数
-	Room                   *float64 `json:"room,omitempty"`         // 房间号
-	SwitchCount            float64  `json:"switchCount"`            // 开关次数
-	TemperatureChangeCount string   `json:"temperatureChangeCount"` // 调温次数
-	TotalCost              string   `json:"totalCost"`              // 总费用
+	DetailCount            string   `json:"detailCount"`             // 详单条数
+	DispatchCount          string   `json:"dispatchCount"`           // 调度次数
+	Duration               string   `json:"duration"`                // 请求时长
+	FanSpeedChangeCount    string   `json:"fanSpeedChangeCount"`     // 调风次数
+	Room                   *float64 `json:"room,omitempty"`          // 房间号
+	Zone                   string   `json:"zone"`                    // 所属机组
+	Class                  string   `json:"class,omitempty"`         // 房间参与调度的等级，按房间统计时返回
+	ClassPriority          *float64 `json:"classPriority,omitempty"` // 该等级的优先级加成，按房间统计时返回
+	RoomCount              *int     `json:"roomCount,omitempty"`     // 汇总的房间数，按机组汇总时返回
+	SwitchCount            float64  `json:"switchCount"`             // 开关次数
+	TemperatureChangeCount string   `json:"temperatureChangeCount"`  // 调温次数
+	TotalCost              string   `json:"totalCost"`               // 总费用
 }
 
 type ReportHandler struct {
@@ -32,7 +37,7 @@ type ReportHandler struct {
 
 func NewReportHandler() *ReportHandler {
 	return &ReportHandler{
-		statsService: service.NewStatisticsService(),
+		statsService: service.NewStatisticsService(service.GetClock()),
 	}
 }
 
@@ -52,15 +57,21 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 
 	switch req.Period {
 	case "daily":
-		stats, err = h.statsService.GetDailyReport(time.Now())
+		stats, err = h.statsService.GetTodayReport()
 	case "weekly":
-		stats, err = h.statsService.GetWeeklyReport(time.Now())
+		stats, err = h.statsService.GetThisWeekReport()
 	default:
 		c.JSON(http.StatusBadRequest, Response{
 			Msg: "无效的时间周期，必须是 'daily' 或 'weekly'",
 		})
 		return
 	}
+	if req.GroupBy != "" && req.GroupBy != "room" && req.GroupBy != "zone" {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的汇总方式，必须是 'room' 或 'zone'",
+		})
+		return
+	}
 
 	if err != nil {
 		logger.Error("获取报表失败: %v", err)
@@ -71,11 +82,45 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 		return
 	}
 
+	if req.Zone != "" {
+		filtered := make([]service.StatisticRecord, 0, len(stats))
+		for _, stat := range stats {
+			if stat.Zone == req.Zone {
+				filtered = append(filtered, stat)
+			}
+		}
+		stats = filtered
+	}
+
+	if req.GroupBy == "zone" {
+		responses := make([]ReportResponse, 0)
+		for _, stat := range service.AggregateByZone(stats) {
+			roomCount := stat.RoomCount
+			responses = append(responses, ReportResponse{
+				DetailCount:            strconv.Itoa(stat.DetailCount),
+				DispatchCount:          strconv.Itoa(stat.DispatchCount),
+				Duration:               strconv.FormatFloat(float64(stat.Duration), 'f', 2, 32),
+				FanSpeedChangeCount:    strconv.Itoa(stat.FanSpeedChangeCount),
+				Zone:                   stat.Zone,
+				RoomCount:              &roomCount,
+				SwitchCount:            float64(stat.SwitchCount),
+				TemperatureChangeCount: strconv.Itoa(stat.TemperatureChangeCount),
+				TotalCost:              strconv.FormatFloat(stat.TotalCost.Yuan(), 'f', 2, 64),
+			})
+		}
+		c.JSON(http.StatusOK, Response{
+			Msg:  "获取报表成功",
+			Data: responses,
+		})
+		return
+	}
+
 	// 转换为响应格式
 	responses := make([]ReportResponse, 0, len(stats))
 	for _, stat := range stats {
 		// 转换房间号
 		roomFloat := float64(stat.Room)
+		classPriority := float64(stat.ClassPriority)
 
 		response := ReportResponse{
 			DetailCount:            strconv.Itoa(stat.DetailCount),
@@ -83,9 +128,12 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 			Duration:               strconv.FormatFloat(float64(stat.Duration), 'f', 2, 32),
 			FanSpeedChangeCount:    strconv.Itoa(stat.FanSpeedChangeCount),
 			Room:                   &roomFloat,
+			Zone:                   stat.Zone,
+			Class:                  stat.Class,
+			ClassPriority:          &classPriority,
 			SwitchCount:            float64(stat.SwitchCount),
 			TemperatureChangeCount: strconv.Itoa(stat.TemperatureChangeCount),
-			TotalCost:              strconv.FormatFloat(float64(stat.TotalCost), 'f', 2, 32),
+			TotalCost:              strconv.FormatFloat(stat.TotalCost.Yuan(), 'f', 2, 64),
 		}
 		responses = append(responses, response)
 	}
@@ -95,3 +143,77 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 		Data: responses,
 	})
 }
+
+type EnergyReportRequest struct {
+	Period  string `json:"period" binding:"required"`
+	Zone    string `json:"zone"`    // 只返回该机组及其房间的记录，按全酒店汇总时忽略
+	GroupBy string `json:"groupBy"` // room(默认) 按房间，zone 按机组，hotel 汇总全酒店
+}
+
+type EnergyReportResponse struct {
+	Room        *int   `json:"room,omitempty"` // 房间号，按房间统计时返回
+	Zone        string `json:"zone,omitempty"` // 所属机组，全酒店汇总时为空
+	Duration    string `json:"duration"`       // 统计时段内的送风时长(分钟)
+	Energy      string `json:"energy"`         // 统计时段内的耗电量(度)
+	TotalEnergy string `json:"totalEnergy"`    // 累计耗电量(度)
+}
+
+// GetEnergyReport 按房间、机组或全酒店统计当天或当周的耗电量
+func (h *ReportHandler) GetEnergyReport(c *gin.Context) {
+	var req EnergyReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+	if req.Period != "daily" && req.Period != "weekly" {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的时间周期，必须是 'daily' 或 'weekly'",
+		})
+		return
+	}
+	if req.GroupBy == "" {
+		req.GroupBy = "room"
+	}
+	if req.GroupBy != "room" && req.GroupBy != "zone" && req.GroupBy != "hotel" {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的汇总方式，必须是 'room'、'zone' 或 'hotel'",
+		})
+		return
+	}
+
+	records, err := h.statsService.GetEnergyReport(req.Period, req.GroupBy)
+	if err != nil {
+		logger.Error("获取耗电量报表失败: %v", err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取耗电量报表失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]EnergyReportResponse, 0, len(records))
+	for _, record := range records {
+		if req.Zone != "" && req.GroupBy != "hotel" && record.Zone != req.Zone {
+			continue
+		}
+		response := EnergyReportResponse{
+			Zone:        record.Zone,
+			Duration:    strconv.FormatFloat(float64(record.Duration), 'f', 2, 32),
+			Energy:      strconv.FormatFloat(float64(record.Energy), 'f', 4, 32),
+			TotalEnergy: strconv.FormatFloat(record.TotalEnergy, 'f', 4, 64),
+		}
+		if req.GroupBy == "room" {
+			room := record.Room
+			response.Room = &room
+		}
+		responses = append(responses, response)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取耗电量报表成功",
+		Data: responses,
+	})
+}
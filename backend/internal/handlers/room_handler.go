@@ -2,11 +2,12 @@ package handlers
 
 import (
 	"backend/internal/db"
+	"backend/internal/logger"
 	"backend/internal/service"
+	"backend/internal/types"
 	"backend/internal/utils"
 	"bytes"
 	"fmt"
-	"math"
 	"net/http"
 	"time"
 
@@ -18,6 +19,7 @@ type CheckInRequest struct {
 	ClientID   string  `json:"client_id" binding:"required"`
 	ClientName string  `json:"client_name" binding:"required"`
 	Deposit    float32 `json:"deposit" binding:"required"` // 添加押金字段
+	GuestClass string  `json:"guest_class"`                // 住客等级 standard/premium/vip，不传时按房间等级调度
 }
 
 // PrintDetailRequest 打印详单请求结构
@@ -26,12 +28,14 @@ type PrintDetailRequest struct {
 }
 
 type RoomHandler struct {
-	roomRepo *db.RoomRepository
+	roomRepo  *db.RoomRepository
+	acService *service.ACService
 }
 
 func NewRoomHandler() *RoomHandler {
 	return &RoomHandler{
-		roomRepo: db.NewRoomRepository(),
+		roomRepo:  db.NewRoomRepository(),
+		acService: service.GetACService(),
 	}
 }
 
@@ -62,7 +66,15 @@ func (h *RoomHandler) CheckIn(c *gin.Context) {
 		return
 	}
 
-	err = h.roomRepo.CheckIn(req.RoomID, req.ClientID, req.ClientName, req.Deposit)
+	guestClass := types.RoomClass(req.GuestClass)
+	if req.GuestClass != "" && !guestClass.Valid() {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的住客等级，只能是 standard/premium/vip",
+		})
+		return
+	}
+
+	err = h.acService.CheckIn(req.RoomID, req.ClientID, req.ClientName, types.Yuan(float64(req.Deposit)))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 
@@ -71,6 +83,11 @@ func (h *RoomHandler) CheckIn(c *gin.Context) {
 		})
 		return
 	}
+	if req.GuestClass != "" {
+		if err := h.acService.SetGuestClass(req.RoomID, guestClass); err != nil {
+			logger.Error("设置房间 %d 的住客等级失败: %v", req.RoomID, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"msg":    "入住成功",
@@ -91,6 +108,9 @@ type CheckOutResponse struct {
 	ClientName   string  `json:"client_name"`  // 用户名字
 	Cost         float64 `json:"Cost"`         // 房费
 	Msg          string  `json:"msg"`          // 消息
+
+	InvoiceNumber string  `json:"invoiceNumber"` // 发票编号
+	Balance       float64 `json:"balance"`       // 应付余额(元)，负数为应退还住客
 }
 
 func (h *RoomHandler) CheckOut(c *gin.Context) {
@@ -119,42 +139,8 @@ func (h *RoomHandler) CheckOut(c *gin.Context) {
 		return
 	}
 
-	if room.State != 1 {
-		c.JSON(http.StatusBadRequest, Response{
-			Msg: "房间未入住，无法退房",
-		})
-		return
-	}
-
-	// 获取空调费用
-	var airConFare float64
-	if room.ACState == 1 {
-		// 如果空调还在运行，先关闭空调
-		scheduler := service.GetScheduler()
-		if scheduler != nil {
-			scheduler.RemoveRoom(req.RoomID)
-		}
-	}
-
-	// 计算总空调费用
-	billingService := service.GetBillingService()
-	if billingService != nil {
-		totalFee, err := billingService.CalculateTotalFee(req.RoomID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, Response{
-				Msg: "计算空调费用失败",
-				Err: err.Error(),
-			})
-			return
-		}
-		airConFare = float64(totalFee)
-	}
-	// 计算房费 (按天计算，每天100元)
-	checkoutTime := time.Now()
-	days := int(checkoutTime.Sub(room.CheckinTime).Hours()/24) + 1 // 向上取整
-	roomCost := float64(days * 100)
-	// 处理退房
-	err = h.roomRepo.CheckOut(req.RoomID)
+	// 关闭空调、结算账单并退房
+	invoice, statement, err := h.acService.CheckOut(req.RoomID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "退房失败",
@@ -162,15 +148,18 @@ func (h *RoomHandler) CheckOut(c *gin.Context) {
 		})
 		return
 	}
+
 	// 构造响应
 	response := CheckOutResponse{
-		AirConFare:   airConFare,
-		CheckinTime:  room.CheckinTime.Format("2006-01-02 15:04:05"),
-		CheckoutTime: checkoutTime.Format("2006-01-02 15:04:05"),
-		ClientID:     room.ClientID,
-		ClientName:   room.ClientName,
-		Cost:         roomCost,
-		Msg:          "退房成功",
+		AirConFare:    statement.KindTotal(db.FolioACSegment).Yuan(),
+		CheckinTime:   room.CheckinTime.Format("2006-01-02 15:04:05"),
+		CheckoutTime:  invoice.CheckoutTime.Format("2006-01-02 15:04:05"),
+		ClientID:      room.ClientID,
+		ClientName:    room.ClientName,
+		Cost:          statement.KindTotal(db.FolioRoomNight).Yuan(),
+		InvoiceNumber: invoice.Number,
+		Balance:       invoice.Balance.Yuan(),
+		Msg:           "退房成功",
 	}
 	c.JSON(http.StatusOK, response)
 }
@@ -205,8 +194,9 @@ func (h *RoomHandler) PrintDetail(c *gin.Context) {
 	}
 
 	// 获取详单信息
+	now := service.GetClock().Now()
 	billingService := service.GetBillingService()
-	details, err := billingService.GetDetails(req.RoomID, room.CheckinTime, time.Now())
+	details, err := billingService.GetDetails(req.RoomID, room.CheckinTime, now)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Msg: "获取详单失败",
@@ -239,7 +229,7 @@ func (h *RoomHandler) PrintDetail(c *gin.Context) {
 		ClientName:   room.ClientName,
 		ClientID:     room.ClientID,
 		CheckInTime:  room.CheckinTime,
-		CheckOutTime: time.Now(),
+		CheckOutTime: now,
 		TotalCost:    totalCost,
 		Details:      details,
 	}
@@ -313,27 +303,28 @@ func (h *RoomHandler) PrintBill(c *gin.Context) {
 		return
 	}
 
-	// 获取账单服务实例
-	billingService := service.GetBillingService()
-
-	// 计算空调费用总额
-	acCost, err := billingService.CalculateTotalFee(req.RoomID)
+	// 获取住客账单，房费和空调费用计算到当前时刻
+	_, statement, err := h.acService.GetFolio(req.RoomID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
-			Msg: "计算空调费用失败",
+			Msg: "获取账单失败",
 			Err: err.Error(),
 		})
 		return
 	}
 
-	// 计算入住天数（向上取整，不足一天按一天计算）
-	daysStayed := int(math.Ceil(time.Since(room.CheckinTime).Hours() / 24))
-	if daysStayed < 1 {
-		daysStayed = 1
+	// 按房费计算规则列出的房费账目，以及按工作模式汇总的空调费用
+	var roomItems []db.FolioItem
+	acByMode := make(map[string]types.Money)
+	for _, item := range statement.Items {
+		switch item.Kind {
+		case db.FolioRoomNight:
+			roomItems = append(roomItems, item)
+		case db.FolioACSegment:
+			acByMode[item.Mode] = acByMode[item.Mode].Add(item.Amount)
+		}
 	}
-
-	// 每日房费
-	roomCost := float32(daysStayed) * room.DailyRate
+	now := service.GetClock().Now()
 
 	// 准备账单数据
 	bill := utils.Bill{
@@ -341,13 +332,17 @@ func (h *RoomHandler) PrintBill(c *gin.Context) {
 		ClientName:   room.ClientName,
 		ClientID:     room.ClientID,
 		CheckInTime:  room.CheckinTime,
-		CheckOutTime: time.Now(),
-		DaysStayed:   daysStayed,
+		CheckOutTime: now,
+		RoomItems:    roomItems,
 		RoomRate:     room.DailyRate,
-		TotalRoom:    roomCost,
-		TotalAC:      acCost,
-		Deposit:      room.Deposit,
-		FinalTotal:   roomCost + acCost - room.Deposit,
+		TotalRoom:    statement.KindTotal(db.FolioRoomNight),
+		TotalAC:      statement.KindTotal(db.FolioACSegment),
+		ACByMode:     acByMode,
+		Deposit:      statement.KindTotal(db.FolioDeposit).Mul(-1),
+		FinalTotal:   statement.Balance,
+		Extras:       statement.KindTotal(db.FolioExtra),
+		Payments:     statement.KindTotal(db.FolioPayment).Mul(-1),
+		Adjustments:  statement.KindTotal(db.FolioAdjustment),
 	}
 
 	// 生成PDF
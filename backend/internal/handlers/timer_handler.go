@@ -0,0 +1,184 @@
+// internal/handlers/timer_handler.go
+package handlers
+
+import (
+	"backend/internal/db"
+	"backend/internal/service"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimerHandler struct {
+	acService *service.ACService
+}
+
+func NewTimerHandler() *TimerHandler {
+	return &TimerHandler{
+		acService: service.GetACService(),
+	}
+}
+
+// SetTimerRequest 设置定时任务的请求结构
+// 触发时间可以用 delayMinutes 指定多少分钟后触发，也可以用 fireTime 指定时刻
+type SetTimerRequest struct {
+	RoomNumber        int     `json:"roomNumber" binding:"required"`
+	Type              string  `json:"type" binding:"required"` // power_off/power_on/sleep
+	DelayMinutes      int     `json:"delayMinutes"`            // 多少分钟后触发
+	FireTime          string  `json:"fireTime"`                // 触发时间，格式 2006-01-02 15:04:05
+	TargetTemperature float32 `json:"targetTemperature"`       // 定时开机后的目标温度，不传时为默认温度
+	Step              float32 `json:"step"`                    // 睡眠曲线每小时调整的温度(°C)，正数升温、负数降温
+	Hours             int     `json:"hours"`                   // 睡眠曲线持续的小时数
+}
+
+// CancelTimerRequest 取消定时任务的请求结构
+type CancelTimerRequest struct {
+	RoomNumber int `json:"roomNumber" binding:"required"`
+	TimerID    int `json:"timerId" binding:"required"`
+}
+
+// TimerResponse 定时任务的响应结构
+type TimerResponse struct {
+	ID                int     `json:"timerId"`
+	RoomNumber        int     `json:"roomNumber"`
+	Type              string  `json:"type"`
+	FireTime          string  `json:"fireTime"`
+	NextTime          string  `json:"nextTime"` // 下一次执行的时间，任务结束后为空
+	TargetTemperature float64 `json:"targetTemperature,omitempty"`
+	Step              float64 `json:"step,omitempty"`
+	Hours             int     `json:"hours,omitempty"`
+	StepsDone         int     `json:"stepsDone,omitempty"` // 睡眠曲线已执行的调整次数
+	Status            string  `json:"status"`              // pending/running/done/cancelled/failed
+	Note              string  `json:"note,omitempty"`
+}
+
+func newTimerResponse(timer *db.RoomTimer) TimerResponse {
+	response := TimerResponse{
+		ID:                timer.ID,
+		RoomNumber:        timer.RoomID,
+		Type:              string(timer.Kind),
+		FireTime:          formatTime(timer.FireTime),
+		TargetTemperature: math.Round(float64(timer.TargetTemp)*100) / 100,
+		Step:              math.Round(float64(timer.Step)*100) / 100,
+		Hours:             timer.Hours,
+		StepsDone:         timer.StepsDone,
+		Status:            string(timer.Status),
+		Note:              timer.Note,
+	}
+	if timer.Status == db.TimerPending || timer.Status == db.TimerRunning {
+		response.NextTime = formatTime(service.NextFireTime(timer))
+	}
+	return response
+}
+
+// PanelSetTimer 设置房间的定时关机、定时开机或睡眠曲线
+func (h *TimerHandler) PanelSetTimer(c *gin.Context) {
+	var req SetTimerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	var fireTime time.Time
+	switch {
+	case req.DelayMinutes > 0:
+		fireTime = service.GetClock().Now().Add(time.Duration(req.DelayMinutes) * time.Minute)
+	case req.FireTime != "":
+		t, err := time.ParseInLocation(timeLayout, req.FireTime, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Msg: "无效的触发时间，格式应为 2006-01-02 15:04:05",
+				Err: err.Error(),
+			})
+			return
+		}
+		fireTime = t
+	default:
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "请通过 delayMinutes 或 fireTime 指定触发时间",
+		})
+		return
+	}
+
+	timer, err := h.acService.SetTimer(req.RoomNumber, db.RoomTimer{
+		Kind:       db.TimerKind(req.Type),
+		FireTime:   fireTime,
+		TargetTemp: req.TargetTemperature,
+		Step:       req.Step,
+		Hours:      req.Hours,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "设置定时任务失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("定时任务已设置，%s 执行", formatTime(timer.FireTime)),
+		Data: newTimerResponse(timer),
+	})
+}
+
+// PanelCancelTimer 取消房间的定时任务
+func (h *TimerHandler) PanelCancelTimer(c *gin.Context) {
+	var req CancelTimerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	timer, err := h.acService.CancelTimer(req.RoomNumber, req.TimerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "取消定时任务失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  fmt.Sprintf("定时任务 %d 已取消", timer.ID),
+		Data: newTimerResponse(timer),
+	})
+}
+
+// PanelTimers 查询房间的定时任务
+func (h *TimerHandler) PanelTimers(c *gin.Context) {
+	var req PowerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	timers, err := h.acService.GetTimers(req.RoomNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取定时任务失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	response := make([]TimerResponse, 0, len(timers))
+	for i := range timers {
+		response = append(response, newTimerResponse(&timers[i]))
+	}
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取定时任务成功",
+		Data: response,
+	})
+}
@@ -0,0 +1,176 @@
+// internal/handlers/zone_handler.go
+package handlers
+
+import (
+	"backend/internal/service"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ZoneHandler struct {
+	acService *service.ACService
+}
+
+func NewZoneHandler() *ZoneHandler {
+	return &ZoneHandler{
+		acService: service.GetACService(),
+	}
+}
+
+// ZoneRequest 只包含机组编号的请求，请求体可以为空
+type ZoneRequest struct {
+	Zone string `json:"zone"` // 机组编号，不传时为默认机组
+}
+
+// bindOptionalJSON 解析可选的JSON请求体，请求体为空时保留零值
+func bindOptionalJSON(c *gin.Context, req interface{}) error {
+	if err := c.ShouldBindJSON(req); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// CreateZoneRequest 新建机组的请求结构
+type CreateZoneRequest struct {
+	Zone  string `json:"zone" binding:"required"` // 机组编号
+	Name  string `json:"name"`                    // 机组名称，不传时与编号相同
+	Rooms []int  `json:"rooms"`                   // 划入该机组的房间号
+}
+
+// AssignRoomsRequest 将房间划入机组的请求结构
+type AssignRoomsRequest struct {
+	Zone  string `json:"zone" binding:"required"`
+	Rooms []int  `json:"rooms" binding:"required"`
+}
+
+// ZoneResponse 机组状态的响应结构
+type ZoneResponse struct {
+	Zone          string  `json:"zone"`
+	Name          string  `json:"name"`
+	ACState       bool    `json:"acState"`
+	OperationMode string  `json:"operationMode"`
+	Policy        string  `json:"policy"`
+	Rooms         []int   `json:"rooms"`
+	Serving       int     `json:"serving"`      // 服务队列中的房间数
+	Waiting       int     `json:"waiting"`      // 等待队列中的房间数
+	CapacityMode  string  `json:"capacityMode"` // count/power
+	Capacity      int     `json:"capacity"`     // 同时送风的房间数，count 模式使用
+	PowerBudget   float64 `json:"powerBudget"`  // 功率预算(kW)，power 模式使用
+	Used          float64 `json:"used"`         // 已占用的容量
+}
+
+// AdminZones 获取所有机组的状态
+func (h *ZoneHandler) AdminZones(c *gin.Context) {
+	zones, err := h.acService.GetZones()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Msg: "获取机组列表失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]ZoneResponse, 0, len(zones))
+	for _, zone := range zones {
+		rooms := zone.Rooms
+		if rooms == nil {
+			rooms = []int{}
+		}
+		responses = append(responses, ZoneResponse{
+			Zone:          zone.ID,
+			Name:          zone.Name,
+			ACState:       zone.IsOn,
+			OperationMode: string(zone.Mode),
+			Policy:        zone.Policy,
+			Rooms:         rooms,
+			Serving:       zone.Serving,
+			Waiting:       zone.Waiting,
+			CapacityMode:  string(zone.Capacity.Mode),
+			Capacity:      zone.Capacity.Slots,
+			PowerBudget:   math.Round(float64(zone.Capacity.Budget)*100) / 100,
+			Used:          math.Round(float64(zone.Used)*100) / 100,
+		})
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg:  "获取机组列表成功",
+		Data: responses,
+	})
+}
+
+// AdminCreateZone 新建机组
+// 新机组使用默认配置且处于关闭状态，需通过 /admin/adminpoweron 指定机组编号开启
+func (h *ZoneHandler) AdminCreateZone(c *gin.Context) {
+	var req CreateZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if err := h.acService.CreateZone(req.Zone, req.Name, req.Rooms); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "新建机组失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("机组 %s 新建成功", req.Zone),
+	})
+}
+
+// AdminDeleteZone 删除机组，机组的房间划回默认机组
+func (h *ZoneHandler) AdminDeleteZone(c *gin.Context) {
+	var req ZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Zone == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式，需要机组编号",
+		})
+		return
+	}
+
+	if err := h.acService.DeleteZone(req.Zone); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "删除机组失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("机组 %s 已删除", req.Zone),
+	})
+}
+
+// AdminAssignRooms 将房间划入机组，房间的空调必须已关闭
+func (h *ZoneHandler) AdminAssignRooms(c *gin.Context) {
+	var req AssignRoomsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "无效的请求格式",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	if err := h.acService.AssignRooms(req.Zone, req.Rooms); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Msg: "划分房间失败",
+			Err: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Msg: fmt.Sprintf("房间 %v 已划入机组 %s", req.Rooms, req.Zone),
+	})
+}
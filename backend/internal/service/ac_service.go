@@ -4,11 +4,16 @@
 package service
 
 import (
+	"backend/internal/clock"
 	"backend/internal/db"
+	"backend/internal/events"
 	"backend/internal/logger"
+	"backend/internal/thermal"
 	"backend/internal/types"
 	"fmt"
+	"reflect"
 	"sync"
+	"time"
 )
 
 // DefaultConfig 默认空调配置
@@ -18,12 +23,57 @@ var DefaultConfig = types.Config{
 	TempRanges: map[types.Mode]types.TempRange{
 		types.ModeCooling: {Min: 16, Max: 24},
 		types.ModeHeating: {Min: 22, Max: 28},
+		types.ModeAuto:    {Min: 18, Max: 28},
+		types.ModeFan:     {Min: 16, Max: 30},
+		types.ModeDry:     {Min: 16, Max: 30},
 	},
+	// 各风速的费率(元/分钟)，按每分钟耗电量乘以1元/度
 	Rates: map[types.Speed]float32{
-		types.SpeedLow:    0.5,
-		types.SpeedMedium: 1.0,
-		types.SpeedHigh:   2.0,
+		types.SpeedLow:    1.0 / 3.0,
+		types.SpeedMedium: 1.0 / 2.0,
+		types.SpeedHigh:   1.0,
 	},
+	// 各风速每分钟的耗电量(度)，默认费率即按1元/度计算
+	EnergyRates: map[types.Speed]float32{
+		types.SpeedLow:    1.0 / 3.0,
+		types.SpeedMedium: 1.0 / 2.0,
+		types.SpeedHigh:   1.0,
+	},
+	// 送风只开风机、除湿以小负荷运行压缩机，按风速计算的费用打折
+	ModeRates: map[types.Mode]float32{
+		types.ModeCooling: 1.0,
+		types.ModeHeating: 1.0,
+		types.ModeAuto:    1.0,
+		types.ModeFan:     0.2,
+		types.ModeDry:     0.8,
+	},
+	CapacityMode: types.CapacityCount,
+	MaxServices:  3,
+	PowerBudget:  1.5,
+	// 各风速的负载与计费中每分钟耗电量的假设一致
+	SpeedLoad: map[types.Speed]float32{
+		types.SpeedLow:    1.0 / 3.0,
+		types.SpeedMedium: 0.5,
+		types.SpeedHigh:   1.0,
+	},
+	TimeSlice:        2 * time.Minute,
+	WaitGrowthFactor: 0.5,
+	AgingRate:        0.25,
+	MaxWait:          10 * time.Minute,
+	Hysteresis:       1.0,
+	// 默认VIP以低风速请求时的优先级(1+3)高于标准间的高风速请求(3)
+	SpeedWeight: 1.0,
+	ClassPriority: map[types.RoomClass]float32{
+		types.ClassStandard: 0,
+		types.ClassPremium:  1,
+		types.ClassVIP:      3,
+	},
+	Thermal: types.ThermalConfig{
+		Model:          thermal.DefaultModelName,
+		Capacity:       thermal.DefaultCapacity,
+		TargetHumidity: thermal.DefaultTargetHumidity,
+	},
+	PreconditionCharge: types.ChargeHotel,
 }
 
 var (
@@ -32,133 +82,162 @@ var (
 )
 
 // ACService 空调服务对象
-// 提供空调系统的核心功能,包括开关机、温控、计费等
+// 提供空调系统的核心功能,包括开关机、温控、计费等。
+// 房间的操作按房间所属的机组路由到该机组的配置和调度器
 type ACService struct {
-	mu         sync.RWMutex
-	config     types.Config
-	roomRepo   *db.RoomRepository
-	detailRepo *db.DetailRepository
-	scheduler  *Scheduler
-	billing    *BillingService
-
-	// 中央空调状态
-	centralACState struct {
-		isOn bool       // 是否开启
-		mode types.Mode // 工作模式(制冷/制热)
-	}
+	mu               sync.RWMutex
+	zones            map[string]*Zone // 中央空调机组，key为机组编号
+	roomRepo         *db.RoomRepository
+	detailRepo       *db.DetailRepository
+	settingRepo      *db.SettingRepository
+	preconditionRepo *db.PreconditionRepository
+	timerRepo        *db.TimerRepository
+	tariffRepo       *db.TariffRepository
+	folioRepo        *db.FolioRepository
+	roomCharge       *RoomChargePolicy // 房费计算规则，nil时为默认规则
+	billing          *BillingService
+	bus              *events.Bus
+	clock            clock.Clock
+	stopChecks       func()     // 停止预调温计划的定时检查
+	timerMu          sync.Mutex // 串行执行房间的定时任务，先于mu加锁
+	stopTimers       func()     // 停止房间定时任务的定时检查
 }
 
 // ACStatus 空调状态信息结构体
 // 用于返回空调的完整运行状态
 type ACStatus struct {
-	CurrentTemp  float32     // 当前温度
-	TargetTemp   float32     // 目标温度
-	CurrentSpeed types.Speed // 当前风速
-	Mode         types.Mode  // 运行模式
-	CurrentFee   float32     // 当前费用
-	TotalFee     float32     // 总费用
-	PowerState   bool        // 开关机状态
+	CurrentTemp  float32        // 当前温度
+	TargetTemp   float32        // 目标温度
+	CurrentSpeed types.Speed    // 当前风速
+	Mode         types.Mode     // 运行模式
+	CurrentFee   types.Money    // 当前费用
+	TotalFee     types.Money    // 总费用
+	PowerState   bool           // 开关机状态
+	RunState     types.RunState // 运行状态
 }
 
 // GetACService 获取 ACService 单例
 func GetACService() *ACService {
 	acOnce.Do(func() {
-		scheduler := GetScheduler()
 		acService = &ACService{
-			config:     DefaultConfig,
-			roomRepo:   db.NewRoomRepository(),
-			detailRepo: db.NewDetailRepository(),
-			scheduler:  scheduler,
-			billing:    GetBillingService(),
-			centralACState: struct {
-				isOn bool
-				mode types.Mode
-			}{
-				isOn: false,
-				mode: types.ModeCooling,
-			},
+			zones:            make(map[string]*Zone),
+			roomRepo:         db.NewRoomRepository(),
+			detailRepo:       db.NewDetailRepository(),
+			settingRepo:      db.NewSettingRepository(),
+			preconditionRepo: db.NewPreconditionRepository(),
+			timerRepo:        db.NewTimerRepository(),
+			tariffRepo:       db.NewTariffRepository(),
+			folioRepo:        db.NewFolioRepository(),
+			billing:          GetBillingService(),
+			bus:              GetEventBus(),
+			clock:            GetClock(),
 		}
+		acService.zones[db.DefaultZone] = acService.newZone(db.DefaultZone, "默认机组")
 	})
 	return acService
 }
 
-// StartCentralAC 启动中央空调系统
-// mode: 运行模式(制冷/制热)
+// StartCentralAC 启动机组的中央空调
+// zoneID: 机组编号，为空时为默认机组
+// mode: 运行模式(制冷/制热/自动/送风/除湿)
 // 返回值: 错误信息
-func (s *ACService) StartCentralAC(mode types.Mode) error {
+func (s *ACService) StartCentralAC(zoneID string, mode types.Mode) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.centralACState.isOn {
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return err
+	}
+
+	if zone.isOn {
 		return fmt.Errorf("中央空调已经开启")
 	}
 
-	if mode != types.ModeCooling && mode != types.ModeHeating {
-		return fmt.Errorf("无效的工作模式")
+	if err := zone.checkMode(mode); err != nil {
+		return err
 	}
 
-	if err := s.roomRepo.SetACMode(string(mode)); err != nil {
+	if err := s.roomRepo.SetACMode(zone.ID, string(mode)); err != nil {
 		return fmt.Errorf("设置工作模式失败: %v", err)
 	}
 
-	s.centralACState.isOn = true
-	s.centralACState.mode = mode
+	zone.isOn = true
+	zone.mode = mode
+	zone.applyMode()
+	s.saveCentralState(zone)
 	StartMonitorService()
-	logger.Info("中央空调启动成功，工作模式：%s", mode)
+	logger.Info("机组 %s 中央空调启动成功，工作模式：%s", zone.ID, mode)
 	return nil
 }
 
-// StopCentralAC 关闭中央空调系统
+// StopCentralAC 关闭机组的中央空调，机组内开着空调的房间随之关机
+// zoneID: 机组编号，为空时为默认机组
 // 返回值: 错误信息
-func (s *ACService) StopCentralAC() error {
+func (s *ACService) StopCentralAC(zoneID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.centralACState.isOn {
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return err
+	}
+
+	if !zone.isOn {
 		return fmt.Errorf("中央空调已经关闭")
 	}
 
-	rooms, err := s.roomRepo.GetOccupiedRooms()
+	// 包括入住前预调温中的空房
+	rooms, err := s.roomRepo.GetRoomsByZone(zone.ID)
 	if err != nil {
-		return fmt.Errorf("获取已入住房间失败: %v", err)
+		return fmt.Errorf("获取机组房间失败: %v", err)
 	}
 
 	for _, room := range rooms {
 		if room.ACState == 1 {
-			if err := s.PowerOff(room.RoomID); err != nil {
+			if err := s.powerOff(zone, room.RoomID); err != nil {
 				logger.Error("关闭房间 %d 空调失败: %v", room.RoomID, err)
 			}
 		}
 	}
 
-	s.scheduler.ClearAllQueues()
-	s.centralACState.isOn = false
-	logger.Info("中央空调关闭成功")
+	zone.scheduler.ClearAllQueues()
+	zone.isOn = false
+	s.saveCentralState(zone)
+	logger.Info("机组 %s 中央空调关闭成功", zone.ID)
 	return nil
 }
 
-// SetCentralACMode 设置中央空调运行模式
+// SetCentralACMode 设置机组的中央空调运行模式
+// zoneID: 机组编号，为空时为默认机组
 // mode: 新的运行模式
 // 返回值: 错误信息
-func (s *ACService) SetCentralACMode(mode types.Mode) error {
+func (s *ACService) SetCentralACMode(zoneID string, mode types.Mode) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.centralACState.isOn {
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return err
+	}
+
+	if !zone.isOn {
 		return fmt.Errorf("中央空调未开启")
 	}
 
-	if mode != types.ModeCooling && mode != types.ModeHeating {
-		return fmt.Errorf("无效的工作模式")
+	if err := zone.checkMode(mode); err != nil {
+		return err
 	}
 
-	if err := s.roomRepo.SetACMode(string(mode)); err != nil {
+	if err := s.roomRepo.SetACMode(zone.ID, string(mode)); err != nil {
 		return fmt.Errorf("设置工作模式失败: %v", err)
 	}
 
-	s.scheduler.ClearAllQueues()
-	s.centralACState.mode = mode
-	logger.Info("中央空调模式更改为：%s", mode)
+	zone.scheduler.ClearAllQueues()
+	zone.mode = mode
+	zone.applyMode()
+	s.saveCentralState(zone)
+	logger.Info("机组 %s 中央空调模式更改为：%s", zone.ID, mode)
 	return nil
 }
 
@@ -169,15 +248,20 @@ func (s *ACService) PowerOn(roomID int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.centralACState.isOn {
-		return fmt.Errorf("中央空调未开启")
-	}
-
 	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
 		return fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+
+	if !zone.isOn {
+		return fmt.Errorf("中央空调未开启")
+	}
+
 	if room.State != 1 {
 		return fmt.Errorf("房间未入住")
 	}
@@ -186,14 +270,24 @@ func (s *ACService) PowerOn(roomID int) error {
 		return fmt.Errorf("空调已开启")
 	}
 
-	if err := s.roomRepo.PowerOnAC(roomID, string(s.centralACState.mode), s.config.DefaultTemp); err != nil {
+	if err := s.roomRepo.PowerOnAC(roomID, string(zone.mode), zone.config.DefaultTemp,
+		string(zone.config.DefaultSpeed), s.clock.Now()); err != nil {
 		return fmt.Errorf("开启空调失败: %v", err)
 	}
 
-	inService, err := s.scheduler.HandleRequest(
+	s.bus.Publish(events.Event{
+		Type:        events.PoweredOn,
+		RoomID:      roomID,
+		Speed:       zone.config.DefaultSpeed,
+		TargetTemp:  zone.config.DefaultTemp,
+		CurrentTemp: room.CurrentTemp,
+		Time:        s.clock.Now(),
+	})
+
+	inService, err := zone.scheduler.HandleRequest(
 		roomID,
-		s.config.DefaultSpeed,
-		s.config.DefaultTemp,
+		zone.config.DefaultSpeed,
+		zone.config.DefaultTemp,
 		room.CurrentTemp,
 	)
 	if err != nil {
@@ -212,21 +306,147 @@ func (s *ACService) PowerOff(roomID int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.roomRepo.GetRoomByID(roomID)
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间状态失败: %v", err)
+	}
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+	return s.powerOff(zone, roomID)
+}
+
+// powerOff 关闭机组内房间的空调，调用方需持有锁
+func (s *ACService) powerOff(zone *Zone, roomID int) error {
+	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
 		return fmt.Errorf("获取房间状态失败: %v", err)
 	}
 
-	s.scheduler.RemoveRoom(roomID)
+	zone.scheduler.RemoveRoom(roomID)
 
 	if err := s.roomRepo.PowerOffAC(roomID); err != nil {
 		return fmt.Errorf("关闭空调失败: %v", err)
 	}
+	s.bus.Publish(events.Event{
+		Type:        events.PoweredOff,
+		RoomID:      roomID,
+		TargetTemp:  room.TargetTemp,
+		CurrentTemp: room.CurrentTemp,
+		Time:        s.clock.Now(),
+	})
 
 	logger.Info("房间 %d 空调关机成功", roomID)
 	return nil
 }
 
+// CheckIn 办理入住
+// 返回值: 错误信息
+func (s *ACService) CheckIn(roomID int, clientID, clientName string, deposit types.Money) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+
+	if room.State != 0 {
+		return fmt.Errorf("房间已被占用")
+	}
+
+	// 入住前的预调温随入住结束，空调关机后由住客自行开机
+	plan, err := s.finishPreconditions(room)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roomRepo.CheckIn(roomID, clientID, clientName, deposit, s.clock.Now()); err != nil {
+		return fmt.Errorf("入住失败: %v", err)
+	}
+	// 开立住客账单并记入押金
+	if room, err = s.roomRepo.GetRoomByID(roomID); err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if _, err := s.openFolio(room); err != nil {
+		logger.Error("房间 %d 开立账单失败: %v", roomID, err)
+	}
+	s.bus.Publish(events.Event{
+		Type:        events.CheckedIn,
+		RoomID:      roomID,
+		CurrentTemp: room.CurrentTemp,
+		Time:        s.clock.Now(),
+	})
+
+	if plan != nil && plan.ChargeTo == string(types.ChargeGuest) {
+		if err := s.billing.ChargePrecondition(plan, types.Mode(room.Mode), s.clock.Now()); err != nil {
+			logger.Error("房间 %d 的预调温费用计入账单失败: %v", roomID, err)
+		}
+	}
+
+	logger.Info("房间 %d 入住成功", roomID)
+	return nil
+}
+
+// CheckOut 办理退房
+// 空调仍在运行时先将房间移出调度器，再结算房费和空调费用，将住客账单结算为发票
+// 返回值:
+//   - *db.Invoice: 开具的发票
+//   - *FolioStatement: 发票的账目及合计
+//   - error: 错误信息
+func (s *ACService) CheckOut(roomID int) (*db.Invoice, *FolioStatement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取房间信息失败: %v", err)
+	}
+
+	if room.State != 1 {
+		return nil, nil, fmt.Errorf("房间未入住，无法退房")
+	}
+
+	if room.ACState == 1 {
+		zone, err := s.roomZone(room)
+		if err != nil {
+			return nil, nil, err
+		}
+		zone.scheduler.RemoveRoom(roomID)
+	}
+
+	totalFee, err := s.billing.CalculateTotalFee(roomID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("计算空调费用失败: %v", err)
+	}
+
+	now := s.clock.Now()
+	invoice, statement, settled, err := s.finalizeFolio(room, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("结算账单失败: %v", err)
+	}
+
+	if err := s.roomRepo.CheckOut(roomID, now); err != nil {
+		// 房间仍为入住状态，撤销结算，账单和发票编号留给下一次退房
+		s.reopenFolio(invoice, settled)
+		return nil, nil, fmt.Errorf("退房失败: %v", err)
+	}
+	if err := s.timerRepo.CancelByRoom(roomID, "住客已退房", now); err != nil {
+		logger.Error("%v", err)
+	}
+	s.bus.Publish(events.Event{
+		Type:        events.CheckedOut,
+		RoomID:      roomID,
+		CurrentTemp: room.CurrentTemp,
+		Time:        now,
+		Fee:         totalFee,
+	})
+
+	logger.Info("房间 %d 退房成功，空调费用 %s，发票 %s", roomID, totalFee, invoice.Number)
+	return invoice, statement, nil
+}
+
 // SetTemperature 设置目标温度
 // roomID: 房间号
 // targetTemp: 目标温度
@@ -235,20 +455,25 @@ func (s *ACService) SetTemperature(roomID int, targetTemp float32) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.centralACState.isOn {
-		return fmt.Errorf("中央空调未开启")
-	}
-
 	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
 		return fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+
+	if !zone.isOn {
+		return fmt.Errorf("中央空调未开启")
+	}
+
 	if room.ACState != 1 {
 		return fmt.Errorf("空调未开启")
 	}
 
-	if !s.isValidTemp(types.Mode(room.Mode), targetTemp) {
+	if !zone.isValidTemp(types.Mode(room.Mode), targetTemp) {
 		return fmt.Errorf("温度 %.1f°C 超出当前模式允许范围", targetTemp)
 	}
 
@@ -260,10 +485,10 @@ func (s *ACService) SetTemperature(roomID int, targetTemp float32) error {
 		return fmt.Errorf("更新目标温度失败: %v", err)
 	}
 
-	// 将温度调节请求发送给调度器
-	inService, err := s.scheduler.HandleRequest(
+	// 将温度调节请求发送给机组的调度器，风速沿用住客请求的风速
+	inService, err := zone.scheduler.HandleRequest(
 		roomID,
-		types.Speed(room.CurrentSpeed),
+		zone.scheduler.RequestedSpeed(roomID, types.Speed(room.CurrentSpeed)),
 		targetTemp,
 		room.CurrentTemp,
 	)
@@ -290,20 +515,25 @@ func (s *ACService) SetFanSpeed(roomID int, speed types.Speed) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.centralACState.isOn {
-		return fmt.Errorf("中央空调未开启")
-	}
-
 	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
 		return fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+
+	if !zone.isOn {
+		return fmt.Errorf("中央空调未开启")
+	}
+
 	if room.ACState != 1 {
 		return fmt.Errorf("空调未开启")
 	}
 
-	inService, err := s.scheduler.HandleRequest(
+	inService, err := zone.scheduler.HandleRequest(
 		roomID,
 		speed,
 		room.TargetTemp,
@@ -318,10 +548,7 @@ func (s *ACService) SetFanSpeed(roomID int, speed types.Speed) error {
 		return nil
 	}
 
-	if err := s.roomRepo.UpdateSpeed(roomID, string(speed)); err != nil {
-		return fmt.Errorf("设置风速失败: %v", err)
-	}
-
+	// 服务中的房间由调度器记录实际送风的风速，限负荷时可能低于请求的风速
 	logger.Info("房间 %d 设置风速为 %s 成功", roomID, speed)
 	return nil
 }
@@ -337,7 +564,7 @@ func (s *ACService) GetACStatus(roomID int) (*ACStatus, error) {
 		return nil, fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
-	var currentFee, totalFee float32 = 0, 0
+	currentFee, totalFee := types.Fen(0), types.Fen(0)
 	if room.ACState == 1 {
 		// 获取当前费用
 		currentFee, err = s.billing.CalculateCurrentSessionFee(roomID)
@@ -360,56 +587,113 @@ func (s *ACService) GetACStatus(roomID int) (*ACStatus, error) {
 		CurrentFee:   currentFee,
 		TotalFee:     totalFee,
 		PowerState:   room.ACState == 1,
+		RunState:     RoomRunState(room),
 	}
 
 	return status, nil
 }
 
-// GetCentralACState 获取中央空调运行状态
+// GetCentralACState 获取机组的中央空调运行状态
+// zoneID: 机组编号，为空时为默认机组
 // 返回值:
 //   - bool: 是否开启
 //   - types.Mode: 当前运行模式
-func (s *ACService) GetCentralACState() (bool, types.Mode) {
+//   - error: 机组不存在时返回错误
+func (s *ACService) GetCentralACState(zoneID string) (bool, types.Mode, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.centralACState.isOn, s.centralACState.mode
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return false, "", err
+	}
+	return zone.isOn, zone.mode, nil
 }
 
-// GetConfig 获取空调配置
-func (s *ACService) GetConfig() types.Config {
+// GetConfig 获取机组的空调配置
+// 返回的是配置的副本，修改后需通过 SetConfig 生效
+func (s *ACService) GetConfig(zoneID string) (types.Config, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.config
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return types.Config{}, err
+	}
+	return cloneConfig(zone.config), nil
 }
 
-// SetConfig 设置空调系统配置
+// SetConfig 设置机组的空调配置
+// 新配置会持久化到数据库，调度参数立即应用到机组的调度器
+// zoneID: 机组编号，为空时为默认机组
 // config: 新的配置信息
 // 返回值: 错误信息
-func (s *ACService) SetConfig(config types.Config) error {
+func (s *ACService) SetConfig(zoneID string, config types.Config) error {
+	return s.UpdateConfig(zoneID, func(current *types.Config) error {
+		*current = cloneConfig(config)
+		return nil
+	})
+}
+
+// UpdateConfig 在同一次加锁内读取、修改并应用机组的空调配置
+// 并发修改同一机组的不同参数时不会互相覆盖
+// update: 修改配置的副本，返回错误时配置保持不变
+func (s *ACService) UpdateConfig(zoneID string, update func(config *types.Config) error) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	config := cloneConfig(zone.config)
+	if err := update(&config); err != nil {
+		s.mu.Unlock()
+		return err
+	}
 
 	// 验证配置
-	if err := s.validateConfig(config); err != nil {
+	if err := zone.validateConfig(config); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	if err := zone.applySchedulingConfig(config); err != nil {
+		s.mu.Unlock()
 		return err
 	}
 
 	// 更新配置
-	s.config = config
-	logger.Info("空调配置已更新")
+	zone.config = cloneConfig(config)
+	zone.applyMode()
+	if err := s.settingRepo.Save(db.ZoneKey(db.SettingACConfig, zone.ID), zone.config, s.clock.Now()); err != nil {
+		logger.Error("保存空调配置失败: %v", err)
+	}
+	s.billing.SetEnergyRates(zone.ID, zone.config.EnergyRates)
+	// 费率调整时结束服务中房间的当前服务段，调整前后的时段分别按各自的费率计费
+	if s.recordTariffs(zone, s.clock.Now()) {
+		zone.scheduler.SplitServing()
+		logger.Info("机组 %s 费率已调整，服务中的房间按新费率计费", zone.ID)
+	}
+	s.bus.Publish(events.Event{Type: events.ConfigChanged, Time: s.clock.Now()})
+	logger.Info("机组 %s 空调配置已更新", zone.ID)
 
-	// 检查所有房间的目标温度是否在新范围内
+	// 检查机组内所有房间的目标温度是否在新范围内
 	rooms, err := s.roomRepo.GetOccupiedRooms()
+	s.mu.Unlock()
 	if err != nil {
 		logger.Error("获取已入住房间失败: %v", err)
 		return err
 	}
 
-	// 遍历所有房间，将超出范围的目标温度调整到范围内
+	// 遍历机组内的房间，将超出范围的目标温度调整到范围内
+	// SetTemperature 会重新加锁，因此需在释放锁之后调用
 	for _, room := range rooms {
-		if room.ACState == 1 {
+		if room.ACState == 1 && room.Zone == zone.ID {
 			currentMode := types.Mode(room.Mode)
-			tempRange := config.TempRanges[currentMode]
+			tempRange, ok := config.TempRanges[currentMode]
+			if !ok {
+				continue
+			}
 
 			if room.TargetTemp < tempRange.Min {
 				if err := s.SetTemperature(room.RoomID, tempRange.Min); err != nil {
@@ -426,13 +710,352 @@ func (s *ACService) SetConfig(config types.Config) error {
 	return nil
 }
 
+// SetCapacity 修改机组服务队列容量
+func (s *ACService) SetCapacity(zoneID string, maxServices int) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		config.MaxServices = maxServices
+		return nil
+	})
+}
+
+// SetPowerBudget 修改机组的容量模型和功率预算
+// mode 为空、budget 为nil时沿用当前配置，loads 中未包含的风速沿用当前负载
+func (s *ACService) SetPowerBudget(zoneID string, mode types.CapacityMode, budget *float32, loads map[types.Speed]float32) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		if mode != "" {
+			config.CapacityMode = mode
+		}
+		if budget != nil {
+			config.PowerBudget = *budget
+		}
+		for speed, load := range loads {
+			config.SpeedLoad[speed] = load
+		}
+		return nil
+	})
+}
+
+// SetTimeSlice 修改机组的时间片和等待时长增长系数，growthFactor 为nil时沿用当前配置
+func (s *ACService) SetTimeSlice(zoneID string, timeSlice time.Duration, growthFactor *float32) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		config.TimeSlice = timeSlice
+		if growthFactor != nil {
+			config.WaitGrowthFactor = *growthFactor
+		}
+		return nil
+	})
+}
+
+// SetAging 修改机组的等待优先级老化速率和最长等待时间
+// agingRate 为nil、maxWait 为0时沿用当前配置，最长等待时间不能小于时间片
+func (s *ACService) SetAging(zoneID string, agingRate *float32, maxWait time.Duration) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		if agingRate != nil {
+			config.AgingRate = *agingRate
+		}
+		if maxWait != 0 {
+			config.MaxWait = maxWait
+		}
+		if config.MaxWait < config.TimeSlice {
+			return fmt.Errorf("最长等待时间不能小于时间片")
+		}
+		return nil
+	})
+}
+
+// SetHysteresis 修改机组的回差
+func (s *ACService) SetHysteresis(zoneID string, hysteresis float32) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		config.Hysteresis = hysteresis
+		return nil
+	})
+}
+
+// SetPreconditionCharge 修改机组内房间入住前预调温费用的计费对象，对之后安排的预调温计划生效
+func (s *ACService) SetPreconditionCharge(zoneID string, charge types.PreconditionCharge) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		config.PreconditionCharge = charge
+		return nil
+	})
+}
+
+// SetPriorityWeights 修改机组的风速优先级权重和各房间等级的优先级加成，未给出的等级保持不变
+func (s *ACService) SetPriorityWeights(zoneID string, speedWeight float32, classPriority map[types.RoomClass]float32) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		config.SpeedWeight = speedWeight
+		for class, weight := range classPriority {
+			config.ClassPriority[class] = weight
+		}
+		return nil
+	})
+}
+
+// SetRoomClass 修改房间等级，队列中的房间立即按新的等级调度
+func (s *ACService) SetRoomClass(roomID int, class types.RoomClass) error {
+	if !class.Valid() {
+		return fmt.Errorf("无效的房间等级: %s", class)
+	}
+	return s.updateClass(roomID, func() error { return s.roomRepo.UpdateClass(roomID, string(class)) })
+}
+
+// SetGuestClass 设置本次入住的住客等级，退房时清除
+func (s *ACService) SetGuestClass(roomID int, class types.RoomClass) error {
+	if !class.Valid() {
+		return fmt.Errorf("无效的住客等级: %s", class)
+	}
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if room.State != 1 {
+		return fmt.Errorf("房间未入住")
+	}
+	return s.updateClass(roomID, func() error { return s.roomRepo.UpdateGuestClass(roomID, string(class)) })
+}
+
+// updateClass 修改房间或住客等级，并通知房间所属机组的调度器
+func (s *ACService) updateClass(roomID int, update func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := update(); err != nil {
+		return err
+	}
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+	class := RoomClass(room)
+	zone.scheduler.SetRoomClass(roomID, class)
+	logger.Info("房间 %d 的等级已修改为 %s", roomID, class)
+	return nil
+}
+
+// RoomClass 房间当前参与调度的等级，取房间等级和住客等级中较高的
+func RoomClass(room *db.RoomInfo) types.RoomClass {
+	return types.HigherClass(types.RoomClass(room.Class), types.RoomClass(room.GuestClass))
+}
+
+// SetThermal 修改机组的房间热模型、室外温度和各风速的制冷/制热功率
+// update 在加锁后基于机组当前的热模型修改，未修改的参数保持不变
+func (s *ACService) SetThermal(zoneID string, update func(thermalConfig *types.ThermalConfig)) error {
+	return s.UpdateConfig(zoneID, func(config *types.Config) error {
+		update(&config.Thermal)
+		return nil
+	})
+}
+
+// SetRoomThermalParams 修改房间的热参数，从下一个周期开始生效
+func (s *ACService) SetRoomThermalParams(roomID int, params thermal.Params) error {
+	if params.Volume <= 0 || params.Insulation < 0 || params.OccupancyLoad < 0 {
+		return fmt.Errorf("房间热参数无效")
+	}
+	return s.roomRepo.UpdateThermalParams(roomID, params.Volume, params.Insulation, params.OccupancyLoad)
+}
+
+// restoreConfig 从数据库恢复各机组上次保存的空调配置，没有保存过时使用默认配置
+func (s *ACService) restoreConfig() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, zone := range s.zones {
+		s.restoreZoneConfig(zone)
+		// 记录恢复后的费率，首次启动时为费率表写入初始费率
+		s.recordTariffs(zone, s.clock.Now())
+		s.billing.SetEnergyRates(zone.ID, zone.config.EnergyRates)
+	}
+}
+
+// restoreZoneConfig 恢复机组的空调配置，调用方需持有锁
+func (s *ACService) restoreZoneConfig(zone *Zone) {
+	var config types.Config
+	found, err := s.settingRepo.Load(db.ZoneKey(db.SettingACConfig, zone.ID), &config)
+	if err != nil {
+		logger.Error("恢复机组 %s 的空调配置失败，使用默认配置: %v", zone.ID, err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	// 旧版本保存的配置可能缺少调度参数，缺少的部分使用默认值
+	if config.MaxServices == 0 {
+		config.MaxServices = DefaultConfig.MaxServices
+	}
+	if config.CapacityMode == "" {
+		defaults := cloneConfig(DefaultConfig)
+		config.CapacityMode = defaults.CapacityMode
+		config.PowerBudget = defaults.PowerBudget
+		config.SpeedLoad = defaults.SpeedLoad
+	}
+	if config.TimeSlice == 0 {
+		config.TimeSlice = DefaultConfig.TimeSlice
+		config.WaitGrowthFactor = DefaultConfig.WaitGrowthFactor
+	}
+	if config.MaxWait == 0 {
+		config.AgingRate = DefaultConfig.AgingRate
+		config.MaxWait = DefaultConfig.MaxWait
+	}
+	if config.Hysteresis == 0 {
+		config.Hysteresis = DefaultConfig.Hysteresis
+	}
+	if config.Thermal.Model == "" {
+		config.Thermal = cloneConfig(DefaultConfig).Thermal
+	}
+	if config.Thermal.TargetHumidity == 0 {
+		config.Thermal.TargetHumidity = DefaultConfig.Thermal.TargetHumidity
+	}
+	if config.PreconditionCharge == "" {
+		config.PreconditionCharge = DefaultConfig.PreconditionCharge
+	}
+	if config.SpeedWeight == 0 {
+		defaults := cloneConfig(DefaultConfig)
+		config.SpeedWeight = defaults.SpeedWeight
+		config.ClassPriority = defaults.ClassPriority
+	}
+	if config.ModeRates == nil {
+		config.ModeRates = cloneConfig(DefaultConfig).ModeRates
+	}
+	if config.EnergyRates == nil {
+		config.EnergyRates = cloneConfig(DefaultConfig).EnergyRates
+	}
+	if config.TempRanges == nil {
+		config.TempRanges = make(map[types.Mode]types.TempRange)
+	}
+	for _, mode := range types.Modes {
+		if _, ok := config.TempRanges[mode]; !ok {
+			config.TempRanges[mode] = DefaultConfig.TempRanges[mode]
+		}
+	}
+
+	if err := zone.validateConfig(config); err != nil {
+		logger.Error("机组 %s 保存的空调配置无效，使用默认配置: %v", zone.ID, err)
+		return
+	}
+	if err := zone.applySchedulingConfig(config); err != nil {
+		logger.Error("恢复机组 %s 的调度参数失败，使用默认配置: %v", zone.ID, err)
+		return
+	}
+	zone.config = config
+	zone.applyMode()
+	logger.Info("已恢复机组 %s 保存的空调配置", zone.ID)
+}
+
+// applySchedulingConfig 将配置中的调度参数和热模型应用到机组的调度器
+// 热模型最先构造，构造失败时调度器保持不变
+func (z *Zone) applySchedulingConfig(config types.Config) error {
+	if !reflect.DeepEqual(config.Thermal, z.config.Thermal) {
+		model, err := thermal.NewModel(config.Thermal)
+		if err != nil {
+			return err
+		}
+		weather, err := thermal.NewWeather(config.Thermal)
+		if err != nil {
+			return err
+		}
+		z.scheduler.SetThermal(model, weather, config.Thermal.TargetHumidity)
+	}
+	if capacity := CapacityFromConfig(config); !reflect.DeepEqual(capacity, z.scheduler.GetCapacity()) {
+		if err := z.scheduler.SetCapacity(capacity); err != nil {
+			return err
+		}
+	}
+	if config.TimeSlice != z.config.TimeSlice || config.WaitGrowthFactor != z.config.WaitGrowthFactor {
+		if err := z.scheduler.SetTimeSlice(config.TimeSlice, config.WaitGrowthFactor); err != nil {
+			return err
+		}
+	}
+	if config.AgingRate != z.config.AgingRate || config.MaxWait != z.config.MaxWait {
+		if err := z.scheduler.SetAging(config.AgingRate, config.MaxWait); err != nil {
+			return err
+		}
+	}
+	if config.Hysteresis != z.config.Hysteresis {
+		if err := z.scheduler.SetHysteresis(config.Hysteresis); err != nil {
+			return err
+		}
+	}
+	if config.SpeedWeight != z.config.SpeedWeight || !reflect.DeepEqual(config.ClassPriority, z.config.ClassPriority) {
+		if err := z.scheduler.SetPriorityWeights(config.SpeedWeight, config.ClassPriority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMode 检查工作模式是否有效且机组配置了该模式的温度范围
+func (z *Zone) checkMode(mode types.Mode) error {
+	if !mode.Valid() {
+		return fmt.Errorf("无效的工作模式")
+	}
+	if _, ok := z.config.TempRanges[mode]; !ok {
+		return fmt.Errorf("机组 %s 未配置模式 %s 的温度范围", z.ID, mode)
+	}
+	return nil
+}
+
+// modeRate 机组当前工作模式的费率系数，未配置时为1
+func (z *Zone) modeRate() float32 {
+	if rate, ok := z.config.ModeRates[z.mode]; ok {
+		return rate
+	}
+	return 1
+}
+
+// applyMode 将机组的工作模式和费率系数应用到调度器，调用方需持有锁
+func (z *Zone) applyMode() {
+	z.scheduler.SetMode(z.mode, z.modeRate())
+}
+
+// cloneConfig 复制配置，避免不同配置共享温度范围、费率表、分时电价表、耗电量、负载表和热模型参数
+func cloneConfig(config types.Config) types.Config {
+	clone := config
+	clone.TempRanges = make(map[types.Mode]types.TempRange, len(config.TempRanges))
+	for mode, tempRange := range config.TempRanges {
+		clone.TempRanges[mode] = tempRange
+	}
+	clone.Rates = make(map[types.Speed]float32, len(config.Rates))
+	for speed, rate := range config.Rates {
+		clone.Rates[speed] = rate
+	}
+	clone.ModeRates = make(map[types.Mode]float32, len(config.ModeRates))
+	for mode, rate := range config.ModeRates {
+		clone.ModeRates[mode] = rate
+	}
+	clone.TimeOfUse = cloneTimeOfUse(config.TimeOfUse)
+	clone.EnergyRates = make(map[types.Speed]float32, len(config.EnergyRates))
+	for speed, energy := range config.EnergyRates {
+		clone.EnergyRates[speed] = energy
+	}
+	clone.SpeedLoad = make(map[types.Speed]float32, len(config.SpeedLoad))
+	for speed, load := range config.SpeedLoad {
+		clone.SpeedLoad[speed] = load
+	}
+	clone.ClassPriority = make(map[types.RoomClass]float32, len(config.ClassPriority))
+	for class, weight := range config.ClassPriority {
+		clone.ClassPriority[class] = weight
+	}
+	clone.Thermal.Capacity = make(map[types.Speed]float32, len(config.Thermal.Capacity))
+	for speed, power := range config.Thermal.Capacity {
+		clone.Thermal.Capacity[speed] = power
+	}
+	if config.Thermal.OutdoorTemp != nil {
+		outdoor := *config.Thermal.OutdoorTemp
+		clone.Thermal.OutdoorTemp = &outdoor
+	}
+	return clone
+}
+
 // 内部辅助方法
 // isValidTemp 检查温度是否在指定模式的有效范围内
 // mode: 运行模式
 // temp: 待检查的温度
 // 返回值: 是否有效
-func (s *ACService) isValidTemp(mode types.Mode, temp float32) bool {
-	if tempRange, ok := s.config.TempRanges[mode]; ok {
+func (z *Zone) isValidTemp(mode types.Mode, temp float32) bool {
+	if tempRange, ok := z.config.TempRanges[mode]; ok {
 		return temp >= tempRange.Min && temp <= tempRange.Max
 	}
 	return false
@@ -441,10 +1064,10 @@ func (s *ACService) isValidTemp(mode types.Mode, temp float32) bool {
 // validateConfig 验证配置参数是否有效
 // config: 待验证的配置
 // 返回值: 错误信息
-func (s *ACService) validateConfig(config types.Config) error {
+func (z *Zone) validateConfig(config types.Config) error {
 	// 验证默认温度
-	if !s.isValidTemp(types.ModeCooling, config.DefaultTemp) &&
-		!s.isValidTemp(types.ModeHeating, config.DefaultTemp) {
+	if !z.isValidTemp(types.ModeCooling, config.DefaultTemp) &&
+		!z.isValidTemp(types.ModeHeating, config.DefaultTemp) {
 		return fmt.Errorf("默认温度超出有效范围")
 	}
 
@@ -461,36 +1084,140 @@ func (s *ACService) validateConfig(config types.Config) error {
 			return fmt.Errorf("风速 %s 的费率无效", speed)
 		}
 	}
+	for mode, rate := range config.ModeRates {
+		if !mode.Valid() {
+			return fmt.Errorf("无效的工作模式: %s", mode)
+		}
+		if rate < 0 {
+			return fmt.Errorf("模式 %s 的费率系数不能为负数", mode)
+		}
+	}
+	if err := validateTimeOfUse(config.TimeOfUse); err != nil {
+		return fmt.Errorf("分时电价表无效: %v", err)
+	}
+	for speed, energy := range config.EnergyRates {
+		if energy <= 0 {
+			return fmt.Errorf("风速 %s 的耗电量无效", speed)
+		}
+	}
+	if config.Thermal.TargetHumidity <= 0 || config.Thermal.TargetHumidity >= 100 {
+		return fmt.Errorf("除湿目标湿度必须在0到100之间")
+	}
+
+	// 验证调度参数
+	if err := CapacityFromConfig(config).Validate(); err != nil {
+		return err
+	}
+	if config.TimeSlice < tickInterval {
+		return fmt.Errorf("时间片不能小于 %v", tickInterval)
+	}
+	if config.WaitGrowthFactor < 0 {
+		return fmt.Errorf("等待时长增长系数不能为负数")
+	}
+	if config.AgingRate < 0 {
+		return fmt.Errorf("优先级老化速率不能为负数")
+	}
+	if config.MaxWait < config.TimeSlice {
+		return fmt.Errorf("最长等待时间不能小于时间片")
+	}
+	if config.Hysteresis <= 0 {
+		return fmt.Errorf("回差必须大于0")
+	}
+	if config.SpeedWeight <= 0 {
+		return fmt.Errorf("风速优先级的权重必须大于0")
+	}
+	for class, weight := range config.ClassPriority {
+		if !class.Valid() {
+			return fmt.Errorf("无效的房间等级: %s", class)
+		}
+		if weight < 0 {
+			return fmt.Errorf("房间等级 %s 的优先级加成不能为负数", class)
+		}
+	}
+	if !config.PreconditionCharge.Valid() {
+		return fmt.Errorf("无效的预调温计费对象: %s", config.PreconditionCharge)
+	}
+
+	// 验证热模型
+	if _, err := thermal.NewModel(config.Thermal); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// GetQueueInfo 获取队列状态
-func (s *ACService) GetQueueInfo() (map[int]*ServiceObject, []*WaitObject) {
-	return s.scheduler.GetServiceQueue(), s.scheduler.GetWaitQueue()
+// SetSchedulingPolicy 按名称切换机组调度器的调度策略
+func (s *ACService) SetSchedulingPolicy(zoneID, name string) error {
+	scheduler, err := s.zoneScheduler(zoneID)
+	if err != nil {
+		return err
+	}
+	return scheduler.SetPolicy(name)
+}
+
+// GetSchedulingPolicy 获取机组当前的调度策略名称
+func (s *ACService) GetSchedulingPolicy(zoneID string) (string, error) {
+	scheduler, err := s.zoneScheduler(zoneID)
+	if err != nil {
+		return "", err
+	}
+	return scheduler.GetPolicyName(), nil
+}
+
+// GetQueueSnapshot 获取机组调度队列的只读快照
+func (s *ACService) GetQueueSnapshot(zoneID string) (SchedulerSnapshot, error) {
+	scheduler, err := s.zoneScheduler(zoneID)
+	if err != nil {
+		return SchedulerSnapshot{}, err
+	}
+	return scheduler.Snapshot(), nil
+}
+
+// GetScheduler 获取机组的调度器实例，机组不存在时返回nil
+func (s *ACService) GetScheduler(zoneID string) *Scheduler {
+	scheduler, _ := s.zoneScheduler(zoneID)
+	return scheduler
 }
 
-// GetScheduler 获取调度器实例
-func (s *ACService) GetScheduler() *Scheduler {
-	return s.scheduler
+// zoneScheduler 获取机组的调度器
+func (s *ACService) zoneScheduler(zoneID string) (*Scheduler, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	return zone.scheduler, nil
 }
 
 // 以下是一些用于测试和调试的辅助方法
 
-// ResetState 重置服务状态（仅用于测试）
+// ResetState 重置所有机组的状态（仅用于测试）
 func (s *ACService) ResetState() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.centralACState.isOn = false
-	s.centralACState.mode = types.ModeCooling
-	s.config = DefaultConfig
-	s.scheduler.ClearAllQueues()
+	for _, zone := range s.zones {
+		zone.isOn = false
+		zone.mode = types.ModeCooling
+		zone.scheduler.ClearAllQueues()
+		if err := zone.applySchedulingConfig(DefaultConfig); err != nil {
+			logger.Error("重置机组 %s 的调度参数失败: %v", zone.ID, err)
+		}
+		zone.config = cloneConfig(DefaultConfig)
+		zone.applyMode()
+		s.recordTariffs(zone, s.clock.Now())
+		s.billing.SetEnergyRates(zone.ID, zone.config.EnergyRates)
+	}
 }
 
 // SetLogging 设置是否启用服务日志
 func (s *ACService) SetLogging(enable bool) {
-	s.scheduler.SetLogging(enable)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, zone := range s.zones {
+		zone.scheduler.SetLogging(enable)
+	}
 }
 
 // GetRoomRepo 获取房间存储库（用于测试）
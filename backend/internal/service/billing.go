@@ -2,33 +2,43 @@
 package service
 
 import (
+	"backend/internal/clock"
 	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/logger"
+	"backend/internal/types"
 	"fmt"
 	"math"
+	"sync"
 	"time"
 )
 
 // 电费费率 (元/度)
 const PowerRate = 1.0
-const TimeScale = 6.0
 
-// roundTo2Decimals 将浮点数四舍五入到2位小数
+// roundTo2Decimals 将时长、温度等浮点数四舍五入到2位小数，金额的舍入见 types.Yuan
 func roundTo2Decimals(value float32) float32 {
 	return float32(math.Round(float64(value)*100) / 100)
 }
 
-// 不同风速的费率 (元/分钟)
-var speedToRate = map[string]float32{
-	"high":   1.0,       // 1元/分钟 (1度电/分钟 * 1元/度)
-	"medium": 1.0 / 2.0, // 0.5元/分钟 (0.5度电/分钟 * 1元/度)
-	"low":    1.0 / 3.0, // 0.33元/分钟 (0.33度电/分钟 * 1元/度)
+// periodCost 计费时段从start到end按费率(元/分钟)计算的费用，每个计费时段只在这里舍入到分一次
+func periodCost(start, end time.Time, rate float32) types.Money {
+	return types.Yuan(end.Sub(start).Minutes() * float64(rate))
 }
 
 // BillingService 账单服务
 type BillingService struct {
 	roomRepo   *db.RoomRepository
 	detailRepo *db.DetailRepository
-	scheduler  *Scheduler
+	tariffRepo *db.TariffRepository // 服务段按开始时生效的费率计费
+	energyRepo *db.EnergyRepository // 房间、机组和全酒店的累计耗电量
+	schedulers *SchedulerRegistry   // 按房间所属机组查找调度器
+	clock      clock.Clock
+	// 详单由事件订阅者异步写入，读取详单前需先 FlushDetails
+	subscription *events.Subscription
+	// 各机组每个风速每分钟的耗电量，由空调服务在配置变化时设置
+	energyMu    sync.RWMutex
+	energyRates map[string]map[types.Speed]float32
 }
 
 // BillResponse 账单响应
@@ -37,192 +47,402 @@ type BillResponse struct {
 	CheckInTime   time.Time   `json:"check_in_time"`
 	CheckOutTime  time.Time   `json:"check_out_time"`
 	TotalDuration float32     `json:"total_duration"` // 总使用时长(分钟)
-	TotalCost     float32     `json:"total_cost"`     // 总费用(元)
+	TotalCost     types.Money `json:"total_cost"`     // 总费用
 	Details       []db.Detail `json:"details"`        // 详单列表
 }
 
 // CurrentBill 实时费用计算结果
 type CurrentBill struct {
-	RoomID      int       `json:"room_id"`
-	CurrentFee  float32   `json:"current_fee"`   // 当前时段费用(元)
-	TotalFee    float32   `json:"total_fee"`     // 总费用(元)
-	LastBilled  time.Time `json:"last_billed"`   // 上次计费时间点
-	IsInService bool      `json:"is_in_service"` // 是否在服务队列中
+	RoomID      int         `json:"room_id"`
+	CurrentFee  types.Money `json:"current_fee"`   // 当前时段费用
+	TotalFee    types.Money `json:"total_fee"`     // 总费用
+	LastBilled  time.Time   `json:"last_billed"`   // 上次计费时间点
+	IsInService bool        `json:"is_in_service"` // 是否在服务队列中
 }
 
 // NewBillingService 创建账单服务
-func NewBillingService(scheduler *Scheduler) *BillingService {
+func NewBillingService(schedulers *SchedulerRegistry, clk clock.Clock) *BillingService {
 	return &BillingService{
 		roomRepo:   db.NewRoomRepository(),
 		detailRepo: db.NewDetailRepository(),
-		scheduler:  scheduler,
+		tariffRepo: db.NewTariffRepository(),
+		energyRepo: db.NewEnergyRepository(),
+		schedulers: schedulers,
+		clock:      clk,
+	}
+}
+
+// Subscribe 订阅房间运行状态的变化和风速调整，为每个事件写入对应的详单
+func (s *BillingService) Subscribe(bus *events.Bus) {
+	s.subscription = bus.SubscribeLossless("billing", s.handleEvent, events.StateChanged, events.SpeedChanged)
+}
+
+// stateDetailType 运行状态变化对应的详单类型
+// 结束送风的转换结算服务段的费用，其余转换只记录状态变化
+func stateDetailType(e events.Event) db.DetailType {
+	switch {
+	case e.To == types.RunServing:
+		return db.DetailTypeServiceStart
+	case e.From == types.RunServing && e.Reason == events.TargetReached:
+		return db.DetailTypeTargetReached
+	case e.From == types.RunServing && e.Reason == events.LoadShed:
+		return db.DetailTypeShedStop
+	case e.From == types.RunServing:
+		return db.DetailTypeServiceInterrupt
+	case e.To == types.RunWaiting:
+		return db.DetailTypeWaiting
+	case e.To == types.RunStandby:
+		return db.DetailTypeStandby
+	default:
+		return db.DetailTypePowerOff
+	}
+}
+
+// handleEvent 将状态变化和风速调整记录为详单，详单时间取事件发生的时间
+func (s *BillingService) handleEvent(e events.Event) {
+	service := &ServiceObject{
+		RoomID:      e.RoomID,
+		StartTime:   e.StartTime,
+		Speed:       e.Speed,
+		TargetTemp:  e.TargetTemp,
+		CurrentTemp: e.CurrentTemp,
+		Mode:        e.Mode,
+		ModeRate:    e.ModeRate,
+	}
+	detailType := db.DetailTypeSpeedChange
+	switch e.Reason {
+	case events.LoadShed:
+		detailType = db.DetailTypeShedSpeed
+	case events.RateChanged:
+		detailType = db.DetailTypeRateChange
+	}
+	if e.Type == events.StateChanged {
+		detailType = stateDetailType(e)
+	}
+	if err := s.createBandDetails(service, detailType, e.Time, e.PreconditionID); err != nil {
+		logger.Error("创建时段切换详单失败 - 房间ID: %d, 事件: %s, 错误: %v", e.RoomID, e.Type, err)
+	}
+	detail := s.newDetail(service, detailType, e.Time)
+	if e.Type == events.StateChanged {
+		detail.FromState = string(e.From)
+		detail.ToState = string(e.To)
+	}
+	detail.PreconditionID = e.PreconditionID
+	if err := s.saveDetail(detail); err != nil {
+		logger.Error("创建详单失败 - 房间ID: %d, 事件: %s, 错误: %v", e.RoomID, e.Type, err)
+	}
+}
+
+// endsSegment 判断详单是否结束了计费的服务段
+func endsSegment(detailType db.DetailType) bool {
+	return detailType == db.DetailTypeServiceInterrupt || detailType == db.DetailTypeTargetReached ||
+		detailType == db.DetailTypeShedStop
+}
+
+// closesPeriod 判断详单是否结束了一个计费时段：结束服务段的详单，以及切换风速、费率或分时电价时段后按新费率继续计费的详单
+func closesPeriod(detailType db.DetailType) bool {
+	switch detailType {
+	case db.DetailTypeSpeedChange, db.DetailTypeShedSpeed, db.DetailTypeRateChange, db.DetailTypeBandChange:
+		return true
+	}
+	return endsSegment(detailType)
+}
+
+// guestDetails 去掉入住前预调温期间产生的详单，预调温的费用不按服务段计入住客的费用
+func guestDetails(details []db.Detail) []db.Detail {
+	guest := make([]db.Detail, 0, len(details))
+	for _, detail := range details {
+		if detail.PreconditionID == 0 {
+			guest = append(guest, detail)
+		}
+	}
+	return guest
+}
+
+// FlushDetails 等待已发布的服务事件全部写入详单
+func (s *BillingService) FlushDetails() {
+	if s.subscription != nil {
+		s.subscription.Flush()
 	}
 }
 
 // CalculateCurrentSessionFee 计算本次开机会话的费用（从开机到现在）
-func (s *BillingService) CalculateCurrentSessionFee(roomID int) (float32, error) {
+func (s *BillingService) CalculateCurrentSessionFee(roomID int) (types.Money, error) {
+	s.FlushDetails()
 	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
-		return 0, fmt.Errorf("获取房间信息失败: %v", err)
+		return types.Money{}, fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
 	// 空调关闭时，当前费用为0
 	if room.ACState != 1 {
-		return 0, nil
+		return types.Fen(0), nil
 	}
 
 	// 获取本次开机以来的所有详单记录
 	details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(
 		roomID,
 		room.LastPowerOnTime, // 使用LastPowerOnTime替代查找PowerOn详单
-		time.Now(),
+		s.clock.Now(),
 	)
 	if err != nil {
-		return 0, fmt.Errorf("获取详单记录失败: %v", err)
-	}
-	var currentFee float32
-	var lastServiceStart time.Time
-	var isInService bool
-
-	// 遍历详单记录,根据服务开始和中断事件计算费用
-	for _, detail := range details {
-		switch detail.DetailType {
-		case db.DetailTypeServiceStart:
-			lastServiceStart = detail.StartTime
-			isInService = true
-		case db.DetailTypeServiceInterrupt:
-			if isInService {
-				duration := calculateScaledDuration(lastServiceStart, detail.EndTime)
-				rate := speedToRate[detail.Speed]
-				currentFee += roundTo2Decimals(duration * rate)
-				isInService = false
-			}
-		case db.DetailTypeSpeedChange:
-			if isInService {
-				// 计算切换前的费用
-				duration := calculateScaledDuration(lastServiceStart, detail.EndTime)
-				rate := speedToRate[detail.Speed]
-				currentFee += roundTo2Decimals(duration * rate)
-				// 更新新服务段的开始时间和费率
-				lastServiceStart = detail.EndTime
-			}
-		}
+		return types.Money{}, fmt.Errorf("获取详单记录失败: %v", err)
 	}
+	return sumFees(s.detailFees(room, guestDetails(details), false)), nil
+}
 
-	// 如果在服务队列中，计算实时费用
-	if isInService {
-		if serviceObj, exists := s.scheduler.GetServiceQueue()[roomID]; exists {
-			now := time.Now()
-			duration := calculateScaledDuration(lastServiceStart, now)
-			rate := speedToRate[string(serviceObj.Speed)]
-			currentServiceFee := roundTo2Decimals(duration * rate)
-			currentFee = roundTo2Decimals(currentFee + currentServiceFee)
-		}
+// CalculateTotalFee 计算总费用
+func (s *BillingService) CalculateTotalFee(roomID int) (types.Money, error) {
+	fees, err := s.CalculateFeeByMode(roomID)
+	if err != nil {
+		return types.Money{}, err
 	}
-
-	return currentFee, nil
+	return sumFees(fees), nil
 }
 
-// CalculateTotalFee 计算总费用
-func (s *BillingService) CalculateTotalFee(roomID int) (float32, error) {
+// CalculateFeeByMode 按工作模式汇总入住以来的空调费用，用于在账单中列出各模式的费用
+// 旧版本的详单没有记录工作模式，汇总在空模式下
+func (s *BillingService) CalculateFeeByMode(roomID int) (map[types.Mode]types.Money, error) {
+	s.FlushDetails()
 	room, err := s.roomRepo.GetRoomByID(roomID)
 	if err != nil {
-		return 0, fmt.Errorf("获取房间信息失败: %v", err)
+		return nil, fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
-	// 获取所有详单记录
-	details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(
-		roomID,
-		room.CheckinTime,
-		time.Now(),
-	)
+	details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(roomID, room.CheckinTime, s.clock.Now())
 	if err != nil {
-		return 0, fmt.Errorf("获取详单记录失败: %v", err)
+		return nil, fmt.Errorf("获取详单记录失败: %v", err)
 	}
+	return s.detailFees(room, guestDetails(details), true), nil
+}
 
-	var totalFee float32
-	var lastServiceStart time.Time
-	var isInService bool
-
-	// 遍历所有详单,根据服务开始和中断事件计算费用
+// detailFees 按工作模式合计详单上的费用，再加上仍在送风的服务段到当前时刻的费用
+// 每个计费时段的费用记录在结束它的详单上，合计只做分的整数加法，与详单PDF上逐条相加的结果一致
+// withPrecondition: 是否计入记入住客账单的预调温详单
+func (s *BillingService) detailFees(room *db.RoomInfo, details []db.Detail, withPrecondition bool) map[types.Mode]types.Money {
+	fees := make(map[types.Mode]types.Money)
 	for _, detail := range details {
-		switch detail.DetailType {
-		case db.DetailTypeServiceStart:
-			lastServiceStart = detail.StartTime
-			isInService = true
-		case db.DetailTypeServiceInterrupt:
-			if isInService {
-				duration := calculateScaledDuration(lastServiceStart, detail.EndTime)
-				rate := speedToRate[detail.Speed]
-				totalFee += roundTo2Decimals(duration * rate)
-				isInService = false
-			}
-		case db.DetailTypeSpeedChange:
-			if isInService {
-				// 计算切换前的费用
-				duration := calculateScaledDuration(lastServiceStart, detail.EndTime)
-				rate := speedToRate[detail.Speed]
-				totalFee += roundTo2Decimals(duration * rate)
-				// 更新新服务段的开始时间和费率
-				lastServiceStart = detail.EndTime
-			}
+		if detail.DetailType == db.DetailTypePrecondition && !withPrecondition {
+			continue
+		}
+		if !detail.Cost.IsZero() {
+			mode := types.Mode(detail.Mode)
+			fees[mode] = fees[mode].Add(detail.Cost)
 		}
-
 	}
 
 	// 如果当前正在服务中,计算最后一段服务的费用
-	if isInService && room.ACState == 1 {
-		if serviceObj, exists := s.scheduler.GetServiceQueue()[roomID]; exists {
-			now := time.Now()
-			duration := calculateScaledDuration(lastServiceStart, now)
-			rate := speedToRate[string(serviceObj.Speed)]
-			currentServiceFee := roundTo2Decimals(duration * rate)
-			totalFee = roundTo2Decimals(totalFee + currentServiceFee)
-		}
+	if mode, fee, ok := s.ongoingFee(room, details); ok {
+		fees[mode] = fees[mode].Add(fee)
+	}
+	return fees
+}
+
+// ongoingFee 房间仍在送风的服务段从最后一个计费时段开始到当前时刻的费用，该费用尚未记录在详单上
+// 返回值: 服务段的工作模式、费用，以及是否有仍在送风的服务段
+func (s *BillingService) ongoingFee(room *db.RoomInfo, details []db.Detail) (types.Mode, types.Money, bool) {
+	start, opening := openSegment(details)
+	if opening == nil || room.ACState != 1 {
+		return "", types.Money{}, false
+	}
+	serviceObj, exists := s.servingRoom(room)
+	if !exists {
+		return "", types.Money{}, false
 	}
+	return serviceObj.Mode, s.segmentFee(&serviceObj, start, s.clock.Now()), true
+}
 
-	return totalFee, nil
+// sumFees 合计各工作模式的费用
+func sumFees(fees map[types.Mode]types.Money) types.Money {
+	total := types.Fen(0)
+	for _, fee := range fees {
+		total = total.Add(fee)
+	}
+	return total
+}
+
+// servingRoom 在房间所属机组的调度器中查找房间的服务对象
+func (s *BillingService) servingRoom(room *db.RoomInfo) (ServiceObject, bool) {
+	scheduler := s.schedulers.Get(room.Zone)
+	if scheduler == nil {
+		return ServiceObject{}, false
+	}
+	snapshot := scheduler.Snapshot()
+	return snapshot.ServingRoom(room.RoomID)
 }
 
-// calculateScaledDuration 计算缩放后的持续时间(分钟)
-func calculateScaledDuration(start time.Time, end time.Time) float32 {
-	realDuration := end.Sub(start).Seconds()
-	// 将实际秒数转换为模拟的分钟数 (10秒=1分钟)
-	return float32(realDuration) * float32(TimeScale) / 60.0
+// calculateDuration 计算持续时间(分钟)
+// 时间均取自系统时钟，演示时的加速由模拟时钟的倍速负责
+func calculateDuration(start time.Time, end time.Time) float32 {
+	return float32(end.Sub(start).Minutes())
 }
 
 // CreateDetail 创建详单记录
 func (s *BillingService) CreateDetail(roomID int, service *ServiceObject, detailType db.DetailType) error {
-	now := time.Now()
-	rate := speedToRate[string(service.Speed)]
+	return s.CreateDetailAt(roomID, service, detailType, s.clock.Now())
+}
+
+// CreateDetailAt 以指定的结束时间创建详单记录，用于补记过去时刻发生的事件
+func (s *BillingService) CreateDetailAt(roomID int, service *ServiceObject, detailType db.DetailType, now time.Time) error {
+	segment := *service
+	segment.RoomID = roomID
+	if err := s.createBandDetails(&segment, detailType, now, 0); err != nil {
+		return err
+	}
+	detail := s.newDetail(&segment, detailType, now)
+	return s.saveDetail(detail)
+}
+
+// newDetail 构造以now结束服务对象所描述服务段的详单，结束计费时段的详单计算该时段的费用
+func (s *BillingService) newDetail(service *ServiceObject, detailType db.DetailType, now time.Time) *db.Detail {
+	rate, band := s.serviceRate(service)
 
 	detail := &db.Detail{
-		RoomID:      roomID,
+		RoomID:      service.RoomID,
 		QueryTime:   now,
 		StartTime:   service.StartTime,
 		EndTime:     now,
-		ServeTime:   roundTo2Decimals(calculateScaledDuration(service.StartTime, now)),
+		ServeTime:   roundTo2Decimals(calculateDuration(service.StartTime, now)),
 		Speed:       string(service.Speed),
+		Cost:        types.Fen(0),
 		Rate:        rate,
 		TempChange:  roundTo2Decimals(service.TargetTemp - service.CurrentTemp),
 		DetailType:  detailType,
 		TargetTemp:  service.TargetTemp,
 		CurrentTemp: roundTo2Decimals(service.CurrentTemp),
+		Mode:        string(service.Mode),
+		Band:        band,
+	}
+	// 结束计费时段的详单记录该时段的费用和耗电量，耗电量与费率无关
+	if closesPeriod(detailType) {
+		detail.Cost = periodCost(service.StartTime, now, rate)
+		minutes := calculateDuration(service.StartTime, now)
+		detail.Energy = minutes * s.energyRate(s.roomZone(service.RoomID), service.Speed)
 	}
-	// 只有服务中断和关机时才计算费用
-	if detailType == db.DetailTypeServiceInterrupt {
-		detail.Cost = roundTo2Decimals(detail.ServeTime * detail.Rate)
+	return detail
+}
+
+// createBandDetails 结束计费时段的详单所描述的服务段跨过分时电价的时段边界时，在每个边界补记一条时段切换详单，
+// 并将服务对象的开始时间移到最后一个边界，之后以该服务对象构造的详单只结算最后一个时段
+func (s *BillingService) createBandDetails(service *ServiceObject, detailType db.DetailType, now time.Time, preconditionID int) error {
+	if !closesPeriod(detailType) {
+		return nil
+	}
+	for _, boundary := range s.bandBoundaries(s.roomZone(service.RoomID), service.Speed, service.StartTime, now) {
+		detail := s.newDetail(service, db.DetailTypeBandChange, boundary)
+		detail.PreconditionID = preconditionID
+		if err := s.saveDetail(detail); err != nil {
+			return err
+		}
+		service.StartTime = boundary
+	}
+	return nil
+}
+
+// openSegment 找出详单中尚未结束的服务段
+// 返回值:
+//   - time.Time: 服务段(或风速切换后的新服务段)的开始时间
+//   - *db.Detail: 开始该服务段的详单，不存在未结束的服务段时为nil
+func openSegment(details []db.Detail) (time.Time, *db.Detail) {
+	var lastServiceStart time.Time
+	var opening *db.Detail
+	for i, detail := range details {
+		switch detail.DetailType {
+		case db.DetailTypeServiceStart:
+			lastServiceStart = detail.StartTime
+			opening = &details[i]
+		case db.DetailTypeServiceInterrupt, db.DetailTypeTargetReached, db.DetailTypeShedStop:
+			opening = nil
+		case db.DetailTypeSpeedChange, db.DetailTypeShedSpeed, db.DetailTypeRateChange, db.DetailTypeBandChange:
+			if opening != nil {
+				lastServiceStart = detail.EndTime
+				opening = &details[i]
+			}
+		}
+	}
+	return lastServiceStart, opening
+}
+
+// CloseOpenSegment 结束房间尚未结束的服务段，在end时刻补记送风转为待机的服务中断详单
+// 用于系统重启后结算重启前未结束的计费段，end不会早于服务段的开始时间。
+// 预调温中的房间结算预调温计划的服务段，其余房间结算住客的服务段
+// 返回值: 是否补记了详单
+func (s *BillingService) CloseOpenSegment(room *db.RoomInfo, speed types.Speed, end time.Time) (bool, error) {
+	s.FlushDetails()
+	details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(room.RoomID, room.CheckinTime, s.clock.Now())
+	if err != nil {
+		return false, fmt.Errorf("获取详单记录失败: %v", err)
+	}
+	segment := make([]db.Detail, 0, len(details))
+	for _, detail := range details {
+		if detail.PreconditionID == room.PreconditionID {
+			segment = append(segment, detail)
+		}
+	}
+	start, opening := openSegment(segment)
+	if opening == nil {
+		return false, nil
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	service := &ServiceObject{
+		RoomID:      room.RoomID,
+		StartTime:   start,
+		Speed:       speed,
+		TargetTemp:  room.TargetTemp,
+		CurrentTemp: room.CurrentTemp,
+		// 工作模式和费率系数沿用服务段开始时的记录
+		Mode:     types.Mode(opening.Mode),
+		ModeRate: s.detailModeRate(opening),
+	}
+	if err := s.createBandDetails(service, db.DetailTypeServiceInterrupt, end, room.PreconditionID); err != nil {
+		return false, err
+	}
+	// 重启前送风中的房间在恢复时转为待机
+	detail := s.newDetail(service, db.DetailTypeServiceInterrupt, end)
+	detail.FromState = string(types.RunServing)
+	detail.ToState = string(types.RunStandby)
+	detail.PreconditionID = room.PreconditionID
+	if err := s.saveDetail(detail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PreconditionCost 结算预调温计划期间产生的费用
+func (s *BillingService) PreconditionCost(planID int) (types.Money, error) {
+	s.FlushDetails()
+	return s.detailRepo.GetPreconditionCost(planID)
+}
+
+// ChargePrecondition 将预调温的费用记入住客账单，在入住时刻记录一条预调温详单
+// mode: 预调温时机组的工作模式，账单按模式列出费用时使用
+func (s *BillingService) ChargePrecondition(plan *db.Precondition, mode types.Mode, now time.Time) error {
+	detail := &db.Detail{
+		RoomID:     plan.RoomID,
+		QueryTime:  now,
+		StartTime:  plan.StartedAt,
+		EndTime:    plan.EndedAt,
+		ServeTime:  roundTo2Decimals(calculateDuration(plan.StartedAt, plan.EndedAt)),
+		Speed:      string(types.SpeedLow),
+		Cost:       plan.Cost,
+		TargetTemp: plan.TargetTemp,
+		DetailType: db.DetailTypePrecondition,
+		Mode:       string(mode),
 	}
-	return s.detailRepo.CreateDetail(detail)
+	return s.saveDetail(detail)
 }
 
 // GetDetails 获取详单记录
 func (s *BillingService) GetDetails(roomID int, startTime, endTime time.Time) ([]db.Detail, error) {
+	s.FlushDetails()
 	details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(roomID, startTime, endTime)
 	if err != nil {
 		return nil, fmt.Errorf("获取详单记录失败: %v", err)
 	}
-	return details, nil
+	return guestDetails(details), nil
 }
 
 // GetBillingService 获取账单服务实例
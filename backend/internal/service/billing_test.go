@@ -0,0 +1,166 @@
+// internal/service/billing_test.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/types"
+	"testing"
+	"time"
+)
+
+func TestSegmentCosts(t *testing.T) {
+	type segment struct {
+		speed    types.Speed
+		duration time.Duration
+	}
+	tests := []struct {
+		name     string
+		segments []segment
+		costs    []int64 // 各计费时段的费用(分)，按时间顺序
+	}{
+		{"单一风速", []segment{{types.SpeedMedium, 2 * time.Minute}}, []int64{100}},
+		{"调风后按新风速计费", []segment{{types.SpeedMedium, 2 * time.Minute}, {types.SpeedHigh, 3 * time.Minute}}, []int64{100, 300}},
+		{"时段费用舍入到分", []segment{{types.SpeedLow, 7 * time.Minute}}, []int64{233}},
+		{"每个时段各自舍入", []segment{
+			{types.SpeedLow, time.Minute}, {types.SpeedMedium, time.Minute}, {types.SpeedLow, time.Minute},
+		}, []int64{33, 50, 33}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acService := resetHotel(t, nil)
+			billing := GetBillingService()
+			checkInAndPowerOn(t, acService, 1, tt.segments[0].speed, 18)
+			checkin := testClock.Now()
+
+			var want int64
+			for i, seg := range tt.segments {
+				if i > 0 {
+					if err := acService.SetFanSpeed(1, seg.speed); err != nil {
+						t.Fatal(err)
+					}
+				}
+				testClock.Advance(seg.duration)
+				want += tt.costs[i]
+			}
+
+			// 仍在送风的时段按同样的规则计入实时费用
+			total, err := billing.CalculateTotalFee(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total.Fen != want {
+				t.Errorf("送风中的总费用为 %s，期望 %s", total, types.Fen(want))
+			}
+
+			if err := acService.PowerOff(1); err != nil {
+				t.Fatal(err)
+			}
+			details, err := billing.GetDetails(1, checkin, testClock.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var costs []int64
+			for _, detail := range details {
+				if !detail.Cost.IsZero() {
+					costs = append(costs, detail.Cost.Fen)
+				}
+			}
+			if len(costs) != len(tt.costs) {
+				t.Fatalf("有费用的详单为 %v 分，期望 %v 分", costs, tt.costs)
+			}
+			for i := range costs {
+				if costs[i] != tt.costs[i] {
+					t.Errorf("第 %d 个时段的费用为 %d 分，期望 %d 分", i+1, costs[i], tt.costs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPreemptedSegmentCost(t *testing.T) {
+	acService := resetHotel(t, nil)
+	billing := GetBillingService()
+	for _, roomID := range []int{1, 2, 3} {
+		checkInAndPowerOn(t, acService, roomID, types.SpeedMedium, 18)
+	}
+	testClock.Advance(time.Minute)
+	checkInAndPowerOn(t, acService, 4, types.SpeedHigh, 18)
+
+	snapshot := queueSnapshot(t)
+	if len(snapshot.Waiting) != 1 {
+		t.Fatalf("等待队列有 %d 个房间，期望 1 个", len(snapshot.Waiting))
+	}
+	preempted := snapshot.Waiting[0].RoomID
+
+	// 被抢占前送风1分钟，等待期间不计费
+	testClock.Advance(time.Minute)
+	total, err := billing.CalculateTotalFee(preempted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.Fen != 50 {
+		t.Errorf("被抢占的房间 %d 费用为 %s，期望 0.50元", preempted, total)
+	}
+	total, err = billing.CalculateTotalFee(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.Fen != 100 {
+		t.Errorf("抢占的房间 4 费用为 %s，期望 1.00元", total)
+	}
+}
+
+// TestBillingDoesNotDropEvents 计费订阅者积压超过 MailboxCapacity 时仍为每个事件写入带费用的详单
+func TestBillingDoesNotDropEvents(t *testing.T) {
+	acService := resetHotel(t, nil)
+	billing := GetBillingService()
+	if err := acService.CheckIn(5, "T005", "测试住客", types.Fen(0)); err != nil {
+		t.Fatal(err)
+	}
+	checkin := testClock.Now()
+
+	// 占住唯一的数据库连接，计费订阅者写详单时阻塞，之后发布的事件都积压在邮箱中
+	tx := db.DB.Begin()
+	const published = events.MailboxCapacity + 100
+	now := testClock.Now()
+	for i := 0; i < published; i++ {
+		GetEventBus().Publish(events.Event{
+			Type:      events.SpeedChanged,
+			RoomID:    5,
+			Speed:     types.SpeedMedium,
+			Mode:      types.ModeCooling,
+			ModeRate:  1,
+			StartTime: now.Add(-time.Minute),
+			Time:      now,
+		})
+	}
+	var backlog events.SubscriptionStats
+	for _, stats := range GetEventBus().Stats() {
+		if stats.Name == "billing" {
+			backlog = stats
+		}
+	}
+	tx.Rollback()
+	if backlog.Backlog < events.MailboxCapacity || backlog.Dropped != 0 {
+		t.Fatalf("计费订阅者积压 %d 个、丢弃 %d 个事件，期望积压超过 %d 个且不丢弃",
+			backlog.Backlog, backlog.Dropped, events.MailboxCapacity)
+	}
+
+	details, err := billing.GetDetails(5, checkin, testClock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var billed int
+	var total types.Money
+	for _, detail := range details {
+		if detail.DetailType == db.DetailTypeSpeedChange {
+			billed++
+			total = total.Add(detail.Cost)
+		}
+	}
+	// 每个事件结算1分钟中风速，0.50元
+	if billed != published || total != types.Fen(50*published) {
+		t.Errorf("写入 %d 条调风详单共 %s，期望 %d 条共 %s", billed, total, published, types.Fen(50*published))
+	}
+}
@@ -0,0 +1,140 @@
+// internal/service/capacity.go
+package service
+
+import (
+	"backend/internal/types"
+	"fmt"
+)
+
+// loadEpsilon 比较负载时容忍的浮点误差，避免 3 × 0.33 之类的累加结果被误判为超出预算
+const loadEpsilon = 1e-4
+
+// Capacity 服务队列容量
+// count 模式下每个服务对象占用1个名额，总量为 Slots；
+// power 模式下每个服务对象按风速占用 Load 中的负载，总量为 Budget
+type Capacity struct {
+	Mode   types.CapacityMode
+	Slots  int                     // 同时送风的房间数，count 模式使用
+	Budget float32                 // 功率预算(kW)，power 模式使用
+	Load   map[types.Speed]float32 // 各风速占用的负载(kW)，power 模式使用
+}
+
+// CapacityFromConfig 从空调配置中提取服务队列容量
+func CapacityFromConfig(config types.Config) Capacity {
+	capacity := Capacity{
+		Mode:   config.CapacityMode,
+		Slots:  config.MaxServices,
+		Budget: config.PowerBudget,
+		Load:   make(map[types.Speed]float32, len(config.SpeedLoad)),
+	}
+	if capacity.Mode == "" {
+		capacity.Mode = types.CapacityCount
+	}
+	for speed, load := range config.SpeedLoad {
+		capacity.Load[speed] = load
+	}
+	return capacity
+}
+
+// Validate 检查容量设置是否有效
+func (c Capacity) Validate() error {
+	switch c.Mode {
+	case types.CapacityCount:
+		if c.Slots <= 0 {
+			return fmt.Errorf("服务队列容量必须大于0")
+		}
+	case types.CapacityPower:
+		if c.Budget <= 0 {
+			return fmt.Errorf("功率预算必须大于0")
+		}
+		for _, speed := range []types.Speed{types.SpeedLow, types.SpeedMedium, types.SpeedHigh} {
+			load, ok := c.Load[speed]
+			if !ok || load <= 0 {
+				return fmt.Errorf("风速 %s 的负载必须大于0", speed)
+			}
+			// 单个请求的负载超过预算时，该风速的请求永远无法得到服务
+			if load > c.Budget+loadEpsilon {
+				return fmt.Errorf("风速 %s 的负载 %.2fkW 超过功率预算 %.2fkW", speed, load, c.Budget)
+			}
+		}
+	default:
+		return fmt.Errorf("未知的容量模型: %s", c.Mode)
+	}
+	return nil
+}
+
+// load 一个风速为speed的服务对象占用的容量
+func (c Capacity) load(speed types.Speed) float32 {
+	if c.Mode == types.CapacityPower {
+		return c.Load[speed]
+	}
+	return 1
+}
+
+// limit 服务队列的总容量
+func (c Capacity) limit() float32 {
+	if c.Mode == types.CapacityPower {
+		return c.Budget
+	}
+	return float32(c.Slots)
+}
+
+// clone 复制容量设置，避免与配置共享负载表
+func (c Capacity) clone() Capacity {
+	clone := c
+	clone.Load = make(map[types.Speed]float32, len(c.Load))
+	for speed, load := range c.Load {
+		clone.Load[speed] = load
+	}
+	return clone
+}
+
+// Used 服务队列已占用的容量
+func (v QueueView) Used() float32 {
+	var used float32
+	for _, service := range v.Serving {
+		used += v.Capacity.load(service.Speed)
+	}
+	return used
+}
+
+// Fits 服务队列能否再容纳一个风速为speed的请求
+func (v QueueView) Fits(speed types.Speed) bool {
+	return v.Used()+v.Capacity.load(speed) <= v.Capacity.limit()+loadEpsilon
+}
+
+// Overloaded 已占用的容量是否超过总容量，发生在容量减小或服务中的房间调高风速之后
+func (v QueueView) Overloaded() bool {
+	return v.Used() > v.Capacity.limit()+loadEpsilon
+}
+
+// without 返回移出victim后的队列视图
+func (v QueueView) without(victim *ServiceObject) QueueView {
+	serving := make([]*ServiceObject, 0, len(v.Serving))
+	for _, service := range v.Serving {
+		if service != victim {
+			serving = append(serving, service)
+		}
+	}
+	v.Serving = serving
+	return v
+}
+
+// selectVictims 为风速为speed的请求腾出容量
+// 每次由selectOne在剩余的服务对象中选择一个被移出的对象，直到能容纳该请求；
+// count 模式下最多选择一个，power 模式下可能需要移出多个低负载的对象。
+// 返回值:
+//   - []*ServiceObject: 按选择顺序排列的被移出对象
+//   - bool: 能否腾出足够的容量，为false时不应移出任何对象
+func selectVictims(view QueueView, speed types.Speed, selectOne func(QueueView) *ServiceObject) ([]*ServiceObject, bool) {
+	var victims []*ServiceObject
+	for !view.Fits(speed) {
+		victim := selectOne(view)
+		if victim == nil {
+			return nil, false
+		}
+		victims = append(victims, victim)
+		view = view.without(victim)
+	}
+	return victims, true
+}
@@ -0,0 +1,147 @@
+// internal/service/energy.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"sort"
+	"strconv"
+)
+
+// SetEnergyRates 设置机组各风速每分钟的耗电量，之后结束的计费时段按新的耗电量计量
+func (s *BillingService) SetEnergyRates(zone string, rates map[types.Speed]float32) {
+	clone := make(map[types.Speed]float32, len(rates))
+	for speed, energy := range rates {
+		clone[speed] = energy
+	}
+	s.energyMu.Lock()
+	if s.energyRates == nil {
+		s.energyRates = make(map[string]map[types.Speed]float32)
+	}
+	s.energyRates[zone] = clone
+	s.energyMu.Unlock()
+}
+
+// energyRate 机组某个风速每分钟的耗电量(度)，机组未设置时使用默认配置
+func (s *BillingService) energyRate(zone string, speed types.Speed) float32 {
+	s.energyMu.RLock()
+	defer s.energyMu.RUnlock()
+	if energy, ok := s.energyRates[zone][speed]; ok {
+		return energy
+	}
+	return DefaultConfig.EnergyRates[speed]
+}
+
+// saveDetail 写入详单，并将详单的耗电量累计到房间、所属机组和全酒店
+func (s *BillingService) saveDetail(detail *db.Detail) error {
+	if err := s.detailRepo.CreateDetail(detail); err != nil {
+		return err
+	}
+	if detail.Energy > 0 {
+		if err := s.energyRepo.Add(detail.RoomID, s.roomZone(detail.RoomID), float64(detail.Energy), detail.EndTime); err != nil {
+			logger.Error("累计房间 %d 的耗电量失败: %v", detail.RoomID, err)
+		}
+	}
+	return nil
+}
+
+// EnergyRecord 耗电量统计记录
+type EnergyRecord struct {
+	Room        int     `json:"room,omitempty"` // 房间号，按机组或全酒店汇总时为0
+	Zone        string  `json:"zone,omitempty"` // 机组编号，全酒店汇总时为空
+	Duration    float32 `json:"duration"`       // 统计时段内的送风时长(分钟)
+	Energy      float32 `json:"energy"`         // 统计时段内的耗电量(度)
+	TotalEnergy float64 `json:"totalEnergy"`    // 累计耗电量(度)
+}
+
+// GetEnergyReport 统计系统时钟当天(daily)或当周(weekly)的耗电量
+// groupBy: room 按房间、zone 按机组、hotel 汇总全酒店；统计时段内的耗电量按房间当前所属的机组汇总，
+// 累计耗电量取计量表中的值。仍在送风的计费时段在结束后才计入
+func (s *StatisticsService) GetEnergyReport(period, groupBy string) ([]EnergyRecord, error) {
+	start, end := dayRange(s.clock.Now())
+	if period == "weekly" {
+		start, end = weekRange(s.clock.Now())
+	}
+
+	if billing := GetBillingService(); billing != nil {
+		billing.FlushDetails()
+	}
+	rooms, err := s.roomRepo.GetAllRooms()
+	if err != nil {
+		return nil, err
+	}
+
+	// 统计时段内每个房间的送风时长和耗电量
+	byRoom := make(map[int]*EnergyRecord, len(rooms))
+	for _, room := range rooms {
+		details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(room.RoomID, start, end)
+		if err != nil {
+			logger.Error("获取房间 %d 详单失败: %v", room.RoomID, err)
+			continue
+		}
+		record := &EnergyRecord{Room: room.RoomID, Zone: room.Zone}
+		for _, detail := range details {
+			if detail.Energy > 0 {
+				record.Duration += calculateDuration(detail.StartTime, detail.EndTime)
+				record.Energy += detail.Energy
+			}
+		}
+		byRoom[room.RoomID] = record
+	}
+
+	scope := db.MeterRoom
+	switch groupBy {
+	case "zone":
+		scope = db.MeterZone
+	case "hotel":
+		scope = db.MeterHotel
+	}
+	meters, err := s.energyRepo.List(scope)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]float64, len(meters))
+	for _, meter := range meters {
+		totals[meter.Key] = meter.Energy
+	}
+
+	records := make([]EnergyRecord, 0)
+	switch scope {
+	case db.MeterRoom:
+		for _, room := range rooms {
+			record := byRoom[room.RoomID]
+			if record == nil {
+				record = &EnergyRecord{Room: room.RoomID, Zone: room.Zone}
+			}
+			record.TotalEnergy = totals[strconv.Itoa(room.RoomID)]
+			records = append(records, *record)
+		}
+	case db.MeterZone:
+		byZone := make(map[string]*EnergyRecord)
+		for key, total := range totals {
+			byZone[key] = &EnergyRecord{Zone: key, TotalEnergy: total}
+		}
+		for _, record := range byRoom {
+			zone, ok := byZone[record.Zone]
+			if !ok {
+				zone = &EnergyRecord{Zone: record.Zone}
+				byZone[record.Zone] = zone
+			}
+			zone.Duration += record.Duration
+			zone.Energy += record.Energy
+		}
+		for _, record := range byZone {
+			records = append(records, *record)
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].Zone < records[j].Zone })
+	default:
+		hotel := EnergyRecord{TotalEnergy: totals[""]}
+		for _, record := range byRoom {
+			hotel.Duration += record.Duration
+			hotel.Energy += record.Energy
+		}
+		records = append(records, hotel)
+	}
+	return records, nil
+}
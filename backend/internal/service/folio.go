@@ -0,0 +1,293 @@
+// internal/service/folio.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FolioStatement 住客账单或发票的账目及合计
+type FolioStatement struct {
+	Items   []db.FolioItem
+	Charges types.Money // 应收合计：房费、空调、消费和调整
+	Credits types.Money // 押金和付款合计(正数)
+	Balance types.Money // 应付余额，负数为应退还住客
+}
+
+// newFolioStatement 按账目计算合计，押金和付款记为负数，计入已付
+func newFolioStatement(items []db.FolioItem) *FolioStatement {
+	statement := &FolioStatement{
+		Items:   items,
+		Charges: types.Fen(0),
+		Credits: types.Fen(0),
+		Balance: types.Fen(0),
+	}
+	for _, item := range items {
+		switch item.Kind {
+		case db.FolioDeposit, db.FolioPayment:
+			statement.Credits = statement.Credits.Sub(item.Amount)
+		default:
+			statement.Charges = statement.Charges.Add(item.Amount)
+		}
+		statement.Balance = statement.Balance.Add(item.Amount)
+	}
+	return statement
+}
+
+// KindTotal 某类账目的金额合计
+func (st *FolioStatement) KindTotal(kind db.FolioItemKind) types.Money {
+	total := types.Fen(0)
+	for _, item := range st.Items {
+		if item.Kind == kind {
+			total = total.Add(item.Amount)
+		}
+	}
+	return total
+}
+
+// openFolio 获取房间未结算的账单，没有时按房间的入住信息开立并记入押金
+// 升级前已入住的房间在第一次使用账单时开立。调用方需持有锁
+func (s *ACService) openFolio(room *db.RoomInfo) (*db.Folio, error) {
+	folio, err := s.folioRepo.GetOpenByRoom(room.RoomID)
+	if err != nil || folio != nil {
+		return folio, err
+	}
+	folio = &db.Folio{
+		RoomID:      room.RoomID,
+		ClientID:    room.ClientID,
+		ClientName:  room.ClientName,
+		CheckinTime: room.CheckinTime,
+		Status:      db.FolioOpen,
+	}
+	if err := s.folioRepo.Create(folio); err != nil {
+		return nil, err
+	}
+	if room.Deposit.Fen > 0 {
+		deposit := &db.FolioItem{
+			FolioID:     folio.ID,
+			Kind:        db.FolioDeposit,
+			Description: "押金",
+			Quantity:    1,
+			UnitPrice:   room.Deposit.Mul(-1),
+			Amount:      room.Deposit.Mul(-1),
+			CreatedTime: room.CheckinTime,
+		}
+		if err := s.folioRepo.AddItem(deposit); err != nil {
+			return nil, err
+		}
+	}
+	return folio, nil
+}
+
+// pendingItems 尚未记入账单的房费和空调费用：按房费计算规则算出的入住到now的房费，以及入住以来每条有费用的详单
+// withOngoing: 是否计入仍在送风的服务段到当前时刻的费用，预览账单时使用
+func (s *ACService) pendingItems(room *db.RoomInfo, now time.Time, withOngoing bool) ([]db.FolioItem, error) {
+	items := s.roomChargePolicy().Charge(room.CheckinTime, now, room.DailyRate)
+
+	details, err := s.billing.GetDetails(room.RoomID, room.CheckinTime, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, detail := range details {
+		if detail.Cost.IsZero() {
+			continue
+		}
+		items = append(items, db.FolioItem{
+			Kind:        db.FolioACSegment,
+			Description: detailDescription(&detail),
+			Quantity:    1,
+			UnitPrice:   detail.Cost,
+			Amount:      detail.Cost,
+			Mode:        detail.Mode,
+			DetailID:    detail.ID,
+			CreatedTime: now,
+		})
+	}
+	if withOngoing {
+		if mode, fee, ok := s.billing.ongoingFee(room, details); ok {
+			items = append(items, db.FolioItem{
+				Kind:        db.FolioACSegment,
+				Description: "空调 送风中(截至当前)",
+				Quantity:    1,
+				UnitPrice:   fee,
+				Amount:      fee,
+				Mode:        string(mode),
+				CreatedTime: now,
+			})
+		}
+	}
+	return items, nil
+}
+
+// detailDescription 空调账目的说明：风速、计费时段的起止时间和分时电价时段
+func detailDescription(detail *db.Detail) string {
+	if detail.DetailType == db.DetailTypePrecondition {
+		return fmt.Sprintf("入住前预调温 %s-%s", detail.StartTime.Format("01-02 15:04"), detail.EndTime.Format("01-02 15:04"))
+	}
+	description := fmt.Sprintf("空调 %s %s-%s", detail.Speed,
+		detail.StartTime.Format("01-02 15:04:05"), detail.EndTime.Format("15:04:05"))
+	if detail.Band != "" {
+		description += fmt.Sprintf(" (%s)", detail.Band)
+	}
+	return description
+}
+
+// GetFolio 获取入住中房间的账单，账目包括已记入的押金、消费、付款和调整，以及截至当前的房费和空调费用
+func (s *ACService) GetFolio(roomID int) (*db.Folio, *FolioStatement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if room.State != 1 {
+		return nil, nil, fmt.Errorf("房间未入住")
+	}
+	folio, err := s.openFolio(room)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.folioRepo.ListItems(folio.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	pending, err := s.pendingItems(room, s.clock.Now(), true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return folio, newFolioStatement(append(items, pending...)), nil
+}
+
+// PostFolioItem 向入住中房间的账单追加消费、付款或调整
+// amount: 消费和付款为正数，付款记为负数；调整为正数加收、负数减免
+func (s *ACService) PostFolioItem(roomID int, kind db.FolioItemKind, description string, amount types.Money) (*db.FolioItem, error) {
+	switch kind {
+	case db.FolioExtra, db.FolioPayment:
+		if amount.Fen <= 0 {
+			return nil, fmt.Errorf("金额必须大于0")
+		}
+	case db.FolioAdjustment:
+		if amount.IsZero() {
+			return nil, fmt.Errorf("调整金额不能为0")
+		}
+	default:
+		return nil, fmt.Errorf("只能追加 extra、payment 或 adjustment 账目")
+	}
+	if kind == db.FolioPayment {
+		amount = amount.Mul(-1)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if room.State != 1 {
+		return nil, fmt.Errorf("房间未入住")
+	}
+	folio, err := s.openFolio(room)
+	if err != nil {
+		return nil, err
+	}
+	item := &db.FolioItem{
+		FolioID:     folio.ID,
+		Kind:        kind,
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   amount,
+		Amount:      amount,
+		CreatedTime: s.clock.Now(),
+	}
+	if err := s.folioRepo.AddItem(item); err != nil {
+		return nil, err
+	}
+	logger.Info("房间 %d 的账单 %d 追加账目: %s %s", roomID, folio.ID, description, amount)
+	return item, nil
+}
+
+// finalizeFolio 退房时记入房费和空调费用，将房间的账单结算为发票，调用方需持有锁
+// 空调已移出调度器，所有计费时段的费用都已记录在详单上
+// 返回值: 发票、账目和结算时写入的账目，之后退房失败时用于撤销结算(见 reopenFolio)
+func (s *ACService) finalizeFolio(room *db.RoomInfo, now time.Time) (*db.Invoice, *FolioStatement, []db.FolioItem, error) {
+	folio, err := s.openFolio(room)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	items, err := s.folioRepo.ListItems(folio.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pending, err := s.pendingItems(room, now, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	statement := newFolioStatement(append(items, pending...))
+	invoice := &db.Invoice{
+		RoomID:       room.RoomID,
+		ClientID:     folio.ClientID,
+		ClientName:   folio.ClientName,
+		CheckinTime:  folio.CheckinTime,
+		CheckoutTime: now,
+		Charges:      statement.Charges,
+		Credits:      statement.Credits,
+		Balance:      statement.Balance,
+		IssuedAt:     now,
+	}
+	// 账目快照在结算的账目写入后生成，与发票在同一事务中保存
+	err = s.folioRepo.Finalize(folio, pending, invoice, func() (string, error) {
+		statement.Items = append(items, pending...)
+		data, err := json.Marshal(statement.Items)
+		if err != nil {
+			return "", fmt.Errorf("生成发票账目失败: %v", err)
+		}
+		return string(data), nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logger.Info("房间 %d 的账单 %d 已结算为发票 %s，应付 %s", room.RoomID, folio.ID, invoice.Number, invoice.Balance)
+	return invoice, statement, pending, nil
+}
+
+// reopenFolio 结算后退房失败时撤销结算，账单恢复为未结算，发票编号留给下一次结算，调用方需持有锁
+// 房间仍为入住状态，下一次退房继续使用原账单，押金不会重复记账
+func (s *ACService) reopenFolio(invoice *db.Invoice, settled []db.FolioItem) {
+	if err := s.folioRepo.Reopen(invoice, settled); err != nil {
+		logger.Error("房间 %d 退房失败后撤销发票 %s 失败: %v", invoice.RoomID, invoice.Number, err)
+		return
+	}
+	logger.Info("房间 %d 退房失败，已撤销发票 %s，账单 %d 恢复为未结算", invoice.RoomID, invoice.Number, invoice.FolioID)
+}
+
+// GetInvoice 按编号获取发票及开具时的账目
+func (s *ACService) GetInvoice(number string) (*db.Invoice, *FolioStatement, error) {
+	invoice, err := s.folioRepo.GetInvoiceByNumber(number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invoice == nil {
+		return nil, nil, fmt.Errorf("发票 %s 不存在", number)
+	}
+	var items []db.FolioItem
+	if err := json.Unmarshal([]byte(invoice.Items), &items); err != nil {
+		return nil, nil, fmt.Errorf("解析发票 %s 的账目失败: %v", number, err)
+	}
+	return invoice, &FolioStatement{
+		Items:   items,
+		Charges: invoice.Charges,
+		Credits: invoice.Credits,
+		Balance: invoice.Balance,
+	}, nil
+}
+
+// GetInvoices 按编号顺序获取发票，roomID为0时获取全部房间的发票
+func (s *ACService) GetInvoices(roomID int) ([]db.Invoice, error) {
+	return s.folioRepo.ListInvoices(roomID)
+}
@@ -0,0 +1,98 @@
+// internal/service/folio_test.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/types"
+	"testing"
+	"time"
+)
+
+// countDeposits 账目中押金的条数
+func countDeposits(items []db.FolioItem) int {
+	count := 0
+	for _, item := range items {
+		if item.Kind == db.FolioDeposit {
+			count++
+		}
+	}
+	return count
+}
+
+// TestCheckOutFailureReopensFolio 结算后房间退房失败时撤销结算，下一次退房使用原账单和下一个发票序号
+func TestCheckOutFailureReopensFolio(t *testing.T) {
+	acService := resetHotel(t, nil)
+	if err := acService.CheckIn(3, "T003", "测试住客", types.Fen(50000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := acService.PowerOn(3); err != nil {
+		t.Fatal(err)
+	}
+	testClock.Advance(2 * time.Minute)
+
+	before, err := acService.GetInvoices(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lastSeq int64
+	if len(before) > 0 {
+		lastSeq = before[len(before)-1].Seq
+	}
+	folio, _, err := acService.GetFolio(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 房间表不可写时退房写回失败
+	if err := db.DB.Exec("ALTER TABLE room_infos RENAME TO room_infos_broken").Error; err != nil {
+		t.Fatal(err)
+	}
+	_, _, checkoutErr := acService.CheckOut(3)
+	if err := db.DB.Exec("ALTER TABLE room_infos_broken RENAME TO room_infos").Error; err != nil {
+		t.Fatal(err)
+	}
+	if checkoutErr == nil {
+		t.Fatal("房间表不可写时退房成功")
+	}
+
+	room, err := db.NewRoomRepository().GetRoomByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.State != 1 {
+		t.Fatalf("退房失败后房间状态为 %d，期望仍为入住", room.State)
+	}
+	reopened, _, err := acService.GetFolio(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.ID != folio.ID || reopened.Status != db.FolioOpen || reopened.InvoiceID != 0 {
+		t.Errorf("退房失败后账单为 %d(%s, 发票 %d)，期望原账单 %d 恢复为未结算", reopened.ID, reopened.Status, reopened.InvoiceID, folio.ID)
+	}
+	items, err := db.NewFolioRepository().ListItems(folio.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || countDeposits(items) != 1 {
+		t.Errorf("退房失败后账单有 %d 条账目，期望只有押金", len(items))
+	}
+	after, err := acService.GetInvoices(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("退房失败后开具了 %d 张发票", len(after)-len(before))
+	}
+
+	// 再次退房结算原账单，押金只记一次，发票使用下一个序号
+	invoice, statement, err := acService.CheckOut(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.FolioID != folio.ID || invoice.Seq != lastSeq+1 {
+		t.Errorf("发票 %s 结算账单 %d、序号 %d，期望账单 %d、序号 %d", invoice.Number, invoice.FolioID, invoice.Seq, folio.ID, lastSeq+1)
+	}
+	if deposits := countDeposits(statement.Items); deposits != 1 {
+		t.Errorf("发票中有 %d 条押金，期望 1 条", deposits)
+	}
+}
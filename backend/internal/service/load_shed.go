@@ -0,0 +1,357 @@
+// internal/service/load_shed.go
+package service
+
+import (
+	"backend/internal/events"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"sort"
+)
+
+// LoadShed 限负荷模式的设置，用于停电、设备故障等紧急情况下降低机组负载
+// 三项措施可以组合使用，至少需要指定一项
+type LoadShed struct {
+	MaxSpeed types.Speed // 送风风速上限，为空时不限制；服务中和等待中的房间立即降到上限，记录限负荷降风速详单
+	Suspend  bool        // 暂停准入：新的请求和等待中的请求都不进入服务队列，也不轮转
+	Shed     int         // 按调度优先级从低到高停止送风的服务对象数，记录限负荷停止送风详单；空出的容量不再分配，同时暂停准入
+}
+
+// Validate 检查限负荷模式的设置
+func (l LoadShed) Validate() error {
+	if l.MaxSpeed != "" && speedPriority[l.MaxSpeed] == 0 {
+		return fmt.Errorf("无效的风速上限 %s", l.MaxSpeed)
+	}
+	if l.Shed < 0 {
+		return fmt.Errorf("停止送风的房间数不能为负数")
+	}
+	if l.MaxSpeed == "" && !l.Suspend && l.Shed == 0 {
+		return fmt.Errorf("请至少指定风速上限、暂停准入或停止送风的房间数中的一项")
+	}
+	return nil
+}
+
+// suspended 是否暂停准入，停止送风时总是暂停准入，避免空出的容量被其他房间占用
+func (l LoadShed) suspended() bool {
+	return l.Suspend || l.Shed > 0
+}
+
+// LoadShedState 调度器限负荷模式的状态
+type LoadShedState struct {
+	LoadShed
+	Requested map[int]types.Speed // 风速被降低的房间及住客请求的风速
+	Held      []int               // 被停止送风的房间，按房间号排序
+}
+
+// EnterLoadShed 进入限负荷模式
+// 1. 风速高于上限的服务对象结束当前服务段并按上限继续送风，等待对象的风速同样降到上限
+// 2. 按调度优先级从低到高将指定数量的服务对象移至等待队列，优先级相同时先停止服务时间最长的对象
+// 限负荷期间新请求的风速同样受上限限制，住客请求的风速保留到退出限负荷模式
+func (s *Scheduler) EnterLoadShed(shed LoadShed) error {
+	if err := shed.Validate(); err != nil {
+		return err
+	}
+	var err error
+	if !s.exec(cmdReconfigure, func() {
+		if s.loadShed != nil {
+			err = fmt.Errorf("机组 %s 已处于限负荷模式", s.zone)
+			return
+		}
+		s.enterLoadShed(shed)
+	}) {
+		return errSchedulerStopped
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("机组 %s 进入限负荷模式: 风速上限 %q, 暂停准入 %v, 停止送风 %d 间",
+		s.zone, shed.MaxSpeed, shed.suspended(), shed.Shed)
+	return nil
+}
+
+// enterLoadShed 在命令循环中进入限负荷模式
+func (s *Scheduler) enterLoadShed(shed LoadShed) {
+	s.loadShed = &shed
+	s.dirty = true
+	now := s.clock.Now()
+
+	view := s.queueView()
+	if shed.MaxSpeed != "" {
+		for _, service := range view.Serving {
+			if speedPriority[service.Speed] <= speedPriority[shed.MaxSpeed] {
+				continue
+			}
+			s.requested[service.RoomID] = service.Speed
+			// 结束原风速的服务段，之后按上限计费
+			s.publishShedSpeed(service)
+			service.StartTime = now
+			service.Speed = shed.MaxSpeed
+			service.Priority = s.requestPriority(service.Speed, service.Class, service.Precondition)
+			if err := s.roomRepo.UpdateSpeed(service.RoomID, string(service.Speed)); err != nil {
+				logger.Error("更新房间风速失败: %v", err)
+			}
+			logger.Info("限负荷模式: 房间 %d 的风速由 %s 降为 %s", service.RoomID, s.requested[service.RoomID], shed.MaxSpeed)
+		}
+		for _, wait := range view.Waiting {
+			if speedPriority[wait.Speed] <= speedPriority[shed.MaxSpeed] {
+				continue
+			}
+			s.requested[wait.RoomID] = wait.Speed
+			tempService := waitService(wait)
+			tempService.StartTime = now
+			s.publishShedSpeed(tempService)
+			wait.Speed = shed.MaxSpeed
+			s.updatePriority(s.waitQueueIndex[wait.RoomID])
+		}
+	}
+
+	for i := 0; i < shed.Shed; i++ {
+		victim := lowestLongestServing(s.queueView().Serving)
+		if victim == nil {
+			break
+		}
+		s.held[victim.RoomID] = true
+		s.preempt(victim, events.LoadShed)
+		logger.Info("限负荷模式: 房间 %d 停止送风", victim.RoomID)
+	}
+
+	// 只限制风速时，降低风速空出的容量照常分配
+	s.promoteWaiting()
+}
+
+// publishShedSpeed 发布限负荷降低风速的风速调整事件，结束原风速的服务段
+func (s *Scheduler) publishShedSpeed(service *ServiceObject) {
+	event := s.serviceEvent(events.SpeedChanged, service)
+	event.Reason = events.LoadShed
+	s.bus.Publish(event)
+}
+
+// ExitLoadShed 退出限负荷模式并恢复住客的请求
+// 1. 风速被降低的房间恢复住客请求的风速，服务中的房间记录调整风速详单
+// 2. 被停止送风的房间按调度优先级从高到低重新进入服务队列，容纳不下的继续等待
+// 3. 恢复准入，按调度策略处理超出的负载和等待队列
+func (s *Scheduler) ExitLoadShed() error {
+	var err error
+	if !s.exec(cmdReconfigure, func() {
+		if s.loadShed == nil {
+			err = fmt.Errorf("机组 %s 未处于限负荷模式", s.zone)
+			return
+		}
+		s.exitLoadShed()
+	}) {
+		return errSchedulerStopped
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("机组 %s 退出限负荷模式", s.zone)
+	return nil
+}
+
+// exitLoadShed 在命令循环中退出限负荷模式
+func (s *Scheduler) exitLoadShed() {
+	s.loadShed = nil
+	s.dirty = true
+
+	rooms := make([]int, 0, len(s.requested))
+	for roomID := range s.requested {
+		rooms = append(rooms, roomID)
+	}
+	sort.Ints(rooms)
+	for _, roomID := range rooms {
+		speed := s.requested[roomID]
+		switch {
+		case s.serviceQueue[roomID] != nil:
+			service := s.serviceQueue[roomID]
+			s.publish(events.SpeedChanged, service)
+			service.StartTime = s.clock.Now()
+			service.Speed = speed
+			service.Priority = s.requestPriority(speed, service.Class, service.Precondition)
+		case s.waitQueueIndex[roomID] != nil:
+			item := s.waitQueueIndex[roomID]
+			tempService := waitService(item.waitObj)
+			tempService.StartTime = s.clock.Now()
+			s.publish(events.SpeedChanged, tempService)
+			item.waitObj.Speed = speed
+			s.updatePriority(item)
+		}
+		// 待机的房间同样恢复风速，重新申请服务时按住客请求的风速
+		if err := s.roomRepo.UpdateSpeed(roomID, string(speed)); err != nil {
+			logger.Error("更新房间风速失败: %v", err)
+		}
+	}
+	s.requested = make(map[int]types.Speed)
+	s.shedLoad()
+
+	held := make([]*WaitObject, 0, len(s.held))
+	for roomID := range s.held {
+		if item, ok := s.waitQueueIndex[roomID]; ok {
+			held = append(held, item.waitObj)
+		}
+	}
+	sort.Slice(held, func(i, j int) bool {
+		if held[i].BasePriority != held[j].BasePriority {
+			return held[i].BasePriority > held[j].BasePriority
+		}
+		return held[i].RoomID < held[j].RoomID
+	})
+	s.held = make(map[int]bool)
+	for _, wait := range held {
+		if !s.queueView().Fits(wait.Speed) {
+			continue
+		}
+		s.removeFromWaitQueue(wait.RoomID)
+		if err := s.addToServiceQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp); err != nil {
+			logger.Error("恢复房间 %d 的服务失败: %v", wait.RoomID, err)
+			s.addToWaitQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp)
+			continue
+		}
+		logger.Info("退出限负荷模式: 房间 %d 恢复送风", wait.RoomID)
+	}
+	s.promoteWaiting()
+}
+
+// capSpeed 限负荷期间将请求的风速限制在上限内，并记住住客请求的风速
+// 请求的风速不高于上限时以新的请求为准
+func (s *Scheduler) capSpeed(roomID int, speed types.Speed) types.Speed {
+	if s.loadShed == nil || s.loadShed.MaxSpeed == "" {
+		return speed
+	}
+	limit := s.loadShed.MaxSpeed
+	if speedPriority[speed] > speedPriority[limit] {
+		s.requested[roomID] = speed
+		return limit
+	}
+	delete(s.requested, roomID)
+	return speed
+}
+
+// RequestedSpeed 获取房间住客请求的风速
+// 限负荷期间风速被降低的房间返回住客请求的风速，其余房间返回 current(房间记录的风速)。
+// 调温等沿用原风速的请求应使用该风速，避免把降低后的风速当作住客的新请求
+func (s *Scheduler) RequestedSpeed(roomID int, current types.Speed) types.Speed {
+	speed := current
+	s.exec(cmdSnapshot, func() {
+		if requested, ok := s.requested[roomID]; ok {
+			speed = requested
+		}
+	})
+	return speed
+}
+
+// admissionSuspended 限负荷模式是否暂停准入
+func (s *Scheduler) admissionSuspended() bool {
+	return s.loadShed != nil && s.loadShed.suspended()
+}
+
+// loadShedState 复制限负荷模式的状态，未处于限负荷模式时返回nil
+func (s *Scheduler) loadShedState() *LoadShedState {
+	if s.loadShed == nil {
+		return nil
+	}
+	state := &LoadShedState{
+		LoadShed:  *s.loadShed,
+		Requested: make(map[int]types.Speed, len(s.requested)),
+		Held:      make([]int, 0, len(s.held)),
+	}
+	for roomID, speed := range s.requested {
+		state.Requested[roomID] = speed
+	}
+	for roomID := range s.held {
+		state.Held = append(state.Held, roomID)
+	}
+	sort.Ints(state.Held)
+	return state
+}
+
+// restoreLoadShed 按重启前的快照恢复限负荷模式，只保留重启后空调仍开启的房间
+func (s *Scheduler) restoreLoadShed(state *LoadShedState, active func(roomID int) bool) {
+	if state == nil {
+		return
+	}
+	shed := state.LoadShed
+	s.loadShed = &shed
+	for roomID, speed := range state.Requested {
+		if active(roomID) {
+			s.requested[roomID] = speed
+		}
+	}
+	for _, roomID := range state.Held {
+		if active(roomID) {
+			s.held[roomID] = true
+		}
+	}
+	logger.Info("机组 %s 恢复限负荷模式", s.zone)
+}
+
+// EnterLoadShed 进入限负荷模式，zoneID 为空时全酒店所有机组同时进入
+// 任一机组已处于限负荷模式时不做任何修改
+// 返回值: 进入限负荷模式的机组和错误信息
+func (s *ACService) EnterLoadShed(zoneID string, shed LoadShed) ([]string, error) {
+	if err := shed.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones, err := s.loadShedZones(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones {
+		if zone.scheduler.Snapshot().LoadShed != nil {
+			return nil, fmt.Errorf("机组 %s 已处于限负荷模式", zone.ID)
+		}
+	}
+	entered := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		if err := zone.scheduler.EnterLoadShed(shed); err != nil {
+			return entered, fmt.Errorf("机组 %s 进入限负荷模式失败: %v", zone.ID, err)
+		}
+		entered = append(entered, zone.ID)
+	}
+	return entered, nil
+}
+
+// ExitLoadShed 退出限负荷模式，zoneID 为空时所有处于限负荷模式的机组同时退出
+// 返回值: 退出限负荷模式的机组和错误信息
+func (s *ACService) ExitLoadShed(zoneID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones, err := s.loadShedZones(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	exited := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		if zoneID == "" && zone.scheduler.Snapshot().LoadShed == nil {
+			continue
+		}
+		if err := zone.scheduler.ExitLoadShed(); err != nil {
+			return exited, err
+		}
+		exited = append(exited, zone.ID)
+	}
+	if len(exited) == 0 {
+		return nil, fmt.Errorf("没有处于限负荷模式的机组")
+	}
+	return exited, nil
+}
+
+// loadShedZones 限负荷模式作用的机组，zoneID 为空时返回所有机组，按编号排序，调用方需持有锁
+func (s *ACService) loadShedZones(zoneID string) ([]*Zone, error) {
+	if zoneID != "" {
+		zone, err := s.zone(zoneID)
+		if err != nil {
+			return nil, err
+		}
+		return []*Zone{zone}, nil
+	}
+	zones := make([]*Zone, 0, len(s.zones))
+	for _, zone := range s.zones {
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].ID < zones[j].ID })
+	return zones, nil
+}
@@ -3,55 +3,45 @@
 package service
 
 import (
+	"backend/internal/clock"
 	"backend/internal/db"
+	"backend/internal/events"
 	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"sync"
 	"time"
 )
 
 type MonitorService struct {
-	scheduler    *Scheduler
-	stopChan     chan struct{}
-	tempTicker   *time.Ticker
-	queuesTicker *time.Ticker
-	roomRepo     *db.RoomRepository
+	mu         sync.Mutex
+	schedulers *SchedulerRegistry
+	clock      clock.Clock
+	stopTemp   func()
+	roomRepo   *db.RoomRepository
 }
 
-func NewMonitorService(scheduler *Scheduler) *MonitorService {
+func NewMonitorService(schedulers *SchedulerRegistry, clk clock.Clock) *MonitorService {
 	return &MonitorService{
-		scheduler: scheduler,
-		stopChan:  make(chan struct{}),
-		roomRepo:  db.NewRoomRepository(),
+		schedulers: schedulers,
+		clock:      clk,
+		roomRepo:   db.NewRoomRepository(),
 	}
 }
 
 // 开始监控房间温度
 func (s *MonitorService) StartRoomTempMonitor(interval time.Duration) {
-	s.tempTicker = time.NewTicker(interval)
-	go func() {
-		for {
-			select {
-			case <-s.tempTicker.C:
-				s.logAllRoomStatus()
-			case <-s.stopChan:
-				return
-			}
-		}
-	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopTemp != nil {
+		return
+	}
+	s.stopTemp = s.clock.Every(interval, s.logAllRoomStatus)
 }
 
-// 开始监控调度队列
-func (s *MonitorService) StartQueuesMonitor(interval time.Duration) {
-	s.queuesTicker = time.NewTicker(interval)
-	go func() {
-		for {
-			select {
-			case <-s.queuesTicker.C:
-				s.logSchedulerQueues()
-			case <-s.stopChan:
-				return
-			}
-		}
-	}()
+// Subscribe 订阅全部事件，每个事件记录一行日志，取代定时轮询调度队列
+func (s *MonitorService) Subscribe(bus *events.Bus) {
+	bus.Subscribe("monitor", s.logEvent)
 }
 
 func (s *MonitorService) logAllRoomStatus() {
@@ -62,15 +52,17 @@ func (s *MonitorService) logAllRoomStatus() {
 		return
 	}
 
-	// 获取服务队列和计费服务
-	serviceQueue := s.scheduler.GetServiceQueue()
+	// 获取各机组的服务队列、等待队列和计费服务
+	snapshots := s.schedulers.Snapshots()
 	billingService := GetBillingService()
 
-	logger.Info("=== 所有房间状态 (时间: %s) ===", time.Now().Format("15:04:05"))
+	logger.Info("=== 所有房间状态 (时间: %s) ===", s.clock.Now().Format("15:04:05"))
 
 	for _, room := range rooms {
+		snapshot := snapshots[room.Zone]
+
 		// 获取账单信息
-		var currentFee, totalFee float32 = 0, 0
+		currentFee, totalFee := types.Fen(0), types.Fen(0)
 		if billingService != nil {
 			// 使用新的独立方法获取费用
 			currentFee, err = billingService.CalculateCurrentSessionFee(room.RoomID)
@@ -89,11 +81,14 @@ func (s *MonitorService) logAllRoomStatus() {
 		currentSpeed := "无"
 		if room.State == 1 {
 			if room.ACState == 1 {
-				if service, exists := serviceQueue[room.RoomID]; exists {
+				if service, exists := snapshot.ServingRoom(room.RoomID); exists {
 					status = "服务中"
 					currentSpeed = string(service.Speed)
+				} else if wait, ok := snapshot.WaitingRoom(room.RoomID); ok {
+					status = fmt.Sprintf("等待中, 有效优先级 %.2f, 已等待 %.0f秒", wait.Priority, wait.Waited)
+					currentSpeed = string(wait.Speed)
 				} else {
-					status = "等待中"
+					status = runStateNames[RoomRunState(&room)]
 					currentSpeed = room.CurrentSpeed // 使用房间记录的风速
 				}
 			} else {
@@ -101,65 +96,79 @@ func (s *MonitorService) logAllRoomStatus() {
 			}
 		}
 
-		logger.Info("房间 %d [%s]:", room.RoomID, status)
+		logger.Info("房间 %d [%s] 机组 %s:", room.RoomID, status, room.Zone)
 		logger.Info("  - 温度: 当前 %.2f°C / 目标 %.2f°C / 初始 %.2f°C",
 			room.CurrentTemp, room.TargetTemp, room.InitialTemp)
 		if room.ACState == 1 {
 			logger.Info("  - 空调: 模式 %s / 风速 %s", room.Mode, currentSpeed)
-			logger.Info("  - 费用: 当前 %.2f元 / 累计 %.2f元", currentFee, totalFee)
+			logger.Info("  - 费用: 当前 %s / 累计 %s", currentFee, totalFee)
 		}
 	}
 	logger.Info("=============================")
 }
 
-// 记录调度队列信息
-func (s *MonitorService) logSchedulerQueues() {
-	serviceQueue := s.scheduler.GetServiceQueue()
-	waitQueue := s.scheduler.GetWaitQueue()
-
-	logger.Info("=== 调度队列状态 (时间: %s) ===", time.Now().Format("15:04:05"))
-
-	// 打印服务队列信息
-	logger.Info("--- 服务队列 (共 %d 个房间) ---", len(serviceQueue))
-	if len(serviceQueue) == 0 {
-		logger.Info("服务队列为空")
-	} else {
-		for roomID, service := range serviceQueue {
-			logger.Info("房间 %d: 温度 %.1f°C -> %.1f°C, 风速: %s, 已服务时长: %.1f秒",
-				roomID,
-				service.CurrentTemp,
-				service.TargetTemp,
-				service.Speed,
-				service.Duration,
-			)
-		}
-	}
+// eventNames 事件的日志名称
+var eventNames = map[events.Type]string{
+	events.ServiceStarted:   "开始服务",
+	events.Preempted:        "被抢占",
+	events.TimeSliceExpired: "时间片到期",
+	events.TargetReached:    "达到目标温度",
+	events.ServiceStopped:   "结束服务",
+	events.SpeedChanged:     "切换风速",
+	events.Waiting:          "进入等待队列",
+	events.PoweredOn:        "空调开机",
+	events.PoweredOff:       "空调关机",
+	events.CheckedIn:        "入住",
+	events.CheckedOut:       "退房",
+	events.ConfigChanged:    "配置变更",
+	events.StateChanged:     "运行状态变化",
+	events.PreconditionOn:   "开始预调温",
+	events.PreconditionOff:  "结束预调温",
+	events.LoadShed:         "限负荷停止送风",
+}
 
-	// 打印等待队列信息
-	logger.Info("--- 等待队列 (共 %d 个房间) ---", len(waitQueue))
-	if len(waitQueue) == 0 {
-		logger.Info("等待队列为空")
-	} else {
-		for _, wait := range waitQueue {
-			logger.Info("房间 %d: 温度 %.1f°C -> %.1f°C, 风速: %s, 剩余等待时间: %.1f秒",
-				wait.RoomID,
-				wait.CurrentTemp,
-				wait.TargetTemp,
-				wait.Speed,
-				wait.WaitDuration,
-			)
-		}
+// runStateNames 运行状态的日志名称
+var runStateNames = map[types.RunState]string{
+	types.RunOff:     "关机",
+	types.RunWaiting: "等待",
+	types.RunServing: "送风",
+	types.RunStandby: "待机",
+}
+
+// logEvent 记录调度和房间事件
+func (s *MonitorService) logEvent(e events.Event) {
+	name, ok := eventNames[e.Type]
+	if !ok {
+		name = string(e.Type)
+	}
+	at := e.Time.Format("15:04:05")
+	switch e.Type {
+	case events.ConfigChanged:
+		logger.Info("[%s] %s", at, name)
+	case events.CheckedIn, events.PoweredOff:
+		logger.Info("[%s] 房间 %d %s, 当前温度 %.1f°C", at, e.RoomID, name, e.CurrentTemp)
+	case events.StateChanged:
+		logger.Info("[%s] 房间 %d %s: %s -> %s (%s)", at, e.RoomID, name,
+			runStateNames[e.From], runStateNames[e.To], eventNames[e.Reason])
+	case events.CheckedOut:
+		logger.Info("[%s] 房间 %d %s, 空调费用 %s", at, e.RoomID, name, e.Fee)
+	case events.PreconditionOn:
+		logger.Info("[%s] 房间 %d %s(计划 %d), 温度 %.1f°C -> %.1f°C",
+			at, e.RoomID, name, e.PreconditionID, e.CurrentTemp, e.TargetTemp)
+	case events.PreconditionOff:
+		logger.Info("[%s] 房间 %d %s(计划 %d), 费用 %s", at, e.RoomID, name, e.PreconditionID, e.Fee)
+	default:
+		logger.Info("[%s] 房间 %d %s, 温度 %.1f°C -> %.1f°C, 风速: %s",
+			at, e.RoomID, name, e.CurrentTemp, e.TargetTemp, e.Speed)
 	}
-	logger.Info("=============================")
 }
 
 // 停止监控
 func (s *MonitorService) Stop() {
-	if s.tempTicker != nil {
-		s.tempTicker.Stop()
-	}
-	if s.queuesTicker != nil {
-		s.queuesTicker.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopTemp != nil {
+		s.stopTemp()
+		s.stopTemp = nil
 	}
-	close(s.stopChan)
 }
@@ -0,0 +1,211 @@
+// internal/service/policy.go
+package service
+
+import (
+	"fmt"
+	"sort"
+)
+
+// QueueView 调度策略可见的队列视图
+// 由调度器在持有锁时构造，策略只读不写
+type QueueView struct {
+	Serving  []*ServiceObject // 服务队列中的对象
+	Waiting  []*WaitObject    // 等待队列中的对象
+	Capacity Capacity         // 服务队列容量
+}
+
+// SchedulingPolicy 调度策略接口
+// 调度器负责维护队列与详单，策略只负责做出调度决策
+type SchedulingPolicy interface {
+	// Name 策略名称，用于管理员按名称切换
+	Name() string
+	// Admit 服务队列容纳得下新请求时，决定新请求能否直接进入服务队列
+	Admit(req *WaitObject, view QueueView) bool
+	// SelectVictim 服务队列容纳不下新请求时，为新请求选择被抢占的服务对象，返回nil表示进入等待队列
+	// power 模式下调度器会在移出已选对象后的视图上反复调用，直到腾出足够的容量
+	SelectVictim(req *WaitObject, view QueueView) *ServiceObject
+	// OnTimeSliceExpired 等待对象时间片到期时，选择被轮换出的服务对象，返回nil表示重置等待时间
+	// 与 SelectVictim 相同，容量不足时会被反复调用
+	OnTimeSliceExpired(wait *WaitObject, view QueueView) *ServiceObject
+	// SelectNext 服务队列空出容量时，从等待队列中选择下一个被服务的对象，返回nil表示不提升
+	// 选中的对象容纳不下时调度器不再提升，避免高负载请求被低负载请求持续插队
+	SelectNext(view QueueView) *WaitObject
+}
+
+// DefaultPolicyName 默认调度策略名称
+const DefaultPolicyName = "priority"
+
+// schedulingPolicies 已注册的调度策略
+var schedulingPolicies = map[string]func() SchedulingPolicy{
+	DefaultPolicyName: func() SchedulingPolicy { return &PriorityRoundRobinPolicy{} },
+	"fcfs":            func() SchedulingPolicy { return &FCFSPolicy{} },
+	"roundrobin":      func() SchedulingPolicy { return &RoundRobinPolicy{} },
+}
+
+// NewSchedulingPolicy 按名称创建调度策略
+func NewSchedulingPolicy(name string) (SchedulingPolicy, error) {
+	factory, ok := schedulingPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的调度策略: %s", name)
+	}
+	return factory(), nil
+}
+
+// SchedulingPolicyNames 返回所有可用的调度策略名称
+func SchedulingPolicyNames() []string {
+	names := make([]string, 0, len(schedulingPolicies))
+	for name := range schedulingPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PriorityRoundRobinPolicy 优先级抢占 + 同优先级时间片轮转（默认策略）
+// 优先级为风速和房间等级加权得到的调度优先级，所有房间都是标准间时与风速优先级一致
+//  1. 高优先级请求抢占低优先级服务中优先级最低、服务时间最长的对象
+//  2. 等待对象时间片到期后，若其有效优先级已老化到高于某些服务对象的调度优先级，
+//     替换其中优先级最低、服务时间最长的对象；否则替换调度优先级相同的对象中服务时间最长的对象
+//  3. 空出位置时提升有效优先级最高的等待对象
+type PriorityRoundRobinPolicy struct{}
+
+func (p *PriorityRoundRobinPolicy) Name() string { return DefaultPolicyName }
+
+func (p *PriorityRoundRobinPolicy) Admit(req *WaitObject, view QueueView) bool {
+	return view.Fits(req.Speed)
+}
+
+func (p *PriorityRoundRobinPolicy) SelectVictim(req *WaitObject, view QueueView) *ServiceObject {
+	var lower []*ServiceObject
+	for _, service := range preemptible(view.Serving) {
+		if service.Priority < req.BasePriority {
+			lower = append(lower, service)
+		}
+	}
+	return lowestLongestServing(lower)
+}
+
+func (p *PriorityRoundRobinPolicy) OnTimeSliceExpired(wait *WaitObject, view QueueView) *ServiceObject {
+	serving := preemptible(view.Serving)
+	var lower []*ServiceObject
+	for _, service := range serving {
+		if service.Priority < wait.Priority {
+			lower = append(lower, service)
+		}
+	}
+	if victim := lowestLongestServing(lower); victim != nil {
+		return victim
+	}
+	return longestServing(serving, func(service *ServiceObject) bool {
+		return service.Priority == wait.BasePriority
+	})
+}
+
+func (p *PriorityRoundRobinPolicy) SelectNext(view QueueView) *WaitObject {
+	var next *WaitObject
+	for _, wait := range view.Waiting {
+		if next == nil ||
+			wait.Priority > next.Priority ||
+			(wait.Priority == next.Priority && wait.RequestTime.Before(next.RequestTime)) {
+			next = wait
+		}
+	}
+	return next
+}
+
+// FCFSPolicy 先来先服务
+// 不抢占、不轮转，空出位置时按请求时间先后提升
+type FCFSPolicy struct{}
+
+func (p *FCFSPolicy) Name() string { return "fcfs" }
+
+func (p *FCFSPolicy) Admit(req *WaitObject, view QueueView) bool {
+	// 已有请求在排队时，新请求必须排在其后
+	return view.Fits(req.Speed) && len(view.Waiting) == 0
+}
+
+func (p *FCFSPolicy) SelectVictim(req *WaitObject, view QueueView) *ServiceObject {
+	return nil
+}
+
+func (p *FCFSPolicy) OnTimeSliceExpired(wait *WaitObject, view QueueView) *ServiceObject {
+	return nil
+}
+
+func (p *FCFSPolicy) SelectNext(view QueueView) *WaitObject {
+	return earliestWaiting(view.Waiting)
+}
+
+// RoundRobinPolicy 纯时间片轮转
+// 不区分风速：不抢占，时间片到期后替换服务时间最长的对象
+type RoundRobinPolicy struct{}
+
+func (p *RoundRobinPolicy) Name() string { return "roundrobin" }
+
+func (p *RoundRobinPolicy) Admit(req *WaitObject, view QueueView) bool {
+	return view.Fits(req.Speed)
+}
+
+func (p *RoundRobinPolicy) SelectVictim(req *WaitObject, view QueueView) *ServiceObject {
+	return nil
+}
+
+func (p *RoundRobinPolicy) OnTimeSliceExpired(wait *WaitObject, view QueueView) *ServiceObject {
+	return longestServing(preemptible(view.Serving), nil)
+}
+
+func (p *RoundRobinPolicy) SelectNext(view QueueView) *WaitObject {
+	return earliestWaiting(view.Waiting)
+}
+
+// longestServing 在满足条件的服务对象中找出服务时间最长的，filter为nil时不过滤
+func longestServing(serving []*ServiceObject, filter func(*ServiceObject) bool) *ServiceObject {
+	var longest *ServiceObject
+	for _, service := range serving {
+		if filter != nil && !filter(service) {
+			continue
+		}
+		if longest == nil || service.Duration > longest.Duration {
+			longest = service
+		}
+	}
+	return longest
+}
+
+// lowestLongestServing 找出调度优先级最低的服务对象，优先级相同时取服务时间最长的
+func lowestLongestServing(serving []*ServiceObject) *ServiceObject {
+	var victim *ServiceObject
+	for _, service := range serving {
+		if victim == nil {
+			victim = service
+			continue
+		}
+		if service.Priority < victim.Priority ||
+			(service.Priority == victim.Priority && service.Duration > victim.Duration) {
+			victim = service
+		}
+	}
+	return victim
+}
+
+// preemptible 过滤掉处于保证时间片内的服务对象
+func preemptible(serving []*ServiceObject) []*ServiceObject {
+	result := make([]*ServiceObject, 0, len(serving))
+	for _, service := range serving {
+		if !service.Guaranteed {
+			result = append(result, service)
+		}
+	}
+	return result
+}
+
+// earliestWaiting 找出请求时间最早的等待对象
+func earliestWaiting(waiting []*WaitObject) *WaitObject {
+	var earliest *WaitObject
+	for _, wait := range waiting {
+		if earliest == nil || wait.RequestTime.Before(earliest.RequestTime) {
+			earliest = wait
+		}
+	}
+	return earliest
+}
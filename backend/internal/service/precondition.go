@@ -0,0 +1,362 @@
+// internal/service/precondition.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/logger"
+	"backend/internal/thermal"
+	"backend/internal/types"
+	"fmt"
+	"time"
+)
+
+const (
+	preconditionCheckInterval = 10 * time.Second // 检查预调温计划的周期(系统时间)
+	preconditionHold          = 30 * time.Minute // 到达时间之后继续保持目标温度的时长，超过后仍未入住则关机
+	preconditionLimit         = 12 * time.Hour   // 估算调温时间的上限
+	preconditionSpeed         = types.SpeedLow   // 预调温以最低优先级的低风速请求服务
+)
+
+// EstimateDuration 按热模型估算房间以指定的模式和风速从当前温度送风到达目标温度所需的时间
+// 以调度周期为步长模拟送风，不修改房间状态
+// 返回值: 所需时间，以及能否在limit之内达到目标
+func (s *Scheduler) EstimateDuration(room db.RoomInfo, mode types.Mode, speed types.Speed, targetTemp float32, limit time.Duration) (time.Duration, bool) {
+	var elapsed time.Duration
+	var reached bool
+	s.exec(cmdEstimate, func() {
+		r := s.thermalRoom(&room, room.CurrentTemp)
+		r.Mode = mode
+		r.Speed = speed
+		r.TargetTemp = targetTemp
+		r.Serving = true
+		for ; elapsed <= limit; elapsed += tickInterval {
+			if thermal.Reached(mode, r.Temp, targetTemp) {
+				reached = true
+				return
+			}
+			r.Temp = s.thermal.Step(r, tickInterval)
+		}
+	})
+	return elapsed, reached
+}
+
+// SchedulePrecondition 安排空房在住客到达前预调温
+// 按机组的热模型估算低风速下达到目标温度所需的时间，低风速请求可能需要等待，再提前一个时间片开机；
+// 来不及时立即开机。费用的计费对象取机组当前的配置
+// roomID: 房间号
+// targetTemp: 目标温度
+// arriveTime: 住客预计到达时间
+// 返回值: 新的预调温计划和错误信息
+func (s *ACService) SchedulePrecondition(roomID int, targetTemp float32, arriveTime time.Time) (*db.Precondition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.State != 0 {
+		return nil, fmt.Errorf("房间已入住，请通过控制面板调节温度")
+	}
+	now := s.clock.Now()
+	if !arriveTime.After(now) {
+		return nil, fmt.Errorf("到达时间必须晚于当前时间")
+	}
+	switch zone.mode {
+	case types.ModeCooling, types.ModeHeating, types.ModeAuto:
+	default:
+		return nil, fmt.Errorf("机组 %s 当前为 %s 模式，不能预调温", zone.ID, zone.mode)
+	}
+	if !zone.isValidTemp(zone.mode, targetTemp) {
+		return nil, fmt.Errorf("温度 %.1f°C 超出当前模式允许范围", targetTemp)
+	}
+
+	plans, err := s.preconditionRepo.ListActive()
+	if err != nil {
+		return nil, err
+	}
+	for _, plan := range plans {
+		if plan.RoomID == roomID {
+			return nil, fmt.Errorf("房间 %d 已有未结束的预调温计划 %d", roomID, plan.ID)
+		}
+	}
+
+	duration, ok := zone.scheduler.EstimateDuration(*room, zone.mode, preconditionSpeed, targetTemp, preconditionLimit)
+	if !ok {
+		return nil, fmt.Errorf("低风速下 %v 内无法达到目标温度", preconditionLimit)
+	}
+	startTime := arriveTime.Add(-duration - zone.config.TimeSlice)
+	if startTime.Before(now) {
+		startTime = now
+	}
+
+	plan := &db.Precondition{
+		RoomID:      roomID,
+		TargetTemp:  targetTemp,
+		ArriveTime:  arriveTime,
+		StartTime:   startTime,
+		Status:      db.PreconditionPending,
+		ChargeTo:    string(zone.config.PreconditionCharge),
+		CreatedTime: now,
+	}
+	if err := s.preconditionRepo.Create(plan); err != nil {
+		return nil, err
+	}
+	logger.Info("房间 %d 的预调温计划 %d 已安排：%s 到达，目标 %.1f°C，预计调温 %v，%s 开机",
+		roomID, plan.ID, arriveTime.Format("15:04:05"), targetTemp, duration, startTime.Format("15:04:05"))
+	return plan, nil
+}
+
+// CancelPrecondition 取消预调温计划，调温中的房间随之关机
+func (s *ACService) CancelPrecondition(planID int) (*db.Precondition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.preconditionRepo.GetByID(planID)
+	if err != nil {
+		return nil, fmt.Errorf("预调温计划 %d 不存在", planID)
+	}
+	switch plan.Status {
+	case db.PreconditionPending:
+		plan.Status = db.PreconditionCancelled
+		plan.EndedAt = s.clock.Now()
+		plan.Note = "前台取消"
+		if err := s.preconditionRepo.Save(plan); err != nil {
+			return nil, err
+		}
+	case db.PreconditionRunning:
+		if err := s.endPrecondition(plan, db.PreconditionCancelled, "前台取消"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("预调温计划 %d 已结束", planID)
+	}
+	return plan, nil
+}
+
+// GetPreconditions 获取房间的预调温计划，roomID为0时获取全部房间的计划
+func (s *ACService) GetPreconditions(roomID int) ([]db.Precondition, error) {
+	return s.preconditionRepo.ListByRoom(roomID)
+}
+
+// startPreconditionChecks 按系统时钟定时检查预调温计划
+func (s *ACService) startPreconditionChecks() {
+	s.stopChecks = s.clock.Every(preconditionCheckInterval, s.checkPreconditions)
+}
+
+// stopPreconditionChecks 停止预调温计划的定时检查
+func (s *ACService) stopPreconditionChecks() {
+	if s.stopChecks != nil {
+		s.stopChecks()
+		s.stopChecks = nil
+	}
+}
+
+// checkPreconditions 到开机时间的计划开机；调温中的计划在空调被关闭或住客逾期未入住时结束
+func (s *ACService) checkPreconditions() {
+	plans, err := s.preconditionRepo.ListActive()
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	now := s.clock.Now()
+	for _, plan := range plans {
+		switch {
+		case plan.Status == db.PreconditionPending && !now.Before(plan.StartTime):
+			s.startPrecondition(plan.ID)
+		case plan.Status == db.PreconditionRunning:
+			s.holdPrecondition(plan.ID)
+		}
+	}
+}
+
+// startPrecondition 为预调温计划开机，以低风速和计划的目标温度请求服务
+func (s *ACService) startPrecondition(planID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.preconditionRepo.GetByID(planID)
+	if err != nil || plan.Status != db.PreconditionPending {
+		return
+	}
+	if err := s.powerOnPrecondition(plan); err != nil {
+		plan.Status = db.PreconditionFailed
+		plan.EndedAt = s.clock.Now()
+		plan.Note = err.Error()
+		logger.Error("房间 %d 的预调温计划 %d 开机失败: %v", plan.RoomID, plan.ID, err)
+	} else {
+		plan.Status = db.PreconditionRunning
+		plan.StartedAt = s.clock.Now()
+	}
+	if err := s.preconditionRepo.Save(plan); err != nil {
+		logger.Error("%v", err)
+	}
+}
+
+// powerOnPrecondition 开启空房的空调，调用方需持有锁
+// 房间先记录预调温计划，之后的详单都归入该计划
+func (s *ACService) powerOnPrecondition(plan *db.Precondition) error {
+	room, err := s.roomRepo.GetRoomByID(plan.RoomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return err
+	}
+	if !zone.isOn {
+		return fmt.Errorf("中央空调未开启")
+	}
+	if room.State != 0 {
+		return fmt.Errorf("房间已入住")
+	}
+	if room.ACState == 1 {
+		return fmt.Errorf("空调已开启")
+	}
+	if !zone.isValidTemp(zone.mode, plan.TargetTemp) {
+		return fmt.Errorf("温度 %.1f°C 超出当前模式允许范围", plan.TargetTemp)
+	}
+
+	if err := s.roomRepo.SetPrecondition(room.RoomID, plan.ID); err != nil {
+		return fmt.Errorf("记录预调温计划失败: %v", err)
+	}
+	if err := s.roomRepo.PowerOnAC(room.RoomID, string(zone.mode), plan.TargetTemp,
+		string(preconditionSpeed), s.clock.Now()); err != nil {
+		s.roomRepo.SetPrecondition(room.RoomID, 0)
+		return fmt.Errorf("开启空调失败: %v", err)
+	}
+	s.bus.Publish(events.Event{
+		Type:           events.PreconditionOn,
+		RoomID:         room.RoomID,
+		Speed:          preconditionSpeed,
+		TargetTemp:     plan.TargetTemp,
+		CurrentTemp:    room.CurrentTemp,
+		Time:           s.clock.Now(),
+		PreconditionID: plan.ID,
+	})
+
+	if _, err := zone.scheduler.HandleRequest(room.RoomID, preconditionSpeed, plan.TargetTemp, room.CurrentTemp); err != nil {
+		if err := s.powerOff(zone, room.RoomID); err != nil {
+			logger.Error("关闭房间 %d 空调失败: %v", room.RoomID, err)
+		}
+		s.roomRepo.SetPrecondition(room.RoomID, 0)
+		return fmt.Errorf("调度失败: %v", err)
+	}
+	logger.Info("房间 %d 开始预调温，目标温度 %.1f°C", room.RoomID, plan.TargetTemp)
+	return nil
+}
+
+// holdPrecondition 检查调温中的计划：空调已被关闭时取消计划，超过到达时间的保持时长仍未入住时关机
+func (s *ACService) holdPrecondition(planID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.preconditionRepo.GetByID(planID)
+	if err != nil || plan.Status != db.PreconditionRunning {
+		return
+	}
+	room, err := s.roomRepo.GetRoomByID(plan.RoomID)
+	if err != nil {
+		logger.Error("获取房间信息失败: %v", err)
+		return
+	}
+
+	status, note := db.PreconditionStatus(""), ""
+	switch {
+	case room.ACState != 1:
+		status, note = db.PreconditionCancelled, "空调已关闭"
+	case s.clock.Now().After(plan.ArriveTime.Add(preconditionHold)):
+		status, note = db.PreconditionExpired, "住客未按时入住"
+	default:
+		return
+	}
+	if err := s.endPrecondition(plan, status, note); err != nil {
+		logger.Error("结束房间 %d 的预调温计划 %d 失败: %v", plan.RoomID, plan.ID, err)
+	}
+}
+
+// finishPreconditions 房间入住时结束房间的预调温：调温中的计划完成并关机，尚未开机的计划取消，调用方需持有锁
+// 返回值: 完成的计划，房间不在预调温时为nil
+func (s *ACService) finishPreconditions(room *db.RoomInfo) (*db.Precondition, error) {
+	plans, err := s.preconditionRepo.ListActive()
+	if err != nil {
+		return nil, err
+	}
+	var finished *db.Precondition
+	for i := range plans {
+		plan := &plans[i]
+		if plan.RoomID != room.RoomID {
+			continue
+		}
+		if plan.Status == db.PreconditionRunning {
+			if err := s.endPrecondition(plan, db.PreconditionCompleted, ""); err != nil {
+				return nil, err
+			}
+			finished = plan
+			continue
+		}
+		plan.Status = db.PreconditionCancelled
+		plan.EndedAt = s.clock.Now()
+		plan.Note = "住客已入住"
+		if err := s.preconditionRepo.Save(plan); err != nil {
+			return nil, err
+		}
+	}
+	return finished, nil
+}
+
+// endPrecondition 结束调温中的计划，空调仍开启时关机，并结算计划期间的费用，调用方需持有锁
+// 除住客入住外，预调温的费用都计入酒店运营成本
+func (s *ACService) endPrecondition(plan *db.Precondition, status db.PreconditionStatus, note string) error {
+	room, err := s.roomRepo.GetRoomByID(plan.RoomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if room.ACState == 1 && room.PreconditionID == plan.ID {
+		zone, err := s.roomZone(room)
+		if err != nil {
+			return err
+		}
+		if err := s.powerOff(zone, room.RoomID); err != nil {
+			return err
+		}
+	}
+	if room.PreconditionID == plan.ID {
+		if err := s.roomRepo.SetPrecondition(room.RoomID, 0); err != nil {
+			return fmt.Errorf("清除预调温计划失败: %v", err)
+		}
+	}
+
+	cost, err := s.billing.PreconditionCost(plan.ID)
+	if err != nil {
+		return err
+	}
+	plan.Status = status
+	plan.EndedAt = s.clock.Now()
+	plan.Cost = cost
+	plan.Note = note
+	if status != db.PreconditionCompleted {
+		plan.ChargeTo = string(types.ChargeHotel)
+	}
+	if err := s.preconditionRepo.Save(plan); err != nil {
+		return err
+	}
+	s.bus.Publish(events.Event{
+		Type:           events.PreconditionOff,
+		RoomID:         plan.RoomID,
+		TargetTemp:     plan.TargetTemp,
+		CurrentTemp:    room.CurrentTemp,
+		Time:           s.clock.Now(),
+		Fee:            cost,
+		PreconditionID: plan.ID,
+	})
+	logger.Info("房间 %d 的预调温计划 %d 结束(%s)，费用 %s，计费对象: %s",
+		plan.RoomID, plan.ID, status, cost, plan.ChargeTo)
+	return nil
+}
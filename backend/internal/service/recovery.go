@@ -0,0 +1,269 @@
+// internal/service/recovery.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"sort"
+	"time"
+)
+
+// schedulerState 调度器的持久化快照
+// 队列变化时立即保存，无变化时每隔 stateHeartbeat 保存一次，SavedAt 同时作为心跳时间
+type schedulerState struct {
+	SavedAt  time.Time        // 保存时间(系统时间)
+	Serving  []*ServiceObject // 服务队列
+	Waiting  []*WaitObject    // 等待队列
+	RoomTemp map[int]float32  // 房间温度缓存
+	LoadShed *LoadShedState   // 限负荷模式的状态，未处于限负荷模式时为nil
+}
+
+// centralACSnapshot 中央空调的持久化状态
+type centralACSnapshot struct {
+	IsOn bool
+	Mode types.Mode
+}
+
+// persistState 在队列有变化或心跳到期时保存队列快照，由命令循环在每个命令之后调用
+// 启动时恢复配置等命令先于队列恢复执行，恢复之前不保存，避免空队列覆盖上次保存的快照
+func (s *Scheduler) persistState() {
+	now := s.clock.Now()
+	if !s.recovered {
+		return
+	}
+	if !s.dirty && now.Sub(s.lastSaved) < stateHeartbeat {
+		return
+	}
+
+	view := s.queueView()
+	state := schedulerState{
+		SavedAt:  now,
+		Serving:  view.Serving,
+		Waiting:  view.Waiting,
+		RoomTemp: s.roomTemp,
+		LoadShed: s.loadShedState(),
+	}
+	if err := s.settingRepo.Save(db.ZoneKey(db.SettingSchedulerState, s.zone), state, now); err != nil {
+		logger.Error("保存调度队列快照失败: %v", err)
+		return
+	}
+	s.dirty = false
+	s.lastSaved = now
+}
+
+// loadState 读取上次保存的队列快照
+func (s *Scheduler) loadState() (*schedulerState, bool) {
+	var state schedulerState
+	found, err := s.settingRepo.Load(db.ZoneKey(db.SettingSchedulerState, s.zone), &state)
+	if err != nil {
+		logger.Error("读取调度队列快照失败: %v", err)
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+	return &state, true
+}
+
+// LastHeartbeat 读取上次运行最后一次保存队列快照的时间
+// 各机组的调度器共用系统时钟，取默认机组的心跳即可。
+// 模拟时钟据此从上次的虚拟时间继续，避免重启后系统时间倒退，调用前需初始化数据库
+func LastHeartbeat() (time.Time, bool) {
+	var state schedulerState
+	found, err := db.NewSettingRepository().Load(db.SettingSchedulerState, &state)
+	if err != nil || !found {
+		return time.Time{}, false
+	}
+	return state.SavedAt, true
+}
+
+// restore 按重启前的快照恢复队列
+// rooms 为重启后空调仍处于开启状态的房间，快照中的其他房间会被丢弃。
+// 重启前在服务队列中的房间按开始服务的先后重新进入服务队列并记录新的服务开始详单，
+// 容量不足时进入等待队列；重启前在等待队列中的房间保留剩余等待时间和累计等待时间。
+// 温度取自房间表，风速取自快照，重启前处于限负荷模式的机组继续限负荷。没有快照(state为nil)时队列保持为空。
+// 恢复之后调度器才开始保存快照
+func (s *Scheduler) restore(state *schedulerState, rooms map[int]*db.RoomInfo) {
+	s.exec(cmdRestore, func() {
+		if state != nil {
+			s.restoreQueues(state, rooms)
+		}
+		s.recovered = true
+		s.dirty = true
+	})
+}
+
+// restoreQueues 在命令循环中恢复队列
+func (s *Scheduler) restoreQueues(state *schedulerState, rooms map[int]*db.RoomInfo) {
+	for roomID, temp := range state.RoomTemp {
+		s.roomTemp[roomID] = temp
+	}
+	s.restoreLoadShed(state.LoadShed, func(roomID int) bool {
+		_, ok := rooms[roomID]
+		return ok
+	})
+
+	serving := append([]*ServiceObject(nil), state.Serving...)
+	sort.SliceStable(serving, func(i, j int) bool {
+		return serving[i].StartTime.Before(serving[j].StartTime)
+	})
+	for _, service := range serving {
+		room, ok := rooms[service.RoomID]
+		if !ok {
+			continue
+		}
+		if s.queueView().Fits(service.Speed) {
+			if err := s.addToServiceQueue(room.RoomID, service.Speed, room.TargetTemp, room.CurrentTemp); err != nil {
+				logger.Error("恢复房间 %d 的服务失败: %v", room.RoomID, err)
+				continue
+			}
+			logger.Info("房间 %d 恢复至服务队列", room.RoomID)
+		} else {
+			s.addToWaitQueue(room.RoomID, service.Speed, room.TargetTemp, room.CurrentTemp)
+			logger.Info("房间 %d 恢复至等待队列", room.RoomID)
+		}
+	}
+
+	for _, wait := range state.Waiting {
+		room, ok := rooms[wait.RoomID]
+		if !ok {
+			continue
+		}
+		if _, exists := s.serviceQueue[room.RoomID]; exists {
+			continue
+		}
+		if _, exists := s.waitQueueIndex[room.RoomID]; exists {
+			continue
+		}
+		s.addToWaitQueue(room.RoomID, wait.Speed, room.TargetTemp, room.CurrentTemp)
+		item := s.waitQueueIndex[room.RoomID]
+		if wait.WaitDuration > 0 {
+			item.waitObj.WaitDuration = wait.WaitDuration
+			item.waitObj.RequestTime = wait.RequestTime
+		}
+		item.waitObj.Waited = wait.Waited
+		s.updatePriority(item)
+		logger.Info("房间 %d 恢复至等待队列", room.RoomID)
+	}
+}
+
+// saveCentralState 保存机组的中央空调状态，调用方需持有锁
+func (s *ACService) saveCentralState(zone *Zone) {
+	snapshot := centralACSnapshot{
+		IsOn: zone.isOn,
+		Mode: zone.mode,
+	}
+	if err := s.settingRepo.Save(db.ZoneKey(db.SettingCentralAC, zone.ID), snapshot, s.clock.Now()); err != nil {
+		logger.Error("保存机组 %s 的中央空调状态失败: %v", zone.ID, err)
+	}
+}
+
+// zoneRecovery 一个机组重启前保存的状态
+type zoneRecovery struct {
+	zone    *Zone
+	central centralACSnapshot
+	state   *schedulerState      // 调度队列快照，没有保存过时为nil
+	active  map[int]*db.RoomInfo // 重启后空调仍开启的房间
+}
+
+// recoverState 系统启动时恢复各机组的中央空调状态和调度队列
+// 1. 重启前未结束的计费段在房间所属机组最后一次心跳时刻补记服务中断详单，停机期间不计费
+// 2. 机组已关闭或房间已退房时关闭房间空调，入住前预调温中的空房继续调温
+// 3. 其余开启空调的房间先转为待机，再按机组的快照重新进入服务队列或等待队列，
+// 不在快照中的房间由回温检查在偏离目标超过回差时重新申请服务
+func (s *ACService) recoverState() {
+	s.mu.RLock()
+	recoveries := make(map[string]*zoneRecovery, len(s.zones))
+	for _, zone := range s.zones {
+		recoveries[zone.ID] = &zoneRecovery{zone: zone, active: make(map[int]*db.RoomInfo)}
+	}
+	s.mu.RUnlock()
+
+	// 快照中记录的风速，用于补记详单
+	speeds := make(map[int]types.Speed)
+	for _, recovery := range recoveries {
+		if _, err := s.settingRepo.Load(db.ZoneKey(db.SettingCentralAC, recovery.zone.ID), &recovery.central); err != nil {
+			logger.Error("恢复机组 %s 的中央空调状态失败: %v", recovery.zone.ID, err)
+		}
+		state, hasState := recovery.zone.scheduler.loadState()
+		if !hasState {
+			continue
+		}
+		recovery.state = state
+		for _, wait := range state.Waiting {
+			speeds[wait.RoomID] = wait.Speed
+		}
+		for _, service := range state.Serving {
+			speeds[service.RoomID] = service.Speed
+		}
+	}
+
+	rooms, err := s.roomRepo.GetAllRooms()
+	if err != nil {
+		logger.Error("恢复调度状态时获取房间列表失败: %v", err)
+		return
+	}
+
+	for i := range rooms {
+		room := &rooms[i]
+		recovery, ok := recoveries[room.Zone]
+		if !ok {
+			logger.Error("房间 %d 所属的机组 %s 不存在", room.RoomID, room.Zone)
+			continue
+		}
+
+		preconditioning := room.State == 0 && room.PreconditionID != 0
+		if room.State == 1 || preconditioning {
+			// 没有心跳记录时无法确定停机时刻，按该房间最后一条详单的时间结算
+			end := time.Time{}
+			if recovery.state != nil {
+				end = recovery.state.SavedAt
+			} else if latest, err := s.detailRepo.GetLatestDetail(room.RoomID); err == nil && latest != nil {
+				end = latest.QueryTime
+			}
+			speed, ok := speeds[room.RoomID]
+			if !ok {
+				speed = parseSpeed(room.CurrentSpeed)
+			}
+			closed, err := s.billing.CloseOpenSegment(room, speed, end)
+			if err != nil {
+				logger.Error("结算房间 %d 重启前的计费段失败: %v", room.RoomID, err)
+			} else if closed {
+				logger.Info("房间 %d 重启前的计费段已结算", room.RoomID)
+			}
+		}
+
+		if room.ACState != 1 {
+			continue
+		}
+		if !recovery.central.IsOn || (room.State != 1 && !preconditioning) {
+			if err := s.roomRepo.PowerOffAC(room.RoomID); err != nil {
+				logger.Error("关闭房间 %d 空调失败: %v", room.RoomID, err)
+			}
+			continue
+		}
+		if err := s.roomRepo.UpdateRunState(room.RoomID, string(types.RunStandby)); err != nil {
+			logger.Error("重置房间 %d 的运行状态失败: %v", room.RoomID, err)
+		}
+		room.RunState = string(types.RunStandby)
+		recovery.active[room.RoomID] = room
+	}
+
+	for _, recovery := range recoveries {
+		s.mu.Lock()
+		recovery.zone.isOn = recovery.central.IsOn
+		if recovery.central.Mode != "" {
+			recovery.zone.mode = recovery.central.Mode
+		}
+		recovery.zone.applyMode()
+		s.mu.Unlock()
+
+		recovery.zone.scheduler.restore(recovery.state, recovery.active)
+		if recovery.central.IsOn {
+			StartMonitorService()
+			logger.Info("已恢复机组 %s 的中央空调状态，工作模式：%s，开启空调的房间数：%d",
+				recovery.zone.ID, recovery.central.Mode, len(recovery.active))
+		}
+	}
+}
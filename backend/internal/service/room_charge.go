@@ -0,0 +1,165 @@
+// internal/service/room_charge.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RoomChargePolicy 房费计算规则，全酒店统一
+// 房费按入住日期到退房日期之间的晚数计算，至少一晚；次日及之后在截止时刻后退房的加收半天或一天；
+// 入住后在取消时限内当天退房的视为当日取消，按日房费的比例收取
+type RoomChargePolicy struct {
+	CheckoutCutoff     string  `json:"checkoutCutoff"`     // 退房截止时刻 HH:MM，超过后加收半天
+	FullDayAfter       string  `json:"fullDayAfter"`       // 超过该时刻退房加收一天 HH:MM，不早于退房截止时刻
+	HalfDayRatio       float64 `json:"halfDayRatio"`       // 半天房费占日房费的比例
+	CancelGraceMinutes int     `json:"cancelGraceMinutes"` // 入住后多少分钟内当天退房视为当日取消，0为不受理取消
+	CancelRatio        float64 `json:"cancelRatio"`        // 当日取消收取日房费的比例，0为免收
+}
+
+// DefaultRoomChargePolicy 默认房费计算规则：12:00前退房，18:00前加收半天，入住30分钟内取消免收房费
+var DefaultRoomChargePolicy = RoomChargePolicy{
+	CheckoutCutoff:     "12:00",
+	FullDayAfter:       "18:00",
+	HalfDayRatio:       0.5,
+	CancelGraceMinutes: 30,
+	CancelRatio:        0,
+}
+
+// Validate 检查房费计算规则
+func (p RoomChargePolicy) Validate() error {
+	cutoff, err := parseClock(p.CheckoutCutoff)
+	if err != nil {
+		return fmt.Errorf("退房截止时刻无效: %v", err)
+	}
+	fullDay, err := parseClock(p.FullDayAfter)
+	if err != nil {
+		return fmt.Errorf("加收一天的时刻无效: %v", err)
+	}
+	if fullDay < cutoff {
+		return fmt.Errorf("加收一天的时刻不能早于退房截止时刻")
+	}
+	if p.HalfDayRatio <= 0 || p.HalfDayRatio > 1 {
+		return fmt.Errorf("半天房费的比例必须在0到1之间")
+	}
+	if p.CancelGraceMinutes < 0 {
+		return fmt.Errorf("当日取消的时限不能为负数")
+	}
+	if p.CancelRatio < 0 || p.CancelRatio > 1 {
+		return fmt.Errorf("当日取消收取的比例必须在0到1之间")
+	}
+	return nil
+}
+
+// Charge 按规则计算从入住到退房的房费，逐项列为账单的房费账目
+// rate: 日房费；房费账目的记账时间为退房时间
+// 晚数和延迟退房都按入住时间所在时区的日期和时刻计算
+func (p RoomChargePolicy) Charge(checkin, checkout time.Time, rate types.Money) []db.FolioItem {
+	checkout = checkout.In(checkin.Location())
+	nights := calendarDays(checkin, checkout)
+
+	// 当天退房且未超过取消时限
+	if nights == 0 && p.CancelGraceMinutes > 0 && checkout.Sub(checkin) <= time.Duration(p.CancelGraceMinutes)*time.Minute {
+		amount := types.Yuan(rate.Yuan() * p.CancelRatio)
+		return []db.FolioItem{roomChargeItem("当日取消", 1, amount, checkout)}
+	}
+
+	// 当天退房至少收一晚，次日及之后退房才有延迟退房加收
+	late := nights > 0
+	if nights < 1 {
+		nights = 1
+	}
+	items := []db.FolioItem{roomChargeItem(fmt.Sprintf("房费 %d晚", nights), nights, rate, checkout)}
+	if !late {
+		return items
+	}
+
+	cutoff, _ := parseClock(p.CheckoutCutoff)
+	fullDay, _ := parseClock(p.FullDayAfter)
+	second := checkout.Hour()*3600 + checkout.Minute()*60 + checkout.Second()
+	switch {
+	case second > fullDay*60:
+		description := fmt.Sprintf("延迟退房(%s后) 一天", p.FullDayAfter)
+		items = append(items, roomChargeItem(description, 1, rate, checkout))
+	case second > cutoff*60:
+		description := fmt.Sprintf("延迟退房(%s后) 半天", p.CheckoutCutoff)
+		items = append(items, roomChargeItem(description, 1, types.Yuan(rate.Yuan()*p.HalfDayRatio), checkout))
+	}
+	return items
+}
+
+// roomChargeItem 一条房费账目
+func roomChargeItem(description string, quantity int, unitPrice types.Money, now time.Time) db.FolioItem {
+	return db.FolioItem{
+		Kind:        db.FolioRoomNight,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		Amount:      unitPrice.Mul(int64(quantity)),
+		CreatedTime: now,
+	}
+}
+
+// calendarDays 入住日期到退房日期相隔的天数，两个时间需在同一时区
+func calendarDays(checkin, checkout time.Time) int {
+	from := time.Date(checkin.Year(), checkin.Month(), checkin.Day(), 0, 0, 0, 0, checkin.Location())
+	to := time.Date(checkout.Year(), checkout.Month(), checkout.Day(), 0, 0, 0, 0, checkin.Location())
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// GetRoomChargePolicy 获取房费计算规则
+func (s *ACService) GetRoomChargePolicy() RoomChargePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.roomChargePolicy()
+}
+
+// roomChargePolicy 当前的房费计算规则，没有设置过时为默认规则，调用方需持有锁
+func (s *ACService) roomChargePolicy() RoomChargePolicy {
+	if s.roomCharge == nil {
+		return DefaultRoomChargePolicy
+	}
+	return *s.roomCharge
+}
+
+// SetRoomChargePolicy 修改房费计算规则，对之后的账单预览和退房生效
+func (s *ACService) SetRoomChargePolicy(policy RoomChargePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.settingRepo.Save(db.SettingRoomCharge, policy, s.clock.Now()); err != nil {
+		return fmt.Errorf("保存房费计算规则失败: %v", err)
+	}
+	s.roomCharge = &policy
+	logger.Info("房费计算规则已修改: 退房截止 %s，%s后加收一天，半天比例 %.2f，当日取消时限 %d分钟、收取比例 %.2f",
+		policy.CheckoutCutoff, policy.FullDayAfter, policy.HalfDayRatio, policy.CancelGraceMinutes, policy.CancelRatio)
+	return nil
+}
+
+// restoreRoomChargePolicy 系统启动时恢复上次保存的房费计算规则
+func (s *ACService) restoreRoomChargePolicy() {
+	var policy RoomChargePolicy
+	found, err := s.settingRepo.Load(db.SettingRoomCharge, &policy)
+	if err != nil {
+		logger.Error("恢复房费计算规则失败，使用默认规则: %v", err)
+		return
+	}
+	if !found {
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		logger.Error("保存的房费计算规则无效，使用默认规则: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roomCharge = &policy
+}
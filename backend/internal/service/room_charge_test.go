@@ -0,0 +1,89 @@
+// internal/service/room_charge_test.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRoomChargePolicyCharge(t *testing.T) {
+	// 酒店所在时区为东八区，at 为该时区 2024年6月 day 日 hour:minute，utc 为 UTC 的同一写法
+	hotel := time.FixedZone("CST", 8*3600)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 6, day, hour, minute, 0, 0, hotel)
+	}
+	utc := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+	type item struct {
+		description string
+		quantity    int
+		amount      int64 // 分
+	}
+	cancelHalf := DefaultRoomChargePolicy
+	cancelHalf.CancelRatio = 0.5
+
+	tests := []struct {
+		name     string
+		policy   RoomChargePolicy
+		checkin  time.Time
+		checkout time.Time
+		rate     types.Money
+		want     []item
+	}{
+		{"当日取消时限内", DefaultRoomChargePolicy, at(1, 14, 0), at(1, 14, 20), types.Fen(20000),
+			[]item{{"当日取消", 1, 0}}},
+		{"恰好到取消时限", DefaultRoomChargePolicy, at(1, 14, 0), at(1, 14, 30), types.Fen(20000),
+			[]item{{"当日取消", 1, 0}}},
+		{"当日取消按比例收取", cancelHalf, at(1, 14, 0), at(1, 14, 10), types.Fen(20000),
+			[]item{{"当日取消", 1, 10000}}},
+		{"当天退房超过取消时限", DefaultRoomChargePolicy, at(1, 14, 0), at(1, 14, 31), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"当天晚于截止时刻退房不加收", DefaultRoomChargePolicy, at(1, 8, 0), at(1, 20, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"跨零点的短住不是当日取消", DefaultRoomChargePolicy, at(1, 23, 50), at(2, 0, 10), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"次日截止时刻前退房", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 11, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"次日恰好截止时刻退房", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 12, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"次日截止时刻后退房加收半天", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 13, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}, {"延迟退房(12:00后) 半天", 1, 10000}}},
+		{"半天房费舍入到分", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 13, 0), types.Fen(19999),
+			[]item{{"房费 1晚", 1, 19999}, {"延迟退房(12:00后) 半天", 1, 10000}}},
+		{"次日恰好加收一天的时刻退房", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 18, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}, {"延迟退房(12:00后) 半天", 1, 10000}}},
+		{"次日加收一天的时刻后退房", DefaultRoomChargePolicy, at(1, 14, 0), at(2, 19, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}, {"延迟退房(18:00后) 一天", 1, 20000}}},
+		{"退房时间在其他时区: 入住地次日12:30退房加收半天", DefaultRoomChargePolicy, at(1, 14, 0), utc(2, 4, 30), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}, {"延迟退房(12:00后) 半天", 1, 10000}}},
+		{"退房时间在其他时区: 入住地次日01:00退房不加收", DefaultRoomChargePolicy, at(1, 14, 0), utc(1, 17, 0), types.Fen(20000),
+			[]item{{"房费 1晚", 1, 20000}}},
+		{"退房时间在其他时区: 入住地当天取消", DefaultRoomChargePolicy, at(1, 23, 20), utc(1, 15, 45), types.Fen(20000),
+			[]item{{"当日取消", 1, 0}}},
+		{"连住三晚", DefaultRoomChargePolicy, at(1, 14, 0), at(4, 10, 0), types.Fen(20000),
+			[]item{{"房费 3晚", 3, 60000}}},
+		{"连住三晚后延迟退房", DefaultRoomChargePolicy, at(1, 14, 0), at(4, 15, 0), types.Fen(20000),
+			[]item{{"房费 3晚", 3, 60000}, {"延迟退房(12:00后) 半天", 1, 10000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := tt.policy.Charge(tt.checkin, tt.checkout, tt.rate)
+			if len(items) != len(tt.want) {
+				t.Fatalf("房费账目为 %v，期望 %v", items, tt.want)
+			}
+			for i, want := range tt.want {
+				got := items[i]
+				if got.Description != want.description || got.Quantity != want.quantity || got.Amount != types.Fen(want.amount) {
+					t.Errorf("第 %d 条账目为 %s ×%d %s，期望 %s ×%d %s", i+1,
+						got.Description, got.Quantity, got.Amount, want.description, want.quantity, types.Fen(want.amount))
+				}
+				if got.Kind != db.FolioRoomNight || !got.CreatedTime.Equal(tt.checkout) {
+					t.Errorf("第 %d 条账目类型为 %s、记账时间为 %v，期望房费和退房时间", i+1, got.Kind, got.CreatedTime)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// internal/service/room_state.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+)
+
+// runStateTransitions 房间空调运行状态的合法转换
+// 关机 → 等待/送风 → 待机(达到目标或队列被清空) → 等待/送风 …，开机后的任意状态都可以关机
+var runStateTransitions = map[types.RunState][]types.RunState{
+	types.RunOff:     {types.RunWaiting, types.RunServing},
+	types.RunWaiting: {types.RunServing, types.RunStandby, types.RunOff},
+	types.RunServing: {types.RunWaiting, types.RunStandby, types.RunOff},
+	types.RunStandby: {types.RunWaiting, types.RunServing, types.RunOff},
+}
+
+// canTransition 判断运行状态能否从 from 转换到 to
+func canTransition(from, to types.RunState) bool {
+	for _, next := range runStateTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RoomRunState 房间当前的运行状态
+// 刚开机尚未调度的房间仍为关机；旧版本的房间记录由系统启动时的恢复统一转为待机
+func RoomRunState(room *db.RoomInfo) types.RunState {
+	if room.ACState != 1 || room.RunState == "" {
+		return types.RunOff
+	}
+	return types.RunState(room.RunState)
+}
+
+// transition 将房间转换到新的运行状态并发布状态变化事件
+// reason: 引起状态变化的调度事件
+// service: 状态变化所涉及的服务段，进入送风时为新的服务段，结束送风时为结束的服务段，
+// 其余转换描述房间请求的风速和温度
+// 状态未变化时不做任何处理，非法的转换返回错误且不修改状态
+func (s *Scheduler) transition(roomID int, to types.RunState, reason events.Type, service *ServiceObject) error {
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	from := RoomRunState(room)
+	if from == to {
+		return nil
+	}
+	if !canTransition(from, to) {
+		return fmt.Errorf("房间 %d 的运行状态不能从 %s 转换为 %s", roomID, from, to)
+	}
+	if err := s.roomRepo.UpdateRunState(roomID, string(to)); err != nil {
+		return fmt.Errorf("更新房间运行状态失败: %v", err)
+	}
+
+	if service == nil {
+		service = &ServiceObject{
+			RoomID:      roomID,
+			StartTime:   s.clock.Now(),
+			Speed:       parseSpeed(room.CurrentSpeed),
+			TargetTemp:  room.TargetTemp,
+			CurrentTemp: room.CurrentTemp,
+		}
+	}
+	s.bus.Publish(events.Event{
+		Type:           events.StateChanged,
+		RoomID:         roomID,
+		Speed:          service.Speed,
+		Mode:           service.Mode,
+		ModeRate:       service.ModeRate,
+		TargetTemp:     service.TargetTemp,
+		CurrentTemp:    service.CurrentTemp,
+		StartTime:      service.StartTime,
+		Time:           s.clock.Now(),
+		PreconditionID: room.PreconditionID,
+		From:           from,
+		To:             to,
+		Reason:         reason,
+	})
+	return nil
+}
+
+// setRunState 转换房间的运行状态，失败时只记录日志
+func (s *Scheduler) setRunState(roomID int, to types.RunState, reason events.Type, service *ServiceObject) {
+	if err := s.transition(roomID, to, reason, service); err != nil {
+		logger.Error("%v", err)
+	}
+}
@@ -4,49 +4,68 @@
 package service
 
 import (
+	"backend/internal/clock"
 	"backend/internal/db"
+	"backend/internal/events"
 	"backend/internal/logger"
+	"backend/internal/thermal"
 	"backend/internal/types"
 	"container/heap"
 	"fmt"
 	"math"
-	"sync"
+	"sort"
 	"time"
 )
 
 const (
-	MaxServices = 3  // 最大服务对象
-	WaitTime    = 20 // 时间片
+	tickInterval   = time.Second     // 调度与温度更新的周期
+	stateHeartbeat = 5 * time.Second // 队列无变化时保存快照的间隔
 )
 
+// TickInterval 调度器更新队列和房间温度的周期(系统时间)
+func TickInterval() time.Duration {
+	return tickInterval
+}
+
 // ServiceObject 表示一个正在服务中的空调对象
 type ServiceObject struct {
-	RoomID      int         // 房间唯一标识
-	StartTime   time.Time   // 当前服务周期的开始时间
-	PowerOnTime time.Time   // 本次开机的时间点,用于费用计算
-	Speed       types.Speed // 当前风速设置
-	Duration    float32     // 当前服务时长(秒)
-	TargetTemp  float32     // 目标温度
-	CurrentTemp float32     // 当前温度
-	IsCompleted bool        // 是否已完成服务
+	RoomID       int             // 房间唯一标识
+	StartTime    time.Time       // 当前服务周期的开始时间
+	PowerOnTime  time.Time       // 本次开机的时间点,用于费用计算
+	Speed        types.Speed     // 当前风速设置
+	Duration     float32         // 当前服务时长(秒)
+	TargetTemp   float32         // 目标温度
+	CurrentTemp  float32         // 当前温度
+	IsCompleted  bool            // 是否已完成服务
+	Guaranteed   bool            // 因等待超时获得的保证时间片，服务满一个时间片前不会被抢占或轮换
+	Mode         types.Mode      // 服务开始时机组的工作模式
+	ModeRate     float32         // 服务开始时工作模式的费率系数
+	Class        types.RoomClass // 房间等级，取房间和住客等级中较高的
+	Priority     float64         // 调度优先级，由风速和房间等级加权得到
+	Precondition bool            // 是否为空房入住前的预调温，固定为最低优先级
 }
 
 // WaitObject 表示一个等待服务的请求对象
 // 用于管理未能立即得到服务的空调请求
 type WaitObject struct {
-	RoomID       int         // 请求房间号
-	RequestTime  time.Time   // 发起请求的时间
-	Speed        types.Speed // 请求的风速
-	WaitDuration float32     // 剩余等待时间
-	TargetTemp   float32     // 请求的目标温度
-	CurrentTemp  float32     // 请求时的当前温度
+	RoomID       int             // 请求房间号
+	RequestTime  time.Time       // 发起请求的时间
+	Speed        types.Speed     // 请求的风速
+	WaitDuration float32         // 剩余等待时间
+	TargetTemp   float32         // 请求的目标温度
+	CurrentTemp  float32         // 请求时的当前温度
+	Waited       float32         // 本次进入等待队列后累计的等待时间(秒)
+	Class        types.RoomClass // 房间等级，取房间和住客等级中较高的
+	BasePriority float64         // 调度优先级，由风速和房间等级加权得到
+	Priority     float64         // 有效优先级，调度优先级加上等待老化的增量
+	Precondition bool            // 是否为空房入住前的预调温，固定为最低优先级且不老化
 }
 
 // PriorityQueue 优先级队列实现
 // 用于管理等待队列中的请求，支持基于优先级的排序
 type PriorityItem struct {
 	roomID    int         // 房间号
-	priority  int         // 优先级值
+	priority  float64     // 有效优先级
 	waitObj   *WaitObject // 等待对象
 	indexHeap int         // 在堆中的索引
 }
@@ -84,22 +103,50 @@ func (pq *PriorityQueue) Pop() interface{} {
 }
 
 // Scheduler 空调调度器
-// 负责管理所有房间的空调请求,实现服务队列和等待队列的调度
+// 负责管理所有房间的空调请求,实现服务队列和等待队列的调度。
+// 调度器的状态只由命令循环所在的goroutine读写，公开方法都通过命令发送到该goroutine执行
 type Scheduler struct {
-	mu               sync.RWMutex           // 并发安全锁
-	serviceQueue     map[int]*ServiceObject // 服务队列,key为房间号
-	waitQueue        *PriorityQueue         // 等待队列,基于优先级排序
-	waitQueueIndex   map[int]*PriorityItem  // 等待队列索引,用于快速查找
-	currentService   int                    // 当前服务数量
-	stopChan         chan struct{}          // 停止信号通道
-	billingService   *BillingService        // 计费服务
-	enableLogging    bool                   // 是否启用日志
-	roomTemp         map[int]float32        // 房间温度缓存
-	tempRecoveryRate float32                // 温度回温率(每100ms)
-	tempTicker       *time.Ticker           // 温度更新定时器
-	roomRepo         *db.RoomRepository     // 房间数据访问对象
+	commands         chan command                // 命令通道
+	quit             chan struct{}               // 通知命令循环退出
+	stopped          chan struct{}               // 命令循环已退出
+	zone             string                      // 所属机组，只调度和回温该机组的房间
+	serviceQueue     map[int]*ServiceObject      // 服务队列,key为房间号
+	waitQueue        *PriorityQueue              // 等待队列,基于优先级排序
+	waitQueueIndex   map[int]*PriorityItem       // 等待队列索引,用于快速查找
+	clock            clock.Clock                 // 系统时钟
+	stopTicks        []func()                    // 停止定时任务
+	bus              *events.Bus                 // 事件总线
+	enableLogging    bool                        // 是否启用日志
+	roomTemp         map[int]float32             // 房间温度缓存
+	thermal          thermal.Model               // 房间热模型
+	weather          thermal.Weather             // 室外温度，nil时以房间初始温度为环境温度
+	targetHumidity   float32                     // 除湿模式的目标湿度(%)
+	mode             types.Mode                  // 机组的工作模式，记录在新的服务对象上
+	modeRate         float32                     // 当前工作模式的费率系数
+	roomRepo         *db.RoomRepository          // 房间数据访问对象
+	policy           SchedulingPolicy            // 调度策略
+	capacity         Capacity                    // 服务队列容量
+	timeSlice        time.Duration               // 时间片
+	waitGrowthFactor float32                     // 等待时长增长系数
+	agingRate        float32                     // 每等待一分钟增加的优先级
+	maxWait          time.Duration               // 最长等待时间
+	hysteresis       float32                     // 回差(°C)，待机房间偏离目标超过该值时重新请求服务
+	speedWeight      float32                     // 风速优先级的权重
+	classPriority    map[types.RoomClass]float32 // 各房间等级的优先级加成
+	loadShed         *LoadShed                   // 限负荷模式的设置，nil表示未处于限负荷模式
+	requested        map[int]types.Speed         // 限负荷期间风速被降低的房间住客请求的风速，退出时恢复
+	held             map[int]bool                // 限负荷期间被停止送风的房间，退出时优先恢复服务
+	settingRepo      *db.SettingRepository       // 队列快照存储
+	dirty            bool                        // 队列自上次保存快照后是否有变化
+	lastSaved        time.Time                   // 上次保存快照的时间
+	snapshotVersion  uint64                      // 最近一次只读快照的版本号
+	recovered        bool                        // 启动时是否已按上次的快照恢复队列，恢复前不保存快照
 }
 
+// preconditionPriority 空房入住前预调温的调度优先级，低于任何入住房间的请求
+// 风速优先级至少为1，房间等级的加成不为负数
+const preconditionPriority = 0
+
 // 速度优先级映射
 var speedPriority = map[types.Speed]int{
 	types.SpeedLow:    1,
@@ -107,49 +154,370 @@ var speedPriority = map[types.Speed]int{
 	types.SpeedHigh:   3,
 }
 
-func NewScheduler() *Scheduler {
+// NewScheduler 创建机组的调度器
+func NewScheduler(clk clock.Clock, bus *events.Bus, zone string) *Scheduler {
 	pq := make(PriorityQueue, 0)
 	heap.Init(&pq)
 
 	s := &Scheduler{
+		commands:         make(chan command),
+		quit:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+		zone:             zone,
 		serviceQueue:     make(map[int]*ServiceObject),
 		waitQueue:        &pq,
 		waitQueueIndex:   make(map[int]*PriorityItem),
-		currentService:   0,
-		stopChan:         make(chan struct{}),
+		clock:            clk,
+		bus:              bus,
 		roomRepo:         db.NewRoomRepository(),
 		enableLogging:    false,
 		roomTemp:         make(map[int]float32), // 初始化 roomTemp map
-		tempRecoveryRate: 0.005,                 // 设置默认回温速率
+		thermal:          &thermal.LinearModel{},
+		targetHumidity:   thermal.DefaultTargetHumidity,
+		mode:             types.ModeCooling,
+		modeRate:         1,
+		policy:           &PriorityRoundRobinPolicy{},
+		capacity:         CapacityFromConfig(DefaultConfig),
+		timeSlice:        DefaultConfig.TimeSlice,
+		waitGrowthFactor: DefaultConfig.WaitGrowthFactor,
+		agingRate:        DefaultConfig.AgingRate,
+		maxWait:          DefaultConfig.MaxWait,
+		hysteresis:       DefaultConfig.Hysteresis,
+		speedWeight:      DefaultConfig.SpeedWeight,
+		classPriority:    cloneConfig(DefaultConfig).ClassPriority,
+		requested:        make(map[int]types.Speed),
+		held:             make(map[int]bool),
+		settingRepo:      db.NewSettingRepository(),
 	}
 
-	go s.monitorServiceStatus()
-	go s.monitorRoomTemperature()
+	go s.loop()
+	s.startTicks(tickInterval)
 	return s
 }
 
-// SetBillingService 设置billing service的方法
-func (s *Scheduler) SetBillingService(billing *BillingService) {
-	s.mu.Lock()
-	s.billingService = billing
-	s.mu.Unlock()
+// SetPolicy 按名称切换调度策略
+// 切换只影响之后的调度决策，不会改变当前队列
+func (s *Scheduler) SetPolicy(name string) error {
+	policy, err := NewSchedulingPolicy(name)
+	if err != nil {
+		return err
+	}
+	if !s.exec(cmdReconfigure, func() { s.policy = policy }) {
+		return errSchedulerStopped
+	}
+	logger.Info("调度策略已切换为: %s", name)
+	return nil
+}
+
+// GetPolicyName 获取当前调度策略名称
+func (s *Scheduler) GetPolicyName() string {
+	var name string
+	s.exec(cmdSnapshot, func() { name = s.policy.Name() })
+	return name
 }
 
-// 回温处理
-func (s *Scheduler) monitorRoomTemperature() {
-	s.tempTicker = time.NewTicker(100 * time.Millisecond) // 每100毫秒检查一次
+// SetCapacity 修改服务队列容量或容量模型，立即生效
+// 容量减小时将多出的服务对象降级到等待队列，并记录服务中断详单；
+// 容量增大时从等待队列提升请求
+func (s *Scheduler) SetCapacity(capacity Capacity) error {
+	if err := capacity.Validate(); err != nil {
+		return err
+	}
+	if !s.exec(cmdReconfigure, func() {
+		s.capacity = capacity.clone()
+		s.dirty = true
+		s.shedLoad()
+		s.promoteWaiting()
+	}) {
+		return errSchedulerStopped
+	}
+	if capacity.Mode == types.CapacityPower {
+		logger.Info("服务队列容量已修改为: 功率预算 %.2fkW", capacity.Budget)
+	} else {
+		logger.Info("服务队列容量已修改为: %d", capacity.Slots)
+	}
+	return nil
+}
 
-	go func() {
-		for {
-			select {
-			case <-s.tempTicker.C:
-				s.handleTemperatureRecovery()
-			case <-s.stopChan:
-				s.tempTicker.Stop()
-				return
-			}
+// SetTimeSlice 修改时间片和等待时长增长系数，立即生效
+// 等待队列中请求的剩余等待时间按新旧时间片的比例缩放
+func (s *Scheduler) SetTimeSlice(timeSlice time.Duration, growthFactor float32) error {
+	if timeSlice < tickInterval {
+		return fmt.Errorf("时间片不能小于 %v", tickInterval)
+	}
+	if growthFactor < 0 {
+		return fmt.Errorf("等待时长增长系数不能为负数")
+	}
+	if !s.exec(cmdReconfigure, func() {
+		ratio := float32(timeSlice.Seconds() / s.timeSlice.Seconds())
+		for _, item := range *s.waitQueue {
+			item.waitObj.WaitDuration *= ratio
+		}
+		s.timeSlice = timeSlice
+		s.waitGrowthFactor = growthFactor
+		s.dirty = true
+	}) {
+		return errSchedulerStopped
+	}
+	logger.Info("时间片已修改为: %v, 等待时长增长系数: %.2f", timeSlice, growthFactor)
+	return nil
+}
+
+// SetAging 修改优先级老化速率和最长等待时间，立即生效
+// 等待队列中请求的有效优先级按新的老化速率重新计算
+func (s *Scheduler) SetAging(agingRate float32, maxWait time.Duration) error {
+	if agingRate < 0 {
+		return fmt.Errorf("优先级老化速率不能为负数")
+	}
+	if maxWait < tickInterval {
+		return fmt.Errorf("最长等待时间不能小于 %v", tickInterval)
+	}
+	if !s.exec(cmdReconfigure, func() {
+		s.agingRate = agingRate
+		s.maxWait = maxWait
+		for _, item := range *s.waitQueue {
+			s.updatePriority(item)
+		}
+		s.dirty = true
+	}) {
+		return errSchedulerStopped
+	}
+	logger.Info("优先级老化速率已修改为: %.2f/分钟, 最长等待时间: %v", agingRate, maxWait)
+	return nil
+}
+
+// SetHysteresis 修改回差，从下一个周期开始生效
+func (s *Scheduler) SetHysteresis(hysteresis float32) error {
+	if hysteresis <= 0 {
+		return fmt.Errorf("回差必须大于0")
+	}
+	if !s.exec(cmdReconfigure, func() { s.hysteresis = hysteresis }) {
+		return errSchedulerStopped
+	}
+	logger.Info("回差已修改为: %.2f°C", hysteresis)
+	return nil
+}
+
+// SetPriorityWeights 修改风速优先级的权重和各房间等级的优先级加成，立即生效
+// 服务对象和等待对象的优先级按新的权重重新计算
+func (s *Scheduler) SetPriorityWeights(speedWeight float32, classPriority map[types.RoomClass]float32) error {
+	if speedWeight <= 0 {
+		return fmt.Errorf("风速优先级的权重必须大于0")
+	}
+	weights := make(map[types.RoomClass]float32, len(classPriority))
+	for class, weight := range classPriority {
+		if !class.Valid() || weight < 0 {
+			return fmt.Errorf("房间等级 %s 的优先级加成无效", class)
+		}
+		weights[class] = weight
+	}
+	if !s.exec(cmdReconfigure, func() {
+		s.speedWeight = speedWeight
+		s.classPriority = weights
+		s.refreshPriorities(func(int) bool { return true })
+	}) {
+		return errSchedulerStopped
+	}
+	logger.Info("调度优先级权重已修改为: 风速 %.2f, 房间等级 %v", speedWeight, weights)
+	return nil
+}
+
+// SetRoomClass 房间或住客等级变化后更新队列中该房间的等级和优先级
+func (s *Scheduler) SetRoomClass(roomID int, class types.RoomClass) {
+	s.exec(cmdReconfigure, func() {
+		// 预调温的空房不按房间等级调度
+		if service, ok := s.serviceQueue[roomID]; ok && !service.Precondition {
+			service.Class = class
+		}
+		if item, ok := s.waitQueueIndex[roomID]; ok && !item.waitObj.Precondition {
+			item.waitObj.Class = class
+		}
+		s.refreshPriorities(func(id int) bool { return id == roomID })
+	})
+}
+
+// refreshPriorities 重新计算满足条件的房间的优先级
+func (s *Scheduler) refreshPriorities(match func(roomID int) bool) {
+	for roomID, service := range s.serviceQueue {
+		if match(roomID) {
+			service.Priority = s.requestPriority(service.Speed, service.Class, service.Precondition)
+		}
+	}
+	for _, item := range *s.waitQueue {
+		if match(item.roomID) {
+			s.updatePriority(item)
+		}
+	}
+	s.dirty = true
+}
+
+// basePriority 计算调度优先级：风速优先级乘以权重，加上房间等级的优先级加成
+func (s *Scheduler) basePriority(speed types.Speed, class types.RoomClass) float64 {
+	return float64(float32(speedPriority[speed])*s.speedWeight + s.classPriority[class])
+}
+
+// requestPriority 计算请求的调度优先级，空房入住前的预调温固定为最低优先级
+func (s *Scheduler) requestPriority(speed types.Speed, class types.RoomClass, precondition bool) float64 {
+	if precondition {
+		return preconditionPriority
+	}
+	return s.basePriority(speed, class)
+}
+
+// requestClass 房间参与调度的等级，以及是否为空房入住前的预调温
+// 预调温的空房按标准间调度，不因房间等级获得优先级加成
+func requestClass(room *db.RoomInfo) (types.RoomClass, bool) {
+	if room.PreconditionID != 0 {
+		return types.ClassStandard, true
+	}
+	return RoomClass(room), false
+}
+
+// updatePriority 按调度优先级和累计等待时间重新计算等待对象的有效优先级
+// 预调温的请求不随等待老化，有效优先级始终低于入住房间的请求
+func (s *Scheduler) updatePriority(item *PriorityItem) {
+	wait := item.waitObj
+	wait.BasePriority = s.requestPriority(wait.Speed, wait.Class, wait.Precondition)
+	wait.Priority = wait.BasePriority
+	if !wait.Precondition {
+		wait.Priority += float64(s.agingRate * wait.Waited / 60)
+	}
+	item.priority = wait.Priority
+	heap.Fix(s.waitQueue, item.indexHeap)
+}
+
+// SetThermal 切换房间热模型、室外温度来源和除湿的目标湿度，从下一个周期开始生效
+func (s *Scheduler) SetThermal(model thermal.Model, weather thermal.Weather, targetHumidity float32) {
+	s.exec(cmdReconfigure, func() {
+		s.thermal = model
+		s.weather = weather
+		s.targetHumidity = targetHumidity
+	})
+	logger.Info("房间热模型已切换为: %s", model.Name())
+}
+
+// thermalRoom 构造热模型计算所需的房间状态
+func (s *Scheduler) thermalRoom(room *db.RoomInfo, temp float32) thermal.Room {
+	ambient := room.InitialTemp
+	if s.weather != nil {
+		ambient = s.weather.OutdoorTemp(s.clock.Now())
+	}
+	return thermal.Room{
+		Temp:        temp,
+		InitialTemp: room.InitialTemp,
+		TargetTemp:  room.TargetTemp,
+		Ambient:     ambient,
+		Mode:        types.Mode(room.Mode),
+		Speed:       parseSpeed(room.CurrentSpeed),
+		Humidity:    room.Humidity,
+		Params: thermal.Params{
+			Volume:        room.Volume,
+			Insulation:    room.Insulation,
+			OccupancyLoad: room.OccupancyLoad,
+		},
+	}
+}
+
+// SetMode 设置机组的工作模式及其费率系数，对之后开始的服务段生效
+// 已在服务中的服务段沿用开始时的模式和费率系数
+func (s *Scheduler) SetMode(mode types.Mode, modeRate float32) {
+	s.exec(cmdReconfigure, func() {
+		s.mode = mode
+		s.modeRate = modeRate
+	})
+}
+
+// serviceDone 判断服务中的房间是否已完成本次服务
+// 除湿模式以湿度达到目标为准，送风模式持续送风，其余模式以温度达到目标为准
+func (s *Scheduler) serviceDone(room *db.RoomInfo, service *ServiceObject) bool {
+	mode := types.Mode(room.Mode)
+	if mode == types.ModeDry {
+		return thermal.HumidityReached(room.Humidity, s.targetHumidity)
+	}
+	return thermal.Reached(mode, service.CurrentTemp, service.TargetTemp)
+}
+
+// needsService 判断待机的房间是否需要重新申请服务
+// 除湿模式在湿度高于目标5%以上时申请，送风模式总是申请，其余模式在温度向需要送风的方向偏离目标超过回差时申请
+func (s *Scheduler) needsService(room *db.RoomInfo, temp float32) bool {
+	mode := types.Mode(room.Mode)
+	if mode == types.ModeDry {
+		return thermal.NeedsDehumidify(room.Humidity, s.targetHumidity, 5.0)
+	}
+	return thermal.NeedsService(mode, temp, room.TargetTemp, s.hysteresis)
+}
+
+// updateHumidity 由湿度模型计算房间本周期的湿度，湿度有变化时才写入数据库
+func (s *Scheduler) updateHumidity(room *db.RoomInfo, speed types.Speed, serving bool) {
+	state := s.thermalRoom(room, room.CurrentTemp)
+	state.Speed = speed
+	state.Serving = serving
+	humidity := thermal.StepHumidity(state, s.targetHumidity, tickInterval)
+	if humidity == room.Humidity {
+		return
+	}
+	if err := s.roomRepo.UpdateHumidity(room.RoomID, humidity); err != nil {
+		logger.Error("更新房间湿度失败: %v", err)
+		return
+	}
+	room.Humidity = humidity
+}
+
+// GetCapacity 获取服务队列容量
+func (s *Scheduler) GetCapacity() Capacity {
+	var capacity Capacity
+	s.exec(cmdSnapshot, func() { capacity = s.capacity.clone() })
+	return capacity
+}
+
+// shedLoad 服务队列超出容量时，按降级顺序将服务对象移至等待队列
+// 发生在容量减小或服务中的房间调高风速之后
+func (s *Scheduler) shedLoad() {
+	for s.queueView().Overloaded() {
+		victim := s.selectDemotion()
+		if victim == nil {
+			return
 		}
-	}()
+		s.preempt(victim, events.Preempted)
+		logger.Info("服务队列超出容量，房间 %d 降级至等待队列", victim.RoomID)
+	}
+}
+
+// selectDemotion 选择超出容量时被降级的服务对象
+// 优先降级调度优先级最低的对象，优先级相同时降级服务时间最长的对象；
+// 仅当所有服务对象都处于保证时间片内时才降级这些对象
+func (s *Scheduler) selectDemotion() *ServiceObject {
+	view := s.queueView()
+	if victim := lowestLongestServing(preemptible(view.Serving)); victim != nil {
+		return victim
+	}
+	return lowestLongestServing(view.Serving)
+}
+
+// publish 发布与服务对象相关的事件
+// 事件中的风速、温度和开始时间取自服务对象当前(变更前)的状态，预调温中的房间同时记录预调温计划
+func (s *Scheduler) publish(eventType events.Type, service *ServiceObject) {
+	s.bus.Publish(s.serviceEvent(eventType, service))
+}
+
+// serviceEvent 构造与服务对象相关的事件
+func (s *Scheduler) serviceEvent(eventType events.Type, service *ServiceObject) events.Event {
+	var preconditionID int
+	if room, err := s.roomRepo.GetRoomByID(service.RoomID); err == nil {
+		preconditionID = room.PreconditionID
+	}
+	return events.Event{
+		Type:           eventType,
+		RoomID:         service.RoomID,
+		Speed:          service.Speed,
+		Mode:           service.Mode,
+		ModeRate:       service.ModeRate,
+		TargetTemp:     service.TargetTemp,
+		CurrentTemp:    service.CurrentTemp,
+		StartTime:      service.StartTime,
+		Time:           s.clock.Now(),
+		PreconditionID: preconditionID,
+	}
 }
 
 // HandleRequest 处理新的空调请求
@@ -162,25 +530,40 @@ func (s *Scheduler) monitorRoomTemperature() {
 //   - bool: 是否直接进入服务队列
 //   - error: 错误信息
 func (s *Scheduler) HandleRequest(roomID int, speed types.Speed, targetTemp, currentTemp float32) (bool, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var serving bool
+	var err error
+	if !s.exec(cmdRequest, func() {
+		serving, err = s.handleRequest(roomID, speed, targetTemp, currentTemp)
+	}) {
+		return false, errSchedulerStopped
+	}
+	return serving, err
+}
+
+// handleRequest 在命令循环中处理房间请求
+func (s *Scheduler) handleRequest(roomID int, speed types.Speed, targetTemp, currentTemp float32) (bool, error) {
+	s.dirty = true
+	speed = s.capSpeed(roomID, speed)
 	// 检查是否已在服务队列
 	if service, exists := s.serviceQueue[roomID]; exists {
 		service.TargetTemp = targetTemp
 		if service.Speed != speed {
-			// 记录当前服务的详单
-			if err := s.billingService.CreateDetail(roomID, service, db.DetailTypeSpeedChange); err != nil {
-				logger.Error("创建风速切换详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-			}
+			// 结束当前风速的服务段
+			s.publish(events.SpeedChanged, service)
 			// 更新服务对象
-			service.StartTime = time.Now()
+			service.StartTime = s.clock.Now()
 			service.Speed = speed
+			service.Priority = s.requestPriority(speed, service.Class, service.Precondition)
 			// 更新房间风速
 			if err := s.roomRepo.UpdateSpeed(roomID, string(speed)); err != nil {
 				logger.Error("更新房间风速失败: %v", err)
 			}
+			// power 模式下风速决定占用的负载，调高可能超出预算，调低可能空出容量
+			s.shedLoad()
+			s.promoteWaiting()
 		}
-		return true, nil
+		_, serving := s.serviceQueue[roomID]
+		return serving, nil
 	}
 
 	// 检查是否在等待队列
@@ -194,84 +577,102 @@ func (s *Scheduler) HandleRequest(roomID int, speed types.Speed, targetTemp, cur
 				return false, err
 			}
 
-			// 创建一个临时的服务对象用于记录详单
+			// 创建一个临时的服务对象描述旧风速的请求
 			tempService := &ServiceObject{
 				RoomID:      roomID,
-				StartTime:   time.Now(),
+				StartTime:   s.clock.Now(),
 				PowerOnTime: room.CheckinTime,
 				Speed:       oldSpeed, // 使用旧风速
 				TargetTemp:  item.waitObj.TargetTemp,
 				CurrentTemp: item.waitObj.CurrentTemp,
 			}
 
-			// 创建风速切换详单
-			if s.billingService != nil {
-				if err := s.billingService.CreateDetail(roomID, tempService, db.DetailTypeSpeedChange); err != nil {
-					logger.Error("创建风速切换详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-				}
-			}
+			s.publish(events.SpeedChanged, tempService)
 		}
 		if s.shouldReschedule(roomID, speed) {
-			delete(s.waitQueueIndex, roomID)
-			heap.Remove(s.waitQueue, item.indexHeap)
+			s.removeFromWaitQueue(roomID)
 			result, err := s.schedule(roomID, speed, targetTemp, currentTemp)
 			return result, err
 		}
 		item.waitObj.Speed = speed
 		item.waitObj.TargetTemp = targetTemp
-		item.priority = speedPriority[speed]
+		s.updatePriority(item)
 		return false, nil
 	}
 
-	if s.currentService < MaxServices {
-
-		if err := s.addToServiceQueue(roomID, speed, targetTemp, currentTemp); err != nil {
-			return false, err
-		}
-		return true, nil
-	}
-
 	return s.schedule(roomID, speed, targetTemp, currentTemp)
 }
 
 // ClearAllQueues 清空所有队列
+// 服务队列中的房间发布服务结束事件，两个队列中的房间都转为待机
 func (s *Scheduler) ClearAllQueues() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.exec(cmdClear, s.clearAllQueues)
+}
 
+// clearAllQueues 在命令循环中清空所有队列
+func (s *Scheduler) clearAllQueues() {
+	view := s.queueView()
 	// 清空服务队列
-	for roomID := range s.serviceQueue {
-
-		delete(s.serviceQueue, roomID)
+	for _, service := range view.Serving {
+		s.publish(events.ServiceStopped, service)
+		delete(s.serviceQueue, service.RoomID)
+		s.setRunState(service.RoomID, types.RunStandby, events.ServiceStopped, service)
 	}
-	s.currentService = 0
+	s.dirty = true
 
 	// 清空等待队列
+	for _, wait := range view.Waiting {
+		s.setRunState(wait.RoomID, types.RunStandby, events.ServiceStopped, waitService(wait))
+	}
 	s.waitQueue = &PriorityQueue{}
 	heap.Init(s.waitQueue)
 	s.waitQueueIndex = make(map[int]*PriorityItem)
+	s.held = make(map[int]bool)
 }
 
+// schedule 按调度策略处理一个不在任何队列中的请求
+// 1. 服务队列容纳得下且策略允许时直接进入服务队列
+// 2. 容纳不下时由策略依次选择被抢占的对象，直到腾出足够的容量
+// 3. 无法腾出足够容量时进入等待队列，等待时间片轮转
 func (s *Scheduler) schedule(roomID int, speed types.Speed, targetTemp, currentTemp float32) (bool, error) {
-	requestPriority := speedPriority[speed]
-
-	// 1.优先级调度
-	lowPriorityServices := s.findLowPriorityServices(requestPriority)
-	if len(lowPriorityServices) > 0 {
-		victim := s.selectVictim(lowPriorityServices)
-		if victim != nil {
-
-			// 将被抢占的服务对象添加到等待队列
-			s.addToWaitQueue(victim.RoomID, victim.Speed, victim.TargetTemp, victim.CurrentTemp)
-			// 从服务队列中移除
-
-			if s.billingService != nil {
-				if err := s.billingService.CreateDetail(victim.RoomID, victim, db.DetailTypeServiceInterrupt); err != nil {
-					logger.Error("创建服务中断详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-				}
+	req := &WaitObject{
+		RoomID:      roomID,
+		RequestTime: s.clock.Now(),
+		Speed:       speed,
+		TargetTemp:  targetTemp,
+		CurrentTemp: currentTemp,
+		Class:       types.ClassStandard,
+	}
+	if room, err := s.roomRepo.GetRoomByID(roomID); err == nil {
+		req.Class, req.Precondition = requestClass(room)
+	}
+	req.BasePriority = s.requestPriority(speed, req.Class, req.Precondition)
+	req.Priority = req.BasePriority
+
+	// 限负荷暂停准入时直接等待
+	if s.admissionSuspended() {
+		s.addToWaitQueue(roomID, speed, targetTemp, currentTemp)
+		return false, nil
+	}
+
+	// 1.直接服务
+	view := s.queueView()
+	if view.Fits(speed) && s.policy.Admit(req, view) {
+		if err := s.addToServiceQueue(roomID, speed, targetTemp, currentTemp); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	// 2.抢占调度
+	if !view.Fits(speed) {
+		victims, ok := selectVictims(view, speed, func(view QueueView) *ServiceObject {
+			return s.policy.SelectVictim(req, view)
+		})
+		if ok {
+			for _, victim := range victims {
+				s.preempt(victim, events.Preempted)
 			}
-			delete(s.serviceQueue, victim.RoomID)
-			s.currentService--
 
 			// 将新请求加入服务队列
 			if err := s.addToServiceQueue(roomID, speed, targetTemp, currentTemp); err != nil {
@@ -281,81 +682,118 @@ func (s *Scheduler) schedule(roomID int, speed types.Speed, targetTemp, currentT
 		}
 	}
 
-	// 2.时间片调度
+	// 3.时间片调度
 	s.addToWaitQueue(roomID, speed, targetTemp, currentTemp)
 	return false, nil
 }
 
-func (s *Scheduler) monitorServiceStatus() {
+// preempt 将服务对象移出服务队列并放入等待队列
+// reason: 被移出的原因，Preempted 或 TimeSliceExpired
+func (s *Scheduler) preempt(victim *ServiceObject, reason events.Type) {
+	s.publish(reason, victim)
+	s.setRunState(victim.RoomID, types.RunWaiting, reason, victim)
+	s.addToWaitQueue(victim.RoomID, victim.Speed, victim.TargetTemp, victim.CurrentTemp)
+	delete(s.serviceQueue, victim.RoomID)
+	s.dirty = true
+}
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
+// promoteWaiting 服务队列有空余容量时，按调度策略从等待队列中提升请求
+// 策略选中的请求容纳不下时停止提升，即使其他负载更小的请求容纳得下；限负荷暂停准入时不提升
+func (s *Scheduler) promoteWaiting() {
+	for s.waitQueue.Len() > 0 && !s.admissionSuspended() {
+		view := s.queueView()
+		wait := s.policy.SelectNext(view)
+		if wait == nil || !view.Fits(wait.Speed) {
+			return
+		}
+		s.removeFromWaitQueue(wait.RoomID)
 
-	for {
-		select {
-		case <-ticker.C:
-			s.mu.Lock()
-			s.updateServiceStatus()
-			s.checkWaitQueue()
-			s.mu.Unlock()
-		case <-s.stopChan:
+		if err := s.addToServiceQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp); err != nil {
+			logger.Error("添加新服务失败 - 房间ID: %d, 错误: %v", wait.RoomID, err)
 			return
 		}
+		logger.Info("房间 %d 从等待队列提升至服务队列", wait.RoomID)
+	}
+}
+
+// queueView 构造当前队列的只读视图
+// 服务对象按房间号排序，保证策略决策的结果可复现
+func (s *Scheduler) queueView() QueueView {
+	view := QueueView{
+		Serving:  make([]*ServiceObject, 0, len(s.serviceQueue)),
+		Waiting:  make([]*WaitObject, 0, s.waitQueue.Len()),
+		Capacity: s.capacity,
+	}
+	for _, service := range s.serviceQueue {
+		view.Serving = append(view.Serving, service)
+	}
+	sort.Slice(view.Serving, func(i, j int) bool {
+		return view.Serving[i].RoomID < view.Serving[j].RoomID
+	})
+	for _, item := range *s.waitQueue {
+		view.Waiting = append(view.Waiting, item.waitObj)
 	}
+	sort.Slice(view.Waiting, func(i, j int) bool {
+		return view.Waiting[i].RoomID < view.Waiting[j].RoomID
+	})
+	return view
 }
 
+// updateServiceStatus 更新服务队列中房间的温度和湿度
+// 达到目标温度(制冷时不高于目标、制热时不低于目标)或除湿达到目标湿度的房间结束服务
 func (s *Scheduler) updateServiceStatus() {
-	tempChangeRates := map[types.Speed]float32{
-		types.SpeedHigh:   0.1,    // 1度/10秒
-		types.SpeedMedium: 0.05,   // 1度/20秒
-		types.SpeedLow:    0.0333, // 1度/30秒
+	if len(s.serviceQueue) == 0 {
+		return
+	}
+	rooms, err := s.roomRepo.GetRoomsByZone(s.zone)
+	if err != nil {
+		logger.Error("获取房间列表失败: %v", err)
+		return
 	}
+	roomByID := make(map[int]*db.RoomInfo, len(rooms))
+	for i := range rooms {
+		roomByID[rooms[i].RoomID] = &rooms[i]
+	}
+
 	for roomID, service := range s.serviceQueue {
-		service.Duration = float32(time.Since(service.StartTime).Seconds())
+		service.Duration = float32(s.clock.Since(service.StartTime).Seconds())
+		if service.Guaranteed && service.Duration >= float32(s.timeSlice.Seconds()) {
+			service.Guaranteed = false
+		}
 
-		// 计算温度变化
-		tempDiff := service.TargetTemp - service.CurrentTemp
+		room, ok := roomByID[roomID]
+		if !ok {
+			continue
+		}
 
-		if math.Abs(float64(tempDiff)) < 0.05 {
-			// 温度达到目标
-			if err := s.roomRepo.UpdateTemperature(roomID, service.TargetTemp); err != nil {
+		if s.serviceDone(room, service) {
+			// 温度达到目标，与目标的差距在阈值内时取目标温度
+			finalTemp := service.CurrentTemp
+			if math.Abs(float64(service.TargetTemp-service.CurrentTemp)) < 0.05 {
+				finalTemp = service.TargetTemp
+			}
+			if err := s.roomRepo.UpdateTemperature(roomID, finalTemp); err != nil {
 				logger.Error("更新房间温度失败: %v", err)
 			}
 
 			// 更新缓存
-			s.roomTemp[roomID] = service.TargetTemp
+			s.roomTemp[roomID] = finalTemp
 
 			// 从服务队列移除并处理下一个请求
-			if s.billingService != nil {
-				if err := s.billingService.CreateDetail(roomID, service, db.DetailTypeServiceInterrupt); err != nil {
-					logger.Error("创建服务中断详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-				}
-			}
+			s.publish(events.TargetReached, service)
 			delete(s.serviceQueue, roomID)
-			s.currentService--
+			s.dirty = true
+			s.setRunState(roomID, types.RunStandby, events.TargetReached, service)
 			//如果等待队列不为空，处理下一个请求
-			if s.waitQueue.Len() > 0 {
-				item := heap.Pop(s.waitQueue).(*PriorityItem)
-				wait := item.waitObj
-				delete(s.waitQueueIndex, wait.RoomID)
-
-				if err := s.addToServiceQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp); err != nil {
-					logger.Error("添加新服务失败: %v", err)
-				}
-			}
+			s.promoteWaiting()
 		} else {
-			// 温度未达目标继续调节
-			// 根据风速获取温度变化率
-			tempChangeRate := tempChangeRates[service.Speed]
-
-			// 根据目标温度和当前温度的差值确定变化方向
-			var tempChange float32
-			if tempDiff > 0 {
-				tempChange = tempChangeRate // 需要升温
-			} else {
-				tempChange = -tempChangeRate // 需要降温
-			}
-			service.CurrentTemp += tempChange
+			// 温度未达目标继续调节，由热模型计算本周期的温度
+			state := s.thermalRoom(room, service.CurrentTemp)
+			state.TargetTemp = service.TargetTemp
+			state.Speed = service.Speed
+			state.Serving = true
+			service.CurrentTemp = s.thermal.Step(state, tickInterval)
+			s.updateHumidity(room, service.Speed, true)
 
 			// 更新房间温度和缓存
 			if err := s.roomRepo.UpdateTemperature(roomID, service.CurrentTemp); err != nil {
@@ -367,54 +805,101 @@ func (s *Scheduler) updateServiceStatus() {
 }
 
 // checkWaitQueue 检查等待队列中的请求
-// 处理等待超时的请求，实现时间片轮转调度
+// 累计等待时间并老化优先级，处理等待超时的请求，实现时间片轮转调度。
+// 等待时间超过上限的请求即使调度策略不选择轮换对象，也会替换一个服务对象并获得保证时间片；
+// 限负荷暂停准入时只累计等待时间，不轮转
 func (s *Scheduler) checkWaitQueue() {
 	if s.waitQueue.Len() == 0 {
 		return
 	}
-	// 遍历等待队列中的所有请求
+	// 轮转过程中会修改等待队列，先复制一份再遍历
+	waiting := make([]*WaitObject, 0, s.waitQueue.Len())
 	for _, item := range *s.waitQueue {
-		item.waitObj.WaitDuration -= 1 // 递减等待时间
+		waiting = append(waiting, item.waitObj)
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].RoomID < waiting[j].RoomID
+	})
+
+	for _, wait := range waiting {
+		item, exists := s.waitQueueIndex[wait.RoomID]
+		if !exists {
+			continue
+		}
+		wait.WaitDuration -= float32(tickInterval.Seconds()) // 递减等待时间
+		wait.Waited += float32(tickInterval.Seconds())
+		s.updatePriority(item)
+		if s.admissionSuspended() {
+			continue
+		}
+
+		// 预调温的请求不因等待超时获得保证时间片
+		starved := !wait.Precondition && wait.Waited >= float32(s.maxWait.Seconds())
 		// 当等待时间到期时进行处理
-		if item.waitObj.WaitDuration <= 0 {
-			// 查找服务时间最长的相同风速级别的服务
-			var longestServiceRoom int
-			var maxDuration float32 = 0
-
-			for sRoomID, service := range s.serviceQueue {
-				if service.Speed == item.waitObj.Speed && service.Duration > maxDuration {
-					longestServiceRoom = sRoomID
-					maxDuration = service.Duration
-				}
+		if wait.WaitDuration > 0 && !starved {
+			continue
+		}
+
+		// 由调度策略选择被轮换出的服务对象，超过最长等待时间时由调度器兜底选择
+		var victims []*ServiceObject
+		if wait.WaitDuration <= 0 {
+			victims = s.rotationVictims(wait, func(view QueueView) *ServiceObject {
+				return s.policy.OnTimeSliceExpired(wait, view)
+			})
+		}
+		if victims == nil && starved {
+			victims = s.rotationVictims(wait, func(view QueueView) *ServiceObject {
+				return lowestLongestServing(preemptible(view.Serving))
+			})
+		}
+		if victims == nil {
+			if wait.WaitDuration <= 0 {
+				wait.WaitDuration = s.calculateWaitDuration()
 			}
+			continue
+		}
+
+		s.removeFromWaitQueue(wait.RoomID)
+		for _, victim := range victims {
+			s.preempt(victim, events.TimeSliceExpired)
+		}
 
-			if longestServiceRoom != 0 {
-				victim := s.serviceQueue[longestServiceRoom]
-
-				s.addToWaitQueue(victim.RoomID, victim.Speed, victim.TargetTemp, victim.CurrentTemp)
-				if s.billingService != nil {
-					if err := s.billingService.CreateDetail(longestServiceRoom, victim, db.DetailTypeServiceInterrupt); err != nil {
-						logger.Error("创建服务中断详单失败 - 房间ID: %d, 错误: %v", longestServiceRoom, err)
-					}
-				}
-				delete(s.serviceQueue, longestServiceRoom)
-				s.currentService--
-
-				if err := s.addToServiceQueue(item.waitObj.RoomID, item.waitObj.Speed,
-					item.waitObj.TargetTemp, item.waitObj.CurrentTemp); err != nil {
-					logger.Error("添加轮转服务失败: %v", err)
-					// 重置等待时间
-					item.waitObj.WaitDuration = s.calculateWaitDuration()
-					continue
-				}
-
-				delete(s.waitQueueIndex, item.waitObj.RoomID)
-				heap.Remove(s.waitQueue, item.indexHeap)
-			} else {
-				item.waitObj.WaitDuration = s.calculateWaitDuration()
+		if err := s.addToServiceQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp); err != nil {
+			logger.Error("添加轮转服务失败: %v", err)
+			// 重新排队并重置等待时间
+			s.addToWaitQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp)
+			continue
+		}
+		if starved {
+			s.serviceQueue[wait.RoomID].Guaranteed = true
+			logger.Info("房间 %d 等待 %.0f 秒超过上限，获得保证时间片", wait.RoomID, wait.Waited)
+		}
+	}
+}
+
+// rotationVictims 为时间片到期的等待对象选择被轮换出的服务对象
+// 至少轮换出一个对象，容量仍不足时继续选择，直到能容纳该等待对象；
+// 无法选出或无法腾出足够容量时返回nil。预调温的等待对象只能轮换出其他预调温的房间
+func (s *Scheduler) rotationVictims(wait *WaitObject, selectOne func(QueueView) *ServiceObject) []*ServiceObject {
+	if wait.Precondition {
+		selectAny := selectOne
+		selectOne = func(view QueueView) *ServiceObject {
+			if victim := selectAny(view); victim != nil && victim.Precondition {
+				return victim
 			}
+			return nil
 		}
 	}
+	view := s.queueView()
+	first := selectOne(view)
+	if first == nil {
+		return nil
+	}
+	rest, ok := selectVictims(view.without(first), wait.Speed, selectOne)
+	if !ok {
+		return nil
+	}
+	return append([]*ServiceObject{first}, rest...)
 }
 
 // addToServiceQueue 将请求添加到服务队列
@@ -434,26 +919,27 @@ func (s *Scheduler) addToServiceQueue(roomID int, speed types.Speed, targetTemp,
 		return fmt.Errorf("获取房间信息失败: %v", err)
 	}
 
+	class, precondition := requestClass(room)
 	serviceObj := &ServiceObject{
-		RoomID:      roomID,
-		StartTime:   time.Now(),       // 当前服务的开始时间
-		PowerOnTime: room.CheckinTime, // 保存开机时间
-		Speed:       speed,
-		Duration:    0,
-		TargetTemp:  targetTemp,
-		CurrentTemp: currentTemp,
-		IsCompleted: false,
+		RoomID:       roomID,
+		StartTime:    s.clock.Now(),    // 当前服务的开始时间
+		PowerOnTime:  room.CheckinTime, // 保存开机时间
+		Speed:        speed,
+		Duration:     0,
+		TargetTemp:   targetTemp,
+		CurrentTemp:  currentTemp,
+		IsCompleted:  false,
+		Mode:         s.mode,
+		ModeRate:     s.modeRate,
+		Class:        class,
+		Priority:     s.requestPriority(speed, class, precondition),
+		Precondition: precondition,
 	}
 
 	s.serviceQueue[roomID] = serviceObj
-	s.currentService++
-	// 创建服务开始详单
-	if s.billingService != nil {
-		if err := s.billingService.CreateDetail(roomID, serviceObj, db.DetailTypeServiceStart); err != nil {
-			logger.Error("创建服务开始详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-			// 不要因为详单创建失败而影响正常服务
-		}
-	}
+	s.dirty = true
+	s.publish(events.ServiceStarted, serviceObj)
+	s.setRunState(roomID, types.RunServing, events.ServiceStarted, serviceObj)
 
 	return nil
 }
@@ -466,163 +952,154 @@ func (s *Scheduler) addToServiceQueue(roomID int, speed types.Speed, targetTemp,
 func (s *Scheduler) addToWaitQueue(roomID int, speed types.Speed, targetTemp, currentTemp float32) {
 	waitObj := &WaitObject{
 		RoomID:       roomID,
-		RequestTime:  time.Now(),
+		RequestTime:  s.clock.Now(),
 		Speed:        speed,
 		WaitDuration: s.calculateWaitDuration(),
 		TargetTemp:   targetTemp,
 		CurrentTemp:  currentTemp,
+		Class:        types.ClassStandard,
+	}
+	if room, err := s.roomRepo.GetRoomByID(roomID); err == nil {
+		waitObj.Class, waitObj.Precondition = requestClass(room)
 	}
 
+	waitObj.BasePriority = s.requestPriority(speed, waitObj.Class, waitObj.Precondition)
+	waitObj.Priority = waitObj.BasePriority
 	item := &PriorityItem{
 		roomID:   roomID,
-		priority: speedPriority[speed],
+		priority: waitObj.Priority,
 		waitObj:  waitObj,
 	}
 
 	heap.Push(s.waitQueue, item)
 	s.waitQueueIndex[roomID] = item
+	s.dirty = true
+	s.bus.Publish(events.Event{
+		Type:        events.Waiting,
+		RoomID:      roomID,
+		Speed:       speed,
+		TargetTemp:  targetTemp,
+		CurrentTemp: currentTemp,
+		StartTime:   waitObj.RequestTime,
+		Time:        waitObj.RequestTime,
+	})
+	s.setRunState(roomID, types.RunWaiting, events.Waiting, waitService(waitObj))
+}
+
+// waitService 构造描述等待请求的服务对象，用于记录等待中房间的状态变化
+func waitService(wait *WaitObject) *ServiceObject {
+	return &ServiceObject{
+		RoomID:      wait.RoomID,
+		StartTime:   wait.RequestTime,
+		Speed:       wait.Speed,
+		TargetTemp:  wait.TargetTemp,
+		CurrentTemp: wait.CurrentTemp,
+	}
+}
+
+// removeFromWaitQueue 将房间从等待队列中移除
+func (s *Scheduler) removeFromWaitQueue(roomID int) {
+	if item, exists := s.waitQueueIndex[roomID]; exists {
+		heap.Remove(s.waitQueue, item.indexHeap)
+		delete(s.waitQueueIndex, roomID)
+		s.dirty = true
+	}
 }
 
 // calculateWaitDuration 计算新请求的等待时间
 // 根据当前等待队列长度动态调整等待时间
 // 返回值: 计算得到的等待时间(秒)
 func (s *Scheduler) calculateWaitDuration() float32 {
-	baseDuration := float32(WaitTime)
+	baseDuration := float32(s.timeSlice.Seconds())
 	queueLength := s.waitQueue.Len()
 
 	if queueLength > 0 {
-		return baseDuration * (1 + float32(queueLength)*0.5)
+		return baseDuration * (1 + float32(queueLength)*s.waitGrowthFactor)
 	}
 	return baseDuration
 }
 
-// findLowPriorityServices 查找优先级较低的服务
-// requestPriority: 新请求的优先级
-// 返回值: 优先级低于请求的服务对象列表
-func (s *Scheduler) findLowPriorityServices(requestPriority int) []*ServiceObject {
-	services := make([]*ServiceObject, 0)
-	for _, service := range s.serviceQueue {
-		if speedPriority[service.Speed] < requestPriority {
-			services = append(services, service)
-		}
-	}
-	return services
-}
-
-// selectVictim 在候选服务中选择被抢占的对象
-// candidates: 候选服务列表
-// 返回值: 被选中要抢占的服务对象
-func (s *Scheduler) selectVictim(candidates []*ServiceObject) *ServiceObject {
-	if len(candidates) == 0 {
-		return nil
-	}
-
-	var victim *ServiceObject = candidates[0]
-	var minPriority = speedPriority[victim.Speed]
-	var maxDuration float32 = victim.Duration
-
-	for _, service := range candidates {
-		priority := speedPriority[service.Speed]
-		if priority < minPriority ||
-			(priority == minPriority && service.Duration > maxDuration) {
-			victim = service
-			minPriority = priority
-			maxDuration = service.Duration
-		}
-	}
-	return victim
-}
-
 func (s *Scheduler) shouldReschedule(roomID int, newSpeed types.Speed) bool {
 	item := s.waitQueueIndex[roomID]
-	oldPriority := speedPriority[item.waitObj.Speed]
-	newPriority := speedPriority[newSpeed]
-	return newPriority > oldPriority
+	wait := item.waitObj
+	return s.requestPriority(newSpeed, wait.Class, wait.Precondition) > wait.BasePriority
 }
 
-func (s *Scheduler) GetServiceQueue() map[int]*ServiceObject {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.serviceQueue
-}
-
-func (s *Scheduler) GetWaitQueue() []*WaitObject {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	result := make([]*WaitObject, 0, s.waitQueue.Len())
-	for _, item := range *s.waitQueue {
-		result = append(result, item.waitObj)
-	}
-	return result
-}
-
-// RemoveRoom 从调度器中移除指定房间的所有请求
+// RemoveRoom 从调度器中移除指定房间的所有请求，房间转为关机
+// 用于关机和退房，需在房间记录关机之前调用
 func (s *Scheduler) RemoveRoom(roomID int) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.exec(cmdRemove, func() { s.removeRoom(roomID) })
+}
 
+// removeRoom 在命令循环中移除房间的所有请求
+func (s *Scheduler) removeRoom(roomID int) {
 	// 从服务队列中移除
 	if service, exists := s.serviceQueue[roomID]; exists {
-		if s.billingService != nil {
-			if err := s.billingService.CreateDetail(roomID, service, db.DetailTypeServiceInterrupt); err != nil {
-				logger.Error("创建服务中断详单失败 - 房间ID: %d, 错误: %v", roomID, err)
-			}
-		}
+		s.publish(events.ServiceStopped, service)
 		delete(s.serviceQueue, roomID)
-		s.currentService--
+		s.dirty = true
+		s.setRunState(roomID, types.RunOff, events.ServiceStopped, service)
 		logger.Info("房间 %d 从服务队列中移除", roomID)
 	}
 
 	// 从等待队列中移除
 	if item, exists := s.waitQueueIndex[roomID]; exists {
-		heap.Remove(s.waitQueue, item.indexHeap)
-		delete(s.waitQueueIndex, roomID)
+		s.removeFromWaitQueue(roomID)
+		s.setRunState(roomID, types.RunOff, events.PoweredOff, waitService(item.waitObj))
 		logger.Info("房间 %d 从等待队列中移除", roomID)
 	}
 
+	// 待机中的房间直接关机
+	s.setRunState(roomID, types.RunOff, events.PoweredOff, nil)
+	delete(s.requested, roomID)
+	delete(s.held, roomID)
+
 	// 尝试从等待队列中选择下一个请求
-	if s.currentService < MaxServices && s.waitQueue.Len() > 0 {
-		item := heap.Pop(s.waitQueue).(*PriorityItem)
-		wait := item.waitObj
-		delete(s.waitQueueIndex, wait.RoomID)
+	s.promoteWaiting()
+}
 
-		if err := s.addToServiceQueue(wait.RoomID, wait.Speed, wait.TargetTemp, wait.CurrentTemp); err != nil {
-			logger.Error("添加新服务失败 - 房间ID: %d, 错误: %v", wait.RoomID, err)
-		} else {
-			logger.Info("房间 %d 从等待队列提升至服务队列", wait.RoomID)
-		}
+// parseSpeed 解析房间记录的风速，兼容中英文写法，无法识别时使用中速
+func parseSpeed(speed string) types.Speed {
+	switch speed {
+	case "低", string(types.SpeedLow):
+		return types.SpeedLow
+	case "高", string(types.SpeedHigh):
+		return types.SpeedHigh
+	default:
+		return types.SpeedMedium
 	}
 }
 
 // SetLogging 设置是否启用日志
 func (s *Scheduler) SetLogging(enable bool) {
-	s.mu.Lock()
-	s.enableLogging = enable
-	s.mu.Unlock()
+	s.exec(cmdReconfigure, func() { s.enableLogging = enable })
 }
 
-// Stop 停止调度器
+// Stop 停止调度器，停止后的命令不再执行
 func (s *Scheduler) Stop() {
-	if s.tempTicker != nil {
-		s.tempTicker.Stop()
+	for _, stop := range s.stopTicks {
+		stop()
+	}
+	select {
+	case <-s.stopped:
+	default:
+		close(s.quit)
+		<-s.stopped
 	}
-	close(s.stopChan)
 }
 
 // handleTemperatureRecovery 处理房间温度回温
-// 当空调未在服务时，房间温度会逐渐恢复到初始温度
+// 当空调未在服务时，房间温度由热模型按环境温度自然变化；
+// 待机的房间需要送风时在同一个命令中申请服务，检查与申请之间队列不会变化
 func (s *Scheduler) handleTemperatureRecovery() {
-	// 1. 获取当前在服务队列中的房间列表
-	s.mu.RLock()
+	// 1. 获取本周期开始时在服务队列中的房间列表
 	serviceRooms := make(map[int]struct{})
 	for roomID := range s.serviceQueue {
 		serviceRooms[roomID] = struct{}{}
 	}
-	s.mu.RUnlock()
 
-	// 2. 获取所有房间信息
-	rooms, err := s.roomRepo.GetAllRooms()
+	// 2. 获取机组内所有房间信息
+	rooms, err := s.roomRepo.GetRoomsByZone(s.zone)
 	if err != nil {
 		logger.Error("获取房间列表失败: %v", err)
 		return
@@ -634,70 +1111,35 @@ func (s *Scheduler) handleTemperatureRecovery() {
 			continue
 		}
 
-		s.mu.Lock()
-
-		// 4. 计算房间温度与初始温度的差值
+		// 4. 由热模型计算不送风时的温度变化
 		currentTemp := room.CurrentTemp
-		initialTemp := room.InitialTemp
-		tempDiff := currentTemp - initialTemp // 正值表示高于初始温度，需要降温；负值表示低于初始温度，需要回暖
-
-		// 6. 按照回温速率调整温度
-		var newTemp float32
-		if tempDiff > 0 { // 当前温度高于初始温度，需要降温
-			newTemp = currentTemp - s.tempRecoveryRate
-			if newTemp < initialTemp {
-				newTemp = initialTemp
-			}
-		} else { // 当前温度低于初始温度，需要回暖
-			newTemp = currentTemp + s.tempRecoveryRate
-			if newTemp > initialTemp {
-				newTemp = initialTemp
-			}
-		}
+		newTemp := s.thermal.Step(s.thermalRoom(&room, currentTemp), tickInterval)
+		s.updateHumidity(&room, parseSpeed(room.CurrentSpeed), false)
+		needsService := RoomRunState(&room) == types.RunStandby && s.needsService(&room, currentTemp)
 
-		// 7. 更新房间温度
+		// 5. 更新房间温度
 		if err := s.roomRepo.UpdateTemperature(room.RoomID, newTemp); err != nil {
 			logger.Error("更新房间温度失败 - 房间ID: %d, 错误: %v", room.RoomID, err)
-			s.mu.Unlock()
 			continue
 		}
 
-		s.mu.Unlock()
-
-		// 8. 如果房间开着空调且温差>=1度，尝试申请服务
-		if room.ACState == 1 && math.Abs(float64(currentTemp-room.TargetTemp)) >= 1.0 {
-			s.mu.RLock()
-			// 确认不在等待队列中才尝试申请服务
-			if _, waiting := s.waitQueueIndex[room.RoomID]; !waiting {
-				s.mu.RUnlock()
-
-				// 获取当前风速或使用默认中速
-				speed := types.SpeedMedium
-				if room.CurrentSpeed != "" {
-					switch room.CurrentSpeed {
-					case "低":
-						speed = types.SpeedLow
-					case "中":
-						speed = types.SpeedMedium
-					case "高":
-						speed = types.SpeedHigh
-					default:
-						speed = types.SpeedMedium
-					}
-
-				}
-
-				// 尝试申请服务
-				if _, err := s.HandleRequest(room.RoomID, speed, room.TargetTemp, newTemp); err != nil {
-					logger.Error("房间 %d 自动请求服务失败: %v", room.RoomID, err)
-				} else {
-					logger.Info("房间 %d 空调开启且温差超过1度，自动请求服务 (当前: %.1f°C, 目标: %.1f°C)",
-						room.RoomID, newTemp, room.TargetTemp)
-				}
-			} else {
-				s.mu.RUnlock()
-			}
+		// 6. 如果房间处于待机且需要送风(温度偏离目标超过回差、除湿时湿度高于目标>=5%或处于送风模式)，
+		// 且不在等待队列中，申请服务
+		if !needsService {
+			continue
+		}
+		if _, waiting := s.waitQueueIndex[room.RoomID]; waiting {
+			continue
+		}
+		speed := parseSpeed(room.CurrentSpeed)
+		if requested, ok := s.requested[room.RoomID]; ok {
+			speed = requested
+		}
+		if _, err := s.handleRequest(room.RoomID, speed, room.TargetTemp, newTemp); err != nil {
+			logger.Error("房间 %d 自动请求服务失败: %v", room.RoomID, err)
+		} else {
+			logger.Info("房间 %d 待机中需要送风，自动请求服务 (当前: %.1f°C, 目标: %.1f°C)",
+				room.RoomID, newTemp, room.TargetTemp)
 		}
-
 	}
 }
@@ -0,0 +1,83 @@
+// internal/service/scheduler_loop.go
+package service
+
+import (
+	"backend/internal/logger"
+	"errors"
+	"time"
+)
+
+// commandKind 调度器命令的类型
+type commandKind string
+
+const (
+	cmdRequest     commandKind = "request"     // 开机、调温、调风等房间请求
+	cmdRemove      commandKind = "remove"      // 关机或退房，将房间移出调度器
+	cmdClear       commandKind = "clear"       // 清空队列
+	cmdTick        commandKind = "tick"        // 周期性的温度更新、时间片轮转和回温检查
+	cmdSnapshot    commandKind = "snapshot"    // 读取队列快照或配置
+	cmdReconfigure commandKind = "reconfigure" // 修改调度策略、容量、时间片等参数
+	cmdRestore     commandKind = "restore"     // 按重启前的快照恢复队列
+	cmdEstimate    commandKind = "estimate"    // 按热模型估算预调温所需的时间
+)
+
+// errSchedulerStopped 调度器已停止，不再处理命令
+var errSchedulerStopped = errors.New("调度器已停止")
+
+// command 调度器命令
+// run 在调度器的命令循环中执行，执行完毕后关闭 reply 通知发送方，
+// 命令的结果由 run 写入发送方的局部变量
+type command struct {
+	kind  commandKind
+	run   func()
+	reply chan struct{}
+}
+
+// loop 调度器的命令循环
+// 队列、缓存和调度参数只由该goroutine读写，命令按到达顺序逐个执行，不需要加锁。
+// 每个命令执行后按需保存队列快照
+func (s *Scheduler) loop() {
+	defer close(s.stopped)
+	for {
+		select {
+		case cmd := <-s.commands:
+			start := time.Now()
+			cmd.run()
+			s.persistState()
+			close(cmd.reply)
+			if elapsed := time.Since(start); elapsed > tickInterval {
+				logger.Warn("机组 %s 的调度器命令 %s 耗时 %v，超过调度周期", s.zone, cmd.kind, elapsed)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// exec 将命令发送到命令循环并等待执行完毕
+// 命令循环中的代码不能调用 exec，否则会互相等待
+// 返回值: 命令是否已执行，调度器已停止时为false
+func (s *Scheduler) exec(kind commandKind, run func()) bool {
+	cmd := command{kind: kind, run: run, reply: make(chan struct{})}
+	select {
+	case s.commands <- cmd:
+	case <-s.stopped:
+		return false
+	}
+	<-cmd.reply
+	return true
+}
+
+// tick 调度周期：更新服务中房间的温度，检查等待队列的时间片，再处理不在服务的房间的回温
+func (s *Scheduler) tick() {
+	s.updateServiceStatus()
+	s.checkWaitQueue()
+	s.handleTemperatureRecovery()
+}
+
+// startTicks 按调度周期向命令循环发送 tick 命令
+func (s *Scheduler) startTicks(interval time.Duration) {
+	s.stopTicks = append(s.stopTicks, s.clock.Every(interval, func() {
+		s.exec(cmdTick, s.tick)
+	}))
+}
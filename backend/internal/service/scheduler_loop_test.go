@@ -0,0 +1,182 @@
+// internal/service/scheduler_loop_test.go
+package service
+
+import (
+	"backend/internal/clock"
+	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/types"
+	"testing"
+	"time"
+)
+
+// newLoopScheduler 创建默认机组的独立调度器，使用自己的模拟时钟和事件总线，
+// 时钟只由测试推进，不会驱动服务层的调度器
+func newLoopScheduler(t *testing.T) (*Scheduler, *clock.SimClock) {
+	t.Helper()
+	resetHotel(t, nil)
+	clk := clock.NewSimClock(testClock.Now(), clock.DefaultTimeScale)
+	bus := events.NewBus()
+	s := NewScheduler(clk, bus, db.DefaultZone)
+	t.Cleanup(func() {
+		s.Stop()
+		bus.Close()
+	})
+	return s, clk
+}
+
+// execRequest 通过命令循环提交房间请求
+func execRequest(t *testing.T, s *Scheduler, roomID int, speed types.Speed) bool {
+	t.Helper()
+	var serving bool
+	var err error
+	if !s.exec(cmdRequest, func() {
+		serving, err = s.handleRequest(roomID, speed, 18, initialTemp(roomID))
+	}) {
+		t.Fatalf("房间 %d 的请求未执行", roomID)
+	}
+	if err != nil {
+		t.Fatalf("房间 %d 请求失败: %v", roomID, err)
+	}
+	return serving
+}
+
+// expectSnapshot 检查快照中服务队列和等待队列的房间
+func expectSnapshot(t *testing.T, when string, snapshot SchedulerSnapshot, serving, waiting []int) {
+	t.Helper()
+	var gotServing, gotWaiting []int
+	for _, service := range snapshot.Serving {
+		gotServing = append(gotServing, service.RoomID)
+	}
+	for _, wait := range snapshot.Waiting {
+		gotWaiting = append(gotWaiting, wait.RoomID)
+	}
+	if !equalRooms(gotServing, serving) || !equalRooms(gotWaiting, waiting) {
+		t.Errorf("%s: 服务队列 %v、等待队列 %v，期望 %v 和 %v", when, gotServing, gotWaiting, serving, waiting)
+	}
+}
+
+func equalRooms(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCommandLoop(t *testing.T) {
+	s, clk := newLoopScheduler(t)
+
+	for _, roomID := range []int{1, 2, 3} {
+		if !execRequest(t, s, roomID, types.SpeedMedium) {
+			t.Fatalf("房间 %d 未直接进入服务队列", roomID)
+		}
+	}
+	if execRequest(t, s, 4, types.SpeedMedium) {
+		t.Fatalf("服务队列已满时房间 4 进入了服务队列")
+	}
+	expectSnapshot(t, "请求后", s.Snapshot(), []int{1, 2, 3}, []int{4})
+
+	// tick 命令更新服务时长
+	clk.Advance(10 * tickInterval)
+	snapshot := s.Snapshot()
+	for _, service := range snapshot.Serving {
+		if want := float32((10 * tickInterval).Seconds()); service.Duration != want {
+			t.Errorf("房间 %d 的服务时长为 %.0f 秒，期望 %.0f 秒", service.RoomID, service.Duration, want)
+		}
+	}
+
+	if !s.exec(cmdRemove, func() { s.removeRoom(2) }) {
+		t.Fatal("移除命令未执行")
+	}
+	expectSnapshot(t, "移除房间 2 后", s.Snapshot(), []int{1, 3, 4}, nil)
+
+	if !s.exec(cmdClear, s.clearAllQueues) {
+		t.Fatal("清空命令未执行")
+	}
+	expectSnapshot(t, "清空后", s.Snapshot(), nil, nil)
+}
+
+func TestExecAfterStop(t *testing.T) {
+	s, clk := newLoopScheduler(t)
+	s.Stop()
+
+	ran := false
+	if s.exec(cmdSnapshot, func() { ran = true }) || ran {
+		t.Error("调度器停止后命令仍被执行")
+	}
+	if err := s.SetPolicy("fcfs"); err != errSchedulerStopped {
+		t.Errorf("调度器停止后切换策略返回 %v，期望 %v", err, errSchedulerStopped)
+	}
+	// 停止后时钟到期不再发送 tick，推进时钟不会阻塞
+	clk.Advance(10 * tickInterval)
+}
+
+// TestReconfigureDoesNotInterleaveWithTick 切换策略的命令与时钟驱动的 tick 并发提交，
+// 命令执行期间队列不会被 tick 修改，所有 tick 都在某个命令之前或之后完整执行
+func TestReconfigureDoesNotInterleaveWithTick(t *testing.T) {
+	s, clk := newLoopScheduler(t)
+	for _, roomID := range []int{1, 2, 3} {
+		execRequest(t, s, roomID, types.SpeedMedium)
+	}
+
+	const ticks = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < ticks; i++ {
+			clk.Advance(tickInterval)
+		}
+	}()
+
+	names := SchedulingPolicyNames()
+	interleaved := 0
+	reconfigured := 0
+	for running := true; running; reconfigured++ {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		policy, err := NewSchedulingPolicy(names[reconfigured%len(names)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.exec(cmdReconfigure, func() {
+			durations := make(map[int]float32, len(s.serviceQueue))
+			for roomID, service := range s.serviceQueue {
+				durations[roomID] = service.Duration
+			}
+			s.policy = policy
+			time.Sleep(50 * time.Microsecond)
+			for roomID, service := range s.serviceQueue {
+				if service.Duration != durations[roomID] {
+					interleaved++
+				}
+			}
+			if s.policy != policy {
+				interleaved++
+			}
+		})
+		if err := s.SetPolicy(names[(reconfigured+1)%len(names)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if interleaved != 0 {
+		t.Errorf("切换策略的命令执行期间有 %d 次被 tick 修改", interleaved)
+	}
+	// 每个 tick 都执行了，服务时长与时钟推进的时间一致
+	snapshot := s.Snapshot()
+	expectSnapshot(t, "tick 后", snapshot, []int{1, 2, 3}, nil)
+	for _, service := range snapshot.Serving {
+		if want := float32((ticks * tickInterval).Seconds()); service.Duration != want {
+			t.Errorf("房间 %d 的服务时长为 %.0f 秒，期望 %.0f 秒", service.RoomID, service.Duration, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// internal/service/scheduler_test.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/types"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPreemption(t *testing.T) {
+	tests := []struct {
+		name     string
+		speed    types.Speed     // 第4个房间请求的风速
+		class    types.RoomClass // 第4个房间的等级
+		preempts bool
+	}{
+		{"高风速抢占中风速", types.SpeedHigh, types.ClassStandard, true},
+		{"同风速不抢占", types.SpeedMedium, types.ClassStandard, false},
+		{"VIP低风速抢占标准间中风速", types.SpeedLow, types.ClassVIP, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acService := resetHotel(t, nil)
+			for _, roomID := range []int{1, 2, 3} {
+				checkInAndPowerOn(t, acService, roomID, types.SpeedMedium, 18)
+			}
+			testClock.Advance(10 * time.Second)
+
+			if err := acService.SetRoomClass(4, tt.class); err != nil {
+				t.Fatal(err)
+			}
+			checkInAndPowerOn(t, acService, 4, tt.speed, 18)
+
+			snapshot := queueSnapshot(t)
+			if got := roomQueue(snapshot, 4); (got == "serving") != tt.preempts {
+				t.Fatalf("房间 4 在 %s，期望抢占: %v", got, tt.preempts)
+			}
+			if len(snapshot.Serving) != 3 || len(snapshot.Waiting) != 1 {
+				t.Fatalf("服务队列 %d 个、等待队列 %d 个，期望 3 个和 1 个", len(snapshot.Serving), len(snapshot.Waiting))
+			}
+			if tt.preempts && snapshot.Waiting[0].Speed != types.SpeedMedium {
+				t.Errorf("被抢占的是 %s 风速的房间 %d，期望中风速的房间", snapshot.Waiting[0].Speed, snapshot.Waiting[0].RoomID)
+			}
+		})
+	}
+}
+
+func TestTimeSliceRotation(t *testing.T) {
+	acService := resetHotel(t, func(config *types.Config) {
+		config.WaitGrowthFactor = 0
+	})
+	for _, roomID := range []int{1, 2, 3} {
+		checkInAndPowerOn(t, acService, roomID, types.SpeedMedium, 18)
+	}
+	testClock.Advance(5 * time.Second)
+	checkInAndPowerOn(t, acService, 4, types.SpeedMedium, 18)
+	expectQueues(t, "请求时", map[int]string{4: "waiting"})
+
+	// 时间片为2分钟，到期前一直等待
+	testClock.Advance(110 * time.Second)
+	expectQueues(t, "等待110秒", map[int]string{1: "serving", 2: "serving", 3: "serving", 4: "waiting"})
+
+	// 到期后替换同优先级中服务时间最长的房间
+	testClock.Advance(15 * time.Second)
+	snapshot := queueSnapshot(t)
+	if got := roomQueue(snapshot, 4); got != "serving" {
+		t.Fatalf("时间片到期后房间 4 在 %s，期望 serving", got)
+	}
+	if len(snapshot.Waiting) != 1 {
+		t.Fatalf("等待队列有 %d 个房间，期望 1 个", len(snapshot.Waiting))
+	}
+	rotated := snapshot.Waiting[0].RoomID
+	if rotated == 4 {
+		t.Fatalf("房间 4 仍在等待")
+	}
+
+	// 被轮换出的房间再等待一个时间片后轮换回服务队列
+	testClock.Advance(125 * time.Second)
+	expectQueues(t, "再过一个时间片", map[int]string{rotated: "serving"})
+}
+
+func TestAging(t *testing.T) {
+	tests := []struct {
+		name      string
+		agingRate float32
+		promoted  bool
+	}{
+		{"不老化时低风速一直等待", 0, false},
+		{"老化后替换高风速", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acService := resetHotel(t, func(config *types.Config) {
+				config.AgingRate = tt.agingRate
+				config.WaitGrowthFactor = 0
+				config.MaxWait = time.Hour
+			})
+			for _, roomID := range []int{1, 2, 3} {
+				checkInAndPowerOn(t, acService, roomID, types.SpeedHigh, 16)
+			}
+			checkInAndPowerOn(t, acService, 4, types.SpeedLow, 18)
+			expectQueues(t, "请求时", map[int]string{4: "waiting"})
+
+			// 有效优先级为调度优先级加上每分钟 agingRate 的增量
+			testClock.Advance(time.Minute)
+			snapshot := queueSnapshot(t)
+			wait, ok := snapshot.WaitingRoom(4)
+			if !ok {
+				t.Fatalf("房间 4 不在等待队列")
+			}
+			if want := wait.BasePriority + float64(tt.agingRate); math.Abs(wait.Priority-want) > 0.01 {
+				t.Errorf("等待1分钟后有效优先级为 %.2f，期望 %.2f", wait.Priority, want)
+			}
+
+			// 第一个时间片到期时有效优先级(1+2)未超过高风速(3)，第二个时间片到期时(1+4)超过
+			testClock.Advance(170 * time.Second)
+			expectQueues(t, "等待3分50秒", map[int]string{4: "waiting"})
+			testClock.Advance(20 * time.Second)
+			want := "waiting"
+			if tt.promoted {
+				want = "serving"
+			}
+			expectQueues(t, "等待4分10秒", map[int]string{4: want})
+		})
+	}
+}
+
+func TestHysteresis(t *testing.T) {
+	tests := []struct {
+		name       string
+		hysteresis float32
+		resumed    bool
+	}{
+		{"偏离超过回差时重新送风", 1, true},
+		{"偏离未超过回差时保持待机", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acService := resetHotel(t, func(config *types.Config) {
+				config.Hysteresis = tt.hysteresis
+				config.TempRanges[types.ModeCooling] = types.TempRange{Min: 16, Max: 28}
+			})
+			// 房间 2 初始28°C，高风速每分钟降1°C，约2分钟达到目标后待机
+			checkInAndPowerOn(t, acService, 2, types.SpeedHigh, 26)
+			testClock.Advance(130 * time.Second)
+			expectQueues(t, "达到目标后", map[int]string{2: "-"})
+
+			// 待机时每分钟回温0.5°C，1.5分钟后偏离0.75°C
+			testClock.Advance(90 * time.Second)
+			expectQueues(t, "回温0.75°C", map[int]string{2: "-"})
+
+			// 再过1分钟偏离1.25°C
+			testClock.Advance(time.Minute)
+			want := "-"
+			if tt.resumed {
+				want = "serving"
+			}
+			expectQueues(t, "回温1.25°C", map[int]string{2: want})
+		})
+	}
+}
+
+func TestLoadShedKeepsPreconditionPriority(t *testing.T) {
+	s, _ := newLoopScheduler(t)
+	roomRepo := db.NewRoomRepository()
+	// 房间 5 为预调温中的空房
+	if err := roomRepo.SetPrecondition(5, 1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { roomRepo.SetPrecondition(5, 0) })
+	execRequest(t, s, 5, types.SpeedMedium)
+
+	expectPriority := func(when string, speed types.Speed) {
+		t.Helper()
+		snapshot := s.Snapshot()
+		service, ok := snapshot.ServingRoom(5)
+		if !ok {
+			t.Fatalf("%s: 房间 5 不在服务队列", when)
+		}
+		if service.Speed != speed || service.Priority != preconditionPriority {
+			t.Errorf("%s: 房间 5 风速为 %s、优先级为 %.2f，期望 %s 和 %d", when, service.Speed, service.Priority, speed, preconditionPriority)
+		}
+	}
+	expectPriority("请求时", types.SpeedMedium)
+
+	if err := s.EnterLoadShed(LoadShed{MaxSpeed: types.SpeedLow}); err != nil {
+		t.Fatal(err)
+	}
+	expectPriority("限负荷降低风速后", types.SpeedLow)
+
+	if err := s.ExitLoadShed(); err != nil {
+		t.Fatal(err)
+	}
+	expectPriority("退出限负荷恢复风速后", types.SpeedMedium)
+}
@@ -3,36 +3,66 @@
 package service
 
 import (
+	"backend/internal/clock"
+	"backend/internal/events"
 	"sync"
 	"time"
 )
 
 var (
-	schedulerService *Scheduler
-	monitorService   *MonitorService
-	billingService   *BillingService
-	once             sync.Once
+	systemClock    clock.Clock
+	eventBus       *events.Bus
+	schedulers     *SchedulerRegistry
+	monitorService *MonitorService
+	billingService *BillingService
+	once           sync.Once
 )
 
 // InitServices 初始化所有服务
-func InitServices() {
+// clk: 系统时钟，所有服务共享同一个时钟
+func InitServices(clk clock.Clock) {
 	once.Do(func() {
-		schedulerService = NewScheduler()
-		schedulerService.SetLogging(true) // 关闭scheduler的日志
-		billingService = NewBillingService(schedulerService)
-		schedulerService.SetBillingService(billingService)
-		monitorService = NewMonitorService(schedulerService)
+		systemClock = clk
+		eventBus = events.NewBus()
+		// 每个机组有自己的调度器，创建机组时登记到注册表
+		schedulers = NewSchedulerRegistry()
+		// 计费和监控通过订阅事件获知服务状态的变化
+		billingService = NewBillingService(schedulers, clk)
+		billingService.Subscribe(eventBus)
+		monitorService = NewMonitorService(schedulers, clk)
+		monitorService.Subscribe(eventBus)
+		// 恢复机组列表，以及各机组上次保存的空调配置、中央空调状态和调度队列
+		acService := GetACService()
+		acService.restoreZones()
+		acService.restoreConfig()
+		acService.recoverState()
+		// 房费计算规则全酒店统一
+		acService.restoreRoomChargePolicy()
+		// 入住前预调温的计划按系统时钟定时检查
+		acService.startPreconditionChecks()
+		// 房间的定时关机、定时开机和睡眠曲线同样按系统时钟定时检查
+		acService.startTimerChecks()
 	})
 }
 
 // StartMonitorService 启动监控服务
 func StartMonitorService() {
 	if monitorService != nil {
-		monitorService.StartRoomTempMonitor(10 * time.Second)
-		monitorService.StartQueuesMonitor(10 * time.Second)
+		monitorService.StartRoomTempMonitor(time.Minute)
 	}
 }
 
+// GetClock 获取系统时钟
+func GetClock() clock.Clock {
+	return systemClock
+}
+
+// GetSimClock 获取模拟时钟，系统使用真实时钟时返回nil
+func GetSimClock() *clock.SimClock {
+	simClock, _ := systemClock.(*clock.SimClock)
+	return simClock
+}
+
 // StopMonitorService 停止监控服务
 func StopMonitorService() {
 	if monitorService != nil {
@@ -40,9 +70,14 @@ func StopMonitorService() {
 	}
 }
 
-// GetScheduler 获取调度器实例
-func GetScheduler() *Scheduler {
-	return schedulerService
+// GetEventBus 获取事件总线
+func GetEventBus() *events.Bus {
+	return eventBus
+}
+
+// GetSchedulers 获取各机组的调度器
+func GetSchedulers() *SchedulerRegistry {
+	return schedulers
 }
 
 // GetMonitor 获取监控服务实例
@@ -55,7 +90,15 @@ func StopServices() {
 	if monitorService != nil {
 		monitorService.Stop()
 	}
-	if schedulerService != nil {
-		schedulerService.Stop()
+	if acService != nil {
+		acService.stopPreconditionChecks()
+		acService.stopTimerChecks()
+	}
+	if schedulers != nil {
+		schedulers.StopAll()
+	}
+	// 处理完已发布的事件，保证详单全部写入
+	if eventBus != nil {
+		eventBus.Close()
 	}
 }
@@ -0,0 +1,146 @@
+// internal/service/service_test.go
+package service
+
+import (
+	"backend/internal/clock"
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testRooms 测试数据库中的房间，初始温度见 db.InitRooms
+var testRooms = []int{1, 2, 3, 4, 5}
+
+// testClock 测试共用的模拟时钟，只由测试手动推进
+var testClock *clock.SimClock
+
+// TestMain 与 simulate 子命令一样使用内存数据库和手动推进的模拟时钟初始化服务层
+func TestMain(m *testing.M) {
+	logger.SetLevel(logger.OffLevel)
+	log.SetOutput(io.Discard)
+
+	db.Init_DBWithName("file:service_test?mode=memory&cache=shared")
+	// 计费订阅者异步写详单，共享缓存的内存数据库只使用一个连接让读写排队执行
+	db.SQLDB.SetMaxOpenConns(1)
+	db.DB.Config.Logger = gormlogger.Default.LogMode(gormlogger.Silent)
+
+	testClock = clock.NewSimClock(time.Date(2024, 6, 1, 8, 0, 0, 0, time.Local), clock.DefaultTimeScale)
+	InitServices(testClock)
+
+	code := m.Run()
+	StopServices()
+	db.SQLDB.Close()
+	os.Exit(code)
+}
+
+// resetHotel 将所有房间退房并恢复初始温度和等级，机组按默认配置和configure的修改以制冷模式重新开机
+// 服务层是全局单例，每个测试开始时调用，避免受之前测试的影响
+func resetHotel(t *testing.T, configure func(config *types.Config)) *ACService {
+	t.Helper()
+	acService := GetACService()
+	roomRepo := db.NewRoomRepository()
+	for _, roomID := range testRooms {
+		room, err := roomRepo.GetRoomByID(roomID)
+		if err != nil {
+			t.Fatalf("获取房间 %d 失败: %v", roomID, err)
+		}
+		if room.State == 1 {
+			if _, _, err := acService.CheckOut(roomID); err != nil {
+				t.Fatalf("房间 %d 退房失败: %v", roomID, err)
+			}
+		}
+	}
+	if isOn, _, _ := acService.GetCentralACState(db.DefaultZone); isOn {
+		if err := acService.StopCentralAC(db.DefaultZone); err != nil {
+			t.Fatalf("关闭中央空调失败: %v", err)
+		}
+	}
+	// 与上一个测试的退房时间错开，入住以来的详单和费用不包含上一位住客的记录
+	testClock.Advance(time.Minute)
+
+	config := cloneConfig(DefaultConfig)
+	if configure != nil {
+		configure(&config)
+	}
+	if err := acService.SetConfig(db.DefaultZone, config); err != nil {
+		t.Fatalf("设置空调配置失败: %v", err)
+	}
+	if err := acService.SetSchedulingPolicy(db.DefaultZone, DefaultPolicyName); err != nil {
+		t.Fatalf("设置调度策略失败: %v", err)
+	}
+	for _, roomID := range testRooms {
+		if err := roomRepo.UpdateClass(roomID, string(types.ClassStandard)); err != nil {
+			t.Fatal(err)
+		}
+		if err := roomRepo.ResetTemperature(roomID, initialTemp(roomID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acService.StartCentralAC(db.DefaultZone, types.ModeCooling); err != nil {
+		t.Fatalf("开启中央空调失败: %v", err)
+	}
+	GetBillingService().FlushDetails()
+	return acService
+}
+
+// initialTemp 房间在 db.InitRooms 中的初始温度
+func initialTemp(roomID int) float32 {
+	return map[int]float32{1: 32, 2: 28, 3: 30, 4: 29, 5: 35}[roomID]
+}
+
+// checkInAndPowerOn 房间入住并以指定的风速和目标温度开机
+func checkInAndPowerOn(t *testing.T, acService *ACService, roomID int, speed types.Speed, target float32) {
+	t.Helper()
+	if err := acService.CheckIn(roomID, fmt.Sprintf("T%03d", roomID), "测试住客", types.Fen(0)); err != nil {
+		t.Fatalf("房间 %d 入住失败: %v", roomID, err)
+	}
+	if err := acService.PowerOn(roomID); err != nil {
+		t.Fatalf("房间 %d 开机失败: %v", roomID, err)
+	}
+	if err := acService.SetTemperature(roomID, target); err != nil {
+		t.Fatalf("房间 %d 调温失败: %v", roomID, err)
+	}
+	if err := acService.SetFanSpeed(roomID, speed); err != nil {
+		t.Fatalf("房间 %d 调风失败: %v", roomID, err)
+	}
+}
+
+// queueSnapshot 默认机组调度队列的快照
+func queueSnapshot(t *testing.T) SchedulerSnapshot {
+	t.Helper()
+	snapshot, err := GetACService().GetQueueSnapshot(db.DefaultZone)
+	if err != nil {
+		t.Fatalf("获取调度队列失败: %v", err)
+	}
+	return snapshot
+}
+
+// roomQueue 房间当前所在的队列: serving、waiting 或 -
+func roomQueue(snapshot SchedulerSnapshot, roomID int) string {
+	if _, ok := snapshot.ServingRoom(roomID); ok {
+		return "serving"
+	}
+	if _, ok := snapshot.WaitingRoom(roomID); ok {
+		return "waiting"
+	}
+	return "-"
+}
+
+// expectQueues 检查各房间所在的队列
+func expectQueues(t *testing.T, when string, want map[int]string) {
+	t.Helper()
+	snapshot := queueSnapshot(t)
+	for roomID, queue := range want {
+		if got := roomQueue(snapshot, roomID); got != queue {
+			t.Errorf("%s: 房间 %d 在 %s，期望 %s", when, roomID, got, queue)
+		}
+	}
+}
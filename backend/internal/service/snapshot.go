@@ -0,0 +1,84 @@
+// internal/service/snapshot.go
+package service
+
+import (
+	"sort"
+	"time"
+)
+
+// SchedulerSnapshot 调度器队列的只读快照
+// 在调度器的命令循环中一次性复制服务队列和等待队列，之后与调度器不再共享任何数据，
+// 读取方可以任意遍历。
+type SchedulerSnapshot struct {
+	Zone     string          // 所属机组
+	Version  uint64          // 快照版本号，按获取顺序单调递增
+	TakenAt  time.Time       // 获取快照的时间(系统时间)
+	Policy   string          // 调度策略名称
+	Capacity Capacity        // 服务队列容量
+	Used     float32         // 已占用的容量：count 模式为服务对象数，power 模式为负载之和(kW)
+	Serving  []ServiceObject // 服务队列，按房间号排序
+	Waiting  []WaitObject    // 等待队列，按有效优先级从高到低排序
+	LoadShed *LoadShedState  // 限负荷模式的状态，未处于限负荷模式时为nil
+}
+
+// Snapshot 获取调度器队列的一致快照
+func (s *Scheduler) Snapshot() SchedulerSnapshot {
+	snapshot := SchedulerSnapshot{Zone: s.zone}
+	s.exec(cmdSnapshot, func() { snapshot = s.snapshot() })
+	return snapshot
+}
+
+// snapshot 在命令循环中复制队列
+func (s *Scheduler) snapshot() SchedulerSnapshot {
+	s.snapshotVersion++
+	snapshot := SchedulerSnapshot{
+		Zone: s.zone,
+		// 版本号在命令循环中分配，版本号更大的快照反映的队列状态不会更旧
+		Version:  s.snapshotVersion,
+		TakenAt:  s.clock.Now(),
+		Policy:   s.policy.Name(),
+		Capacity: s.capacity.clone(),
+		Serving:  make([]ServiceObject, 0, len(s.serviceQueue)),
+		Waiting:  make([]WaitObject, 0, s.waitQueue.Len()),
+		LoadShed: s.loadShedState(),
+	}
+	for _, service := range s.serviceQueue {
+		snapshot.Serving = append(snapshot.Serving, *service)
+	}
+	sort.Slice(snapshot.Serving, func(i, j int) bool {
+		return snapshot.Serving[i].RoomID < snapshot.Serving[j].RoomID
+	})
+	for _, service := range snapshot.Serving {
+		snapshot.Used += snapshot.Capacity.load(service.Speed)
+	}
+	for _, item := range *s.waitQueue {
+		snapshot.Waiting = append(snapshot.Waiting, *item.waitObj)
+	}
+	sort.SliceStable(snapshot.Waiting, func(i, j int) bool {
+		if snapshot.Waiting[i].Priority != snapshot.Waiting[j].Priority {
+			return snapshot.Waiting[i].Priority > snapshot.Waiting[j].Priority
+		}
+		return snapshot.Waiting[i].RoomID < snapshot.Waiting[j].RoomID
+	})
+	return snapshot
+}
+
+// ServingRoom 查找房间的服务对象
+func (s *SchedulerSnapshot) ServingRoom(roomID int) (ServiceObject, bool) {
+	for _, service := range s.Serving {
+		if service.RoomID == roomID {
+			return service, true
+		}
+	}
+	return ServiceObject{}, false
+}
+
+// WaitingRoom 查找房间的等待对象
+func (s *SchedulerSnapshot) WaitingRoom(roomID int) (WaitObject, bool) {
+	for _, wait := range s.Waiting {
+		if wait.RoomID == roomID {
+			return wait, true
+		}
+	}
+	return WaitObject{}, false
+}
@@ -2,43 +2,73 @@
 package service
 
 import (
+	"backend/internal/clock"
 	"backend/internal/db"
 	"backend/internal/logger"
+	"backend/internal/types"
+	"sort"
 	"time"
 )
 
 type StatisticRecord struct {
-	Room                   int     `json:"room"`                   // 房间号
-	SwitchCount            int     `json:"switchCount"`            // 开关次数
-	DispatchCount          int     `json:"dispatchCount"`          // 调度次数
-	DetailCount            int     `json:"detailCount"`            // 详单条数
-	TemperatureChangeCount int     `json:"temperatureChangeCount"` // 调温次数
-	FanSpeedChangeCount    int     `json:"fanSpeedChangeCount"`    // 调风次数
-	Duration               float32 `json:"duration"`               // 使用时长(分钟)
-	TotalCost              float32 `json:"totalCost"`              // 总费用
+	Room                   int         `json:"room"`                   // 房间号
+	Zone                   string      `json:"zone"`                   // 所属机组
+	Class                  string      `json:"class"`                  // 统计时参与调度的等级
+	ClassPriority          float32     `json:"classPriority"`          // 该等级在所属机组的优先级加成
+	SwitchCount            int         `json:"switchCount"`            // 开关次数
+	DispatchCount          int         `json:"dispatchCount"`          // 调度次数
+	DetailCount            int         `json:"detailCount"`            // 详单条数
+	TemperatureChangeCount int         `json:"temperatureChangeCount"` // 调温次数
+	FanSpeedChangeCount    int         `json:"fanSpeedChangeCount"`    // 调风次数
+	Duration               float32     `json:"duration"`               // 使用时长(分钟)
+	TotalCost              types.Money `json:"totalCost"`              // 总费用
 }
 
 type StatisticsService struct {
 	detailRepo *db.DetailRepository
 	roomRepo   *db.RoomRepository
+	energyRepo *db.EnergyRepository
+	clock      clock.Clock
 }
 
-func NewStatisticsService() *StatisticsService {
+func NewStatisticsService(clk clock.Clock) *StatisticsService {
 	return &StatisticsService{
 		detailRepo: db.NewDetailRepository(),
 		roomRepo:   db.NewRoomRepository(),
+		energyRepo: db.NewEnergyRepository(),
+		clock:      clk,
 	}
 }
 
+// GetTodayReport 获取系统时钟当天的日报数据
+func (s *StatisticsService) GetTodayReport() ([]StatisticRecord, error) {
+	return s.GetDailyReport(s.clock.Now())
+}
+
+// GetThisWeekReport 获取系统时钟当周的周报数据
+func (s *StatisticsService) GetThisWeekReport() ([]StatisticRecord, error) {
+	return s.GetWeeklyReport(s.clock.Now())
+}
+
 // GetDailyReport 获取日报数据
 func (s *StatisticsService) GetDailyReport(date time.Time) ([]StatisticRecord, error) {
-	startTime := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endTime := startTime.Add(24 * time.Hour).Add(-time.Second)
-	return s.getReport(startTime, endTime)
+	return s.getReport(dayRange(date))
 }
 
 // GetWeeklyReport 获取周报数据
 func (s *StatisticsService) GetWeeklyReport(date time.Time) ([]StatisticRecord, error) {
+	return s.getReport(weekRange(date))
+}
+
+// dayRange date所在那一天的开始和结束时间
+func dayRange(date time.Time) (time.Time, time.Time) {
+	startTime := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	endTime := startTime.Add(24 * time.Hour).Add(-time.Second)
+	return startTime, endTime
+}
+
+// weekRange date所在那一周(周一至周日)的开始和结束时间
+func weekRange(date time.Time) (time.Time, time.Time) {
 	offset := int(date.Weekday())
 	if offset == 0 {
 		offset = 7
@@ -46,7 +76,49 @@ func (s *StatisticsService) GetWeeklyReport(date time.Time) ([]StatisticRecord,
 	monday := date.AddDate(0, 0, -offset+1)
 	startTime := time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, date.Location())
 	endTime := startTime.Add(7 * 24 * time.Hour).Add(-time.Second)
-	return s.getReport(startTime, endTime)
+	return startTime, endTime
+}
+
+// ZoneStatisticRecord 机组的汇总统计
+type ZoneStatisticRecord struct {
+	Zone                   string      `json:"zone"`                   // 机组编号
+	RoomCount              int         `json:"roomCount"`              // 有详单的房间数
+	SwitchCount            int         `json:"switchCount"`            // 开关次数
+	DispatchCount          int         `json:"dispatchCount"`          // 调度次数
+	DetailCount            int         `json:"detailCount"`            // 详单条数
+	TemperatureChangeCount int         `json:"temperatureChangeCount"` // 调温次数
+	FanSpeedChangeCount    int         `json:"fanSpeedChangeCount"`    // 调风次数
+	Duration               float32     `json:"duration"`               // 使用时长(分钟)
+	TotalCost              types.Money `json:"totalCost"`              // 总费用
+}
+
+// AggregateByZone 按房间当前所属的机组汇总统计记录，结果按机组编号排序
+func AggregateByZone(records []StatisticRecord) []ZoneStatisticRecord {
+	byZone := make(map[string]*ZoneStatisticRecord)
+	zones := make([]string, 0)
+	for _, record := range records {
+		zone, ok := byZone[record.Zone]
+		if !ok {
+			zone = &ZoneStatisticRecord{Zone: record.Zone}
+			byZone[record.Zone] = zone
+			zones = append(zones, record.Zone)
+		}
+		zone.RoomCount++
+		zone.SwitchCount += record.SwitchCount
+		zone.DispatchCount += record.DispatchCount
+		zone.DetailCount += record.DetailCount
+		zone.TemperatureChangeCount += record.TemperatureChangeCount
+		zone.FanSpeedChangeCount += record.FanSpeedChangeCount
+		zone.Duration += record.Duration
+		zone.TotalCost = zone.TotalCost.Add(record.TotalCost)
+	}
+
+	sort.Strings(zones)
+	aggregated := make([]ZoneStatisticRecord, 0, len(zones))
+	for _, zone := range zones {
+		aggregated = append(aggregated, *byZone[zone])
+	}
+	return aggregated
 }
 
 // ServicePeriod 表示一个服务时间段
@@ -82,6 +154,11 @@ func (s *StatisticsService) getReport(startTime, endTime time.Time) ([]Statistic
 
 	statistics := make([]StatisticRecord, 0)
 
+	// 等待服务事件全部写入详单
+	if billing := GetBillingService(); billing != nil {
+		billing.FlushDetails()
+	}
+
 	for _, room := range rooms {
 		details, err := s.detailRepo.GetDetailsByRoomAndTimeRange(room.RoomID, startTime, endTime)
 		if err != nil {
@@ -97,19 +174,23 @@ func (s *StatisticsService) getReport(startTime, endTime time.Time) ([]Statistic
 			dispatchCount          int
 			temperatureChangeCount int
 			fanSpeedChangeCount    int
-			totalCost              float32
+			totalCost              = types.Fen(0)
 			servicePeriods         []ServicePeriod
 			currentPeriod          *ServicePeriod
 		)
 
 		for _, detail := range details {
-			totalCost += detail.Cost
+			// 计入住客账单的预调温详单只是预调温期间详单费用的汇总
+			if detail.DetailType == db.DetailTypePrecondition {
+				continue
+			}
+			totalCost = totalCost.Add(detail.Cost)
 
 			switch detail.DetailType {
 			case db.DetailTypeSpeedChange:
 				fanSpeedChangeCount++
 
-			case db.DetailTypeServiceInterrupt:
+			case db.DetailTypeServiceInterrupt, db.DetailTypeTargetReached, db.DetailTypeShedStop:
 				dispatchCount++
 				if currentPeriod != nil {
 					currentPeriod.EndTime = detail.EndTime
@@ -136,6 +217,15 @@ func (s *StatisticsService) getReport(startTime, endTime time.Time) ([]Statistic
 			continue
 		}
 
+		// 仍在服务中的时段计算到当前时间(不超过统计结束时间)
+		if currentPeriod != nil {
+			currentPeriod.EndTime = s.clock.Now()
+			if currentPeriod.EndTime.After(endTime) {
+				currentPeriod.EndTime = endTime
+			}
+			servicePeriods = append(servicePeriods, *currentPeriod)
+		}
+
 		// 计算总服务时长
 		var totalDuration float32
 		for _, period := range servicePeriods {
@@ -143,8 +233,16 @@ func (s *StatisticsService) getReport(startTime, endTime time.Time) ([]Statistic
 			totalDuration += float32(duration)
 		}
 		switchCount := int(count)
+		class := RoomClass(&room)
+		var classPriority float32
+		if config, err := GetACService().GetConfig(room.Zone); err == nil {
+			classPriority = config.ClassPriority[class]
+		}
 		stat := StatisticRecord{
 			Room:                   room.RoomID,
+			Zone:                   room.Zone,
+			Class:                  string(class),
+			ClassPriority:          classPriority,
 			SwitchCount:            switchCount,
 			DispatchCount:          dispatchCount,
 			DetailCount:            len(details),
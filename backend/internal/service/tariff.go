@@ -0,0 +1,208 @@
+// internal/service/tariff.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"encoding/json"
+	"time"
+)
+
+// recordTariffs 将机组配置中与当前生效费率不同的风速费率和分时电价表追加到费率表，从now起生效
+// 某个风速还没有费率记录时，首条记录从最早的时刻起生效，覆盖此前开始的服务段
+// 调用方需持有锁
+// 返回值: 是否追加了费率记录
+func (s *ACService) recordTariffs(zone *Zone, now time.Time) bool {
+	changed := false
+	for _, speed := range []types.Speed{types.SpeedLow, types.SpeedMedium, types.SpeedHigh} {
+		rate, ok := zone.config.Rates[speed]
+		if !ok {
+			continue
+		}
+		current, err := s.tariffRepo.RateAt(zone.ID, string(speed), now)
+		if err != nil {
+			logger.Error("获取机组 %s 的费率失败: %v", zone.ID, err)
+			continue
+		}
+		if current != nil && current.Rate == rate {
+			continue
+		}
+		effectiveFrom := now
+		if current == nil {
+			effectiveFrom = time.Time{}
+		}
+		tariff := &db.Tariff{
+			Zone:          zone.ID,
+			Speed:         string(speed),
+			Rate:          rate,
+			EffectiveFrom: effectiveFrom,
+			CreatedTime:   now,
+		}
+		if err := s.tariffRepo.Create(tariff); err != nil {
+			logger.Error("保存机组 %s 的费率失败: %v", zone.ID, err)
+			continue
+		}
+		changed = true
+	}
+	if s.recordSchedule(zone, now) {
+		changed = true
+	}
+	return changed
+}
+
+// recordSchedule 机组配置中的分时电价表与当前生效的不同时，追加一条从now起生效的记录，调用方需持有锁
+// 返回值: 是否追加了记录
+func (s *ACService) recordSchedule(zone *Zone, now time.Time) bool {
+	var schedule string
+	if zone.config.TimeOfUse != nil {
+		data, err := json.Marshal(zone.config.TimeOfUse)
+		if err != nil {
+			logger.Error("编码机组 %s 的分时电价表失败: %v", zone.ID, err)
+			return false
+		}
+		schedule = string(data)
+	}
+	current, err := s.tariffRepo.ScheduleAt(zone.ID, now)
+	if err != nil {
+		logger.Error("获取机组 %s 的分时电价表失败: %v", zone.ID, err)
+		return false
+	}
+	if (current == nil && schedule == "") || (current != nil && current.Schedule == schedule) {
+		return false
+	}
+	record := &db.TariffSchedule{
+		Zone:          zone.ID,
+		Schedule:      schedule,
+		EffectiveFrom: now,
+		CreatedTime:   now,
+	}
+	if err := s.tariffRepo.CreateSchedule(record); err != nil {
+		logger.Error("保存机组 %s 的分时电价表失败: %v", zone.ID, err)
+		return false
+	}
+	return true
+}
+
+// GetTariffs 按生效时间顺序获取机组的费率记录，zoneID为空时获取所有机组的记录
+func (s *ACService) GetTariffs(zoneID string) ([]db.Tariff, error) {
+	return s.tariffRepo.ListByZone(zoneID)
+}
+
+// SplitServing 在费率或分时电价表调整时结束服务中房间的当前服务段，之后的服务段按新费率计费
+func (s *Scheduler) SplitServing() {
+	s.exec(cmdReconfigure, func() {
+		now := s.clock.Now()
+		for _, service := range s.queueView().Serving {
+			event := s.serviceEvent(events.SpeedChanged, service)
+			event.Reason = events.RateChanged
+			s.bus.Publish(event)
+			service.StartTime = now
+		}
+	})
+}
+
+// baseRate 机组某个风速在t时刻生效的费率(元/分钟)
+// 费率表中没有记录时使用默认配置的费率
+func (s *BillingService) baseRate(zone string, speed types.Speed, t time.Time) float32 {
+	tariff, err := s.tariffRepo.RateAt(zone, string(speed), t)
+	if err != nil {
+		logger.Error("获取机组 %s 的费率失败: %v", zone, err)
+	}
+	if tariff == nil {
+		return DefaultConfig.Rates[speed]
+	}
+	return tariff.Rate
+}
+
+// scheduleAt 机组在t时刻生效的分时电价表，未设置时为nil
+func (s *BillingService) scheduleAt(zone string, t time.Time) *types.TimeOfUse {
+	record, err := s.tariffRepo.ScheduleAt(zone, t)
+	if err != nil {
+		logger.Error("获取机组 %s 的分时电价表失败: %v", zone, err)
+		return nil
+	}
+	if record == nil || record.Schedule == "" {
+		return nil
+	}
+	var tou types.TimeOfUse
+	if err := json.Unmarshal([]byte(record.Schedule), &tou); err != nil {
+		logger.Error("解析机组 %s 的分时电价表失败: %v", zone, err)
+		return nil
+	}
+	return &tou
+}
+
+// rateAt 机组某个风速在t时刻的费率(元/分钟)和所在的时段，未设置分时电价时时段为空
+func (s *BillingService) rateAt(zone string, speed types.Speed, t time.Time) (float32, string) {
+	base := s.baseRate(zone, speed, t)
+	tou := s.scheduleAt(zone, t)
+	if tou == nil {
+		return base, ""
+	}
+	return applyBand(bandAt(tou, t), speed, base)
+}
+
+// bandBoundaries 从start到end之间费率或时段发生变化的分时电价时段边界
+// 服务段内的费率表和分时电价表不会变化(调整时服务段会被切分)，均按start时生效的计算
+func (s *BillingService) bandBoundaries(zone string, speed types.Speed, start, end time.Time) []time.Time {
+	tou := s.scheduleAt(zone, start)
+	if tou == nil {
+		return nil
+	}
+	base := s.baseRate(zone, speed, start)
+	var boundaries []time.Time
+	rate, band := applyBand(bandAt(tou, start), speed, base)
+	for t := nextBandBoundary(tou, start); t.Before(end); t = nextBandBoundary(tou, t) {
+		nextRate, nextBand := applyBand(bandAt(tou, t), speed, base)
+		if nextRate != rate || nextBand != band {
+			boundaries = append(boundaries, t)
+			rate, band = nextRate, nextBand
+		}
+	}
+	return boundaries
+}
+
+// roomZone 房间所属的机组，房间不存在时为默认机组
+func (s *BillingService) roomZone(roomID int) string {
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return db.DefaultZone
+	}
+	return room.Zone
+}
+
+// serviceRate 服务段的费率(元/分钟)及所在的时段，按服务段开始时生效的费率乘以工作模式的费率系数
+// 旧版本保存的服务对象没有费率系数，按1计算
+func (s *BillingService) serviceRate(service *ServiceObject) (float32, string) {
+	rate, band := s.rateAt(s.roomZone(service.RoomID), service.Speed, service.StartTime)
+	if service.ModeRate > 0 {
+		rate *= service.ModeRate
+	}
+	return rate, band
+}
+
+// segmentFee 计算服务段从start到end的费用，跨过分时电价时段边界的服务段按各时段的费率分别计费
+// 与结算时写入的时段切换详单一样，每个时段的费用分别舍入到分
+func (s *BillingService) segmentFee(service *ServiceObject, start, end time.Time) types.Money {
+	segment := *service
+	segment.StartTime = start
+	fee := types.Fen(0)
+	for _, boundary := range s.bandBoundaries(s.roomZone(service.RoomID), service.Speed, start, end) {
+		rate, _ := s.serviceRate(&segment)
+		fee = fee.Add(periodCost(segment.StartTime, boundary, rate))
+		segment.StartTime = boundary
+	}
+	rate, _ := s.serviceRate(&segment)
+	return fee.Add(periodCost(segment.StartTime, end, rate))
+}
+
+// detailModeRate 由详单的费率反推服务段工作模式的费率系数
+func (s *BillingService) detailModeRate(detail *db.Detail) float32 {
+	base, _ := s.rateAt(s.roomZone(detail.RoomID), types.Speed(detail.Speed), detail.StartTime)
+	if base == 0 || detail.Rate == 0 {
+		return 1
+	}
+	return detail.Rate / base
+}
@@ -0,0 +1,198 @@
+// internal/service/time_of_use.go
+package service
+
+import (
+	"backend/internal/types"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// standardBand 设置了分时电价表时，不在任何时段内的时刻所属的时段，按风速的费率计费
+const standardBand = "standard"
+
+// dayKeys 分时电价表中按星期设置时段的键，下标为 time.Weekday
+var dayKeys = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// dateLayout 节假日日期的格式
+const dateLayout = "2006-01-02"
+
+// parseClock 将 "15:04" 格式的时刻解析为当天的分钟数，允许 "24:00"
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("无效的时刻 %q，格式应为 HH:MM", value)
+	}
+	if hour < 0 || minute < 0 || minute >= 60 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("无效的时刻 %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// validateTimeOfUse 检查分时电价表：时段名称不能为空，开始时刻早于结束时刻，同一天的时段互不重叠
+func validateTimeOfUse(tou *types.TimeOfUse) error {
+	if tou == nil {
+		return nil
+	}
+	for key, bands := range tou.Days {
+		if key != "default" && indexOf(dayKeys, key) < 0 {
+			return fmt.Errorf("无效的星期 %q，应为 mon、tue、wed、thu、fri、sat、sun 或 default", key)
+		}
+		if err := validateBands(bands); err != nil {
+			return fmt.Errorf("%s 的时段无效: %v", key, err)
+		}
+	}
+	for date, bands := range tou.Holidays {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("无效的节假日日期 %q，格式应为 2006-01-02", date)
+		}
+		if err := validateBands(bands); err != nil {
+			return fmt.Errorf("节假日 %s 的时段无效: %v", date, err)
+		}
+	}
+	return nil
+}
+
+// validateBands 检查一天内的时段
+func validateBands(bands []types.TimeBand) error {
+	type span struct{ start, end int }
+	spans := make([]span, 0, len(bands))
+	for _, band := range bands {
+		if band.Name == "" || len(band.Name) > 32 {
+			return fmt.Errorf("时段名称不能为空且不超过32个字节")
+		}
+		start, err := parseClock(band.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(band.End)
+		if err != nil {
+			return err
+		}
+		if start >= end {
+			return fmt.Errorf("时段 %s 的开始时刻必须早于结束时刻", band.Name)
+		}
+		if band.Multiplier < 0 {
+			return fmt.Errorf("时段 %s 的费率系数不能为负数", band.Name)
+		}
+		for speed, rate := range band.Rates {
+			if speedPriority[speed] == 0 {
+				return fmt.Errorf("时段 %s 的风速 %s 无效", band.Name, speed)
+			}
+			if rate <= 0 {
+				return fmt.Errorf("时段 %s 风速 %s 的费率无效", band.Name, speed)
+			}
+		}
+		spans = append(spans, span{start, end})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start < spans[i-1].end {
+			return fmt.Errorf("时段互相重叠")
+		}
+	}
+	return nil
+}
+
+// indexOf 字符串在切片中的下标，不存在时为-1
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// bandsOn t所在的那一天使用的时段：节假日优先，其次是当天星期的设置，最后是 default
+func bandsOn(tou *types.TimeOfUse, t time.Time) []types.TimeBand {
+	if bands, ok := tou.Holidays[t.Format(dateLayout)]; ok {
+		return bands
+	}
+	if bands, ok := tou.Days[dayKeys[t.Weekday()]]; ok {
+		return bands
+	}
+	return tou.Days["default"]
+}
+
+// midnight t所在那一天的零点
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// bandAt t时刻所在的时段，不在任何时段内时为nil
+func bandAt(tou *types.TimeOfUse, t time.Time) *types.TimeBand {
+	t = t.In(time.Local)
+	minute := t.Sub(midnight(t)).Minutes()
+	bands := bandsOn(tou, t)
+	for i := range bands {
+		start, _ := parseClock(bands[i].Start)
+		end, _ := parseClock(bands[i].End)
+		if minute >= float64(start) && minute < float64(end) {
+			return &bands[i]
+		}
+	}
+	return nil
+}
+
+// nextBandBoundary t之后最近的时段边界：当天时段的开始或结束时刻，没有时为次日零点
+func nextBandBoundary(tou *types.TimeOfUse, t time.Time) time.Time {
+	t = t.In(time.Local)
+	day := midnight(t)
+	next := day.AddDate(0, 0, 1)
+	for _, band := range bandsOn(tou, t) {
+		for _, clock := range []string{band.Start, band.End} {
+			minute, _ := parseClock(clock)
+			boundary := day.Add(time.Duration(minute) * time.Minute)
+			if boundary.After(t) && boundary.Before(next) {
+				next = boundary
+			}
+		}
+	}
+	return next
+}
+
+// applyBand 时段内某个风速的费率(元/分钟)及时段名称，base 为风速的费率
+func applyBand(band *types.TimeBand, speed types.Speed, base float32) (float32, string) {
+	if band == nil {
+		return base, standardBand
+	}
+	if rate, ok := band.Rates[speed]; ok {
+		return rate, band.Name
+	}
+	if band.Multiplier > 0 {
+		return base * band.Multiplier, band.Name
+	}
+	return base, band.Name
+}
+
+// cloneTimeOfUse 复制分时电价表
+func cloneTimeOfUse(tou *types.TimeOfUse) *types.TimeOfUse {
+	if tou == nil {
+		return nil
+	}
+	clone := &types.TimeOfUse{
+		Days:     make(map[string][]types.TimeBand, len(tou.Days)),
+		Holidays: make(map[string][]types.TimeBand, len(tou.Holidays)),
+	}
+	for key, bands := range tou.Days {
+		clone.Days[key] = cloneBands(bands)
+	}
+	for date, bands := range tou.Holidays {
+		clone.Holidays[date] = cloneBands(bands)
+	}
+	return clone
+}
+
+// cloneBands 复制一天的时段
+func cloneBands(bands []types.TimeBand) []types.TimeBand {
+	clone := make([]types.TimeBand, len(bands))
+	for i, band := range bands {
+		clone[i] = band
+		clone[i].Rates = make(map[types.Speed]float32, len(band.Rates))
+		for speed, rate := range band.Rates {
+			clone[i].Rates[speed] = rate
+		}
+	}
+	return clone
+}
@@ -0,0 +1,292 @@
+// internal/service/timer.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	timerCheckInterval = 10 * time.Second // 检查房间定时任务的周期(系统时间)
+	sleepStepInterval  = time.Hour        // 睡眠曲线调整目标温度的间隔
+	maxSleepStep       = 3                // 睡眠曲线每小时最多调整的温度(°C)
+	maxSleepHours      = 12               // 睡眠曲线最多持续的小时数
+)
+
+// SetTimer 为已入住的房间设置定时任务：定时关机、定时开机或睡眠曲线
+// 每个房间同一类型的定时任务只能有一个。任务到时通过普通的开关机和调温请求执行，调度和计费与住客的操作一致
+// roomID: 房间号
+// timer: 任务的类型、触发时间和参数
+// 返回值: 新的定时任务和错误信息
+func (s *ACService) SetTimer(roomID int, timer db.RoomTimer) (*db.RoomTimer, error) {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	room, err := s.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("获取房间信息失败: %v", err)
+	}
+	if room.State != 1 {
+		return nil, fmt.Errorf("房间未入住")
+	}
+	now := s.clock.Now()
+	if !timer.FireTime.After(now) {
+		return nil, fmt.Errorf("触发时间必须晚于当前时间")
+	}
+
+	switch timer.Kind {
+	case db.TimerPowerOff:
+	case db.TimerPowerOn:
+		if timer.TargetTemp != 0 {
+			tempRange, err := s.roomTempRange(room)
+			if err != nil {
+				return nil, err
+			}
+			if timer.TargetTemp < tempRange.Min || timer.TargetTemp > tempRange.Max {
+				return nil, fmt.Errorf("温度 %.1f°C 超出当前模式允许范围", timer.TargetTemp)
+			}
+		}
+	case db.TimerSleep:
+		if timer.Step == 0 || math.Abs(float64(timer.Step)) > maxSleepStep {
+			return nil, fmt.Errorf("睡眠曲线每小时调整的温度必须在 ±%d°C 之内且不为0", maxSleepStep)
+		}
+		if timer.Hours < 1 || timer.Hours > maxSleepHours {
+			return nil, fmt.Errorf("睡眠曲线的持续时间必须在 1 到 %d 小时之间", maxSleepHours)
+		}
+	default:
+		return nil, fmt.Errorf("无效的定时任务类型 %s", timer.Kind)
+	}
+
+	timers, err := s.timerRepo.ListByRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range timers {
+		if t.Kind == timer.Kind && (t.Status == db.TimerPending || t.Status == db.TimerRunning) {
+			return nil, fmt.Errorf("房间 %d 已有未结束的%s任务 %d", roomID, timerKindNames[t.Kind], t.ID)
+		}
+	}
+
+	newTimer := &db.RoomTimer{
+		RoomID:      roomID,
+		Kind:        timer.Kind,
+		FireTime:    timer.FireTime,
+		TargetTemp:  timer.TargetTemp,
+		Step:        timer.Step,
+		Hours:       timer.Hours,
+		Status:      db.TimerPending,
+		CreatedTime: now,
+	}
+	if err := s.timerRepo.Create(newTimer); err != nil {
+		return nil, err
+	}
+	logger.Info("房间 %d 的%s任务 %d 已设置，%s 触发",
+		roomID, timerKindNames[newTimer.Kind], newTimer.ID, newTimer.FireTime.Format("15:04:05"))
+	return newTimer, nil
+}
+
+// CancelTimer 取消房间未结束的定时任务，已执行的睡眠曲线调整不回退
+func (s *ACService) CancelTimer(roomID, timerID int) (*db.RoomTimer, error) {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	timer, err := s.timerRepo.GetByID(timerID)
+	if err != nil || timer.RoomID != roomID {
+		return nil, fmt.Errorf("房间 %d 没有定时任务 %d", roomID, timerID)
+	}
+	if timer.Status != db.TimerPending && timer.Status != db.TimerRunning {
+		return nil, fmt.Errorf("定时任务 %d 已结束", timerID)
+	}
+	s.endTimer(timer, db.TimerCancelled, "住客取消")
+	return timer, nil
+}
+
+// GetTimers 获取房间的定时任务
+func (s *ACService) GetTimers(roomID int) ([]db.RoomTimer, error) {
+	return s.timerRepo.ListByRoom(roomID)
+}
+
+// NextFireTime 定时任务下一次执行的时间，睡眠曲线每小时执行一次
+func NextFireTime(timer *db.RoomTimer) time.Time {
+	if timer.Kind == db.TimerSleep {
+		return timer.FireTime.Add(time.Duration(timer.StepsDone) * sleepStepInterval)
+	}
+	return timer.FireTime
+}
+
+// timerKindNames 定时任务类型的中文名称，用于日志和错误信息
+var timerKindNames = map[db.TimerKind]string{
+	db.TimerPowerOff: "定时关机",
+	db.TimerPowerOn:  "定时开机",
+	db.TimerSleep:    "睡眠曲线",
+}
+
+// startTimerChecks 按系统时钟定时检查房间的定时任务
+func (s *ACService) startTimerChecks() {
+	s.stopTimers = s.clock.Every(timerCheckInterval, s.checkTimers)
+}
+
+// stopTimerChecks 停止房间定时任务的定时检查
+func (s *ACService) stopTimerChecks() {
+	if s.stopTimers != nil {
+		s.stopTimers()
+		s.stopTimers = nil
+	}
+}
+
+// checkTimers 执行到时的定时任务，重启期间错过的任务在恢复后补执行
+func (s *ACService) checkTimers() {
+	timers, err := s.timerRepo.ListActive()
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	now := s.clock.Now()
+	for i := range timers {
+		if !now.Before(NextFireTime(&timers[i])) {
+			s.fireTimer(timers[i].ID)
+		}
+	}
+}
+
+// fireTimer 执行定时任务
+// 任务通过 PowerOn、PowerOff 和 SetTemperature 执行，与住客在控制面板上的操作相同
+func (s *ACService) fireTimer(timerID int) {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	timer, err := s.timerRepo.GetByID(timerID)
+	if err != nil || (timer.Status != db.TimerPending && timer.Status != db.TimerRunning) {
+		return
+	}
+	room, err := s.roomRepo.GetRoomByID(timer.RoomID)
+	if err != nil {
+		logger.Error("获取房间信息失败: %v", err)
+		return
+	}
+	if room.State != 1 || room.CheckinTime.After(timer.CreatedTime) {
+		s.endTimer(timer, db.TimerCancelled, "住客已退房")
+		return
+	}
+
+	switch timer.Kind {
+	case db.TimerPowerOff:
+		if room.ACState != 1 {
+			s.endTimer(timer, db.TimerDone, "空调已关闭")
+			return
+		}
+		if err := s.PowerOff(room.RoomID); err != nil {
+			s.endTimer(timer, db.TimerFailed, err.Error())
+			return
+		}
+		s.endTimer(timer, db.TimerDone, "")
+
+	case db.TimerPowerOn:
+		if room.ACState != 1 {
+			if err := s.PowerOn(room.RoomID); err != nil {
+				s.endTimer(timer, db.TimerFailed, err.Error())
+				return
+			}
+		}
+		if timer.TargetTemp != 0 {
+			if err := s.SetTemperature(room.RoomID, timer.TargetTemp); err != nil {
+				s.endTimer(timer, db.TimerFailed, err.Error())
+				return
+			}
+		}
+		s.endTimer(timer, db.TimerDone, "")
+
+	case db.TimerSleep:
+		s.stepSleepCurve(timer, room)
+	}
+}
+
+// stepSleepCurve 按睡眠曲线调整目标温度，调整后的温度限制在房间当前模式的温度范围内
+// 住客手动调温后，曲线在住客设定的温度上继续调整；错过的调整合并为一次请求
+func (s *ACService) stepSleepCurve(timer *db.RoomTimer, room *db.RoomInfo) {
+	if room.ACState != 1 {
+		s.endTimer(timer, db.TimerCancelled, "空调已关闭")
+		return
+	}
+	tempRange, err := s.roomTempRange(room)
+	if err != nil {
+		s.endTimer(timer, db.TimerFailed, err.Error())
+		return
+	}
+
+	due := int(s.clock.Now().Sub(timer.FireTime)/sleepStepInterval) + 1
+	if due > timer.Hours {
+		due = timer.Hours
+	}
+	target := room.TargetTemp + float32(due-timer.StepsDone)*timer.Step
+	if target < tempRange.Min {
+		target = tempRange.Min
+	}
+	if target > tempRange.Max {
+		target = tempRange.Max
+	}
+	if target != room.TargetTemp {
+		if err := s.SetTemperature(room.RoomID, target); err != nil {
+			s.endTimer(timer, db.TimerFailed, err.Error())
+			return
+		}
+		logger.Info("房间 %d 按睡眠曲线将目标温度调整为 %.1f°C", room.RoomID, target)
+	}
+
+	timer.StepsDone = due
+	switch {
+	case due >= timer.Hours:
+		s.endTimer(timer, db.TimerDone, "")
+	case target == tempRange.Min || target == tempRange.Max:
+		s.endTimer(timer, db.TimerDone, "已达到温度范围边界")
+	default:
+		timer.Status = db.TimerRunning
+		if _, err := s.timerRepo.Update(timer); err != nil {
+			logger.Error("%v", err)
+		}
+	}
+}
+
+// endTimer 结束定时任务，调用方需持有定时任务锁
+func (s *ACService) endTimer(timer *db.RoomTimer, status db.TimerStatus, note string) {
+	timer.Status = status
+	timer.Note = note
+	timer.EndedAt = s.clock.Now()
+	saved, err := s.timerRepo.Update(timer)
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if !saved {
+		return
+	}
+	if note != "" {
+		logger.Info("房间 %d 的%s任务 %d 结束(%s): %s", timer.RoomID, timerKindNames[timer.Kind], timer.ID, status, note)
+	} else {
+		logger.Info("房间 %d 的%s任务 %d 结束(%s)", timer.RoomID, timerKindNames[timer.Kind], timer.ID, status)
+	}
+}
+
+// roomTempRange 获取房间当前模式允许的温度范围
+func (s *ACService) roomTempRange(room *db.RoomInfo) (types.TempRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zone, err := s.roomZone(room)
+	if err != nil {
+		return types.TempRange{}, err
+	}
+	mode := types.Mode(room.Mode)
+	if room.ACState != 1 {
+		mode = zone.mode
+	}
+	tempRange, ok := zone.config.TempRanges[mode]
+	if !ok {
+		return types.TempRange{}, fmt.Errorf("机组 %s 没有 %s 模式的温度范围", zone.ID, mode)
+	}
+	return tempRange, nil
+}
@@ -0,0 +1,315 @@
+// internal/service/zone.go
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/logger"
+	"backend/internal/types"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// zoneIDPattern 机组编号只允许小写字母、数字、下划线和连字符，用于拼接设置的键
+var zoneIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Zone 一台中央空调机组及其负责的房间
+// 每个机组有独立的开关状态、工作模式、配置(温度范围、费率、容量等)和调度器，
+// 房间通过房间表的 zone 字段归属于一个机组。机组的字段由 ACService 的锁保护。
+type Zone struct {
+	ID        string       // 机组编号
+	Name      string       // 机组名称
+	config    types.Config // 机组配置
+	scheduler *Scheduler   // 机组的调度器
+	isOn      bool         // 是否开启
+	mode      types.Mode   // 工作模式
+}
+
+// ZoneState 机组的运行状态
+type ZoneState struct {
+	ID       string
+	Name     string
+	IsOn     bool
+	Mode     types.Mode
+	Policy   string // 调度策略名称
+	Rooms    []int  // 机组内的房间号
+	Serving  int    // 服务队列中的房间数
+	Waiting  int    // 等待队列中的房间数
+	Capacity Capacity
+	Used     float32 // 已占用的容量
+}
+
+// zoneRecord 机组列表的持久化记录，机组的配置和状态按机组分别保存
+type zoneRecord struct {
+	ID   string
+	Name string
+}
+
+// SchedulerRegistry 各机组的调度器
+// 计费和监控按房间所属的机组找到对应的调度器
+type SchedulerRegistry struct {
+	mu     sync.RWMutex
+	byZone map[string]*Scheduler
+}
+
+// NewSchedulerRegistry 创建调度器注册表
+func NewSchedulerRegistry() *SchedulerRegistry {
+	return &SchedulerRegistry{byZone: make(map[string]*Scheduler)}
+}
+
+// Add 登记机组的调度器
+func (r *SchedulerRegistry) Add(zone string, scheduler *Scheduler) {
+	r.mu.Lock()
+	r.byZone[zone] = scheduler
+	r.mu.Unlock()
+}
+
+// Remove 注销机组的调度器并返回它，机组不存在时返回nil
+func (r *SchedulerRegistry) Remove(zone string) *Scheduler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scheduler := r.byZone[zone]
+	delete(r.byZone, zone)
+	return scheduler
+}
+
+// Get 获取机组的调度器，机组不存在时返回nil
+func (r *SchedulerRegistry) Get(zone string) *Scheduler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.byZone[zone]
+}
+
+// Snapshots 获取所有机组调度队列的快照
+func (r *SchedulerRegistry) Snapshots() map[string]SchedulerSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshots := make(map[string]SchedulerSnapshot, len(r.byZone))
+	for zone, scheduler := range r.byZone {
+		snapshots[zone] = scheduler.Snapshot()
+	}
+	return snapshots
+}
+
+// StopAll 停止所有调度器
+func (r *SchedulerRegistry) StopAll() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, scheduler := range r.byZone {
+		scheduler.Stop()
+	}
+}
+
+// newZone 创建使用默认配置、处于关闭状态的机组，并登记机组的调度器
+func (s *ACService) newZone(id, name string) *Zone {
+	scheduler := NewScheduler(s.clock, s.bus, id)
+	scheduler.SetLogging(true)
+	GetSchedulers().Add(id, scheduler)
+	return &Zone{
+		ID:        id,
+		Name:      name,
+		config:    cloneConfig(DefaultConfig),
+		scheduler: scheduler,
+		mode:      types.ModeCooling,
+	}
+}
+
+// zone 按编号查找机组，编号为空时返回默认机组，调用方需持有锁
+func (s *ACService) zone(zoneID string) (*Zone, error) {
+	if zoneID == "" {
+		zoneID = db.DefaultZone
+	}
+	zone, ok := s.zones[zoneID]
+	if !ok {
+		return nil, fmt.Errorf("机组 %s 不存在", zoneID)
+	}
+	return zone, nil
+}
+
+// roomZone 查找房间所属的机组，调用方需持有锁
+func (s *ACService) roomZone(room *db.RoomInfo) (*Zone, error) {
+	zone, ok := s.zones[room.Zone]
+	if !ok {
+		return nil, fmt.Errorf("房间 %d 所属的机组 %s 不存在", room.RoomID, room.Zone)
+	}
+	return zone, nil
+}
+
+// CreateZone 新建机组并将房间划入该机组
+// 新机组使用默认配置且处于关闭状态；划入的房间必须已关闭空调
+func (s *ACService) CreateZone(zoneID, name string, rooms []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !zoneIDPattern.MatchString(zoneID) {
+		return fmt.Errorf("机组编号只能包含小写字母、数字、下划线和连字符，且不超过32个字符")
+	}
+	if _, exists := s.zones[zoneID]; exists {
+		return fmt.Errorf("机组 %s 已存在", zoneID)
+	}
+	if name == "" {
+		name = zoneID
+	}
+
+	zone := s.newZone(zoneID, name)
+	// 新机组没有需要恢复的队列快照
+	zone.scheduler.restore(nil, nil)
+	s.zones[zoneID] = zone
+	s.saveZones()
+	// 覆盖同名机组删除前保存的配置和状态
+	if err := s.settingRepo.Save(db.ZoneKey(db.SettingACConfig, zone.ID), zone.config, s.clock.Now()); err != nil {
+		logger.Error("保存机组 %s 的配置失败: %v", zone.ID, err)
+	}
+	s.saveCentralState(zone)
+	s.recordTariffs(zone, s.clock.Now())
+	s.billing.SetEnergyRates(zone.ID, zone.config.EnergyRates)
+	if err := s.assignRooms(zone, rooms); err != nil {
+		return err
+	}
+	logger.Info("机组 %s(%s) 创建成功，房间: %v", zoneID, name, rooms)
+	return nil
+}
+
+// DeleteZone 删除机组，机组的房间划回默认机组
+// 默认机组不能删除，机组需先关闭
+func (s *ACService) DeleteZone(zoneID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return err
+	}
+	if zone.ID == db.DefaultZone {
+		return fmt.Errorf("默认机组不能删除")
+	}
+	if zone.isOn {
+		return fmt.Errorf("机组 %s 未关闭", zone.ID)
+	}
+
+	if err := s.roomRepo.ReassignZone(zone.ID, db.DefaultZone); err != nil {
+		return err
+	}
+	if err := s.roomRepo.SetACMode(db.DefaultZone, string(s.zones[db.DefaultZone].mode)); err != nil {
+		logger.Error("设置房间工作模式失败: %v", err)
+	}
+	delete(s.zones, zone.ID)
+	if scheduler := GetSchedulers().Remove(zone.ID); scheduler != nil {
+		scheduler.Stop()
+	}
+	s.saveZones()
+	logger.Info("机组 %s 已删除，房间已划回默认机组", zone.ID)
+	return nil
+}
+
+// AssignRooms 将房间划入机组，房间必须已关闭空调
+func (s *ACService) AssignRooms(zoneID string, rooms []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone, err := s.zone(zoneID)
+	if err != nil {
+		return err
+	}
+	if err := s.assignRooms(zone, rooms); err != nil {
+		return err
+	}
+	logger.Info("房间 %v 已划入机组 %s", rooms, zone.ID)
+	return nil
+}
+
+// assignRooms 将房间划入机组，房间的工作模式随之改为机组的模式，调用方需持有锁
+// 开着空调的房间仍在原机组的调度器中，不能直接迁移
+func (s *ACService) assignRooms(zone *Zone, rooms []int) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	for _, roomID := range rooms {
+		room, err := s.roomRepo.GetRoomByID(roomID)
+		if err != nil {
+			return fmt.Errorf("获取房间 %d 信息失败: %v", roomID, err)
+		}
+		if room.ACState == 1 {
+			return fmt.Errorf("房间 %d 的空调未关闭，不能更换机组", roomID)
+		}
+	}
+	if err := s.roomRepo.UpdateZone(rooms, zone.ID); err != nil {
+		return err
+	}
+	return s.roomRepo.SetACMode(zone.ID, string(zone.mode))
+}
+
+// GetZones 获取所有机组的运行状态，按机组编号排序，默认机组在最前
+func (s *ACService) GetZones() ([]ZoneState, error) {
+	rooms, err := s.roomRepo.GetAllRooms()
+	if err != nil {
+		return nil, err
+	}
+	roomsByZone := make(map[string][]int)
+	for _, room := range rooms {
+		roomsByZone[room.Zone] = append(roomsByZone[room.Zone], room.RoomID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make([]ZoneState, 0, len(s.zones))
+	for _, zone := range s.zones {
+		snapshot := zone.scheduler.Snapshot()
+		states = append(states, ZoneState{
+			ID:       zone.ID,
+			Name:     zone.Name,
+			IsOn:     zone.isOn,
+			Mode:     zone.mode,
+			Policy:   snapshot.Policy,
+			Rooms:    roomsByZone[zone.ID],
+			Serving:  len(snapshot.Serving),
+			Waiting:  len(snapshot.Waiting),
+			Capacity: snapshot.Capacity,
+			Used:     snapshot.Used,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if (states[i].ID == db.DefaultZone) != (states[j].ID == db.DefaultZone) {
+			return states[i].ID == db.DefaultZone
+		}
+		return states[i].ID < states[j].ID
+	})
+	return states, nil
+}
+
+// saveZones 保存机组列表，调用方需持有锁
+func (s *ACService) saveZones() {
+	records := make([]zoneRecord, 0, len(s.zones))
+	for _, zone := range s.zones {
+		if zone.ID == db.DefaultZone {
+			continue
+		}
+		records = append(records, zoneRecord{ID: zone.ID, Name: zone.Name})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	if err := s.settingRepo.Save(db.SettingZones, records, s.clock.Now()); err != nil {
+		logger.Error("保存机组列表失败: %v", err)
+	}
+}
+
+// restoreZones 系统启动时按保存的机组列表重建默认机组以外的机组
+func (s *ACService) restoreZones() {
+	var records []zoneRecord
+	if _, err := s.settingRepo.Load(db.SettingZones, &records); err != nil {
+		logger.Error("恢复机组列表失败: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		if _, exists := s.zones[record.ID]; exists {
+			continue
+		}
+		s.zones[record.ID] = s.newZone(record.ID, record.Name)
+	}
+	if len(records) > 0 {
+		logger.Info("已恢复 %d 个机组(不含默认机组)", len(records))
+	}
+}
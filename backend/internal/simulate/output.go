@@ -0,0 +1,187 @@
+// internal/simulate/output.go
+package simulate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvHeader 状态表CSV的表头
+var csvHeader = []string{
+	"minute", "room", "ac", "queue", "speed",
+	"current_temp", "target_temp", "current_fee", "total_fee",
+}
+
+// WriteRows 按格式输出状态表，format为csv或json
+func WriteRows(w io.Writer, rows []StateRow, format string) error {
+	switch format {
+	case "csv":
+		return writeCSV(w, rows)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	return fmt.Errorf("不支持的输出格式: %s", format)
+}
+
+func writeCSV(w io.Writer, rows []StateRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			formatFloat(row.Minute),
+			strconv.Itoa(row.RoomID),
+			row.ACState,
+			row.Queue,
+			row.Speed,
+			formatFloat(row.CurrentTemp),
+			formatFloat(row.TargetTemp),
+			formatFloat(row.CurrentFee),
+			formatFloat(row.TotalFee),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// ReadRows 读取期望的状态表，按扩展名识别csv或json
+func ReadRows(path string) ([]StateRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取期望结果失败: %v", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []StateRow
+		if err := json.NewDecoder(file).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("解析期望结果失败: %v", err)
+		}
+		return rows, nil
+	}
+	return readCSV(file)
+}
+
+func readCSV(r io.Reader) ([]StateRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析期望结果失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// 按表头定位列，允许期望结果只包含部分列
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["minute"]; !ok {
+		return nil, fmt.Errorf("期望结果缺少 minute 列")
+	}
+	if _, ok := columns["room"]; !ok {
+		return nil, fmt.Errorf("期望结果缺少 room 列")
+	}
+
+	rows := make([]StateRow, 0, len(records)-1)
+	for line, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) (float64, error) {
+			value := get(name)
+			if value == "" {
+				return math.NaN(), nil
+			}
+			return strconv.ParseFloat(value, 64)
+		}
+
+		var row StateRow
+		var err error
+		if row.Minute, err = number("minute"); err != nil {
+			return nil, fmt.Errorf("第 %d 行 minute 无效: %v", line+2, err)
+		}
+		if row.RoomID, err = strconv.Atoi(get("room")); err != nil {
+			return nil, fmt.Errorf("第 %d 行 room 无效: %v", line+2, err)
+		}
+		row.ACState = get("ac")
+		row.Queue = get("queue")
+		row.Speed = get("speed")
+		for name, field := range map[string]*float64{
+			"current_temp": &row.CurrentTemp,
+			"target_temp":  &row.TargetTemp,
+			"current_fee":  &row.CurrentFee,
+			"total_fee":    &row.TotalFee,
+		} {
+			if *field, err = number(name); err != nil {
+				return nil, fmt.Errorf("第 %d 行 %s 无效: %v", line+2, name, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Compare 将实际状态表与期望状态表逐行比较，返回所有不一致之处
+// 期望结果中为空的列不参与比较，数值列允许tolerance的误差
+func Compare(actual, expected []StateRow, tolerance float64) []string {
+	type key struct {
+		minute float64
+		room   int
+	}
+	index := make(map[key]StateRow, len(actual))
+	for _, row := range actual {
+		index[key{row.Minute, row.RoomID}] = row
+	}
+
+	var mismatches []string
+	for _, want := range expected {
+		got, ok := index[key{round2(want.Minute), want.RoomID}]
+		if !ok {
+			mismatches = append(mismatches,
+				fmt.Sprintf("第 %.2f 分钟 房间 %d: 实际结果中没有该行", want.Minute, want.RoomID))
+			continue
+		}
+
+		prefix := fmt.Sprintf("第 %.2f 分钟 房间 %d", want.Minute, want.RoomID)
+		compareText := func(name, gotValue, wantValue string) {
+			if wantValue != "" && gotValue != wantValue {
+				mismatches = append(mismatches, fmt.Sprintf("%s %s: 期望 %s, 实际 %s", prefix, name, wantValue, gotValue))
+			}
+		}
+		compareNumber := func(name string, gotValue, wantValue float64) {
+			if !math.IsNaN(wantValue) && math.Abs(gotValue-wantValue) > tolerance {
+				mismatches = append(mismatches, fmt.Sprintf("%s %s: 期望 %.2f, 实际 %.2f", prefix, name, wantValue, gotValue))
+			}
+		}
+
+		compareText("ac", got.ACState, want.ACState)
+		compareText("queue", got.Queue, want.Queue)
+		compareText("speed", got.Speed, want.Speed)
+		compareNumber("current_temp", got.CurrentTemp, want.CurrentTemp)
+		compareNumber("target_temp", got.TargetTemp, want.TargetTemp)
+		compareNumber("current_fee", got.CurrentFee, want.CurrentFee)
+		compareNumber("total_fee", got.TotalFee, want.TotalFee)
+	}
+	return mismatches
+}
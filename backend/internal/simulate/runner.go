@@ -0,0 +1,355 @@
+// internal/simulate/runner.go
+package simulate
+
+import (
+	"backend/internal/clock"
+	"backend/internal/db"
+	"backend/internal/service"
+	"backend/internal/thermal"
+	"backend/internal/types"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 房间在调度队列中的位置
+const (
+	QueueServing = "serving" // 服务队列
+	QueueWaiting = "waiting" // 等待队列
+	QueueNone    = "-"       // 不在队列中
+)
+
+// StateRow 某一时间步单个房间的状态
+type StateRow struct {
+	Minute      float64 `json:"minute"`       // 相对脚本开始的分钟数
+	RoomID      int     `json:"room"`         // 房间号
+	ACState     string  `json:"ac"`           // 空调开关 on/off
+	Queue       string  `json:"queue"`        // 所在队列
+	Speed       string  `json:"speed"`        // 风速
+	CurrentTemp float64 `json:"current_temp"` // 当前温度
+	TargetTemp  float64 `json:"target_temp"`  // 目标温度
+	CurrentFee  float64 `json:"current_fee"`  // 本次开机费用
+	TotalFee    float64 `json:"total_fee"`    // 入住以来总费用
+}
+
+// Result 一次回放的结果
+type Result struct {
+	Rows     []StateRow // 每个时间步每个房间一行
+	Warnings []string   // 执行失败的操作
+}
+
+// Runner 脚本回放器
+// 使用前需以同一个模拟时钟初始化服务层，回放期间时钟只由回放器推进
+type Runner struct {
+	scenario  *Scenario
+	clock     *clock.SimClock
+	start     time.Time
+	acService *service.ACService
+	billing   *service.BillingService
+	roomRepo  *db.RoomRepository
+}
+
+// NewRunner 创建脚本回放器
+func NewRunner(scenario *Scenario, clk *clock.SimClock) *Runner {
+	return &Runner{
+		scenario:  scenario,
+		clock:     clk,
+		start:     clk.Now(),
+		acService: service.GetACService(),
+		billing:   service.GetBillingService(),
+		roomRepo:  db.NewRoomRepository(),
+	}
+}
+
+// Run 按时间顺序执行脚本中的操作，并在每个时间步记录房间状态
+// 同一时刻先执行操作，再记录状态
+func (r *Runner) Run() (*Result, error) {
+	if err := r.setup(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	events := r.scenario.Events
+	next := 0
+	for offset := time.Duration(0); offset <= r.scenario.Duration; offset += r.scenario.Tick {
+		for next < len(events) && events[next].At <= offset {
+			event := events[next]
+			r.advanceTo(event.At)
+			if err := r.apply(event); err != nil {
+				result.Warnings = append(result.Warnings,
+					fmt.Sprintf("第 %.1f 分钟 房间 %d %s 失败: %v", event.At.Minutes(), event.Room, event.Op, err))
+			}
+			next++
+		}
+		r.advanceTo(offset)
+
+		rows, err := r.record(offset)
+		if err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, rows...)
+	}
+	return result, nil
+}
+
+// setup 准备房间、配置并开启中央空调
+func (r *Runner) setup() error {
+	if r.scenario.Policy != "" {
+		if err := r.acService.SetSchedulingPolicy(db.DefaultZone, r.scenario.Policy); err != nil {
+			return err
+		}
+	}
+
+	if r.scenario.Config != nil {
+		config, err := r.buildConfig()
+		if err != nil {
+			return err
+		}
+		if err := r.acService.SetConfig(db.DefaultZone, config); err != nil {
+			return fmt.Errorf("设置空调配置失败: %v", err)
+		}
+	}
+
+	for _, room := range r.scenario.Rooms {
+		if room.InitialTemp != nil {
+			if err := r.roomRepo.ResetTemperature(room.ID, *room.InitialTemp); err != nil {
+				return fmt.Errorf("设置房间 %d 初始温度失败: %v", room.ID, err)
+			}
+		}
+		if err := r.setupThermalParams(room); err != nil {
+			return err
+		}
+		if room.CheckedIn == nil || *room.CheckedIn {
+			if err := r.acService.CheckIn(room.ID, fmt.Sprintf("SIM%03d", room.ID), fmt.Sprintf("房间%d", room.ID), types.Fen(0)); err != nil {
+				return fmt.Errorf("房间 %d 入住失败: %v", room.ID, err)
+			}
+		}
+	}
+
+	if err := r.acService.StartCentralAC(db.DefaultZone, r.scenario.Mode); err != nil {
+		return fmt.Errorf("启动中央空调失败: %v", err)
+	}
+	return nil
+}
+
+// setupThermalParams 按脚本设置房间热参数，未设置的参数保持数据库中的值
+func (r *Runner) setupThermalParams(room ScenarioRoom) error {
+	if room.Volume == 0 && room.Insulation == nil && room.OccupancyLoad == nil {
+		return nil
+	}
+	info, err := r.roomRepo.GetRoomByID(room.ID)
+	if err != nil {
+		return fmt.Errorf("获取房间 %d 信息失败: %v", room.ID, err)
+	}
+	params := thermal.Params{
+		Volume:        info.Volume,
+		Insulation:    info.Insulation,
+		OccupancyLoad: info.OccupancyLoad,
+	}
+	if room.Volume != 0 {
+		params.Volume = room.Volume
+	}
+	if room.Insulation != nil {
+		params.Insulation = *room.Insulation
+	}
+	if room.OccupancyLoad != nil {
+		params.OccupancyLoad = *room.OccupancyLoad
+	}
+	if err := r.acService.SetRoomThermalParams(room.ID, params); err != nil {
+		return fmt.Errorf("设置房间 %d 热参数失败: %v", room.ID, err)
+	}
+	return nil
+}
+
+// buildConfig 以默认机组的当前配置为基础合并脚本中的配置
+func (r *Runner) buildConfig() (types.Config, error) {
+	config, err := r.acService.GetConfig(db.DefaultZone)
+	if err != nil {
+		return config, err
+	}
+
+	sc := r.scenario.Config
+	if sc.DefaultTemp != 0 {
+		config.DefaultTemp = sc.DefaultTemp
+	}
+	if sc.DefaultSpeed != "" {
+		config.DefaultSpeed = types.Speed(sc.DefaultSpeed)
+	}
+	if sc.MinTemp != 0 || sc.MaxTemp != 0 {
+		tempRange := config.TempRanges[r.scenario.Mode]
+		if sc.MinTemp != 0 {
+			tempRange.Min = sc.MinTemp
+		}
+		if sc.MaxTemp != 0 {
+			tempRange.Max = sc.MaxTemp
+		}
+		config.TempRanges[r.scenario.Mode] = tempRange
+	}
+	if sc.LowRate != 0 {
+		config.Rates[types.SpeedLow] = sc.LowRate
+	}
+	if sc.MediumRate != 0 {
+		config.Rates[types.SpeedMedium] = sc.MediumRate
+	}
+	if sc.HighRate != 0 {
+		config.Rates[types.SpeedHigh] = sc.HighRate
+	}
+	if sc.ModeRate != nil {
+		config.ModeRates[r.scenario.Mode] = *sc.ModeRate
+	}
+	if sc.CapacityMode != "" {
+		config.CapacityMode = types.CapacityMode(sc.CapacityMode)
+	}
+	if sc.MaxServices != 0 {
+		config.MaxServices = sc.MaxServices
+	}
+	if sc.PowerBudget != 0 {
+		config.PowerBudget = sc.PowerBudget
+	}
+	if sc.LowLoad != 0 {
+		config.SpeedLoad[types.SpeedLow] = sc.LowLoad
+	}
+	if sc.MediumLoad != 0 {
+		config.SpeedLoad[types.SpeedMedium] = sc.MediumLoad
+	}
+	if sc.HighLoad != 0 {
+		config.SpeedLoad[types.SpeedHigh] = sc.HighLoad
+	}
+	if sc.TimeSlice != 0 {
+		config.TimeSlice = sc.TimeSlice
+	}
+	if sc.WaitGrowthFactor != nil {
+		config.WaitGrowthFactor = *sc.WaitGrowthFactor
+	}
+	if sc.AgingRate != nil {
+		config.AgingRate = *sc.AgingRate
+	}
+	if sc.MaxWait != 0 {
+		config.MaxWait = sc.MaxWait
+	}
+	if sc.Hysteresis != 0 {
+		config.Hysteresis = sc.Hysteresis
+	}
+	if sc.ThermalModel != "" {
+		config.Thermal.Model = sc.ThermalModel
+	}
+	if sc.OutdoorTemp != nil {
+		config.Thermal.OutdoorTemp = sc.OutdoorTemp
+	}
+	if sc.WeatherFile != "" {
+		config.Thermal.WeatherFile = sc.WeatherFile
+	}
+	if sc.LowCapacity != 0 {
+		config.Thermal.Capacity[types.SpeedLow] = sc.LowCapacity
+	}
+	if sc.MediumCapacity != 0 {
+		config.Thermal.Capacity[types.SpeedMedium] = sc.MediumCapacity
+	}
+	if sc.HighCapacity != 0 {
+		config.Thermal.Capacity[types.SpeedHigh] = sc.HighCapacity
+	}
+	if sc.TargetHumidity != 0 {
+		config.Thermal.TargetHumidity = sc.TargetHumidity
+	}
+	return config, nil
+}
+
+// advanceTo 将模拟时钟推进到相对脚本开始的offset时刻
+func (r *Runner) advanceTo(offset time.Duration) {
+	if d := r.start.Add(offset).Sub(r.clock.Now()); d > 0 {
+		r.clock.Advance(d)
+	}
+}
+
+// apply 通过 ACService 执行一次操作
+func (r *Runner) apply(event Event) error {
+	switch event.Op {
+	case OpPowerOn:
+		return r.acService.PowerOn(event.Room)
+	case OpPowerOff:
+		return r.acService.PowerOff(event.Room)
+	case OpSetTemp:
+		return r.acService.SetTemperature(event.Room, event.Temp)
+	case OpSetSpeed:
+		return r.acService.SetFanSpeed(event.Room, event.Speed)
+	case OpSet:
+		if event.Temp != 0 {
+			if err := r.acService.SetTemperature(event.Room, event.Temp); err != nil {
+				return err
+			}
+		}
+		if event.Speed != "" {
+			return r.acService.SetFanSpeed(event.Room, event.Speed)
+		}
+		return nil
+	case OpCheckIn:
+		name := event.Name
+		if name == "" {
+			name = fmt.Sprintf("房间%d", event.Room)
+		}
+		return r.acService.CheckIn(event.Room, fmt.Sprintf("SIM%03d", event.Room), name, types.Fen(0))
+	case OpCheckOut:
+		_, _, err := r.acService.CheckOut(event.Room)
+		return err
+	case OpBudget:
+		return r.acService.SetPowerBudget(db.DefaultZone, "", &event.Budget, nil)
+	}
+	return fmt.Errorf("未知操作: %s", event.Op)
+}
+
+// record 记录当前时刻所有参与房间的状态
+func (r *Runner) record(offset time.Duration) ([]StateRow, error) {
+	snapshots := service.GetSchedulers().Snapshots()
+
+	rows := make([]StateRow, 0, len(r.scenario.Rooms))
+	for _, scenarioRoom := range r.scenario.Rooms {
+		room, err := r.roomRepo.GetRoomByID(scenarioRoom.ID)
+		if err != nil {
+			return nil, fmt.Errorf("获取房间 %d 信息失败: %v", scenarioRoom.ID, err)
+		}
+
+		row := StateRow{
+			Minute:      round2(offset.Minutes()),
+			RoomID:      room.RoomID,
+			ACState:     "off",
+			Queue:       QueueNone,
+			Speed:       "-",
+			CurrentTemp: round2(float64(room.CurrentTemp)),
+			TargetTemp:  round2(float64(room.TargetTemp)),
+		}
+
+		if room.ACState == 1 {
+			row.ACState = "on"
+			row.Speed = room.CurrentSpeed
+			snapshot := snapshots[room.Zone]
+			if serving, ok := snapshot.ServingRoom(room.RoomID); ok {
+				row.Queue = QueueServing
+				row.Speed = string(serving.Speed)
+			} else if wait, ok := snapshot.WaitingRoom(room.RoomID); ok {
+				row.Queue = QueueWaiting
+				row.Speed = string(wait.Speed)
+			}
+
+			currentFee, err := r.billing.CalculateCurrentSessionFee(room.RoomID)
+			if err != nil {
+				return nil, err
+			}
+			row.CurrentFee = currentFee.Yuan()
+		}
+
+		if room.State == 1 {
+			totalFee, err := r.billing.CalculateTotalFee(room.RoomID)
+			if err != nil {
+				return nil, err
+			}
+			row.TotalFee = totalFee.Yuan()
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
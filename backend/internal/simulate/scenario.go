@@ -0,0 +1,206 @@
+// internal/simulate/scenario.go
+// Package simulate 在虚拟时间下回放带时间戳的面板操作脚本，输出每个时间步的房间状态表
+package simulate
+
+import (
+	"backend/internal/types"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 支持的操作类型
+const (
+	OpPowerOn  = "poweron"  // 开机
+	OpPowerOff = "poweroff" // 关机
+	OpSetTemp  = "settemp"  // 调温
+	OpSetSpeed = "setspeed" // 调风
+	OpSet      = "set"      // 同时调温和调风
+	OpCheckIn  = "checkin"  // 入住
+	OpCheckOut = "checkout" // 退房
+	OpBudget   = "budget"   // 修改功率预算(需求响应)，不针对房间
+)
+
+// Scenario 测试脚本
+type Scenario struct {
+	Mode     types.Mode      `yaml:"mode"`     // 中央空调模式，默认制冷
+	Policy   string          `yaml:"policy"`   // 调度策略，默认使用调度器当前策略
+	Tick     time.Duration   `yaml:"tick"`     // 状态表的时间步长，默认1分钟
+	Duration time.Duration   `yaml:"duration"` // 脚本总时长，默认为最后一个操作的时间
+	Start    string          `yaml:"start"`    // 虚拟时钟开始的时刻(HH:MM)，使用天气曲线时决定室外温度，默认为当前时刻
+	Config   *ScenarioConfig `yaml:"config"`   // 空调配置，未设置时使用默认配置
+	Rooms    []ScenarioRoom  `yaml:"rooms"`    // 参与的房间及初始温度
+	Events   []Event         `yaml:"events"`   // 按时间排列的操作
+}
+
+// ScenarioConfig 脚本中的空调配置
+type ScenarioConfig struct {
+	DefaultTemp  float32  `yaml:"default_temp"`
+	DefaultSpeed string   `yaml:"default_speed"`
+	MinTemp      float32  `yaml:"min_temp"`
+	MaxTemp      float32  `yaml:"max_temp"`
+	LowRate      float32  `yaml:"low_rate"`
+	MediumRate   float32  `yaml:"medium_rate"`
+	HighRate     float32  `yaml:"high_rate"`
+	ModeRate     *float32 `yaml:"mode_rate"` // 脚本工作模式的费率系数
+
+	CapacityMode     string        `yaml:"capacity_mode"`      // 容量模型: count 或 power
+	MaxServices      int           `yaml:"max_services"`       // 服务队列容量
+	PowerBudget      float32       `yaml:"power_budget"`       // 功率预算(kW)
+	LowLoad          float32       `yaml:"low_load"`           // 低风速负载(kW)
+	MediumLoad       float32       `yaml:"medium_load"`        // 中风速负载(kW)
+	HighLoad         float32       `yaml:"high_load"`          // 高风速负载(kW)
+	TimeSlice        time.Duration `yaml:"time_slice"`         // 时间片
+	WaitGrowthFactor *float32      `yaml:"wait_growth_factor"` // 等待时长增长系数
+	AgingRate        *float32      `yaml:"aging_rate"`         // 优先级老化速率(每分钟)
+	MaxWait          time.Duration `yaml:"max_wait"`           // 最长等待时间
+	Hysteresis       float32       `yaml:"hysteresis"`         // 回差(°C)
+
+	ThermalModel   string   `yaml:"thermal_model"`   // 热模型: linear 或 physical
+	OutdoorTemp    *float32 `yaml:"outdoor_temp"`    // 固定室外温度
+	WeatherFile    string   `yaml:"weather_file"`    // 天气曲线文件，相对路径相对于脚本所在目录
+	LowCapacity    float32  `yaml:"low_capacity"`    // 低风速制冷/制热功率(W)
+	MediumCapacity float32  `yaml:"medium_capacity"` // 中风速制冷/制热功率(W)
+	HighCapacity   float32  `yaml:"high_capacity"`   // 高风速制冷/制热功率(W)
+	TargetHumidity float32  `yaml:"target_humidity"` // 除湿模式的目标湿度(%)
+}
+
+// ScenarioRoom 脚本中的房间
+type ScenarioRoom struct {
+	ID          int      `yaml:"id"`
+	InitialTemp *float32 `yaml:"initial_temp"` // 初始温度，未设置时使用数据库中的值
+	CheckedIn   *bool    `yaml:"checked_in"`   // 是否在脚本开始前入住，默认入住
+
+	// 房间热参数，未设置时使用数据库中的值
+	Volume        float32  `yaml:"volume"`         // 容积(m³)
+	Insulation    *float32 `yaml:"insulation"`     // 围护结构传热系数(W/K)
+	OccupancyLoad *float32 `yaml:"occupancy_load"` // 人员和设备散热(W)
+}
+
+// Event 一次面板操作
+type Event struct {
+	At     time.Duration `yaml:"at"`     // 相对脚本开始的时间
+	Room   int           `yaml:"room"`   // 房间号
+	Op     string        `yaml:"op"`     // 操作类型
+	Temp   float32       `yaml:"temp"`   // 目标温度(settemp/set)
+	Speed  types.Speed   `yaml:"speed"`  // 风速(setspeed/set)
+	Name   string        `yaml:"name"`   // 入住客户姓名(checkin)
+	Budget float32       `yaml:"budget"` // 功率预算(kW)(budget)
+}
+
+// LoadScenario 从YAML文件加载测试脚本
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本失败: %v", err)
+	}
+
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("解析脚本失败: %v", err)
+	}
+
+	if err := scenario.normalize(); err != nil {
+		return nil, err
+	}
+	if scenario.Config != nil && scenario.Config.WeatherFile != "" && !filepath.IsAbs(scenario.Config.WeatherFile) {
+		scenario.Config.WeatherFile = filepath.Join(filepath.Dir(path), scenario.Config.WeatherFile)
+	}
+	return &scenario, nil
+}
+
+// StartTime 虚拟时钟的开始时间：today当天的开始时刻，未设置开始时刻时为today本身
+func (sc *Scenario) StartTime(today time.Time) time.Time {
+	if sc.Start == "" {
+		return today
+	}
+	at, _ := time.Parse("15:04", sc.Start)
+	year, month, day := today.Date()
+	return time.Date(year, month, day, at.Hour(), at.Minute(), 0, 0, today.Location())
+}
+
+// normalize 填充默认值并校验脚本
+func (sc *Scenario) normalize() error {
+	if sc.Mode == "" {
+		sc.Mode = types.ModeCooling
+	}
+	if !sc.Mode.Valid() {
+		return fmt.Errorf("无效的工作模式: %s", sc.Mode)
+	}
+	if sc.Tick <= 0 {
+		sc.Tick = time.Minute
+	}
+	if sc.Start != "" {
+		if _, err := time.Parse("15:04", sc.Start); err != nil {
+			return fmt.Errorf("无效的开始时刻: %s", sc.Start)
+		}
+	}
+
+	// 操作按时间稳定排序，同一时刻按脚本中的书写顺序执行
+	sort.SliceStable(sc.Events, func(i, j int) bool {
+		return sc.Events[i].At < sc.Events[j].At
+	})
+
+	known := make(map[int]bool)
+	for _, room := range sc.Rooms {
+		known[room.ID] = true
+	}
+
+	for i, event := range sc.Events {
+		if event.At < 0 {
+			return fmt.Errorf("第 %d 个操作的时间无效", i+1)
+		}
+		if event.Op == OpBudget {
+			if event.Budget <= 0 {
+				return fmt.Errorf("第 %d 个操作缺少功率预算", i+1)
+			}
+			continue
+		}
+		if event.Room <= 0 {
+			return fmt.Errorf("第 %d 个操作缺少房间号", i+1)
+		}
+		switch event.Op {
+		case OpPowerOn, OpPowerOff, OpCheckIn, OpCheckOut:
+		case OpSetTemp:
+			if event.Temp == 0 {
+				return fmt.Errorf("第 %d 个操作缺少目标温度", i+1)
+			}
+		case OpSetSpeed:
+			if !validSpeed(event.Speed) {
+				return fmt.Errorf("第 %d 个操作的风速无效: %s", i+1, event.Speed)
+			}
+		case OpSet:
+			if event.Temp == 0 && event.Speed == "" {
+				return fmt.Errorf("第 %d 个操作缺少目标温度或风速", i+1)
+			}
+			if event.Speed != "" && !validSpeed(event.Speed) {
+				return fmt.Errorf("第 %d 个操作的风速无效: %s", i+1, event.Speed)
+			}
+		default:
+			return fmt.Errorf("第 %d 个操作类型无效: %s", i+1, event.Op)
+		}
+
+		// 操作中出现但未声明的房间按默认方式参与
+		if !known[event.Room] {
+			sc.Rooms = append(sc.Rooms, ScenarioRoom{ID: event.Room})
+			known[event.Room] = true
+		}
+	}
+
+	sort.Slice(sc.Rooms, func(i, j int) bool {
+		return sc.Rooms[i].ID < sc.Rooms[j].ID
+	})
+
+	if sc.Duration <= 0 && len(sc.Events) > 0 {
+		sc.Duration = sc.Events[len(sc.Events)-1].At
+	}
+	return nil
+}
+
+func validSpeed(speed types.Speed) bool {
+	return speed == types.SpeedLow || speed == types.SpeedMedium || speed == types.SpeedHigh
+}
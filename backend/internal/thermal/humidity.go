@@ -0,0 +1,50 @@
+// internal/thermal/humidity.go
+package thermal
+
+import (
+	"backend/internal/types"
+	"math"
+	"time"
+)
+
+// DefaultHumidity 环境相对湿度(%)，也是房间的初始湿度
+const DefaultHumidity float32 = 65
+
+// DefaultTargetHumidity 除湿模式默认的目标相对湿度(%)
+const DefaultTargetHumidity float32 = 50
+
+// 不同风速下的除湿速率(%/分钟)
+var dehumidifyRates = map[types.Speed]float32{
+	types.SpeedHigh:   2.0,
+	types.SpeedMedium: 1.0,
+	types.SpeedLow:    0.5,
+}
+
+// humidityRecoveryRate 不除湿时湿度向环境湿度恢复的速率(%/分钟)
+const humidityRecoveryRate = 0.5
+
+// StepHumidity 计算房间经过dt后的相对湿度
+// 除湿模式送风时按风速降低湿度，不低于目标湿度；其余情况湿度以固定速率回到环境湿度
+func StepHumidity(room Room, target float32, dt time.Duration) float32 {
+	minutes := float32(dt.Minutes())
+	if room.Serving && room.Mode == types.ModeDry && room.Humidity > target {
+		humidity := room.Humidity - dehumidifyRates[room.Speed]*minutes
+		return float32(math.Max(float64(humidity), float64(target)))
+	}
+
+	recovery := humidityRecoveryRate * minutes
+	if room.Humidity > DefaultHumidity {
+		return float32(math.Max(float64(room.Humidity-recovery), float64(DefaultHumidity)))
+	}
+	return float32(math.Min(float64(room.Humidity+recovery), float64(DefaultHumidity)))
+}
+
+// HumidityReached 判断除湿模式下房间是否已达到目标湿度
+func HumidityReached(humidity, target float32) bool {
+	return humidity-target < reachedThreshold
+}
+
+// NeedsDehumidify 判断除湿模式下空调开启的房间是否因湿度高于目标超过threshold而需要重新送风
+func NeedsDehumidify(humidity, target, threshold float32) bool {
+	return humidity-target >= threshold
+}
@@ -0,0 +1,219 @@
+// internal/thermal/model.go
+// Package thermal 提供房间温度变化的热模型
+// 调度器每个周期调用热模型计算房间的新温度，送风中的房间由空调驱动，
+// 其余房间随环境温度自然变化。
+package thermal
+
+import (
+	"backend/internal/types"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 达到目标温度的判定阈值(°C)
+const reachedThreshold = 0.05
+
+// Params 房间的热参数
+type Params struct {
+	Volume        float32 // 房间容积(m³)
+	Insulation    float32 // 围护结构的综合传热系数(W/K)，越小保温越好
+	OccupancyLoad float32 // 人员和设备的散热量(W)
+}
+
+// DefaultParams 默认房间热参数：约15㎡、层高3米的标准间
+var DefaultParams = Params{
+	Volume:        45,
+	Insulation:    25,
+	OccupancyLoad: 100,
+}
+
+// Room 热模型计算一个周期所需的房间状态
+type Room struct {
+	Temp        float32     // 当前温度
+	InitialTemp float32     // 初始温度
+	TargetTemp  float32     // 目标温度
+	Ambient     float32     // 环境(室外)温度
+	Mode        types.Mode  // 空调工作模式
+	Speed       types.Speed // 风速
+	Serving     bool        // 是否正在送风
+	Humidity    float32     // 当前相对湿度(%)，除湿模式使用
+	Params      Params      // 房间热参数
+}
+
+// Model 热模型接口
+type Model interface {
+	// Name 模型名称
+	Name() string
+	// Step 计算房间经过dt后的温度
+	Step(room Room, dt time.Duration) float32
+}
+
+// DefaultModelName 默认热模型名称
+const DefaultModelName = "linear"
+
+// NewModel 按配置创建热模型，模型名称为空时使用默认模型
+func NewModel(config types.ThermalConfig) (Model, error) {
+	switch config.Model {
+	case "", DefaultModelName:
+		return &LinearModel{}, nil
+	case "physical":
+		capacity := make(map[types.Speed]float32, len(DefaultCapacity))
+		for speed, power := range DefaultCapacity {
+			capacity[speed] = power
+		}
+		for speed, power := range config.Capacity {
+			if power <= 0 {
+				return nil, fmt.Errorf("风速 %s 的制冷/制热功率必须大于0", speed)
+			}
+			capacity[speed] = power
+		}
+		return &PhysicalModel{capacity: capacity}, nil
+	default:
+		return nil, fmt.Errorf("未知的热模型: %s", config.Model)
+	}
+}
+
+// ModelNames 返回所有可用的热模型名称
+func ModelNames() []string {
+	return []string{DefaultModelName, "physical"}
+}
+
+// Reached 判断房间是否已达到目标温度
+// 制冷模式下室温不高于目标温度即视为达到，制热模式下室温不低于目标温度即视为达到，
+// 自动模式下室温与目标温度足够接近即视为达到；送风和除湿模式不以温度结束服务
+func Reached(mode types.Mode, temp, target float32) bool {
+	switch mode {
+	case types.ModeCooling:
+		return temp-target < reachedThreshold
+	case types.ModeHeating:
+		return target-temp < reachedThreshold
+	case types.ModeFan, types.ModeDry:
+		return false
+	default:
+		return math.Abs(float64(temp-target)) < reachedThreshold
+	}
+}
+
+// NeedsService 判断空调开启的房间是否因温度偏离目标超过threshold而需要重新送风
+// 制冷模式只在室温高于目标时送风，制热模式只在室温低于目标时送风，自动模式两个方向都送风；
+// 送风模式开机期间持续送风，除湿模式不因温度送风
+func NeedsService(mode types.Mode, temp, target, threshold float32) bool {
+	switch mode {
+	case types.ModeCooling:
+		return temp-target >= threshold
+	case types.ModeHeating:
+		return target-temp >= threshold
+	case types.ModeFan:
+		return true
+	case types.ModeDry:
+		return false
+	default:
+		return math.Abs(float64(temp-target)) >= float64(threshold)
+	}
+}
+
+// acting 空调在当前模式下是否需要调节温度
+// 送风和除湿模式不调节温度，室温随环境自然变化
+func acting(room Room) bool {
+	if !room.Serving {
+		return false
+	}
+	switch room.Mode {
+	case types.ModeCooling:
+		return room.Temp > room.TargetTemp
+	case types.ModeHeating:
+		return room.Temp < room.TargetTemp
+	case types.ModeFan, types.ModeDry:
+		return false
+	default:
+		return room.Temp != room.TargetTemp
+	}
+}
+
+// LinearModel 线性热模型（默认）
+// 送风时按风速以固定速率趋近目标温度，不送风时以固定速率回到初始温度，
+// 与课程设计验收用例的温度变化规则一致
+type LinearModel struct{}
+
+// 不同风速下的温度变化速率(°C/分钟)
+var linearRates = map[types.Speed]float32{
+	types.SpeedHigh:   1.0,       // 1度/分钟
+	types.SpeedMedium: 1.0 / 2.0, // 1度/2分钟
+	types.SpeedLow:    1.0 / 3.0, // 1度/3分钟
+}
+
+// linearRecoveryRate 不送风时的回温速率(°C/分钟)
+const linearRecoveryRate = 0.5
+
+func (m *LinearModel) Name() string { return DefaultModelName }
+
+func (m *LinearModel) Step(room Room, dt time.Duration) float32 {
+	minutes := float32(dt.Minutes())
+	if acting(room) {
+		change := linearRates[room.Speed] * minutes
+		if room.TargetTemp > room.Temp {
+			return room.Temp + change
+		}
+		return room.Temp - change
+	}
+
+	recovery := linearRecoveryRate * minutes
+	if room.Temp > room.InitialTemp {
+		return float32(math.Max(float64(room.Temp-recovery), float64(room.InitialTemp)))
+	}
+	return float32(math.Min(float64(room.Temp+recovery), float64(room.InitialTemp)))
+}
+
+// PhysicalModel 集总参数热模型
+// 房间视为一个热容，温度变化率 = (传热系数 × (环境温度 - 室温) + 人员散热 ± 空调功率) / 热容。
+// 空调按目标温度调节出风，不会越过目标温度；制冷只降温，制热只升温。
+type PhysicalModel struct {
+	capacity map[types.Speed]float32 // 各风速的制冷/制热功率(W)
+}
+
+// DefaultCapacity 默认的各风速制冷/制热功率(W)
+var DefaultCapacity = map[types.Speed]float32{
+	types.SpeedLow:    1000,
+	types.SpeedMedium: 1800,
+	types.SpeedHigh:   3000,
+}
+
+const (
+	airDensity      = 1.2  // 空气密度(kg/m³)
+	airHeatCapacity = 1005 // 空气比热容(J/(kg·K))
+	// thermalMassFactor 家具、墙体表层等参与换热的蓄热按空气热容的倍数估算
+	thermalMassFactor = 4
+)
+
+func (m *PhysicalModel) Name() string { return "physical" }
+
+func (m *PhysicalModel) Step(room Room, dt time.Duration) float32 {
+	params := room.Params
+	if params.Volume <= 0 {
+		params = DefaultParams
+	}
+	heatCapacity := params.Volume * airDensity * airHeatCapacity * thermalMassFactor
+
+	power := params.Insulation*(room.Ambient-room.Temp) + params.OccupancyLoad
+	working := acting(room)
+	if working {
+		// 自动模式按室温与目标温度的高低决定制冷或制热
+		if room.Mode == types.ModeHeating || (room.Mode != types.ModeCooling && room.Temp < room.TargetTemp) {
+			power += m.capacity[room.Speed]
+		} else {
+			power -= m.capacity[room.Speed]
+		}
+	}
+
+	temp := room.Temp + power/heatCapacity*float32(dt.Seconds())
+	if working {
+		// 空调按目标温度调节，不会越过目标温度
+		if room.Temp > room.TargetTemp && temp < room.TargetTemp {
+			temp = room.TargetTemp
+		} else if room.Temp < room.TargetTemp && temp > room.TargetTemp {
+			temp = room.TargetTemp
+		}
+	}
+	return temp
+}
@@ -0,0 +1,136 @@
+// internal/thermal/weather.go
+package thermal
+
+import (
+	"backend/internal/types"
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Weather 室外温度来源
+type Weather interface {
+	// OutdoorTemp 返回t时刻的室外温度
+	OutdoorTemp(t time.Time) float32
+}
+
+// NewWeather 按配置创建室外温度来源
+// 设置了天气曲线文件时使用文件，否则使用固定室外温度；都未设置时返回nil，
+// 此时以房间的初始温度作为环境温度
+func NewWeather(config types.ThermalConfig) (Weather, error) {
+	if config.WeatherFile != "" {
+		return LoadWeatherProfile(config.WeatherFile)
+	}
+	if config.OutdoorTemp != nil {
+		return ConstantWeather(*config.OutdoorTemp), nil
+	}
+	return nil, nil
+}
+
+// ConstantWeather 固定的室外温度
+type ConstantWeather float32
+
+func (w ConstantWeather) OutdoorTemp(t time.Time) float32 { return float32(w) }
+
+// WeatherProfile 按一天中的时刻变化的室外温度曲线，相邻时刻之间线性插值，跨午夜循环
+type WeatherProfile struct {
+	points []weatherPoint
+}
+
+type weatherPoint struct {
+	offset time.Duration // 距当天零点的时长
+	temp   float32
+}
+
+// LoadWeatherProfile 读取天气曲线文件
+// 文件为CSV格式，每行 "时刻,室外温度"，例如 "14:00,35.5"；
+// 以#开头的行和无法解析的首行(表头)会被忽略
+func LoadWeatherProfile(path string) (*WeatherProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开天气曲线文件失败: %v", err)
+	}
+	defer file.Close()
+
+	profile := &WeatherProfile{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		point, err := parseWeatherPoint(line)
+		if err != nil {
+			if lineNo == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("天气曲线文件第 %d 行: %v", lineNo, err)
+		}
+		profile.points = append(profile.points, point)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取天气曲线文件失败: %v", err)
+	}
+	if len(profile.points) == 0 {
+		return nil, fmt.Errorf("天气曲线文件没有数据")
+	}
+	sort.Slice(profile.points, func(i, j int) bool {
+		return profile.points[i].offset < profile.points[j].offset
+	})
+	return profile, nil
+}
+
+func parseWeatherPoint(line string) (weatherPoint, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 2 {
+		return weatherPoint{}, fmt.Errorf("格式应为 时刻,室外温度")
+	}
+	at, err := time.Parse("15:04", strings.TrimSpace(fields[0]))
+	if err != nil {
+		return weatherPoint{}, fmt.Errorf("无效的时刻 %q", fields[0])
+	}
+	temp, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 32)
+	if err != nil {
+		return weatherPoint{}, fmt.Errorf("无效的温度 %q", fields[1])
+	}
+	offset := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	return weatherPoint{offset: offset, temp: float32(temp)}, nil
+}
+
+func (p *WeatherProfile) OutdoorTemp(t time.Time) float32 {
+	offset := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	points := p.points
+	if len(points) == 1 {
+		return points[0].temp
+	}
+
+	// 找到offset之后的第一个点，之前的点为最后一个不晚于offset的点，两端跨午夜循环
+	next := sort.Search(len(points), func(i int) bool { return points[i].offset > offset })
+	var prev, after weatherPoint
+	span := time.Duration(0)
+	switch {
+	case next == 0:
+		prev, after = points[len(points)-1], points[0]
+		span = after.offset + 24*time.Hour - prev.offset
+		offset += 24 * time.Hour
+	case next == len(points):
+		prev, after = points[len(points)-1], points[0]
+		span = after.offset + 24*time.Hour - prev.offset
+	default:
+		prev, after = points[next-1], points[next]
+		span = after.offset - prev.offset
+	}
+	if span <= 0 {
+		return prev.temp
+	}
+	ratio := float32(offset-prev.offset) / float32(span)
+	return prev.temp + (after.temp-prev.temp)*ratio
+}
@@ -10,6 +10,32 @@ type Mode string
 const (
 	ModeCooling Mode = "cooling"
 	ModeHeating Mode = "heating"
+	ModeAuto    Mode = "auto" // 自动：按各房间室温与目标温度的高低制冷或制热
+	ModeFan     Mode = "fan"  // 送风：只循环空气，不调节温度
+	ModeDry     Mode = "dry"  // 除湿：降低房间湿度直到目标湿度
+)
+
+// Modes 所有工作模式
+var Modes = []Mode{ModeCooling, ModeHeating, ModeAuto, ModeFan, ModeDry}
+
+// Valid 判断是否为有效的工作模式
+func (m Mode) Valid() bool {
+	for _, mode := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// RunState 房间空调的运行状态
+type RunState string
+
+const (
+	RunOff     RunState = "off"     // 关机
+	RunWaiting RunState = "waiting" // 在等待队列中等待送风
+	RunServing RunState = "serving" // 送风中
+	RunStandby RunState = "standby" // 待机：已达到目标或队列被清空，偏离目标超过回差后重新请求
 )
 
 // Speed 风速
@@ -31,6 +57,65 @@ type State struct {
 	LastModified time.Time // 最后修改时间
 }
 
+// CapacityMode 服务队列容量模型
+type CapacityMode string
+
+const (
+	CapacityCount CapacityMode = "count" // 按同时送风的房间数限制
+	CapacityPower CapacityMode = "power" // 按送风房间的负载之和不超过功率预算限制
+)
+
+// PreconditionCharge 入住前预调温费用的计费对象
+type PreconditionCharge string
+
+const (
+	ChargeHotel PreconditionCharge = "hotel" // 计入酒店运营成本
+	ChargeGuest PreconditionCharge = "guest" // 住客入住后计入住客账单
+)
+
+// Valid 判断是否为有效的计费对象
+func (c PreconditionCharge) Valid() bool {
+	return c == ChargeHotel || c == ChargeGuest
+}
+
+// RoomClass 房间或住客的等级，与风速一起决定调度优先级
+type RoomClass string
+
+const (
+	ClassStandard RoomClass = "standard" // 标准间
+	ClassPremium  RoomClass = "premium"  // 高级房、套房
+	ClassVIP      RoomClass = "vip"      // VIP
+)
+
+// RoomClasses 所有等级，按从低到高排列
+var RoomClasses = []RoomClass{ClassStandard, ClassPremium, ClassVIP}
+
+// Valid 判断是否为有效的等级
+func (c RoomClass) Valid() bool {
+	return c.rank() >= 0
+}
+
+// rank 等级在 RoomClasses 中的位置，无效的等级为-1
+func (c RoomClass) rank() int {
+	for i, class := range RoomClasses {
+		if c == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// HigherClass 返回两个等级中较高的一个，无效或为空的等级视为标准间
+func HigherClass(a, b RoomClass) RoomClass {
+	if !a.Valid() {
+		a = ClassStandard
+	}
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
 // TempRange 温度范围
 type TempRange struct {
 	Min float32
@@ -43,4 +128,52 @@ type Config struct {
 	DefaultSpeed Speed              // 默认风速
 	TempRanges   map[Mode]TempRange // 不同模式的温度范围
 	Rates        map[Speed]float32  // 不同风速的费率
+	ModeRates    map[Mode]float32   // 不同模式的费率系数，按风速计算的费用乘以该系数
+	TimeOfUse    *TimeOfUse         // 分时电价表，为nil时全天按风速的费率计费
+	EnergyRates  map[Speed]float32  // 各风速每分钟的耗电量(度)，用于电量计量，与价格无关
+
+	// 调度参数
+	CapacityMode     CapacityMode      // 容量模型，默认按房间数
+	MaxServices      int               // 服务队列容量(同时送风的房间数)，count 模式使用
+	PowerBudget      float32           // 中央空调的功率预算(kW)，power 模式使用
+	SpeedLoad        map[Speed]float32 // 各风速占用的负载(kW)，power 模式使用
+	TimeSlice        time.Duration     // 时间片，等待队列为空时的基础等待时长
+	WaitGrowthFactor float32           // 等待时长随等待队列长度的增长系数
+	AgingRate        float32           // 等待中的请求每等待一分钟增加的优先级
+	MaxWait          time.Duration     // 最长等待时间，超过后保证获得一个时间片
+	Hysteresis       float32           // 回差(°C)，待机房间的温度向需要送风的方向偏离目标超过该值时重新请求
+	// 调度优先级 = 风速优先级(低1/中2/高3) × SpeedWeight + 房间等级的优先级加成
+	SpeedWeight   float32               // 风速优先级的权重
+	ClassPriority map[RoomClass]float32 // 各房间等级的优先级加成
+
+	Thermal ThermalConfig // 房间热模型
+
+	PreconditionCharge PreconditionCharge // 入住前预调温费用的计费对象，默认计入酒店运营成本
+}
+
+// TimeBand 分时电价的一个时段，从 Start 到 End(不含)，时刻格式为 "15:04"，End 可以为 "24:00"
+// 时段内的费率为风速的费率乘以 Multiplier(为0时按1计算)，Rates 中设置了的风速改用该费率(元/分钟)
+type TimeBand struct {
+	Name       string // 时段名称，如峰、平、谷
+	Start      string
+	End        string
+	Multiplier float32
+	Rates      map[Speed]float32
+}
+
+// TimeOfUse 分时电价表
+// 按星期设置每天的时段，节假日按日期覆盖当天的时段；不在任何时段内的时刻按风速的费率计费
+type TimeOfUse struct {
+	Days     map[string][]TimeBand // 键为 mon、tue、wed、thu、fri、sat、sun，default 用于未单独设置的日子
+	Holidays map[string][]TimeBand // 键为日期 "2006-01-02"
+}
+
+// ThermalConfig 房间热模型配置
+type ThermalConfig struct {
+	Model       string            // 热模型: linear(默认) 或 physical
+	OutdoorTemp *float32          // 固定室外温度，与天气曲线都未设置时以房间初始温度为环境温度
+	WeatherFile string            // 天气曲线文件，设置后优先于固定室外温度
+	Capacity    map[Speed]float32 // 各风速的制冷/制热功率(W)，物理模型使用
+	// TargetHumidity 除湿模式的目标相对湿度(%)
+	TargetHumidity float32
 }
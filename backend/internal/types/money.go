@@ -0,0 +1,86 @@
+// internal/types/money.go
+
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+// Currency 货币代码(ISO 4217)
+type Currency string
+
+// CNY 人民币，系统中的金额都以人民币计价
+const CNY Currency = "CNY"
+
+// Money 金额，以分为单位的整数加上货币
+// 金额之间的加减和按整数倍相乘都是精确的整数运算，只有由浮点数计算出的金额在换算为 Money 时舍入一次(见 Yuan)
+type Money struct {
+	Fen      int64    `json:"fen"`                             // 金额(分)
+	Currency Currency `json:"currency" gorm:"type:varchar(3)"` // 货币，零值表示尚未确定货币的0
+}
+
+// Fen 以分为单位的人民币金额
+func Fen(fen int64) Money {
+	return Money{Fen: fen, Currency: CNY}
+}
+
+// Yuan 将以元为单位的人民币金额换算为 Money，这是系统中唯一的舍入规则：
+// 先舍去小于0.0001分的浮点误差，再四舍五入到分(0.5分远离0舍入)。
+// 费用按计费时段由 时长×费率 换算一次，之后的合计都是分的整数相加，不再舍入
+func Yuan(yuan float64) Money {
+	fen := math.Round(yuan*1e6) / 1e4
+	return Fen(int64(math.Round(fen)))
+}
+
+// Yuan 以元为单位的金额，用于接口返回和显示
+func (m Money) Yuan() float64 {
+	return float64(m.Fen) / 100
+}
+
+// Add 两个金额相加
+func (m Money) Add(other Money) Money {
+	return Money{Fen: m.Fen + other.Fen, Currency: m.currencyWith(other)}
+}
+
+// Sub 两个金额相减
+func (m Money) Sub(other Money) Money {
+	return Money{Fen: m.Fen - other.Fen, Currency: m.currencyWith(other)}
+}
+
+// Mul 金额乘以整数倍，如按入住天数计算房费
+func (m Money) Mul(n int64) Money {
+	return Money{Fen: m.Fen * n, Currency: m.Currency}
+}
+
+// IsZero 判断金额是否为0
+func (m Money) IsZero() bool {
+	return m.Fen == 0
+}
+
+// String 以元显示金额，人民币如 "12.34元"，其他货币如 "12.34 USD"
+func (m Money) String() string {
+	sign := ""
+	fen := m.Fen
+	if fen < 0 {
+		sign = "-"
+		fen = -fen
+	}
+	amount := fmt.Sprintf("%s%d.%02d", sign, fen/100, fen%100)
+	if m.Currency == CNY || m.Currency == "" {
+		return amount + "元"
+	}
+	return amount + " " + string(m.Currency)
+}
+
+// currencyWith 两个金额运算结果的货币，零值金额的货币取另一个金额的货币
+// 不同货币的金额不能直接运算，出现时是程序错误
+func (m Money) currencyWith(other Money) Currency {
+	switch {
+	case m.Currency == "":
+		return other.Currency
+	case other.Currency == "" || other.Currency == m.Currency:
+		return m.Currency
+	}
+	panic(fmt.Sprintf("不能对不同货币的金额进行运算: %s 和 %s", m.Currency, other.Currency))
+}
@@ -0,0 +1,57 @@
+// internal/types/money_test.go
+
+package types
+
+import "testing"
+
+func TestYuan(t *testing.T) {
+	tests := []struct {
+		name string
+		yuan float64
+		fen  int64
+	}{
+		{"整分", 12.34, 1234},
+		{"0.5分进位", 0.005, 1},
+		{"0.5分进位(奇数分)", 0.015, 2},
+		{"0.5分进位(偶数分)", 0.025, 3},
+		{"二进制表示略小于0.5分", 1.005, 101},
+		{"二进制表示略小于0.5分(2.675)", 2.675, 268},
+		{"不足0.5分舍去", 0.0049, 0},
+		{"0.4999分舍去", 0.004999, 0},
+		{"小于0.0001分的误差视为0.5分", 0.00499999, 1},
+		{"浮点加法误差", 0.1 + 0.2, 30},
+		{"时长乘费率", 7.0 / 3, 233},
+		{"零", 0, 0},
+		{"负数0.5分远离0舍入", -0.005, -1},
+		{"负数二进制表示略大于-0.5分", -1.005, -101},
+		{"负数不足0.5分舍去", -0.0049, 0},
+		{"负数时长乘费率", -7.0 / 3, -233},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Yuan(tt.yuan)
+			if got.Fen != tt.fen || got.Currency != CNY {
+				t.Errorf("Yuan(%v) = %d 分 %s，期望 %d 分 %s", tt.yuan, got.Fen, got.Currency, tt.fen, CNY)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Fen(1234), "12.34元"},
+		{Fen(5), "0.05元"},
+		{Fen(-1), "-0.01元"},
+		{Fen(-1234), "-12.34元"},
+		{Money{}, "0.00元"},
+		{Money{Fen: 250, Currency: "USD"}, "2.50 USD"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%d 分 %q 显示为 %q，期望 %q", tt.money.Fen, tt.money.Currency, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package utils
 
 import (
 	"backend/internal/db"
+	"backend/internal/types"
 	"fmt"
 	"time"
 
@@ -17,12 +18,16 @@ type Bill struct {
 	ClientID     string
 	CheckInTime  time.Time
 	CheckOutTime time.Time
-	DaysStayed   int
-	RoomRate     float32 // 每日房费
-	TotalRoom    float32 // 住宿总费用
-	TotalAC      float32 // 空调总费用
-	Deposit      float32 // 押金
-	FinalTotal   float32 // 最终总费用（住宿费+空调费-押金）
+	RoomItems    []db.FolioItem         // 房费账目：入住晚数、延迟退房或当日取消
+	RoomRate     types.Money            // 每日房费
+	TotalRoom    types.Money            // 住宿总费用
+	TotalAC      types.Money            // 空调总费用
+	ACByMode     map[string]types.Money // 各工作模式的空调费用
+	Deposit      types.Money            // 押金
+	FinalTotal   types.Money            // 最终总费用（住宿费+空调费+其他账目-押金）
+	Extras       types.Money            // 其他消费
+	Payments     types.Money            // 已付款
+	Adjustments  types.Money            // 调整(正数加收，负数减免)
 }
 
 type DetailBill struct {
@@ -31,16 +36,45 @@ type DetailBill struct {
 	ClientID     string
 	CheckInTime  time.Time
 	CheckOutTime time.Time
-	TotalCost    float32
+	TotalCost    types.Money // 详单费用的合计
 	Details      []db.Detail
 }
 
+// modeNameMap 工作模式的中文名称，旧版本的详单没有记录工作模式
+var modeNameMap = map[string]string{
+	"cooling": "制冷",
+	"heating": "制热",
+	"auto":    "自动",
+	"fan":     "送风",
+	"dry":     "除湿",
+	"":        "未记录",
+}
+
+// modeOrder 账单中列出各模式费用的顺序
+var modeOrder = []string{"cooling", "heating", "auto", "fan", "dry", ""}
+
 var detailTypeMap = map[db.DetailType]string{
 	db.DetailTypeServiceStart:     "服务开始",
 	db.DetailTypeServiceInterrupt: "服务结束",
 	db.DetailTypeSpeedChange:      "调整风速",
 	db.DetailTypeTargetReached:    "达到目标温度",
 	db.DetailTypeTemp:             "调整温度",
+	db.DetailTypeWaiting:          "等待送风",
+	db.DetailTypeStandby:          "待机",
+	db.DetailTypePowerOff:         "关机",
+	db.DetailTypePrecondition:     "入住前预调温",
+	db.DetailTypeShedSpeed:        "限负荷降风速",
+	db.DetailTypeShedStop:         "限负荷停止送风",
+	db.DetailTypeRateChange:       "费率调整",
+	db.DetailTypeBandChange:       "时段切换",
+}
+
+// bandText 详单计费时段的名称，未设置分时电价时的详单没有时段
+func bandText(band string) string {
+	if band == "" {
+		return "-"
+	}
+	return band
 }
 
 func GenerateDetailPDF(bill DetailBill) (*gofpdf.Fpdf, error) {
@@ -79,7 +113,7 @@ func GenerateDetailPDF(bill DetailBill) (*gofpdf.Fpdf, error) {
 	pdf.SetFont("chinese", "", 12)
 	pdf.SetTextColor(0, 102, 204)
 	pdf.Cell(220, 10, "总费用:")
-	pdf.Cell(40, 10, fmt.Sprintf("%.2f元", bill.TotalCost))
+	pdf.Cell(40, 10, bill.TotalCost.String())
 
 	// 在最后一页添加页脚
 	footerHeight := 15.0 // 页脚高度
@@ -127,13 +161,15 @@ func drawDetailTable(pdf *gofpdf.Fpdf, bill DetailBill) {
 		width float64
 		name  string
 	}{
-		{25, "房间号"},
-		{35, "请求时间"},
-		{35, "开始时间"},
-		{35, "结束时间"},
+		{15, "房间号"},
+		{30, "请求时间"},
+		{30, "开始时间"},
+		{30, "结束时间"},
 		{25, "服务时长"},
+		{15, "模式"},
 		{20, "风速"},
 		{25, "费率"},
+		{20, "时段"},
 		{25, "当前温度"},
 		{25, "目标温度"},
 		{20, "费用"},
@@ -182,30 +218,32 @@ func drawDetailTable(pdf *gofpdf.Fpdf, bill DetailBill) {
 		detailTypeText := detailTypeMap[detail.DetailType]
 
 		// 绘制单元格内容
-		pdf.Cell(25, rowHeight, fmt.Sprintf("%d", bill.RoomID))
-		pdf.Cell(35, rowHeight, detail.QueryTime.Format("15:04:05"))
-		pdf.Cell(35, rowHeight, detail.StartTime.Format("15:04:05"))
-		pdf.Cell(35, rowHeight, detail.EndTime.Format("15:04:05"))
+		pdf.Cell(15, rowHeight, fmt.Sprintf("%d", bill.RoomID))
+		pdf.Cell(30, rowHeight, detail.QueryTime.Format("15:04:05"))
+		pdf.Cell(30, rowHeight, detail.StartTime.Format("15:04:05"))
+		pdf.Cell(30, rowHeight, detail.EndTime.Format("15:04:05"))
 		pdf.Cell(25, rowHeight, fmt.Sprintf("%.1f分钟", detail.ServeTime))
+		pdf.Cell(15, rowHeight, modeNameMap[detail.Mode])
 		pdf.Cell(20, rowHeight, detail.Speed)
-		pdf.Cell(25, rowHeight, fmt.Sprintf("%.2f元/度", detail.Rate))
+		pdf.Cell(25, rowHeight, fmt.Sprintf("%.2f元/分钟", detail.Rate))
+		pdf.Cell(20, rowHeight, bandText(detail.Band))
 		pdf.Cell(25, rowHeight, fmt.Sprintf("%.1f°C", detail.CurrentTemp))
 		pdf.Cell(25, rowHeight, fmt.Sprintf("%.1f°C", detail.TargetTemp))
 
 		// 设置费用颜色
-		if detail.Cost > 0 {
+		if detail.Cost.Fen > 0 {
 			pdf.SetTextColor(204, 0, 0)
 		}
-		pdf.Cell(20, rowHeight, fmt.Sprintf("%.2f元", detail.Cost))
+		pdf.Cell(20, rowHeight, detail.Cost.String())
 		pdf.SetTextColor(0, 0, 0)
 
 		// 设置操作类型颜色
 		switch detail.DetailType {
 		case db.DetailTypeServiceStart:
 			pdf.SetTextColor(0, 153, 0)
-		case db.DetailTypeServiceInterrupt:
+		case db.DetailTypeServiceInterrupt, db.DetailTypeTargetReached, db.DetailTypeShedStop:
 			pdf.SetTextColor(204, 0, 0)
-		case db.DetailTypeSpeedChange:
+		case db.DetailTypeSpeedChange, db.DetailTypeShedSpeed, db.DetailTypeRateChange, db.DetailTypeBandChange:
 			pdf.SetTextColor(0, 102, 204)
 		}
 		pdf.Cell(30, rowHeight, detailTypeText)
@@ -298,24 +336,49 @@ func GenerateBillPDF(bill Bill) (*gofpdf.Fpdf, error) {
 	pdf.SetFont("chinese", "", 11)
 
 	// 住宿费用
-	pdf.Cell(95, 8, "住宿天数:")
-	pdf.Cell(95, 8, fmt.Sprintf("%d天", bill.DaysStayed))
-	pdf.Ln(8)
+	for _, item := range bill.RoomItems {
+		pdf.Cell(95, 8, item.Description+":")
+		pdf.Cell(95, 8, item.Amount.String())
+		pdf.Ln(8)
+	}
 	pdf.Cell(95, 8, "房间日费率:")
-	pdf.Cell(95, 8, fmt.Sprintf("%.2f元/天", bill.RoomRate))
+	pdf.Cell(95, 8, bill.RoomRate.String()+"/天")
 	pdf.Ln(8)
 	pdf.Cell(95, 8, "住宿费用小计:")
-	pdf.Cell(95, 8, fmt.Sprintf("%.2f元", bill.TotalRoom))
+	pdf.Cell(95, 8, bill.TotalRoom.String())
 	pdf.Ln(8)
 
 	// 空调费用
 	pdf.Cell(95, 8, "空调费用小计:")
-	pdf.Cell(95, 8, fmt.Sprintf("%.2f元", bill.TotalAC))
+	pdf.Cell(95, 8, bill.TotalAC.String())
 	pdf.Ln(8)
+	for _, mode := range modeOrder {
+		if fee, ok := bill.ACByMode[mode]; ok {
+			pdf.Cell(95, 8, fmt.Sprintf("    其中%s:", modeNameMap[mode]))
+			pdf.Cell(95, 8, fee.String())
+			pdf.Ln(8)
+		}
+	}
+
+	// 账单上的其他账目
+	for _, line := range []struct {
+		name   string
+		amount types.Money
+	}{
+		{"其他消费:", bill.Extras},
+		{"调整:", bill.Adjustments},
+		{"已付款:", bill.Payments},
+	} {
+		if !line.amount.IsZero() {
+			pdf.Cell(95, 8, line.name)
+			pdf.Cell(95, 8, line.amount.String())
+			pdf.Ln(8)
+		}
+	}
 
 	// 押金
 	pdf.Cell(95, 8, "押金:")
-	pdf.Cell(95, 8, fmt.Sprintf("%.2f元", bill.Deposit))
+	pdf.Cell(95, 8, bill.Deposit.String())
 	pdf.Ln(15)
 
 	// 添加分隔线
@@ -325,14 +388,14 @@ func GenerateBillPDF(bill Bill) (*gofpdf.Fpdf, error) {
 	// 总计金额
 	pdf.SetFont("chinese", "", 14)
 	pdf.Cell(95, 10, "应付总额:")
-	pdf.Cell(95, 10, fmt.Sprintf("%.2f元", bill.FinalTotal))
+	pdf.Cell(95, 10, bill.FinalTotal.String())
 	pdf.Ln(20)
 
 	// 添加备注
 	pdf.SetFont("chinese", "", 10)
 	pdf.Cell(190, 8, "备注：")
 	pdf.Ln(8)
-	pdf.Cell(190, 8, "1. 应付总额 = 住宿费用 + 空调费用 - 押金")
+	pdf.Cell(190, 8, "1. 应付总额 = 住宿费用 + 空调费用 + 其他消费 + 调整 - 已付款 - 押金")
 	pdf.Ln(8)
 	pdf.Cell(190, 8, "2. 如需空调费用详单，请向前台索取")
 	pdf.Ln(8)
@@ -346,3 +409,139 @@ func GenerateBillPDF(bill Bill) (*gofpdf.Fpdf, error) {
 
 	return pdf, nil
 }
+
+// Invoice 退房时开具的发票
+type Invoice struct {
+	Number       string // 发票编号
+	IssuedAt     time.Time
+	RoomID       int
+	ClientName   string
+	ClientID     string
+	CheckInTime  time.Time
+	CheckOutTime time.Time
+	Items        []db.FolioItem
+	Charges      types.Money // 应收合计
+	Credits      types.Money // 押金和付款合计
+	Balance      types.Money // 应付余额，负数为应退还住客
+}
+
+// folioKindMap 账目类型的中文名称
+var folioKindMap = map[db.FolioItemKind]string{
+	db.FolioRoomNight:  "房费",
+	db.FolioACSegment:  "空调",
+	db.FolioExtra:      "消费",
+	db.FolioDeposit:    "押金",
+	db.FolioPayment:    "付款",
+	db.FolioAdjustment: "调整",
+}
+
+// GenerateInvoicePDF 生成发票PDF，逐条列出发票开具时的账目
+func GenerateInvoicePDF(invoice Invoice) (*gofpdf.Fpdf, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	// 添加中文字体
+	pdf.AddUTF8Font("chinese", "", "./SimHei.ttf")
+
+	// 设置标题
+	pdf.SetFont("chinese", "", 20)
+	pdf.Cell(190, 15, "住宿发票")
+	pdf.Ln(20)
+
+	// 发票编号和开具时间
+	pdf.SetFont("chinese", "", 12)
+	pdf.Cell(95, 8, fmt.Sprintf("发票编号: %s", invoice.Number))
+	pdf.Cell(95, 8, fmt.Sprintf("开具时间: %s", invoice.IssuedAt.Format("2006-01-02 15:04:05")))
+	pdf.Ln(15)
+
+	pdf.Line(10, pdf.GetY(), 200, pdf.GetY())
+	pdf.Ln(10)
+
+	// 客户信息部分
+	pdf.Cell(30, 8, "房间号:")
+	pdf.Cell(65, 8, fmt.Sprintf("%d", invoice.RoomID))
+	pdf.Cell(30, 8, "客户姓名:")
+	pdf.Cell(65, 8, invoice.ClientName)
+	pdf.Ln(10)
+	pdf.Cell(30, 8, "身份证号:")
+	pdf.Cell(160, 8, invoice.ClientID)
+	pdf.Ln(10)
+	pdf.Cell(30, 8, "入住时间:")
+	pdf.Cell(65, 8, invoice.CheckInTime.Format("2006-01-02 15:04:05"))
+	pdf.Cell(30, 8, "退房时间:")
+	pdf.Cell(65, 8, invoice.CheckOutTime.Format("2006-01-02 15:04:05"))
+	pdf.Ln(10)
+
+	pdf.Line(10, pdf.GetY(), 200, pdf.GetY())
+	pdf.Ln(5)
+
+	// 账目表格
+	headers := []struct {
+		width float64
+		name  string
+	}{
+		{20, "类型"},
+		{95, "说明"},
+		{15, "数量"},
+		{30, "单价"},
+		{30, "金额"},
+	}
+	pdf.SetFont("chinese", "", 11)
+	for _, h := range headers {
+		pdf.Cell(h.width, 10, h.name)
+	}
+	pdf.Ln(10)
+
+	pdf.SetFont("chinese", "", 10)
+	rowHeight := 7.0
+	for _, item := range invoice.Items {
+		if pdf.GetY() > 260 {
+			pdf.AddPage()
+			pdf.SetFont("chinese", "", 11)
+			for _, h := range headers {
+				pdf.Cell(h.width, 10, h.name)
+			}
+			pdf.Ln(10)
+			pdf.SetFont("chinese", "", 10)
+		}
+		pdf.Cell(20, rowHeight, folioKindMap[item.Kind])
+		pdf.Cell(95, rowHeight, item.Description)
+		pdf.Cell(15, rowHeight, fmt.Sprintf("%d", item.Quantity))
+		pdf.Cell(30, rowHeight, item.UnitPrice.String())
+		if item.Amount.Fen < 0 {
+			pdf.SetTextColor(0, 153, 0)
+		}
+		pdf.Cell(30, rowHeight, item.Amount.String())
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(rowHeight)
+	}
+
+	pdf.Ln(5)
+	pdf.Line(10, pdf.GetY(), 200, pdf.GetY())
+	pdf.Ln(5)
+
+	// 合计
+	pdf.SetFont("chinese", "", 12)
+	pdf.Cell(160, 8, "应收合计:")
+	pdf.Cell(30, 8, invoice.Charges.String())
+	pdf.Ln(8)
+	pdf.Cell(160, 8, "押金及已付款:")
+	pdf.Cell(30, 8, invoice.Credits.String())
+	pdf.Ln(10)
+	pdf.SetFont("chinese", "", 14)
+	if invoice.Balance.Fen < 0 {
+		pdf.Cell(160, 10, "应退还:")
+		pdf.Cell(30, 10, invoice.Balance.Mul(-1).String())
+	} else {
+		pdf.Cell(160, 10, "应付余额:")
+		pdf.Cell(30, 10, invoice.Balance.String())
+	}
+
+	// 添加页脚
+	pdf.SetY(-15)
+	pdf.SetFont("chinese", "", 8)
+	pdf.Cell(190, 10, fmt.Sprintf("波普特酒店 - 发票一经开具不可修改 - 打印时间: %s",
+		time.Now().Format("2006-01-02 15:04:05")))
+
+	return pdf, nil
+}